@@ -0,0 +1,136 @@
+// Command mocapclient receives the simulator's motion-capture stream and
+// checks it: it prints each body's pose once a second and, at the end, the
+// frame rate, arrival jitter and frames lost.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"os"
+	"time"
+
+	"drone-simulator/systems/mocap"
+)
+
+func main() {
+	format := flag.String("format", "natnet", "Stream format (natnet, vrpn)")
+	listen := flag.String("listen", "", "Address to receive on (default: the NatNet multicast group, :0 with -server or for vrpn)")
+	server := flag.String("server", "", "NatNet command address to connect to for unicast (e.g. 127.0.0.1:1510), or the VRPN server (default 127.0.0.1:3883)")
+	duration := flag.Duration("duration", 10*time.Second, "How long to receive")
+	flag.Parse()
+
+	f, err := mocap.ParseFormat(*format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var c *mocap.Client
+	if f == mocap.FormatVRPN {
+		addr := *server
+		if addr == "" {
+			addr = "127.0.0.1" + mocap.VRPNPort
+		}
+		if c, err = mocap.DialVRPN(*listen, addr); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		addr := *listen
+		switch {
+		case addr != "":
+		case *server != "":
+			addr = ":0"
+		default:
+			addr = mocap.NatNetMulticast
+		}
+		if c, err = mocap.Listen(f, addr); err != nil {
+			log.Fatal(err)
+		}
+		if *server != "" {
+			if err := c.Connect(*server); err != nil {
+				c.Close()
+				log.Fatal(err)
+			}
+		}
+	}
+	defer c.Close()
+	fmt.Printf("Receiving %s on %s for %s\n", f, c.LocalAddr(), *duration)
+
+	var (
+		frames, lost, untracked int
+		lastNumber              int
+		first, last             time.Time
+		gaps                    []float64 // s between arrivals
+		lastPrint               time.Time
+	)
+	end := time.Now().Add(*duration)
+	for time.Now().Before(end) {
+		fr, at, err := c.Next(time.Until(end))
+		var ne net.Error
+		if errors.As(err, &ne) && ne.Timeout() {
+			break
+		}
+		if err != nil {
+			log.Printf("bad packet: %v", err)
+			continue
+		}
+		if frames == 0 {
+			first = at
+		} else {
+			gaps = append(gaps, at.Sub(last).Seconds())
+		}
+		if fr.Number > 0 && lastNumber > 0 && fr.Number > lastNumber+1 {
+			lost += fr.Number - lastNumber - 1
+		}
+		lastNumber, last = fr.Number, at
+		frames++
+		for _, b := range fr.Bodies {
+			if !b.Tracked {
+				untracked++
+			}
+		}
+		if at.Sub(lastPrint) >= time.Second {
+			lastPrint = at
+			if c.Server != "" && frames == 1 {
+				fmt.Printf("Connected to %s\n", c.Server)
+			}
+			fmt.Printf("frame %d t=%.3f s\n", fr.Number, fr.Time)
+			for _, b := range fr.Bodies {
+				q := b.Orientation
+				fmt.Printf("  %-8s id %-3d pos (%7.3f, %7.3f, %7.3f) quat (%6.3f, %6.3f, %6.3f, %6.3f) tracked=%v\n",
+					b.Name, b.ID, b.Position.X, b.Position.Y, b.Position.Z, q[0], q[1], q[2], q[3], b.Tracked)
+			}
+			c.KeepAlive()
+		}
+	}
+
+	if frames == 0 {
+		fmt.Println("No frames received")
+		os.Exit(1)
+	}
+	span := last.Sub(first).Seconds()
+	mean, sd := stats(gaps)
+	fmt.Printf("Received %d frames in %.1f s (%.1f Hz)\n", frames, span, float64(frames-1)/math.Max(span, 1e-9))
+	fmt.Printf("Arrival interval %.2f ms mean, %.2f ms jitter (1σ)\n", mean*1000, sd*1000)
+	if f == mocap.FormatNatNet {
+		fmt.Printf("Frames lost: %d (%.1f%%); untracked body reports: %d\n", lost, 100*float64(lost)/float64(frames+lost), untracked)
+	}
+}
+
+// stats returns the mean and standard deviation of xs.
+func stats(xs []float64) (float64, float64) {
+	if len(xs) == 0 {
+		return 0, 0
+	}
+	var sum, sq float64
+	for _, x := range xs {
+		sum += x
+	}
+	mean := sum / float64(len(xs))
+	for _, x := range xs {
+		sq += (x - mean) * (x - mean)
+	}
+	return mean, math.Sqrt(sq / float64(len(xs)))
+}
@@ -0,0 +1,99 @@
+package sim
+
+// AutoAction is a built-in manoeuvre that safety systems (geofence, failsafe)
+// can command. It preempts the flight mode and any loaded mission until it is
+// cleared or the drone is re-armed.
+type AutoAction int
+
+const (
+	ActionNone AutoAction = iota
+	ActionHold
+	ActionReturnHome
+	ActionLand
+	ActionTerminate
+)
+
+func (a AutoAction) String() string {
+	switch a {
+	case ActionHold:
+		return "Hold"
+	case ActionReturnHome:
+		return "ReturnHome"
+	case ActionLand:
+		return "Land"
+	case ActionTerminate:
+		return "Terminate"
+	default:
+		return "None"
+	}
+}
+
+// moreSevere returns the more drastic of two actions.
+func moreSevere(a, b AutoAction) AutoAction {
+	if b > a {
+		return b
+	}
+	return a
+}
+
+// StartAction begins a built-in action. Terminate cuts the motors at once;
+// the others are flown by the mission engine on a private one-item mission.
+func (d *Drone) StartAction(a AutoAction) {
+	var m *Mission
+	switch a {
+	case ActionNone:
+		d.ClearAction()
+		return
+	case ActionTerminate:
+		d.ClearAction()
+		d.Disarm()
+		d.action = ActionTerminate
+		return
+	case ActionHold:
+		m = NewMission(nil)
+		m.State = MissionPaused
+		m.holdPos = d.navPosition()
+		m.holdYaw = d.navRotation().Y
+	case ActionReturnHome:
+		m = NewMission([]MissionItem{{Type: MissionItemReturnHome}})
+		m.State = MissionRunning
+	case ActionLand:
+		m = NewMission([]MissionItem{{Type: MissionItemLand, InPlace: true}})
+		m.State = MissionRunning
+	}
+	if d.actionMission == nil && !d.holdsAltitude() {
+		d.AltitudeHold = d.navPosition().Y
+	}
+	d.action = a
+	d.actionMission = m
+}
+
+// ClearAction hands control back to the current flight mode.
+func (d *Drone) ClearAction() {
+	d.action = ActionNone
+	d.actionMission = nil
+}
+
+// ActiveAction returns the action in control, or ActionNone.
+func (d *Drone) ActiveAction() AutoAction { return d.action }
+
+// autonomous reports whether onboard guidance (mission, orbit, follow,
+// precision landing, action or motor-failure recovery) is flying the drone,
+// so outside controllers such as the swarm must leave it alone.
+func (d *Drone) autonomous() bool {
+	switch d.FlightMode {
+	case FlightModeMission, FlightModeOrbit, FlightModeFollow, FlightModePrecisionLand:
+		return true
+	}
+	return d.action != ActionNone || d.ftActive
+}
+
+// updateAction flies the active action. It reports false when no action is
+// in control.
+func (d *Drone) updateAction(dt float64) bool {
+	if d.actionMission == nil {
+		return false
+	}
+	d.runMission(d.actionMission, dt)
+	return true
+}
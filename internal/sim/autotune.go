@@ -0,0 +1,416 @@
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Relay-feedback (Åström–Hägglund) autotuning.
+//
+// While the drone hovers, the loop under test is replaced by a relay that
+// switches its actuator between trim+d and trim-d whenever the error changes
+// sign (with hysteresis). The loop settles into a limit cycle whose period is
+// the ultimate period Tu and whose amplitude a gives the ultimate gain
+// Ku = 4d/(πa). Gains follow from Ku and Tu with a Ziegler–Nichols style rule.
+
+// TuneAxis selects the loop to identify.
+type TuneAxis int
+
+const (
+	TuneAltitude TuneAxis = iota
+	TunePitch
+	TuneRoll
+	TuneYaw
+)
+
+func (a TuneAxis) String() string {
+	switch a {
+	case TuneAltitude:
+		return "Altitude"
+	case TunePitch:
+		return "Pitch"
+	case TuneRoll:
+		return "Roll"
+	case TuneYaw:
+		return "Yaw"
+	default:
+		return "Unknown"
+	}
+}
+
+// ParseTuneAxis parses an axis name (case-insensitive).
+func ParseTuneAxis(name string) (TuneAxis, error) {
+	for a := TuneAltitude; a <= TuneYaw; a++ {
+		if strings.EqualFold(name, a.String()) {
+			return a, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown autotune axis %q (altitude, pitch, roll, yaw)", name)
+}
+
+// TuneRule maps the ultimate gain and period to PID gains.
+type TuneRule int
+
+const (
+	TuneClassic       TuneRule = iota // Ziegler–Nichols: Kp 0.6Ku
+	TuneSomeOvershoot                 // Kp 0.33Ku
+	TuneNoOvershoot                   // Kp 0.2Ku
+)
+
+func (r TuneRule) String() string {
+	switch r {
+	case TuneSomeOvershoot:
+		return "SomeOvershoot"
+	case TuneNoOvershoot:
+		return "NoOvershoot"
+	default:
+		return "Classic"
+	}
+}
+
+// ParseTuneRule parses a rule name (case-insensitive); empty means Classic.
+func ParseTuneRule(name string) (TuneRule, error) {
+	if name == "" {
+		return TuneClassic, nil
+	}
+	for r := TuneClassic; r <= TuneNoOvershoot; r++ {
+		if strings.EqualFold(name, r.String()) {
+			return r, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown autotune rule %q (classic, someovershoot, noovershoot)", name)
+}
+
+// AutotuneState is the progress of an autotune run.
+type AutotuneState int
+
+const (
+	AutotuneIdle AutotuneState = iota
+	AutotuneRunning
+	AutotuneDone
+	AutotuneFailed
+)
+
+func (s AutotuneState) String() string {
+	switch s {
+	case AutotuneRunning:
+		return "Running"
+	case AutotuneDone:
+		return "Done"
+	case AutotuneFailed:
+		return "Failed"
+	default:
+		return "Idle"
+	}
+}
+
+// AutotuneConfig configures a relay experiment. Zero values pick defaults.
+type AutotuneConfig struct {
+	Axis       TuneAxis
+	Rule       TuneRule
+	Amplitude  float64 // relay amplitude d: N for altitude, N·m for attitude axes
+	Hysteresis float64 // relay hysteresis: m for altitude, rad for attitude axes
+	Cycles     int     // limit cycles to average (after one discarded cycle)
+	Timeout    float64 // s before giving up
+	Apply      bool    // write the gains to AltitudePID when done (altitude only)
+}
+
+// Autotune defaults and safety limits
+const (
+	tuneAltAmplitude   = 0.15 // fraction of weight
+	tuneAttAmplitude   = 4.0  // rad/s^2 of angular acceleration
+	tuneAltHysteresis  = 0.02 // m
+	tuneAttHysteresis  = 0.005
+	tuneCycles         = 4
+	tuneTimeout        = 40.0 // s
+	tuneSettleTime     = 0.5  // s before the relay starts
+	tuneAltMaxError    = 3.0  // m
+	tuneAttMaxError    = 0.6  // rad
+	tuneMinCycleAmplit = 1e-4
+)
+
+// PIDGains is a set of PID gains in the loop's own units.
+type PIDGains struct {
+	Kp, Ki, Kd float64
+}
+
+// AutotuneResult is the outcome of a completed experiment.
+type AutotuneResult struct {
+	Axis      TuneAxis
+	Rule      TuneRule
+	Ku        float64 // ultimate gain
+	Tu        float64 // ultimate period (s)
+	Amplitude float64 // measured limit-cycle amplitude a
+	Gains     PIDGains
+	Applied   bool
+}
+
+// AutotuneStatus reports the tuner state.
+type AutotuneStatus struct {
+	State  AutotuneState
+	Axis   TuneAxis
+	Cycle  int // completed limit cycles
+	Cycles int // cycles needed
+	Result *AutotuneResult
+	Err    string
+}
+
+type autotuner struct {
+	cfg      AutotuneConfig
+	state    AutotuneState
+	elapsed  float64
+	setpoint float64
+	trim     float64 // actuator bias the relay switches around
+	output   float64 // current relay output (N or N·m)
+	high     bool    // relay in the +d position
+	started  bool
+	lastUp   float64   // time of the last switch to +d
+	periods  []float64 // completed cycle periods
+	amps     []float64 // completed cycle half peak-to-peak amplitudes
+	max, min float64   // extremes of the current cycle
+	result   *AutotuneResult
+	err      string
+}
+
+// StartAutotune begins a relay experiment on cfg.Axis. The drone must be
+// armed, airborne and not under autonomous control.
+func (d *Drone) StartAutotune(cfg AutotuneConfig) error {
+	if !d.IsArmed || d.OnGround {
+		return errors.New("autotune: drone must be armed and airborne")
+	}
+	if d.autonomous() {
+		return errors.New("autotune: drone is under autonomous control")
+	}
+	if d.autotune != nil && d.autotune.state == AutotuneRunning {
+		return errors.New("autotune: already running")
+	}
+	if cfg.Axis < TuneAltitude || cfg.Axis > TuneYaw {
+		return fmt.Errorf("autotune: unknown axis %d", cfg.Axis)
+	}
+	// The attitude loops run on fixed gains, not on PitchPID/RollPID/YawPID,
+	// so there is nothing to apply their results to
+	if cfg.Apply && cfg.Axis != TuneAltitude {
+		return fmt.Errorf("autotune: %s gains can only be reported, not applied", cfg.Axis)
+	}
+	if cfg.Amplitude <= 0 {
+		if cfg.Axis == TuneAltitude {
+			cfg.Amplitude = tuneAltAmplitude * d.Mass * 9.81
+		} else {
+			cfg.Amplitude = tuneAttAmplitude * d.axisInertia(cfg.Axis)
+		}
+	}
+	if cfg.Hysteresis <= 0 {
+		cfg.Hysteresis = tuneAttHysteresis
+		if cfg.Axis == TuneAltitude {
+			cfg.Hysteresis = tuneAltHysteresis
+		}
+	}
+	if cfg.Cycles <= 0 {
+		cfg.Cycles = tuneCycles
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = tuneTimeout
+	}
+	t := &autotuner{cfg: cfg, state: AutotuneRunning, setpoint: d.tuneMeasurement(cfg.Axis)}
+	if cfg.Axis == TuneAltitude && d.holdsAltitude() {
+		// Keep whatever force the altitude loop was trimming with
+		t.trim = d.AltitudePID.LastOutput
+	}
+	d.autotune = t
+	d.emit(Event{Kind: "autotune.start", Source: cfg.Axis.String(), Detail: cfg.Rule.String()})
+	return nil
+}
+
+// CancelAutotune stops a running experiment without applying anything.
+func (d *Drone) CancelAutotune() {
+	if d.autotune != nil && d.autotune.state == AutotuneRunning {
+		d.failAutotune("cancelled")
+	}
+}
+
+// AutotuneStatus returns the state of the current or last experiment.
+func (d *Drone) AutotuneStatus() AutotuneStatus {
+	t := d.autotune
+	if t == nil {
+		return AutotuneStatus{State: AutotuneIdle}
+	}
+	return AutotuneStatus{State: t.state, Axis: t.cfg.Axis, Cycle: len(t.periods), Cycles: t.cfg.Cycles, Result: t.result, Err: t.err}
+}
+
+// autotuning reports whether a relay experiment on axis is driving the drone.
+func (d *Drone) autotuning(axis TuneAxis) bool {
+	return d.autotune != nil && d.autotune.state == AutotuneRunning && d.autotune.cfg.Axis == axis
+}
+
+func (d *Drone) axisInertia(axis TuneAxis) float64 {
+	switch axis {
+	case TunePitch:
+		return d.Inertia.X
+	case TuneRoll:
+		return d.Inertia.Z
+	case TuneYaw:
+		return d.Inertia.Y
+	}
+	return 0
+}
+
+func (d *Drone) tuneMeasurement(axis TuneAxis) float64 {
+	switch axis {
+	case TunePitch:
+		return d.Rotation.X
+	case TuneRoll:
+		return d.Rotation.Z
+	case TuneYaw:
+		return d.Rotation.Y
+	}
+	return d.navPosition().Y
+}
+
+func (d *Drone) failAutotune(reason string) {
+	t := d.autotune
+	t.state = AutotuneFailed
+	t.err = reason
+	t.output = 0
+	d.emit(Event{Kind: "autotune.failed", Source: t.cfg.Axis.String(), Detail: reason})
+}
+
+// updateAutotune steps the relay. Attitude axes apply their torque here; the
+// altitude relay force is picked up by Update in place of the altitude PID.
+func (d *Drone) updateAutotune(dt float64) {
+	t := d.autotune
+	if t == nil || t.state != AutotuneRunning {
+		return
+	}
+	switch {
+	case !d.IsArmed || d.OnGround:
+		d.failAutotune("drone landed or disarmed")
+		return
+	case d.autonomous():
+		d.failAutotune("interrupted by autonomous control")
+		return
+	}
+	t.elapsed += dt
+	if t.elapsed > t.cfg.Timeout {
+		d.failAutotune("timed out without a stable limit cycle")
+		return
+	}
+
+	y := d.tuneMeasurement(t.cfg.Axis)
+	e := t.setpoint - y
+	maxErr := tuneAttMaxError
+	if t.cfg.Axis == TuneAltitude {
+		maxErr = tuneAltMaxError
+	} else if t.cfg.Axis == TuneYaw {
+		e = angleDiff(t.setpoint, y)
+		y = t.setpoint - e
+	}
+	if math.Abs(e) > maxErr {
+		d.failAutotune("oscillation exceeded safe amplitude")
+		return
+	}
+
+	if t.elapsed >= tuneSettleTime {
+		h := t.cfg.Hysteresis
+		switch {
+		case !t.started:
+			t.started = true
+			t.high = e > 0
+			t.lastUp = -1
+			t.max, t.min = y, y
+		case !t.high && e > h:
+			// Switch up: one full cycle since the previous switch up
+			t.high = true
+			if t.lastUp >= 0 {
+				t.periods = append(t.periods, t.elapsed-t.lastUp)
+				t.amps = append(t.amps, (t.max-t.min)/2)
+			}
+			t.lastUp = t.elapsed
+			t.max, t.min = y, y
+		case t.high && e < -h:
+			t.high = false
+		}
+		t.max = math.Max(t.max, y)
+		t.min = math.Min(t.min, y)
+
+		t.output = t.trim - t.cfg.Amplitude
+		if t.high {
+			t.output = t.trim + t.cfg.Amplitude
+		}
+	} else {
+		t.output = t.trim
+	}
+
+	if t.cfg.Axis != TuneAltitude {
+		var torque Vec3
+		switch t.cfg.Axis {
+		case TunePitch:
+			torque.X = t.output
+		case TuneRoll:
+			torque.Z = t.output
+		case TuneYaw:
+			torque.Y = t.output
+		}
+		d.AddTorque(torque, dt)
+	}
+
+	// The first recorded cycle still carries the start-up transient
+	if len(t.periods) > t.cfg.Cycles {
+		d.finishAutotune()
+	}
+}
+
+func (d *Drone) finishAutotune() {
+	t := d.autotune
+	tu, a := 0.0, 0.0
+	n := 0
+	for i := 1; i < len(t.periods); i++ {
+		tu += t.periods[i]
+		a += t.amps[i]
+		n++
+	}
+	tu /= float64(n)
+	a /= float64(n)
+	if a < tuneMinCycleAmplit || tu <= 0 {
+		d.failAutotune("no measurable oscillation")
+		return
+	}
+	ku := 4 * t.cfg.Amplitude / (math.Pi * a)
+	res := &AutotuneResult{Axis: t.cfg.Axis, Rule: t.cfg.Rule, Ku: ku, Tu: tu, Amplitude: a, Gains: GainsFromUltimate(ku, tu, t.cfg.Rule)}
+	if t.cfg.Apply {
+		d.applyGains(res.Gains)
+		res.Applied = true
+	}
+	t.result = res
+	t.state = AutotuneDone
+	t.output = 0
+	d.emit(Event{Kind: "autotune.done", Source: t.cfg.Axis.String(), Detail: fmt.Sprintf("Kp=%.4g Ki=%.4g Kd=%.4g", res.Gains.Kp, res.Gains.Ki, res.Gains.Kd)})
+}
+
+// GainsFromUltimate returns PID gains for ultimate gain ku and period tu.
+func GainsFromUltimate(ku, tu float64, rule TuneRule) PIDGains {
+	kp, ti, td := 0.6*ku, tu/2, tu/8
+	switch rule {
+	case TuneSomeOvershoot:
+		kp, ti, td = 0.33*ku, tu/2, tu/3
+	case TuneNoOvershoot:
+		kp, ti, td = 0.2*ku, tu/2, tu/3
+	}
+	return PIDGains{Kp: kp, Ki: kp / ti, Kd: kp * td}
+}
+
+// applyGains writes gains to the altitude controller, the only loop that
+// flies on a PID. The integrator is rescaled so its contribution (the trim
+// the loop was holding) carries over, and the integrator limit keeps the
+// stock "integral term can reach the output limit" sizing.
+func (d *Drone) applyGains(g PIDGains) {
+	pid := &d.AltitudePID
+	if g.Ki > 0 {
+		pid.Integral = pid.Ki * pid.Integral / g.Ki
+		pid.IntegralLimit = pid.OutputLimit / g.Ki
+	} else {
+		pid.Integral = 0
+	}
+	pid.Kp, pid.Ki, pid.Kd = g.Kp, g.Ki, g.Kd
+}
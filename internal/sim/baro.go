@@ -0,0 +1,98 @@
+package sim
+
+import (
+	"math"
+	"math/rand"
+)
+
+// International Standard Atmosphere below 11 km
+const (
+	seaLevelPressure = 101325.0   // Pa
+	isaLapseFactor   = 2.25577e-5 // 1/m
+	isaExponent      = 5.25588
+)
+
+// pressureAt returns the ISA static pressure at altitude h (m MSL).
+func pressureAt(h float64) float64 {
+	return seaLevelPressure * math.Pow(1-isaLapseFactor*h, isaExponent)
+}
+
+// pressureAltitude returns the ISA altitude (m MSL) of pressure p.
+func pressureAltitude(p float64) float64 {
+	return (1 - math.Pow(p/seaLevelPressure, 1/isaExponent)) / isaLapseFactor
+}
+
+// Barometer is a simulated static-pressure sensor. Its reading carries white
+// noise, a drift that random-walks (temperature and weather), and near the
+// ground the overpressure of the drone's own prop wash, which makes the
+// altitude read low just before touchdown and just after takeoff.
+type Barometer struct {
+	Rate  float64 // Hz; 0 disables the barometer
+	Noise float64 // Pa, 1σ per sample
+	Drift float64 // Pa per √s random walk
+
+	// Prop-wash error: GroundEffect Pa at hover thrust on the ground, fading
+	// to nothing at GroundEffectHeight above it
+	GroundEffect       float64 // Pa
+	GroundEffectHeight float64 // m
+
+	Faults FaultSchedule
+
+	rng    *rand.Rand
+	drift  float64
+	age    float64
+	sample BaroSample
+}
+
+// BaroSample is one barometer output.
+type BaroSample struct {
+	Time     float64 // s of simulation
+	Pressure float64 // Pa
+	Altitude float64 // m MSL from the ISA
+	Count    int
+}
+
+// DefaultBarometer returns a MEMS barometer sampled at 50 Hz.
+func DefaultBarometer() Barometer {
+	return Barometer{Rate: 50, Noise: 3, Drift: 0.3, GroundEffect: 20, GroundEffectHeight: 2}
+}
+
+// Sample returns the latest barometer output.
+func (b *Barometer) Sample() BaroSample { return b.sample }
+
+// DriftError returns the barometer's current drift in Pa.
+func (b *Barometer) DriftError() float64 { return b.drift }
+
+// update draws the samples due in the dt up to now for a true altitude alt
+// (m MSL), agl metres above the surface with thrust (fraction of hover).
+func (b *Barometer) update(seed int64, now, dt, alt, agl, thrust float64) {
+	if b.Rate <= 0 {
+		return
+	}
+	if b.rng == nil {
+		b.rng = sensorRand(seed, "baro")
+		b.Faults.init(seed, "baro")
+	}
+	period := 1 / b.Rate
+	for b.age += dt; b.age >= period; {
+		b.age -= period
+		b.drift += b.Drift * math.Sqrt(period) * b.rng.NormFloat64()
+		p := pressureAt(alt) + b.drift + b.Noise*b.rng.NormFloat64()
+		if b.GroundEffectHeight > 0 && agl < b.GroundEffectHeight {
+			p += b.GroundEffect * clamp(thrust, 0, 2) * (1 - math.Max(agl, 0)/b.GroundEffectHeight)
+		}
+		out, ok := applyFaults(&b.Faults, period, BaroSample{Time: now - b.age, Pressure: p, Altitude: pressureAltitude(p)})
+		if ok {
+			out.Count = b.sample.Count + 1
+			b.sample = out
+		}
+	}
+}
+
+// updateBaro feeds the barometer the drone's true altitude and thrust.
+func (d *Drone) updateBaro(dt float64) {
+	clearance := d.groundClearance()
+	surface, _, _ := d.World.surfaceUnder(d.Position, d.Position.Y-clearance)
+	thrust := d.lastVerticalThrustN / (d.Mass * 9.81)
+	d.Baro.update(d.Seed, d.clock, dt, d.World.GeoOrigin().Alt+d.Position.Y, d.Position.Y-clearance-surface, thrust)
+}
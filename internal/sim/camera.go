@@ -22,6 +22,7 @@ type Camera struct {
 	FollowTarget  bool
 	Mode          CameraMode
 	TopDownHeight float64 // Height for top-down view
+	FOV           float64 // Vertical field of view in degrees in FPV, the gimbal camera's
 }
 
 func NewCamera() *Camera {
@@ -50,6 +51,7 @@ func (c *Camera) Update(drone *Drone) {
 	case CameraModeTopDown:
 		c.updateTopDownPosition()
 	case CameraModeFPV:
+		c.FOV = drone.Gimbal.FOV * 180 / math.Pi
 		c.updateFPVPosition(drone)
 	}
 }
@@ -84,28 +86,14 @@ func (c *Camera) updateTopDownPosition() {
 
 // First-Person View (FPV) mode
 func (c *Camera) updateFPVPosition(drone *Drone) {
-	// Position camera at drone location with slight offset
-	offset := Vec3{0, 0.1, 0} // Slightly above drone center
-	c.Position = drone.Position.Add(offset)
+	// See what the drone's gimbal camera sees
+	pos, rot := drone.Gimbal.pose(drone)
+	c.Position = pos
 
-	// Look in the direction the drone is facing
-	pitch, yaw := drone.Rotation.X, drone.Rotation.Y
-
-	// Calculate forward direction based on drone orientation
-	cosPitch, sinPitch := math.Cos(pitch), math.Sin(pitch)
-	cosYaw, sinYaw := math.Cos(yaw), math.Sin(yaw)
-
-	// Forward vector in world coordinates
-	forwardX := sinYaw * cosPitch
-	forwardY := -sinPitch
-	forwardZ := cosYaw * cosPitch
-
-	// Target point ahead of drone
+	// Target point ahead along the camera axis, up vector rolled with it
 	lookDistance := 10.0
-	c.Target = c.Position.Add(Vec3{forwardX, forwardY, forwardZ}.Mul(lookDistance))
-
-	// Up vector rotated with drone
-	c.Up = Vec3{0, 1, 0} // Reset to normal up vector
+	c.Target = c.Position.Add(rot.mulVec(Vec3{Z: 1}).Mul(lookDistance))
+	c.Up = rot.mulVec(Vec3{Y: 1})
 }
 
 // Set camera mode
@@ -143,6 +131,10 @@ func (c *Camera) GetProjectionMatrix(width, height int) Mat4 {
 	}
 
 	aspect := float64(width) / float64(height)
-	// Use more conservative FOV and near/far planes
-	return PerspectiveMat4(45.0, aspect, 0.1, 1000.0)
+	fov := 45.0
+	if c.Mode == CameraModeFPV && c.FOV > 0 {
+		fov = c.FOV
+	}
+	// Use conservative near/far planes
+	return PerspectiveMat4(fov, aspect, 0.1, 1000.0)
 }
@@ -0,0 +1,165 @@
+package sim
+
+// Pluggable flight software. Each physics step a drone hands its state to a
+// Controller and applies the Actuators it returns before integrating forces.
+// Drones without one fly the built-in Autopilot, which owns missions, safety
+// actions, orbit/follow, altitude hold, autotune and motor-failure recovery;
+// a custom controller replaces all of that and flies the airframe outright.
+
+// Controller is flight software for one drone.
+type Controller interface {
+	// Update is called once per physics step with the drone's state and
+	// returns the actuator commands for that step.
+	Update(state ControllerState, dt float64) Actuators
+}
+
+// ControllerState is what a Controller sees each step.
+type ControllerState struct {
+	Time       float64 // s of simulation since the drone was created
+	Position   Vec3    // navigation solution (see NavSource)
+	Velocity   Vec3
+	Rotation   Vec3 // pitch (X), yaw (Y), roll (Z) in radians
+	AngularVel Vec3
+	Armed      bool
+	OnGround   bool
+	FlightMode FlightMode
+	Battery    float64 // percent
+	Mass       float64 // kg
+	Inertia    Vec3    // kg·m² about body X, Y, Z
+	MaxThrust  float64 // N from all working motors at full throttle
+	Hover      float64 // throttle percent that balances gravity
+	Pilot      PilotInput
+	Sticks     Sticks // remote joystick (centred once stale)
+	Remote     bool   // remote sticks are engaged
+}
+
+// Actuators is a controller's output for one step.
+type Actuators struct {
+	Throttle float64   // collective, percent 0..100
+	Torque   Vec3      // body torque in N·m (pitch X, yaw Y, roll Z)
+	Lift     float64   // extra vertical force in N (the altitude-hold trim channel)
+	Motors   []float64 // optional per-motor thrust fractions 0..1; replaces Throttle and Torque
+}
+
+// PilotInput is the pilot's stick state, held until changed.
+type PilotInput struct {
+	ThrottleRate float64 // collective change in percent/s
+	Torque       Vec3    // body torque command in N·m
+}
+
+// Autopilot is the built-in flight software, run for every drone without a
+// custom Controller. It flies its drone directly and reports what it
+// commanded.
+type Autopilot struct {
+	d *Drone
+}
+
+// Autopilot returns the drone's built-in flight software, e.g. for a custom
+// controller that wraps it.
+func (d *Drone) Autopilot() *Autopilot {
+	if d.autopilot == nil {
+		d.autopilot = &Autopilot{d: d}
+	}
+	return d.autopilot
+}
+
+// SetPilotInput sets the held stick state.
+func (d *Drone) SetPilotInput(in PilotInput) {
+	d.pilot = in
+}
+
+// PilotInput returns the held stick state.
+func (d *Drone) PilotInput() PilotInput {
+	return d.pilot
+}
+
+// Update runs the pilot sticks, the active action or flight mode, autotune
+// and altitude hold, collecting their torques instead of applying them.
+func (a *Autopilot) Update(_ ControllerState, dt float64) Actuators {
+	d := a.d
+	var torque Vec3
+	d.torqueSink = &torque
+
+	// Orbit, follow and precision landing fly the airframe themselves; the
+	// sticks steer them through their own setpoints instead
+	if d.IsArmed && d.FlightMode != FlightModeOrbit && d.FlightMode != FlightModeFollow && d.FlightMode != FlightModePrecisionLand {
+		d.SetThrottle(d.ThrottlePercent + d.pilot.ThrottleRate*dt)
+		d.AddTorque(d.pilot.Torque, dt)
+	}
+
+	// Autonomous guidance sets throttle/attitude targets before forces are computed
+	d.altitudeFF = 0
+	if d.IsArmed && d.ftActive {
+		d.updateFaultTolerant(dt)
+	} else if d.IsArmed && !d.updateAction(dt) && !d.flySticks(dt) {
+		switch d.FlightMode {
+		case FlightModeMission:
+			d.updateRoute()
+			d.updateMission(dt)
+		case FlightModeOrbit:
+			d.updateOrbit(dt)
+		case FlightModeFollow:
+			d.updateFollow(dt)
+		case FlightModePrecisionLand:
+			d.updatePrecisionLand(dt)
+		}
+	}
+	d.updateAutotune(dt)
+	d.torqueSink = nil
+
+	out := Actuators{Throttle: d.ThrottlePercent, Torque: torque}
+	// Altitude hold trims vertical force; an altitude autotune drives the
+	// same channel with its relay instead
+	if d.IsArmed && d.autotuning(TuneAltitude) {
+		out.Lift = d.autotune.output
+	} else if d.IsArmed && d.holdsAltitude() {
+		out.Lift = d.calculateAltitudeCorrection(dt)
+	}
+	if d.ftActive {
+		out.Motors = d.motorCmd[:]
+	}
+	return out
+}
+
+// controllerState snapshots the drone for its controller.
+func (d *Drone) controllerState() ControllerState {
+	s := ControllerState{
+		Time:       d.clock,
+		Position:   d.navPosition(),
+		Velocity:   d.navVelocity(),
+		Rotation:   d.navRotation(),
+		AngularVel: d.navAngularVel(),
+		Armed:      d.IsArmed,
+		OnGround:   d.OnGround,
+		FlightMode: d.FlightMode,
+		Battery:    d.BatteryPercent,
+		Mass:       d.Mass,
+		Inertia:    d.Inertia,
+		MaxThrust:  d.maxVerticalThrustN(),
+		Hover:      d.HoverThrottlePercent(),
+		Pilot:      d.pilot,
+	}
+	s.Sticks, s.Remote = d.Sticks()
+	return s
+}
+
+// applyActuators hands a controller's commands to the airframe. Disarmed
+// drones ignore them.
+func (d *Drone) applyActuators(a Actuators, dt float64) {
+	d.lift = 0
+	d.directMotors = false
+	if !d.IsArmed {
+		return
+	}
+	d.SetThrottle(a.Throttle)
+	d.AddTorque(a.Torque, dt)
+	d.lift = a.Lift
+	if len(a.Motors) > 0 {
+		var cmd [4]float64
+		for i := 0; i < len(cmd) && i < len(a.Motors); i++ {
+			cmd[i] = clamp(a.Motors[i], 0, 1)
+		}
+		d.motorCmd = cmd
+		d.directMotors = true
+	}
+}
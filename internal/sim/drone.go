@@ -10,6 +10,10 @@ const (
 	FlightModeManual FlightMode = iota
 	FlightModeAltitudeHold
 	FlightModeHover
+	FlightModeMission
+	FlightModeOrbit
+	FlightModeFollow
+	FlightModePrecisionLand
 )
 
 type Drone struct {
@@ -32,6 +36,7 @@ type Drone struct {
 	ThrottlePercent float64 // 0-100% throttle input
 	BatteryPercent  float64 // 0-100% battery remaining
 	PowerDraw       float64 // Current power consumption (watts)
+	avgPower        float64 // smoothed PowerDraw for the energy estimate
 	MaxPower        float64 // Maximum motor power (watts)
 	HoverPower      float64 // Power needed to hover (watts)
 
@@ -51,9 +56,96 @@ type Drone struct {
 	IsArmed      bool    // Safety - motors armed/disarmed
 	OnGround     bool    // Ground contact detection
 
+	// Autonomy
+	Home           Vec3     // Launch point, captured on arming
+	ReturnAltitude float64  // Minimum altitude for return-to-home transit
+	Mission        *Mission // Active mission (flown in FlightModeMission)
+	PayloadMass    float64  // Releasable payload included in Mass (kg)
+	Geofences      []*Geofence
+	action         AutoAction
+	actionMission  *Mission // private mission flying the active action
+	fenceStatus    map[*Geofence]fenceStatus
+	fenceAlert     bool       // a fence wants the geofence failsafe
+	fenceAction    AutoAction // action requested by breached fences
+
+	// Failsafe manager and its inputs
+	Failsafe     Failsafe
+	PositionLost bool    // set while no valid position estimate is available
+	linkAge      float64 // s since the last command-link heartbeat
+	linkSeen     bool
+
+	// Motor-failure detection and reduced-attitude recovery
+	FaultTolerantControl bool       // fly a detected motor failure down on the remaining motors
+	failedMotor          int        // detected failed engine, -1 if none
+	failedLoss           float64    // estimated thrust loss at detection
+	ftActive             bool       // reduced-attitude controller owns the motors
+	ftTimer              float64    // s the failure signature has persisted
+	ftResidual           float64    // latest angular-acceleration residual magnitude
+	motorCmd             [4]float64 // per-motor thrust fractions while directMotors
+	expectedMotorTorque  Vec3       // torque the motor commands would give with healthy motors
+	expectedMotorThrust  [4]float64 // per-motor thrust commanded this step (N)
+
+	// Vertical force feed-forward (N) set by the position controller each step
+	altitudeFF float64
+
+	// Relay-feedback autotuner (nil until first started)
+	autotune *autotuner
+
+	// Scenery for path planning (shared by the simulator's drones) and the
+	// planned goto being flown, if any
+	World *World
+	route *plannedRoute
+
+	// Point-of-interest orbit (flown in FlightModeOrbit)
+	orbit *orbitState
+
+	// Target following (flown in FlightModeFollow)
+	follow *followState
+
+	// Landing on a platform (flown in FlightModePrecisionLand)
+	precLand *precLandState
+	Fiducial FiducialSensor
+
+	// Onboard sensors; Seed drives their noise (see sensorRand)
+	Seed int64
+	IMU  IMU
+	GNSS GNSS
+	Baro Barometer
+	Mag  Magnetometer
+	EKF  EKF
+
+	// Camera gimbal, pointed in the attitude each step produces
+	Gimbal Gimbal
+
+	// Software-rendered camera on the gimbal or a fixed mount, nil until
+	// fitted
+	ImageCamera *ImageCamera
+
+	// Ray-cast sensors, nil until mounted
+	Rangefinder *Rangefinder
+	Flow        *OpticalFlow
+	Lidar       *Lidar
+
+	// Navigation solution the flight software flies on
+	NavSource NavSource
+	nav       navState
+
+	// Flight software: Controller replaces the built-in autopilot when set
+	Controller   Controller
+	autopilot    *Autopilot
+	pilot        PilotInput    // held local stick state
+	sticks       *remoteSticks // remote joystick, nil until SetSticks
+	clock        float64       // s of simulation
+	torqueSink   *Vec3         // collects AddTorque while the autopilot runs
+	lift         float64       // vertical force commanded this step (N)
+	directMotors bool          // motorCmd drives the motors this step
+
 	// Safety limits
 	LowBatteryWarning float64 // Battery % for warning
 	CriticalBattery   float64 // Battery % for forced landing
+	PreArm            PreArmConfig
+	Calibration       Calibration
+	armDenied         string // reasons of the last refusal, to report each once
 
 	// Internal state
 	PropSpeeds [4]float64 // Individual motor speeds (RPM)
@@ -69,6 +161,9 @@ type Drone struct {
 	// Damage state
 	Destroyed bool
 
+	// Events waiting for an outside consumer (see TakeEvents)
+	events []Event
+
 	// PID controllers for stability
 	PitchPID    PIDController
 	RollPID     PIDController
@@ -79,6 +174,13 @@ type Drone struct {
 	Inertia Vec3
 }
 
+// motorYawCoeff converts rotor thrust (N) to reaction torque about body Y (N·m).
+const motorYawCoeff = 0.02
+
+// angularDampingRate is the airframe's stability augmentation: angular
+// velocity decays as exp(-rate*t) (1/s).
+const angularDampingRate = 5.0
+
 type PIDController struct {
 	Kp, Ki, Kd     float64
 	Integral       float64
@@ -95,6 +197,7 @@ type Engine struct {
 	Spin       int     // +1 = CW, -1 = CCW (yaw torque sign)
 	Efficiency float64 // 0..1 multiplier for available thrust
 	Functional bool    // If false, produces no thrust
+	Imbalance  float64 // 0..1 rotor imbalance (chipped or bent prop), shakes the frame
 	MaxThrust  float64 // N at 100% throttle per engine
 	Mass       float64 // kg mass allocated to motor/arm at this position
 }
@@ -137,9 +240,31 @@ func NewDrone() *Drone {
 		IsArmed:      false, // Start disarmed for safety
 		OnGround:     true,
 
+		// Autonomy
+		Home:           Vec3{0, 0.05, 0},
+		ReturnAltitude: 15.0, // Clear typical obstacles on the way home
+
 		// Safety
 		LowBatteryWarning: 30.0, // Warning at 30%
 		CriticalBattery:   10.0, // Force land at 10%
+		PreArm:            DefaultPreArm(),
+		Calibration:       Calibration{Accel: true, Gyro: true, Compass: true},
+		Failsafe:          DefaultFailsafe(),
+		Fiducial:          DefaultFiducial(),
+
+		// Sensors
+		Seed: 1,
+		IMU:  DefaultIMU(),
+		GNSS: DefaultGNSS(),
+		Baro: DefaultBarometer(),
+		Mag:  DefaultMagnetometer(),
+		EKF:  DefaultEKF(),
+
+		Gimbal: DefaultGimbal(),
+
+		// Motor failure handling
+		FaultTolerantControl: true,
+		failedMotor:          -1,
 
 		// Motor state
 		PropSpeeds: [4]float64{0, 0, 0, 0},
@@ -188,16 +313,29 @@ func NewDrone() *Drone {
 	return d
 }
 
+// Clock returns the seconds of simulation the drone has flown.
+func (d *Drone) Clock() float64 { return d.clock }
+
 func (d *Drone) Update(dt float64) {
 	// Capture previous state for interpolation before mutating
 	d.PrevPosition = d.Position
 	d.PrevRotation = d.Rotation
+	velocity := d.Velocity
 	// If disarmed, cut thrust but continue physics (free-fall under gravity)
 	if !d.IsArmed {
 		d.ThrottlePercent = 0
 		d.PropSpeeds = [4]float64{0, 0, 0, 0}
 	}
 
+	// Flight software commands the actuators before forces are computed
+	d.clock += dt
+	d.updateNavigation(dt)
+	ctrl := d.Controller
+	if ctrl == nil {
+		ctrl = d.Autopilot()
+	}
+	d.applyActuators(ctrl.Update(d.controllerState(), dt), dt)
+
 	// Update battery and power consumption
 	d.updatePowerSystem(dt)
 
@@ -222,10 +360,9 @@ func (d *Drone) Update(dt float64) {
 	// Total force calculation (no arbitrary wind force; wind is in drag via air-relative velocity)
 	totalForce := gravity.Add(thrust).Add(drag)
 
-	altitudeCorrection := 0.0
-	// Apply altitude hold if enabled
-	if d.IsArmed && (d.FlightMode == FlightModeAltitudeHold || d.FlightMode == FlightModeHover) {
-		altitudeCorrection = d.calculateAltitudeCorrection(dt)
+	// Extra vertical force from the controller (altitude hold)
+	altitudeCorrection := d.lift
+	if altitudeCorrection != 0 {
 		totalForce = totalForce.Add(Vec3{0, altitudeCorrection, 0})
 	}
 
@@ -245,16 +382,28 @@ func (d *Drone) Update(dt float64) {
 	// Ground collision with realistic landing
 	d.handleGroundCollision()
 
-	// Apply torque from asymmetric thrust
+	// Apply torque from asymmetric thrust, measuring the achieved response
+	// for motor-failure detection
+	angVel := d.AngularVel
 	if motorTorque.X != 0 || motorTorque.Y != 0 || motorTorque.Z != 0 {
 		d.AddTorque(motorTorque, dt)
 	}
+	if dt > 0 {
+		d.detectMotorFailure(d.AngularVel.Sub(angVel).Mul(1/dt), dt)
+	}
 
 	// Update angular motion with stability augmentation
 	d.updateAngularMotion(dt)
 
 	// Safety systems
-	d.updateSafetySystems()
+	d.updateSafetySystems(dt)
+
+	// Sensors sample the motion this step produced, ground reaction included
+	if dt > 0 {
+		d.updateSensors(dt, d.Velocity.Sub(velocity).Mul(1/dt))
+		d.updateGimbal(dt)
+		d.updateImageCamera(dt)
+	}
 
 	// Numerical safety: guard against NaN/Inf creeping in
 	d.Position.X = sanitizeFinite(d.Position.X)
@@ -307,11 +456,28 @@ func (d *Drone) SetThrottle(throttlePercent float64) {
 	d.ThrottlePercent = throttlePercent
 }
 
-// Arm/disarm motors (safety)
-func (d *Drone) Arm() {
-	if d.OnGround && d.BatteryPercent > d.CriticalBattery {
-		d.IsArmed = true
-	}
+// Arm/disarm motors (safety). Arm runs the pre-arm checks and only arms when
+// all of them pass; the result lists every failing check.
+func (d *Drone) Arm() ArmResult {
+	if d.IsArmed {
+		return ArmResult{Armed: true}
+	}
+	if failures := d.PreArmChecks(); len(failures) > 0 {
+		res := ArmResult{Failures: failures}
+		if r := res.Reasons(); r != d.armDenied {
+			d.armDenied = r
+			d.emit(Event{Kind: "arm.denied", Source: "prearm", Position: d.Position, Detail: r})
+		}
+		return res
+	}
+	d.IsArmed = true
+	d.armDenied = ""
+	d.Home = d.Position
+	d.ClearAction()
+	d.Failsafe.Reset()
+	d.resetMotorFault()
+	d.autotune = nil
+	return ArmResult{Armed: true}
 }
 
 func (d *Drone) Disarm() {
@@ -322,7 +488,9 @@ func (d *Drone) Disarm() {
 // Calculate thrust and resulting body torque from all engines.
 func (d *Drone) calculateThrustAndTorque() (Vec3, Vec3) {
 	// Spin down props if off
-	if !d.IsArmed || d.ThrottlePercent <= 0 || len(d.Engines) == 0 {
+	d.expectedMotorTorque = Vec3{}
+	d.expectedMotorThrust = [4]float64{}
+	if !d.IsArmed || (d.ThrottlePercent <= 0 && !d.directMotors) || len(d.Engines) == 0 {
 		for i := range d.PropSpeeds {
 			d.PropSpeeds[i] += (0 - d.PropSpeeds[i]) * 0.2
 		}
@@ -339,14 +507,10 @@ func (d *Drone) calculateThrustAndTorque() (Vec3, Vec3) {
 	up := rot.MulDirection(Vec3{0, 1, 0})
 
 	// Ground effect factor
-	ge := 1.0
-	if d.Position.Y < 2.0 {
-		ge = 1.0 + (0.15 * (2.0 - d.Position.Y) / 2.0)
-	}
+	ge := d.groundEffect()
 
 	sumFy := 0.0
 	torque := Vec3{}
-	yawCoeff := 0.02
 
 	for i := 0; i < len(d.Engines) && i < len(d.PropSpeeds); i++ {
 		e := d.Engines[i]
@@ -354,14 +518,21 @@ func (d *Drone) calculateThrustAndTorque() (Vec3, Vec3) {
 		if !e.Functional {
 			eff = 0
 		}
-		Fy := eff * tf * e.MaxThrust * ge
+		// The controller may command each motor directly
+		cmd := tf
+		if d.directMotors {
+			cmd = d.motorCmd[i]
+		}
+		Fy := eff * cmd * e.MaxThrust * ge
 		sumFy += Fy
 		// r x F with r=(x,0,z), F=(0,Fy,0) in local axes
-		torque.X += -e.Position.Z * Fy
-		torque.Z += e.Position.X * Fy
-		torque.Y += float64(e.Spin) * yawCoeff * Fy
+		torque = torque.Add(motorTorqueFor(e, Fy))
+
+		// What a healthy motor would have produced for the same command
+		d.expectedMotorThrust[i] = cmd * e.MaxThrust * ge
+		d.expectedMotorTorque = d.expectedMotorTorque.Add(motorTorqueFor(e, d.expectedMotorThrust[i]))
 
-		targetRPM := eff * tf * 8000
+		targetRPM := eff * cmd * 8000
 		d.PropSpeeds[i] += (targetRPM - d.PropSpeeds[i]) * 0.1
 	}
 
@@ -393,34 +564,21 @@ func (d *Drone) calculateDrag() Vec3 {
 func (d *Drone) updatePowerSystem(dt float64) {
 	if !d.IsArmed {
 		d.PowerDraw = 2.0 // Idle power consumption
+		d.avgPower = 0
 		return
 	}
 
 	// Power based on throttle distributed across engines
-	throttleFactor := d.ThrottlePercent / 100.0
-	if len(d.Engines) == 0 {
-		d.PowerDraw = d.HoverPower + (d.MaxPower-d.HoverPower)*throttleFactor
-	} else {
-		basePer := d.HoverPower / 4.0
-		var p float64
-		for _, e := range d.Engines {
-			eff := e.Efficiency
-			if !e.Functional {
-				eff = 0
-			}
-			p += basePer*eff + (d.MaxPower/4.0-basePer)*throttleFactor*eff
-		}
-		d.PowerDraw = p
-	}
+	d.PowerDraw = d.powerAt(d.ThrottlePercent)
 
 	// Additional power for high speeds and maneuvers
 	speed := d.Velocity.Length()
 	if speed > 5.0 {
 		d.PowerDraw += (speed - 5.0) * 2.0 // Extra power for speed
 	}
+	d.trackPower(dt)
 
-	// Battery drain (assuming 2200mAh battery at 11.1V = 24.42Wh)
-	batteryCapacityWh := 24.42
+	// Battery drain
 	powerHours := d.PowerDraw * dt / 3600.0 // Convert seconds to hours
 	batteryDrain := (powerHours / batteryCapacityWh) * 100.0
 	d.BatteryPercent -= batteryDrain
@@ -432,8 +590,14 @@ func (d *Drone) updatePowerSystem(dt float64) {
 
 // Check ground contact
 func (d *Drone) updateGroundContact() {
-	groundLevel := d.groundClearance()
-	d.OnGround = d.Position.Y <= groundLevel && math.Abs(d.Velocity.Y) < 0.1
+	clearance := d.groundClearance()
+	surface, vel, deck := d.World.surfaceUnder(d.Position, d.Position.Y-clearance)
+	groundLevel := surface + clearance
+	if deck {
+		// A sinking deck drops away between steps; allow for it
+		groundLevel += 0.01
+	}
+	d.OnGround = d.Position.Y <= groundLevel && math.Abs(d.Velocity.Y-vel.Y) < 0.1
 }
 
 // Update air density with altitude
@@ -472,21 +636,43 @@ func (d *Drone) enforceFlightEnvelope() {
 
 // Altitude hold PID controller
 func (d *Drone) calculateAltitudeCorrection(dt float64) float64 {
-	// Provide altitude correction both for Altitude Hold and Hover modes
-	if d.FlightMode == FlightModeAltitudeHold || d.FlightMode == FlightModeHover {
-		return d.updatePIDController(&d.AltitudePID, d.AltitudeHold, d.Position.Y, dt)
+	// Provide altitude correction for every mode that holds altitude
+	if d.holdsAltitude() {
+		out := d.updatePIDController(&d.AltitudePID, d.AltitudeHold, d.navPosition().Y, dt) + d.altitudeFF
+		return clamp(out, -d.AltitudePID.OutputLimit, d.AltitudePID.OutputLimit)
 	}
 	return 0
 }
 
+// holdsAltitude reports whether the current flight mode (or an active action)
+// closes the altitude loop.
+func (d *Drone) holdsAltitude() bool {
+	if d.ftActive {
+		return false
+	}
+	if d.actionMission != nil {
+		return true
+	}
+	switch d.FlightMode {
+	case FlightModeAltitudeHold, FlightModeHover, FlightModeMission, FlightModeOrbit, FlightModeFollow, FlightModePrecisionLand:
+		return true
+	}
+	return false
+}
+
+// groundEffect returns the thrust multiplier from ground effect near the surface.
+func (d *Drone) groundEffect() float64 {
+	if d.Position.Y < 2.0 {
+		return 1.0 + (0.15 * (2.0 - d.Position.Y) / 2.0)
+	}
+	return 1.0
+}
+
 func (d *Drone) maxVerticalThrustN() float64 {
 	if len(d.Engines) == 0 {
 		return 0
 	}
-	ge := 1.0
-	if d.Position.Y < 2.0 {
-		ge = 1.0 + (0.15 * (2.0 - d.Position.Y) / 2.0)
-	}
+	ge := d.groundEffect()
 	sum := 0.0
 	for _, e := range d.Engines {
 		eff := e.Efficiency
@@ -502,30 +688,34 @@ func (d *Drone) maxVerticalThrustN() float64 {
 }
 
 // Ground collision handling
+// The surface is flat ground or a platform deck; contact is resolved in the
+// surface's frame, so a landed drone rides a moving deck.
 func (d *Drone) handleGroundCollision() {
-	groundLevel := d.groundClearance()
+	clearance := d.groundClearance()
+	surface, vel, _ := d.World.surfaceUnder(d.Position, d.Position.Y-clearance)
+	groundLevel := surface + clearance
 	if d.Position.Y < groundLevel {
 		// Capture pre-clamp downward speed for damage assessment
 		impactSpeed := 0.0
-		if d.Velocity.Y < 0 {
-			impactSpeed = -d.Velocity.Y
+		if d.Velocity.Y < vel.Y {
+			impactSpeed = vel.Y - d.Velocity.Y
 		}
 		d.Position.Y = groundLevel
 
 		// Absorb landing impact
 		if impactSpeed > 2.0 {
 			// Hard landing - potential damage
-			d.Velocity.Y = 0
+			d.Velocity.Y = vel.Y
 			d.applyGroundImpactDamage(impactSpeed)
 		} else if impactSpeed > 0 {
 			// Soft landing
-			d.Velocity.Y = 0
+			d.Velocity.Y = vel.Y
 		}
 
 		// Friction on ground
 		if d.OnGround {
-			d.Velocity.X *= 0.8
-			d.Velocity.Z *= 0.8
+			d.Velocity.X = vel.X + (d.Velocity.X-vel.X)*0.8
+			d.Velocity.Z = vel.Z + (d.Velocity.Z-vel.Z)*0.8
 		}
 	}
 }
@@ -533,8 +723,7 @@ func (d *Drone) handleGroundCollision() {
 // Angular motion with stability
 func (d *Drone) updateAngularMotion(dt float64) {
 	// Time-based stability augmentation (exponential damping)
-	stabilityRate := 5.0 // 1/s
-	damping := math.Exp(-stabilityRate * dt)
+	damping := math.Exp(-angularDampingRate * dt)
 	d.AngularVel = d.AngularVel.Mul(damping)
 	d.Rotation = d.Rotation.Add(d.AngularVel.Mul(dt))
 	// Wrap yaw to [-pi, pi] to avoid unbounded growth
@@ -545,7 +734,14 @@ func (d *Drone) updateAngularMotion(dt float64) {
 		d.Rotation.Y += 2 * math.Pi
 	}
 
-	// Prevent excessive rotation
+	// Prevent excessive rotation. The limit stands in for a healthy attitude
+	// controller, so it is dropped once a motor is out and the airframe can
+	// actually flip.
+	for _, e := range d.Engines {
+		if !e.Functional {
+			return
+		}
+	}
 	maxAngle := math.Pi / 3 // 60 degrees max tilt
 	if math.Abs(d.Rotation.X) > maxAngle {
 		d.Rotation.X = maxAngle * math.Copysign(1, d.Rotation.X)
@@ -640,18 +836,11 @@ func sanitizeFinite(x float64) float64 {
 	return x
 }
 
-// Safety systems
-func (d *Drone) updateSafetySystems() {
-	// Auto-disarm on critical battery
-	if d.BatteryPercent <= d.CriticalBattery {
-		d.Disarm()
-	}
-
-	// Force landing on low battery
-	if d.BatteryPercent <= d.LowBatteryWarning && d.FlightMode != FlightModeManual {
-		d.FlightMode = FlightModeManual
-		d.ThrottlePercent = 25.0 // Gentle descent
-	}
+// Safety systems: geofence checks feed the failsafe manager, which picks the
+// action for the highest-priority active trigger.
+func (d *Drone) updateSafetySystems(dt float64) {
+	d.updateGeofences()
+	d.updateFailsafe(dt)
 }
 
 // PID controller update
@@ -691,6 +880,10 @@ func (d *Drone) AddTorque(torque Vec3, dt float64) {
 	if !d.IsArmed {
 		return
 	}
+	if d.torqueSink != nil {
+		*d.torqueSink = d.torqueSink.Add(torque)
+		return
+	}
 
 	// Convert torque to angular acceleration
 	// Use per-axis inertia for more realistic response
@@ -789,7 +982,7 @@ func (d *Drone) SetEngineEfficiency(i int, eff float64) {
 // Set flight mode
 func (d *Drone) SetFlightMode(mode FlightMode) {
 	d.FlightMode = mode
-	if mode == FlightModeAltitudeHold || mode == FlightModeHover {
+	if d.holdsAltitude() {
 		d.AltitudeHold = d.Position.Y
 	}
 }
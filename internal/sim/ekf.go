@@ -0,0 +1,557 @@
+package sim
+
+import "math"
+
+// EKF is an error-state extended Kalman filter fusing the onboard sensors
+// into a navigation solution. The IMU drives the prediction: its specific
+// force, rotated by the estimated attitude, is integrated into velocity and
+// position, and its body rate into attitude. GNSS position and velocity, the
+// barometric altitude and the magnetometer heading then correct the sixteen
+// error states: position, velocity, attitude, accelerometer and gyro biases,
+// and the barometer's offset from the GNSS altitude.
+//
+// Every measurement is checked against the filter's own uncertainty first: an
+// innovation more than Gate standard deviations out is rejected rather than
+// fused, so a GNSS jump or a magnetic disturbance does not drag the estimate.
+type EKF struct {
+	// Process noise: how far the IMU can be trusted between corrections
+	AccelNoise     float64 // m/s per √s velocity random walk
+	GyroNoise      float64 // rad per √s angle random walk
+	AccelBiasWalk  float64 // m/s² per √s
+	GyroBiasWalk   float64 // rad/s per √s
+	BaroOffsetWalk float64 // m per √s
+
+	// Measurement noise, 1σ
+	GNSSPosition float64 // m per unit of HDOP/VDOP
+	GNSSVelocity float64 // m/s
+	BaroAltitude float64 // m
+	MagHeading   float64 // rad
+
+	Gate float64 // σ of innovation beyond which a measurement is rejected
+
+	valid    bool
+	time     float64
+	pos, vel Vec3
+	rot      mat3 // body to world
+	ba, bg   Vec3
+	bb       float64 // barometer altitude minus the estimated altitude MSL
+	rates    Vec3    // bias-corrected body rate of the last prediction
+	p        [ekfStates][ekfStates]float64
+
+	gnssCount, baroCount, magCount int
+	gnssRejectedSince              float64
+	gnss, baro, mag                EKFInnovation
+}
+
+// Error-state layout
+const (
+	ekfPos = 3 * iota
+	ekfVel
+	ekfAtt
+	ekfAccelBias
+	ekfGyroBias
+	ekfBaro
+	ekfStates = ekfBaro + 1
+)
+
+// EKFInnovation summarises one measurement stream of the filter.
+type EKFInnovation struct {
+	Ratio    float64 // last innovation over its standard deviation (worst axis)
+	Fused    int
+	Rejected int
+}
+
+// EKFEstimate is the filter's navigation solution.
+type EKFEstimate struct {
+	Valid     bool
+	Time      float64 // s of simulation
+	Position  Vec3
+	Velocity  Vec3
+	Rotation  Vec3 // Euler angles like Drone.Rotation
+	AccelBias Vec3 // m/s², body frame
+	GyroBias  Vec3 // rad/s, body frame
+	BaroBias  float64
+
+	// 1σ uncertainty per axis
+	PositionSigma Vec3
+	VelocitySigma Vec3
+	AttitudeSigma Vec3 // rad, world axes
+
+	GNSS, Baro, Mag EKFInnovation
+}
+
+// EKFError is the estimate minus the truth.
+type EKFError struct {
+	Position Vec3
+	Velocity Vec3
+	Attitude Vec3 // rad per Euler angle, wrapped
+}
+
+// DefaultEKF returns a filter tuned for the default sensors.
+func DefaultEKF() EKF {
+	return EKF{
+		AccelNoise:     0.5,
+		GyroNoise:      0.02,
+		AccelBiasWalk:  0.005,
+		GyroBiasWalk:   0.0005,
+		BaroOffsetWalk: 0.05,
+		GNSSPosition:   1.5,
+		GNSSVelocity:   0.15,
+		BaroAltitude:   0.5,
+		MagHeading:     0.1,
+		Gate:           5,
+	}
+}
+
+// EKF tuning
+const (
+	ekfGNSSReset     = 5.0 // s of rejected GNSS before the filter realigns on it
+	ekfMaxPosSigma   = 5.0 // m horizontal 1σ beyond which the solution is unusable
+	ekfInitPosSigma  = 3.0
+	ekfInitVelSigma  = 0.5
+	ekfInitAttSigma  = 0.1
+	ekfInitYawSigma  = 0.2
+	ekfInitABSigma   = 0.1
+	ekfInitGBSigma   = 0.01
+	ekfInitBaroSigma = 3.0
+)
+
+var ekfGravity = Vec3{Y: -9.81}
+
+// Estimate returns the filter's latest solution.
+func (e *EKF) Estimate() EKFEstimate {
+	est := EKFEstimate{
+		Valid:     e.valid,
+		Time:      e.time,
+		Position:  e.pos,
+		Velocity:  e.vel,
+		Rotation:  e.rot.euler(),
+		AccelBias: e.ba,
+		GyroBias:  e.bg,
+		BaroBias:  e.bb,
+		GNSS:      e.gnss,
+		Baro:      e.baro,
+		Mag:       e.mag,
+	}
+	sigma := func(i int) Vec3 {
+		return Vec3{X: math.Sqrt(e.p[i][i]), Y: math.Sqrt(e.p[i+1][i+1]), Z: math.Sqrt(e.p[i+2][i+2])}
+	}
+	if e.valid {
+		est.PositionSigma, est.VelocitySigma, est.AttitudeSigma = sigma(ekfPos), sigma(ekfVel), sigma(ekfAtt)
+	}
+	return est
+}
+
+// Reset drops the solution; the filter realigns on the next sensor data.
+func (e *EKF) Reset() { e.valid = false }
+
+// EKFError returns how far the filter's estimate is from the true state.
+func (d *Drone) EKFError() EKFError {
+	est := d.EKF.Estimate()
+	return EKFError{
+		Position: est.Position.Sub(d.Position),
+		Velocity: est.Velocity.Sub(d.Velocity),
+		Attitude: Vec3{
+			X: angleDiff(est.Rotation.X, d.Rotation.X),
+			Y: angleDiff(est.Rotation.Y, d.Rotation.Y),
+			Z: angleDiff(est.Rotation.Z, d.Rotation.Z),
+		},
+	}
+}
+
+// usable reports whether the solution is good enough to fly on.
+func (e *EKF) usable() bool {
+	return e.valid && math.Max(e.p[ekfPos][ekfPos], e.p[ekfPos+2][ekfPos+2]) < ekfMaxPosSigma*ekfMaxPosSigma
+}
+
+// updateEKF runs the filter over the step just sampled.
+func (d *Drone) updateEKF(dt float64) {
+	if d.IMU.Rate <= 0 {
+		d.EKF.valid = false
+		return
+	}
+	accel, gyro := d.IMU.drain()
+	d.EKF.update(d.clock, dt, accel, gyro, d.Sensors(), d.World.GeoOrigin())
+}
+
+// update predicts over dt on the mean IMU output, then fuses the new
+// measurements.
+func (e *EKF) update(now, dt float64, accel, gyro Vec3, s SensorReadings, origin GeoPoint) {
+	if !e.valid {
+		e.align(now, accel, s, origin)
+		return
+	}
+	e.predict(dt, accel, gyro)
+	e.time = now
+
+	if s.GNSS.Count != e.gnssCount {
+		e.gnssCount = s.GNSS.Count
+		if s.GNSS.Fix != GNSSNoFix {
+			e.fuseGNSS(now, s.GNSS)
+		}
+	}
+	if s.Baro.Count != e.baroCount {
+		e.baroCount = s.Baro.Count
+		e.fuseBaro(s.Baro, origin)
+	}
+	if s.Mag.Count != e.magCount {
+		e.magCount = s.Mag.Count
+		e.fuseMag(s.Mag, origin)
+	}
+}
+
+// align initialises the filter once every sensor has reported: position and
+// velocity from GNSS, tilt from the accelerometer's gravity, heading from the
+// magnetometer, and the barometer offset from the two altitudes.
+func (e *EKF) align(now float64, accel Vec3, s SensorReadings, origin GeoPoint) {
+	if s.GNSS.Fix != GNSSFix3D || s.IMU.Count == 0 || s.Baro.Count == 0 || s.Mag.Count == 0 || accel.Length() < 1 {
+		return
+	}
+	f := accel.Normalize()
+	pitch, roll := math.Asin(clamp(f.Z, -1, 1)), -math.Atan2(f.X, f.Y)
+	level := mat3FromEuler(Vec3{X: pitch, Z: roll})
+	yaw := angleDiff(azimuth(level.mulVec(s.Mag.Field)), azimuth(EarthField(origin)))
+
+	e.time = now
+	e.pos = s.GNSS.Position.Add(s.GNSS.Velocity.Mul(now - s.GNSS.Time))
+	e.vel = s.GNSS.Velocity
+	e.rot = mat3FromEuler(Vec3{X: pitch, Y: yaw, Z: roll})
+	e.ba, e.bg = Vec3{}, Vec3{}
+	e.bb = s.Baro.Altitude - origin.Alt - e.pos.Y
+	e.gnssCount, e.baroCount, e.magCount = s.GNSS.Count, s.Baro.Count, s.Mag.Count
+	e.gnssRejectedSince = 0
+	e.p = [ekfStates][ekfStates]float64{}
+	for i, sigma := range [ekfStates]float64{
+		ekfInitPosSigma, ekfInitPosSigma, ekfInitPosSigma,
+		ekfInitVelSigma, ekfInitVelSigma, ekfInitVelSigma,
+		ekfInitAttSigma, ekfInitYawSigma, ekfInitAttSigma,
+		ekfInitABSigma, ekfInitABSigma, ekfInitABSigma,
+		ekfInitGBSigma, ekfInitGBSigma, ekfInitGBSigma,
+		ekfInitBaroSigma,
+	} {
+		e.p[i][i] = sigma * sigma
+	}
+	e.valid = true
+}
+
+// predict integrates the IMU over dt and propagates the covariance.
+func (e *EKF) predict(dt float64, accel, gyro Vec3) {
+	if dt <= 0 {
+		return
+	}
+	f := accel.Sub(e.ba)
+	e.rates = gyro.Sub(e.bg)
+	force := e.rot.mulVec(f)
+	a := force.Add(ekfGravity)
+	e.pos = e.pos.Add(e.vel.Mul(dt)).Add(a.Mul(0.5 * dt * dt))
+	e.vel = e.vel.Add(a.Mul(dt))
+	e.rot = e.rot.mul(rodrigues(e.rates.Mul(dt))).orthonormal()
+
+	// Error-state transition Φ = I + F·dt
+	var phi [ekfStates][ekfStates]float64
+	for i := range phi {
+		phi[i][i] = 1
+	}
+	skew := skewMat(force)
+	for i := 0; i < 3; i++ {
+		phi[ekfPos+i][ekfVel+i] = dt
+		for j := 0; j < 3; j++ {
+			phi[ekfVel+i][ekfAtt+j] = -skew[i][j] * dt
+			phi[ekfVel+i][ekfAccelBias+j] = -e.rot[i][j] * dt
+			phi[ekfAtt+i][ekfGyroBias+j] = -e.rot[i][j] * dt
+		}
+	}
+	var tmp [ekfStates][ekfStates]float64
+	for i := 0; i < ekfStates; i++ {
+		for j := 0; j < ekfStates; j++ {
+			sum := 0.0
+			for k := 0; k < ekfStates; k++ {
+				if phi[i][k] != 0 {
+					sum += phi[i][k] * e.p[k][j]
+				}
+			}
+			tmp[i][j] = sum
+		}
+	}
+	for i := 0; i < ekfStates; i++ {
+		for j := 0; j < ekfStates; j++ {
+			sum := 0.0
+			for k := 0; k < ekfStates; k++ {
+				if phi[j][k] != 0 {
+					sum += tmp[i][k] * phi[j][k]
+				}
+			}
+			e.p[i][j] = sum
+		}
+	}
+	for i, q := range [ekfStates]float64{
+		0, 0, 0,
+		e.AccelNoise, e.AccelNoise, e.AccelNoise,
+		e.GyroNoise, e.GyroNoise, e.GyroNoise,
+		e.AccelBiasWalk, e.AccelBiasWalk, e.AccelBiasWalk,
+		e.GyroBiasWalk, e.GyroBiasWalk, e.GyroBiasWalk,
+		e.BaroOffsetWalk,
+	} {
+		e.p[i][i] += q * q * dt
+	}
+}
+
+// ekfRow is one scalar measurement: its sensitivity h to the error states,
+// its noise variance, and its innovation against the current estimate.
+type ekfRow struct {
+	h        [ekfStates]float64
+	variance float64
+	innov    func() float64
+}
+
+// fuse gates the scalar measurements of one sensor reading together on the
+// worst of them, then applies them one after the other. It reports whether
+// they were accepted.
+func (e *EKF) fuse(stats *EKFInnovation, rows []ekfRow) bool {
+	worst := 0.0
+	for _, r := range rows {
+		s := r.variance
+		for i, hi := range r.h {
+			for j, hj := range r.h {
+				if hi != 0 && hj != 0 {
+					s += hi * e.p[i][j] * hj
+				}
+			}
+		}
+		worst = math.Max(worst, math.Abs(r.innov())/math.Sqrt(s))
+	}
+	stats.Ratio = worst
+	if e.Gate > 0 && worst > e.Gate {
+		stats.Rejected++
+		return false
+	}
+	stats.Fused++
+	for _, r := range rows {
+		e.correct(r)
+	}
+	return true
+}
+
+// correct applies one scalar Kalman update and folds the error into the
+// nominal state.
+func (e *EKF) correct(r ekfRow) {
+	var ph [ekfStates]float64 // P·hᵀ
+	s := r.variance
+	for i := 0; i < ekfStates; i++ {
+		for j, hj := range r.h {
+			if hj != 0 {
+				ph[i] += e.p[i][j] * hj
+			}
+		}
+		s += r.h[i] * ph[i]
+	}
+	if s <= 0 {
+		return
+	}
+	innov := r.innov()
+	var dx [ekfStates]float64
+	for i := range dx {
+		dx[i] = ph[i] / s * innov
+	}
+	// P ← P - K·h·P with K = P·hᵀ/s, kept symmetric
+	for i := 0; i < ekfStates; i++ {
+		for j := i; j < ekfStates; j++ {
+			v := e.p[i][j] - ph[i]*ph[j]/s
+			e.p[i][j], e.p[j][i] = v, v
+		}
+	}
+	e.inject(dx)
+}
+
+// inject moves an error-state correction into the nominal state.
+func (e *EKF) inject(dx [ekfStates]float64) {
+	vec := func(i int) Vec3 { return Vec3{X: dx[i], Y: dx[i+1], Z: dx[i+2]} }
+	e.pos = e.pos.Add(vec(ekfPos))
+	e.vel = e.vel.Add(vec(ekfVel))
+	e.rot = rodrigues(vec(ekfAtt)).mul(e.rot).orthonormal()
+	e.ba = e.ba.Add(vec(ekfAccelBias))
+	e.bg = e.bg.Add(vec(ekfGyroBias))
+	e.bb += dx[ekfBaro]
+}
+
+// fuseGNSS corrects position and velocity with a fix taken lag seconds ago,
+// compared against the estimate carried back over the lag. A receiver the
+// gate has refused for ekfGNSSReset seconds is believed again: the estimate
+// has drifted, not the satellites.
+func (e *EKF) fuseGNSS(now float64, r GNSSReading) {
+	lag := now - r.Time
+	rows := make([]ekfRow, 0, 6)
+	for axis := 0; axis < 3; axis++ {
+		axis := axis
+		sigma := e.GNSSPosition * r.HDOP
+		if axis == 1 {
+			if r.Fix != GNSSFix3D {
+				continue
+			}
+			sigma = e.GNSSPosition * r.VDOP
+		}
+		var h [ekfStates]float64
+		h[ekfPos+axis], h[ekfVel+axis] = 1, -lag
+		rows = append(rows, ekfRow{h: h, variance: sigma * sigma, innov: func() float64 {
+			return vecAxis(r.Position, axis) - (vecAxis(e.pos, axis) - vecAxis(e.vel, axis)*lag)
+		}})
+	}
+	for axis := 0; axis < 3; axis++ {
+		axis := axis
+		var h [ekfStates]float64
+		h[ekfVel+axis] = 1
+		rows = append(rows, ekfRow{h: h, variance: e.GNSSVelocity * e.GNSSVelocity, innov: func() float64 {
+			return vecAxis(r.Velocity, axis) - vecAxis(e.vel, axis)
+		}})
+	}
+	if e.fuse(&e.gnss, rows) {
+		e.gnssRejectedSince = 0
+		return
+	}
+	if e.gnssRejectedSince == 0 {
+		e.gnssRejectedSince = now
+	} else if now-e.gnssRejectedSince >= ekfGNSSReset {
+		e.pos = r.Position.Add(r.Velocity.Mul(lag))
+		e.vel = r.Velocity
+		for i := ekfPos; i < ekfAtt; i++ {
+			for j := range e.p {
+				e.p[i][j], e.p[j][i] = 0, 0
+			}
+		}
+		for i := 0; i < 3; i++ {
+			e.p[ekfPos+i][ekfPos+i] = ekfInitPosSigma * ekfInitPosSigma
+			e.p[ekfVel+i][ekfVel+i] = ekfInitVelSigma * ekfInitVelSigma
+		}
+		e.gnssRejectedSince = 0
+	}
+}
+
+// fuseBaro corrects the altitude and the barometer offset.
+func (e *EKF) fuseBaro(b BaroSample, origin GeoPoint) {
+	var h [ekfStates]float64
+	h[ekfPos+1], h[ekfBaro] = 1, 1
+	e.fuse(&e.baro, []ekfRow{{h: h, variance: e.BaroAltitude * e.BaroAltitude, innov: func() float64 {
+		return b.Altitude - (origin.Alt + e.pos.Y + e.bb)
+	}}})
+}
+
+// fuseMag corrects the heading: the measured field, rotated into the world
+// by the estimated attitude, should point along the Earth's horizontal field.
+// Only yaw is corrected; tilt is the accelerometer's business.
+func (e *EKF) fuseMag(m MagSample, origin GeoPoint) {
+	var h [ekfStates]float64
+	h[ekfAtt+1] = 1
+	earth := azimuth(EarthField(origin))
+	e.fuse(&e.mag, []ekfRow{{h: h, variance: e.MagHeading * e.MagHeading, innov: func() float64 {
+		return angleDiff(earth, azimuth(e.rot.mulVec(m.Field)))
+	}}})
+}
+
+// azimuth returns the angle of v's horizontal part from +Z toward +X. Only
+// differences of azimuths are used, so it holds for any heading reference.
+func azimuth(v Vec3) float64 { return math.Atan2(v.X, v.Z) }
+
+func vecAxis(v Vec3, axis int) float64 {
+	switch axis {
+	case 0:
+		return v.X
+	case 1:
+		return v.Y
+	}
+	return v.Z
+}
+
+// mat3 is a 3×3 rotation, row-major.
+type mat3 [3][3]float64
+
+// mat3FromEuler returns the body-to-world rotation R_y·R_x·R_z of rot, the
+// one the thrust and the renderer use.
+func mat3FromEuler(rot Vec3) mat3 {
+	m4 := RotationYMat4(rot.Y).Mul(RotationXMat4(rot.X)).Mul(RotationZMat4(rot.Z))
+	var m mat3
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			m[i][j] = m4[j*4+i]
+		}
+	}
+	return m
+}
+
+// euler inverts mat3FromEuler.
+func (m mat3) euler() Vec3 {
+	return Vec3{
+		X: math.Asin(clamp(m[1][2], -1, 1)),
+		Y: -math.Atan2(m[0][2], m[2][2]),
+		Z: -math.Atan2(m[1][0], m[1][1]),
+	}
+}
+
+func (m mat3) mul(o mat3) mat3 {
+	var r mat3
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			r[i][j] = m[i][0]*o[0][j] + m[i][1]*o[1][j] + m[i][2]*o[2][j]
+		}
+	}
+	return r
+}
+
+func (m mat3) mulVec(v Vec3) Vec3 {
+	return Vec3{
+		X: m[0][0]*v.X + m[0][1]*v.Y + m[0][2]*v.Z,
+		Y: m[1][0]*v.X + m[1][1]*v.Y + m[1][2]*v.Z,
+		Z: m[2][0]*v.X + m[2][1]*v.Y + m[2][2]*v.Z,
+	}
+}
+
+func (m mat3) transpose() mat3 {
+	return mat3{{m[0][0], m[1][0], m[2][0]}, {m[0][1], m[1][1], m[2][1]}, {m[0][2], m[1][2], m[2][2]}}
+}
+
+// orthonormal removes the drift of repeated products from a rotation.
+func (m mat3) orthonormal() mat3 {
+	x := Vec3{X: m[0][0], Y: m[1][0], Z: m[2][0]}.Normalize()
+	y := Vec3{X: m[0][1], Y: m[1][1], Z: m[2][1]}
+	y = y.Sub(x.Mul(x.Dot(y))).Normalize()
+	z := x.Cross(y)
+	return mat3{{x.X, y.X, z.X}, {x.Y, y.Y, z.Y}, {x.Z, y.Z, z.Z}}
+}
+
+// skewMat returns [v]×, the matrix of the cross product v × ·.
+func skewMat(v Vec3) mat3 {
+	return mat3{{0, -v.Z, v.Y}, {v.Z, 0, -v.X}, {-v.Y, v.X, 0}}
+}
+
+// rodrigues returns the rotation by |v| radians about v.
+func rodrigues(v Vec3) mat3 {
+	id := mat3{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+	angle := v.Length()
+	k := skewMat(v)
+	a, b := 1.0, 0.5
+	if angle > 1e-9 {
+		a, b = math.Sin(angle)/angle, (1-math.Cos(angle))/(angle*angle)
+	}
+	k2 := k.mul(k)
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			id[i][j] += a*k[i][j] + b*k2[i][j]
+		}
+	}
+	return id
+}
+
+// eulerRates converts a body angular velocity into Euler angle rates at rot,
+// inverting bodyRates.
+func eulerRates(rot, w Vec3) Vec3 {
+	cx, cy, cz := bodyRates(rot, Vec3{X: 1}), bodyRates(rot, Vec3{Y: 1}), bodyRates(rot, Vec3{Z: 1})
+	det := cx.Dot(cy.Cross(cz))
+	if math.Abs(det) < 1e-9 {
+		return Vec3{}
+	}
+	// Cramer's rule on w = [cx cy cz]·rates
+	return Vec3{
+		X: w.Dot(cy.Cross(cz)) / det,
+		Y: cx.Dot(w.Cross(cz)) / det,
+		Z: cx.Dot(cy.Cross(w)) / det,
+	}
+}
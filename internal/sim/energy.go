@@ -0,0 +1,150 @@
+package sim
+
+import "math"
+
+// Energy budget. The estimator runs the power model forward: how long the
+// battery lasts at the current draw, how far that gets the drone at its
+// current speed, and what a return-to-home costs against the wind. When
+// the energy left above the landing reserve no longer covers the trip home
+// with a margin, or the wind keeps it from making home at all, the drone
+// must return now; the SmartRTH failsafe acts on it with its rule's action.
+
+// Battery pack model (3S LiPo, 2200 mAh)
+const (
+	batteryCells       = 3
+	batteryCapacityWh  = 24.42 // 2.2 Ah at 11.1 V
+	cellEmptyVoltage   = 3.3   // V at 0%
+	cellFullVoltage    = 4.2   // V at 100%
+	cellNominalVoltage = 3.7
+	batteryInternalR   = 0.045 // ohm, whole pack
+	powerAverageTau    = 5.0   // s, smoothing of the draw used for estimates
+)
+
+// EnergyEstimate is the drone's energy budget.
+type EnergyEstimate struct {
+	Power        float64 // W, smoothed draw (hover draw on the ground)
+	Usable       float64 // Wh left above the landing reserve (CriticalBattery)
+	Endurance    float64 // s of flight left at Power
+	Speed        float64 // m/s horizontal ground speed
+	Range        float64 // m flown at Speed before the reserve is reached
+	HomeDistance float64 // m horizontal
+	ReturnSpeed  float64 // m/s ground speed toward home, with the wind
+	ReturnTime   float64 // s to climb, fly home and land
+	ReturnEnergy float64 // Wh for the return
+	Margin       float64 // Wh spare after the return and its reserve
+	Reachable    bool    // home can be made good against the wind
+	MustReturn   bool    // the return needs all the energy that is left, or home is out of reach
+}
+
+// BatteryVoltage returns the pack voltage under the current load: an open
+// circuit voltage that falls with charge, minus internal-resistance sag.
+func (d *Drone) BatteryVoltage() float64 {
+	soc := clamp(d.BatteryPercent/100, 0, 1)
+	// LiPo discharge: a steep knee below ~10% and a shallow plateau above
+	ocv := cellEmptyVoltage + (cellFullVoltage-cellEmptyVoltage)*(0.75*soc+0.25*math.Sqrt(soc))
+	pack := ocv * batteryCells
+	current := d.PowerDraw / (cellNominalVoltage * batteryCells)
+	return math.Max(pack-current*batteryInternalR, 0)
+}
+
+// powerAt returns the motor power at a collective throttle percent.
+func (d *Drone) powerAt(throttle float64) float64 {
+	tf := clamp(throttle/100, 0, 1)
+	if len(d.Engines) == 0 {
+		return d.HoverPower + (d.MaxPower-d.HoverPower)*tf
+	}
+	basePer := d.HoverPower / 4.0
+	var p float64
+	for _, e := range d.Engines {
+		eff := e.Efficiency
+		if !e.Functional {
+			eff = 0
+		}
+		p += basePer*eff + (d.MaxPower/4.0-basePer)*tf*eff
+	}
+	return p
+}
+
+// dragAt returns the drag force at airspeed v (see calculateDrag).
+func (d *Drone) dragAt(v float64) float64 {
+	return 0.5 * d.AirDensity * v * v * 0.1 * d.Dimensions.X * d.Dimensions.Z
+}
+
+// airspeedAtTilt returns the level-flight airspeed at which drag needs the
+// given tilt.
+func (d *Drone) airspeedAtTilt(tilt float64) float64 {
+	k := d.dragAt(1)
+	if k <= 0 {
+		return math.Inf(1)
+	}
+	return math.Sqrt(d.Mass * 9.81 * math.Tan(tilt) / k)
+}
+
+// cruisePower returns the power to fly level at airspeed v: tilting against
+// drag needs more collective to keep the weight up.
+func (d *Drone) cruisePower(v, groundSpeed float64) float64 {
+	tilt := math.Atan2(d.dragAt(v), d.Mass*9.81)
+	p := d.powerAt(d.HoverThrottlePercent() / math.Sqrt(math.Cos(tilt)))
+	if groundSpeed > 5 {
+		p += (groundSpeed - 5) * 2
+	}
+	return p
+}
+
+// EnergyEstimate returns the current energy budget.
+func (d *Drone) EnergyEstimate() EnergyEstimate {
+	e := EnergyEstimate{Power: d.avgPower}
+	hover := d.powerAt(d.HoverThrottlePercent())
+	if !d.IsArmed || e.Power <= 0 {
+		e.Power = hover
+	}
+	e.Usable = math.Max(d.BatteryPercent-d.CriticalBattery, 0) / 100 * batteryCapacityWh
+	e.Endurance = e.Usable * 3600 / e.Power
+	e.Speed = math.Hypot(d.Velocity.X, d.Velocity.Z)
+	e.Range = e.Endurance * e.Speed
+
+	// Return home the way ReturnHome flies it: climb, cruise, land
+	toHome := Vec3{X: d.Home.X - d.Position.X, Z: d.Home.Z - d.Position.Z}
+	e.HomeDistance = toHome.Length()
+	alt := math.Max(d.Position.Y, d.ReturnAltitude) - d.Home.Y
+	climb := math.Max(d.ReturnAltitude-d.Position.Y, 0) / navClimbRate
+	land := (math.Max(alt, 0) + 1.5) / missionLandSpeed // the last 1.5 m at half rate
+	e.ReturnTime = climb + land
+	e.ReturnEnergy = hover * e.ReturnTime / 3600
+	e.Reachable = true
+	if e.HomeDistance > missionDefaultAccept {
+		// The navigator holds the cruise ground speed toward home and tilts
+		// into the wind for it, up to its tilt limit
+		u := toHome.Mul(1 / e.HomeDistance)
+		along := d.WindVelocity.X*u.X + d.WindVelocity.Z*u.Z
+		cross := math.Abs(d.WindVelocity.X*u.Z - d.WindVelocity.Z*u.X)
+		maxAir := d.airspeedAtTilt(navMaxTiltDeg * math.Pi / 180)
+		if cross < maxAir {
+			e.ReturnSpeed = math.Min(missionDefaultSpeed, along+math.Sqrt(maxAir*maxAir-cross*cross))
+		}
+		if e.ReturnSpeed < 0.1 {
+			e.Reachable = false
+			e.ReturnTime = math.Inf(1)
+			e.ReturnEnergy = math.Inf(1)
+		} else {
+			cruise := e.HomeDistance / e.ReturnSpeed
+			air := u.Mul(e.ReturnSpeed).Sub(Vec3{X: d.WindVelocity.X, Z: d.WindVelocity.Z}).Length()
+			e.ReturnTime += cruise
+			e.ReturnEnergy += d.cruisePower(air, e.ReturnSpeed) * cruise / 3600
+		}
+	}
+	e.Margin = e.Usable - e.ReturnEnergy*(1+d.Failsafe.ReturnReserve)
+	// Out of reach, the margin is -Inf: waiting only burns what the
+	// drone needs to get as close as it can or to land
+	e.MustReturn = d.IsArmed && !d.OnGround && e.Margin <= 0
+	return e
+}
+
+// trackPower smooths the power draw for the estimator.
+func (d *Drone) trackPower(dt float64) {
+	if d.avgPower <= 0 {
+		d.avgPower = d.PowerDraw
+		return
+	}
+	d.avgPower += (d.PowerDraw - d.avgPower) * math.Min(dt/powerAverageTau, 1)
+}
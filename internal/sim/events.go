@@ -0,0 +1,37 @@
+package sim
+
+// Event is a notable state change raised by a drone's onboard systems, e.g. a
+// geofence breach. Events queue on the drone until an outside consumer (the
+// NATS client) takes them.
+type Event struct {
+	Kind     string // dotted event name, e.g. "geofence.breach"
+	Source   string // what raised it (fence name, failsafe trigger)
+	Action   string // response taken, if any
+	Position Vec3
+	Detail   string
+}
+
+// maxPendingEvents bounds the queue when nobody is consuming events.
+const maxPendingEvents = 64
+
+func (d *Drone) emit(e Event) {
+	if e.Position == (Vec3{}) {
+		e.Position = d.Position
+	}
+	if len(d.events) >= maxPendingEvents {
+		copy(d.events, d.events[1:])
+		d.events = d.events[:len(d.events)-1]
+	}
+	d.events = append(d.events, e)
+}
+
+// TakeEvents returns and clears the queued events. Callers must hold the
+// simulator write lock.
+func (d *Drone) TakeEvents() []Event {
+	if len(d.events) == 0 {
+		return nil
+	}
+	out := d.events
+	d.events = nil
+	return out
+}
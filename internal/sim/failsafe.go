@@ -0,0 +1,216 @@
+package sim
+
+// FailsafeTrigger identifies a failsafe condition. Higher values take
+// priority when several are active at once.
+type FailsafeTrigger int
+
+const (
+	FailsafeLowBattery FailsafeTrigger = iota
+	FailsafeSmartRTH
+	FailsafeGeofence
+	FailsafeInputLoss
+	FailsafeLinkLoss
+	FailsafePositionLoss
+	FailsafeCriticalBattery
+	failsafeTriggerCount
+
+	// FailsafeNone means no failsafe is in control.
+	FailsafeNone FailsafeTrigger = -1
+)
+
+func (t FailsafeTrigger) String() string {
+	switch t {
+	case FailsafeLowBattery:
+		return "LowBattery"
+	case FailsafeSmartRTH:
+		return "SmartRTH"
+	case FailsafeGeofence:
+		return "Geofence"
+	case FailsafeInputLoss:
+		return "InputLoss"
+	case FailsafeLinkLoss:
+		return "LinkLoss"
+	case FailsafePositionLoss:
+		return "PositionLoss"
+	case FailsafeCriticalBattery:
+		return "CriticalBattery"
+	default:
+		return "None"
+	}
+}
+
+// FailsafeRule configures one trigger. Action ActionNone means "continue":
+// the trigger is reported but does not take control. The geofence trigger
+// ignores Action and runs the breached fence's own action.
+type FailsafeRule struct {
+	Action     AutoAction
+	Delay      float64 // s the condition must persist before triggering
+	ClearDelay float64 // s the condition must be gone before clearing
+	Latch      bool    // once triggered, stay triggered until re-armed
+}
+
+// Failsafe arbitrates between failsafe triggers and runs the action of the
+// highest-priority one through the drone's AutoAction layer.
+type Failsafe struct {
+	Rules             [failsafeTriggerCount]FailsafeRule
+	LinkTimeout       float64 // s without a heartbeat before the link counts as lost
+	InputTimeout      float64 // s without a stick sample before the sticks count as lost
+	BatteryHysteresis float64 // % above a battery threshold needed to clear it
+	ReturnReserve     float64 // fraction of the return energy kept spare before SmartRTH
+
+	state  [failsafeTriggerCount]failsafeState
+	active FailsafeTrigger
+}
+
+type failsafeState struct {
+	triggered bool
+	pending   float64 // s the condition has been present
+	healthy   float64 // s the condition has been absent while triggered
+}
+
+// DefaultFailsafe returns the stock failsafe configuration.
+func DefaultFailsafe() Failsafe {
+	f := Failsafe{LinkTimeout: 5, InputTimeout: 0.5, BatteryHysteresis: 3, ReturnReserve: 0.25, active: FailsafeNone}
+	f.Rules[FailsafeLowBattery] = FailsafeRule{Action: ActionReturnHome, Delay: 2, Latch: true}
+	f.Rules[FailsafeSmartRTH] = FailsafeRule{Action: ActionReturnHome, Delay: 2, Latch: true}
+	f.Rules[FailsafeGeofence] = FailsafeRule{ClearDelay: 2, Latch: true}
+	f.Rules[FailsafeInputLoss] = FailsafeRule{Action: ActionHold, ClearDelay: 0.5}
+	f.Rules[FailsafeLinkLoss] = FailsafeRule{Action: ActionReturnHome, ClearDelay: 2}
+	f.Rules[FailsafePositionLoss] = FailsafeRule{Action: ActionLand, Delay: 1, ClearDelay: 3}
+	f.Rules[FailsafeCriticalBattery] = FailsafeRule{Action: ActionLand, Delay: 1, Latch: true}
+	return f
+}
+
+// Active returns the trigger currently in control, or FailsafeNone.
+func (f *Failsafe) Active() FailsafeTrigger { return f.active }
+
+// Triggered reports whether t is currently triggered, in control or not.
+func (f *Failsafe) Triggered(t FailsafeTrigger) bool {
+	return t >= 0 && t < failsafeTriggerCount && f.state[t].triggered
+}
+
+// Reset clears all trigger state, including latched triggers.
+func (f *Failsafe) Reset() {
+	f.state = [failsafeTriggerCount]failsafeState{}
+	f.active = FailsafeNone
+}
+
+// Heartbeat records activity on the command link. Link-loss monitoring starts
+// with the first heartbeat, so drones flown only locally never trip it.
+func (d *Drone) Heartbeat() {
+	d.linkAge = 0
+	d.linkSeen = true
+}
+
+// failsafeCondition evaluates a trigger's raw condition. Battery thresholds
+// include hysteresis once triggered.
+func (d *Drone) failsafeCondition(t FailsafeTrigger, triggered bool) bool {
+	f := &d.Failsafe
+	battery := func(threshold float64) bool {
+		if triggered {
+			return d.BatteryPercent < threshold+f.BatteryHysteresis
+		}
+		return d.BatteryPercent <= threshold
+	}
+	switch t {
+	case FailsafeLowBattery:
+		return battery(d.LowBatteryWarning)
+	case FailsafeCriticalBattery:
+		return battery(d.CriticalBattery)
+	case FailsafeSmartRTH:
+		return d.EnergyEstimate().MustReturn
+	case FailsafeGeofence:
+		return d.fenceAlert
+	case FailsafeInputLoss:
+		return d.sticks != nil && d.sticks.stats.Stale
+	case FailsafeLinkLoss:
+		return d.linkSeen && d.linkAge > f.LinkTimeout
+	case FailsafePositionLoss:
+		return d.PositionLost
+	}
+	return false
+}
+
+// failsafeAction returns the action a trigger asks for.
+func (d *Drone) failsafeAction(t FailsafeTrigger) AutoAction {
+	if t == FailsafeGeofence {
+		return d.fenceAction
+	}
+	return d.Failsafe.Rules[t].Action
+}
+
+// updateFailsafe steps every trigger's state machine, raises events for each
+// transition and hands the highest-priority action to the action layer.
+func (d *Drone) updateFailsafe(dt float64) {
+	f := &d.Failsafe
+	d.linkAge += dt
+	d.ageSticks(dt)
+	if !d.IsArmed {
+		return
+	}
+
+	for t := FailsafeTrigger(0); t < failsafeTriggerCount; t++ {
+		st := &f.state[t]
+		rule := f.Rules[t]
+		if d.failsafeCondition(t, st.triggered) {
+			st.healthy = 0
+			if st.triggered {
+				continue
+			}
+			st.pending += dt
+			if st.pending >= rule.Delay {
+				st.triggered = true
+				d.emit(Event{Kind: "failsafe.trigger", Source: t.String(), Action: d.failsafeAction(t).String()})
+			}
+			continue
+		}
+		st.pending = 0
+		if !st.triggered || rule.Latch {
+			continue
+		}
+		st.healthy += dt
+		if st.healthy >= rule.ClearDelay {
+			st.triggered = false
+			st.healthy = 0
+			d.emit(Event{Kind: "failsafe.clear", Source: t.String()})
+		}
+	}
+
+	// Highest-priority triggered condition that asks for an action
+	next := FailsafeNone
+	for t := failsafeTriggerCount - 1; t >= 0; t-- {
+		if f.state[t].triggered && d.failsafeAction(t) != ActionNone {
+			next = t
+			break
+		}
+	}
+
+	want := ActionNone
+	if next != FailsafeNone {
+		want = d.failsafeAction(next)
+		// Without a position estimate the drone can only descend in place
+		if d.PositionLost && (want == ActionHold || want == ActionReturnHome) {
+			want = ActionLand
+		}
+		// On the ground there is nowhere to go: stop the motors instead
+		if d.OnGround && want != ActionTerminate {
+			want = ActionLand
+		}
+	}
+
+	if next == f.active && want == d.action {
+		return
+	}
+	prev := f.active
+	f.active = next
+	if want != d.action {
+		if want == ActionNone {
+			d.ClearAction()
+		} else {
+			d.StartAction(want)
+		}
+	}
+	if next != prev || want != ActionNone {
+		d.emit(Event{Kind: "failsafe.action", Source: next.String(), Action: want.String(), Detail: "from " + prev.String()})
+	}
+}
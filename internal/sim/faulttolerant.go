@@ -0,0 +1,248 @@
+package sim
+
+import (
+	"math"
+	"strconv"
+)
+
+// Motor-failure detection and reduced-attitude recovery.
+//
+// With one rotor gone a quad can no longer produce roll, pitch and yaw torque
+// independently. The fault-tolerant controller gives up yaw: the three
+// remaining rotors are allocated to total thrust plus roll and pitch torque,
+// the leftover rotor drag torque spins the airframe about the vertical axis,
+// and the drone descends with its thrust axis held upright until touchdown.
+
+// Fault-tolerant control parameters
+const (
+	ftDetectLoss    = 0.5  // fraction of a motor's thrust that must be missing
+	ftDetectAlign   = 0.9  // cosine match between residual and a motor's signature
+	ftDetectTime    = 0.03 // s the residual must persist
+	ftDescentRate   = 1.0  // m/s
+	ftFinalRate     = 0.4  // m/s below ftFinalAGL
+	ftFinalAGL      = 2.0  // m
+	ftKpVert        = 3.0  // (m/s) -> m/s^2
+	ftKpAtt         = 36.0 // rad -> rad/s^2
+	ftKdAtt         = 10.0 // (rad/s) -> rad/s^2
+	ftVelDamp       = 0.5  // horizontal velocity damping (1/s)
+	ftMaxTiltDeg    = 10.0
+	ftMinTiltCosine = 0.5
+)
+
+// MotorFault describes a detected motor failure.
+type MotorFault struct {
+	Motor    int     // index into Engines, -1 when no failure is detected
+	Loss     float64 // estimated fraction of the motor's thrust missing at detection
+	Active   bool    // reduced-attitude control is flying the drone
+	YawRate  float64 // current spin rate in rad/s
+	Residual float64 // magnitude of the latest angular-acceleration residual (rad/s^2)
+}
+
+// MotorFault reports the motor-failure detector state.
+func (d *Drone) MotorFault() MotorFault {
+	return MotorFault{Motor: d.failedMotor, Loss: d.failedLoss, Active: d.ftActive, YawRate: d.AngularVel.Y, Residual: d.ftResidual}
+}
+
+// resetMotorFault clears detection state (e.g. on arming).
+func (d *Drone) resetMotorFault() {
+	d.failedMotor = -1
+	d.failedLoss = 0
+	d.ftActive = false
+	d.ftTimer = 0
+	d.ftResidual = 0
+}
+
+// motorTorqueFor returns the body torque produced by thrust f at engine e,
+// matching calculateThrustAndTorque.
+func motorTorqueFor(e Engine, f float64) Vec3 {
+	return Vec3{X: -e.Position.Z * f, Y: float64(e.Spin) * motorYawCoeff * f, Z: e.Position.X * f}
+}
+
+// detectMotorFailure compares the angular acceleration the motor commands
+// should produce (healthy motors) with the one actually achieved. A persistent
+// residual that matches one motor's signature marks that motor as lost.
+func (d *Drone) detectMotorFailure(achieved Vec3, dt float64) {
+	if !d.IsArmed || d.OnGround || d.failedMotor >= 0 || dt <= 0 || len(d.Engines) != 4 {
+		d.ftTimer = 0
+		return
+	}
+	inv := func(t Vec3) Vec3 { return Vec3{X: t.X / d.Inertia.X, Y: t.Y / d.Inertia.Y, Z: t.Z / d.Inertia.Z} }
+	res := achieved.Sub(inv(d.expectedMotorTorque))
+	d.ftResidual = res.Length()
+
+	best, bestAlign, bestLoss := -1, 0.0, 0.0
+	if rl := res.Length(); rl > 1e-6 {
+		for i, e := range d.Engines {
+			// Losing motor i removes its torque contribution
+			sig := inv(motorTorqueFor(e, d.expectedMotorThrust[i])).Mul(-1)
+			sl := sig.Length()
+			if sl < 1e-9 {
+				continue
+			}
+			align := res.Dot(sig) / (rl * sl)
+			if align > bestAlign {
+				best, bestAlign, bestLoss = i, align, res.Dot(sig)/(sl*sl)
+			}
+		}
+	}
+	if best < 0 || bestAlign < ftDetectAlign || bestLoss < ftDetectLoss {
+		d.ftTimer = 0
+		return
+	}
+	d.ftTimer += dt
+	if d.ftTimer < ftDetectTime {
+		return
+	}
+	d.failedMotor = best
+	d.failedLoss = bestLoss
+	action := "None"
+	if d.FaultTolerantControl {
+		d.ftActive = true
+		action = "ReducedAttitude"
+	}
+	d.emit(Event{Kind: "fault.motor", Source: "motor" + strconv.Itoa(best), Action: action})
+}
+
+// updateFaultTolerant flies the reduced-attitude descent by commanding
+// per-motor thrust directly.
+func (d *Drone) updateFaultTolerant(dt float64) {
+	if d.OnGround {
+		d.Disarm()
+		return
+	}
+	g := 9.81
+	ge := d.groundEffect()
+
+	// Vertical: descend at a fixed rate, slower close to the ground
+	pos, vel := d.navPosition(), d.navVelocity()
+	rot, rates := d.navRotation(), d.navAngularVel()
+	agl := pos.Y - d.groundClearance()
+	vzDes := -ftDescentRate
+	if agl < ftFinalAGL {
+		vzDes = -ftFinalRate
+	}
+	az := clamp(ftKpVert*(vzDes-vel.Y), -3, 3)
+	tiltCos := math.Max(math.Cos(rot.X)*math.Cos(rot.Z), ftMinTiltCosine)
+	thrust := d.Mass * (g + az) / tiltCos
+
+	// Thrust axis: upright, leaning gently against horizontal drift
+	ax := clamp(-ftVelDamp*vel.X, -1, 1)
+	azw := clamp(-ftVelDamp*vel.Z, -1, 1)
+	cy, sy := math.Cos(rot.Y), math.Sin(rot.Y)
+	bx := cy*ax + sy*azw
+	bz := -sy*ax + cy*azw
+	maxTilt := ftMaxTiltDeg * math.Pi / 180
+	rollT := clamp(math.Atan2(bx, g), -maxTilt, maxTilt)
+	pitchT := clamp(-math.Atan2(bz, g), -maxTilt, maxTilt)
+	tau := Vec3{
+		X: (ftKpAtt*(pitchT-rot.X) - ftKdAtt*rates.X) * d.Inertia.X,
+		Z: (ftKpAtt*(rollT-rot.Z) - ftKdAtt*rates.Z) * d.Inertia.Z,
+	}
+
+	// Allocate thrust, roll and pitch torque to the three working motors.
+	// Allocation is linear, so f = T*u + v with u the per-newton thrust split
+	// and v the torque split. Attitude comes first: take the largest thrust
+	// up to the demand that keeps every motor in range, and only back off the
+	// torque demand when no thrust level works.
+	var idx []int
+	for i := range d.Engines {
+		if i != d.failedMotor {
+			idx = append(idx, i)
+		}
+	}
+	u := allocate3(d.Engines, idx, 1, Vec3{})
+	// The motor opposite the failed one carries no share of the thrust and
+	// can only push one way, so torque it would need to pull is unreachable:
+	// project the demand back onto what it can deliver.
+	for k, i := range idx {
+		if u[k] > 1e-9 {
+			continue
+		}
+		gx := allocate3(d.Engines, idx, 0, Vec3{X: 1})[k]
+		gz := allocate3(d.Engines, idx, 0, Vec3{Z: 1})[k]
+		maxF := d.Engines[i].Efficiency * d.Engines[i].MaxThrust * ge
+		v := gx*tau.X + gz*tau.Z
+		if want := clamp(v, 0, maxF); want != v && gx*gx+gz*gz > 0 {
+			c := (v - want) / (gx*gx + gz*gz)
+			tau.X -= c * gx
+			tau.Z -= c * gz
+		}
+	}
+	var f [3]float64
+	solved := false
+	for attempt := 0; attempt < 6 && !solved; attempt++ {
+		v := allocate3(d.Engines, idx, 0, tau)
+		lo, hi := 0.0, thrust
+		for k, i := range idx {
+			maxF := d.Engines[i].Efficiency * d.Engines[i].MaxThrust * ge
+			if u[k] <= 1e-9 {
+				continue
+			}
+			// 0 <= T*u + v <= maxF
+			lo = math.Max(lo, -v[k]/u[k])
+			hi = math.Min(hi, (maxF-v[k])/u[k])
+		}
+		if lo <= hi {
+			for k := range f {
+				f[k] = hi*u[k] + v[k]
+			}
+			solved = true
+			continue
+		}
+		tau = tau.Mul(0.5)
+	}
+	// Still out of range: fly the backed-off torque and let the motor
+	// commands saturate
+	if !solved {
+		v := allocate3(d.Engines, idx, 0, tau)
+		for k := range f {
+			f[k] = thrust*u[k] + v[k]
+		}
+	}
+	for i := range d.motorCmd {
+		d.motorCmd[i] = 0
+	}
+	sum := 0.0
+	for k, i := range idx {
+		e := d.Engines[i]
+		maxF := e.Efficiency * e.MaxThrust * ge
+		if maxF <= 0 {
+			continue
+		}
+		d.motorCmd[i] = clamp(f[k]/maxF, 0, 1)
+		sum += d.motorCmd[i]
+	}
+	// Report an equivalent throttle so HUD/audio stay meaningful
+	d.ThrottlePercent = math.Sqrt(sum/3) * 100
+}
+
+// allocate3 solves for the thrust of engines idx[0..2] that produce total
+// thrust t and body torques tau.X/tau.Z (Cramer's rule).
+func allocate3(engines []Engine, idx []int, t float64, tau Vec3) [3]float64 {
+	var a [3][3]float64
+	for k, i := range idx {
+		e := engines[i]
+		a[0][k] = 1
+		a[1][k] = -e.Position.Z
+		a[2][k] = e.Position.X
+	}
+	b := [3]float64{t, tau.X, tau.Z}
+	det := func(m [3][3]float64) float64 {
+		return m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+			m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+			m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+	}
+	D := det(a)
+	var out [3]float64
+	if math.Abs(D) < 1e-12 {
+		return out
+	}
+	for k := 0; k < 3; k++ {
+		m := a
+		for r := 0; r < 3; r++ {
+			m[r][k] = b[r]
+		}
+		out[k] = det(m) / D
+	}
+	return out
+}
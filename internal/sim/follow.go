@@ -0,0 +1,188 @@
+package sim
+
+import (
+	"errors"
+	"math"
+)
+
+// Follow-me: the drone keeps station relative to a moving Target. The
+// station is an offset in the target's frame (so "behind and to the right"
+// turns with the target) at a height above it; the target's velocity is fed
+// forward along with its acceleration, which is projected Lead seconds ahead
+// so the drone anticipates turns instead of lagging them.
+
+// FollowHeading selects where the nose points while following.
+type FollowHeading int
+
+const (
+	FollowFaceTarget   FollowHeading = iota // nose on the target
+	FollowTargetCourse                      // same heading as the target
+	FollowFixedHeading                      // FollowConfig.Yaw
+)
+
+func (h FollowHeading) String() string {
+	switch h {
+	case FollowTargetCourse:
+		return "Course"
+	case FollowFixedHeading:
+		return "Fixed"
+	}
+	return "Face"
+}
+
+// Follow defaults
+const (
+	followDefaultAltitude = 10.0 // m above the target
+	followDefaultLead     = 0.5  // s
+	followMinFaceRange    = 1.0  // m, below which a face-target heading is held
+)
+
+// FollowConfig describes how to follow a target.
+type FollowConfig struct {
+	Target      *Target
+	Offset      Vec3    // station relative to the target: X right, Z ahead (Y unused)
+	WorldOffset bool    // Offset is in world axes instead of the target's frame
+	Altitude    float64 // m above the target (0 = default)
+	Heading     FollowHeading
+	Yaw         float64 // FollowFixedHeading
+	Lead        float64 // s of acceleration lead (0 = default, <0 = none)
+	MaxSpeed    float64 // horizontal speed limit in m/s (0 = drone MaxSpeed)
+}
+
+// FollowStatus reports how well the drone is keeping station.
+type FollowStatus struct {
+	Target        string
+	Station       Vec3    // where the drone should be now
+	Error         Vec3    // Station minus drone position
+	ErrorDistance float64 // |Error| in m
+	Range         float64 // distance from the drone to the target
+	Lost          bool    // external target stopped reporting; holding position
+}
+
+type followState struct {
+	cfg     FollowConfig
+	lost    bool
+	holdPos Vec3
+	holdYaw float64
+}
+
+// StartFollow switches the drone to FlightModeFollow.
+func (d *Drone) StartFollow(cfg FollowConfig) error {
+	if !d.IsArmed {
+		return errors.New("follow: drone is not armed")
+	}
+	if cfg.Target == nil {
+		return errors.New("follow: no target")
+	}
+	if d.action != ActionNone || d.ftActive {
+		return errors.New("follow: a safety action is in control")
+	}
+	d.follow = &followState{}
+	d.SetFollow(cfg)
+	d.SetFlightMode(FlightModeFollow)
+	return nil
+}
+
+// SetFollow changes the follow parameters while following.
+func (d *Drone) SetFollow(cfg FollowConfig) {
+	if d.follow == nil || cfg.Target == nil {
+		return
+	}
+	if cfg.Altitude <= 0 {
+		cfg.Altitude = followDefaultAltitude
+	}
+	if cfg.Lead == 0 {
+		cfg.Lead = followDefaultLead
+	}
+	d.follow.cfg = cfg
+}
+
+// FollowConfig returns the active follow parameters and whether the drone is
+// following.
+func (d *Drone) FollowConfig() (FollowConfig, bool) {
+	if d.follow == nil || d.FlightMode != FlightModeFollow {
+		return FollowConfig{}, false
+	}
+	return d.follow.cfg, true
+}
+
+// FollowStatus returns the tracking state and whether the drone is following.
+func (d *Drone) FollowStatus() (FollowStatus, bool) {
+	if d.follow == nil || d.FlightMode != FlightModeFollow {
+		return FollowStatus{}, false
+	}
+	cfg := d.follow.cfg
+	station := followStation(cfg)
+	e := station.Sub(d.navPosition())
+	return FollowStatus{
+		Target:        cfg.Target.Name,
+		Station:       station,
+		Error:         e,
+		ErrorDistance: e.Length(),
+		Range:         cfg.Target.Position.Sub(d.navPosition()).Length(),
+		Lost:          d.follow.lost,
+	}, true
+}
+
+// followStation returns the point the drone should occupy for cfg.
+func followStation(cfg FollowConfig) Vec3 {
+	t := cfg.Target
+	off := cfg.Offset
+	if !cfg.WorldOffset {
+		// Target frame to world: X right -> (-cos, -sin), Z ahead -> (-sin, cos)
+		c, s := math.Cos(t.Heading), math.Sin(t.Heading)
+		off = Vec3{X: -off.X*c - off.Z*s, Z: -off.X*s + off.Z*c}
+	}
+	return Vec3{X: t.Position.X + off.X, Y: t.Position.Y + cfg.Altitude, Z: t.Position.Z + off.Z}
+}
+
+// updateFollow keeps station on the target, or holds position while an
+// external target has stopped reporting.
+func (d *Drone) updateFollow(dt float64) {
+	f := d.follow
+	if f == nil || f.cfg.Target == nil {
+		d.SetFlightMode(FlightModeHover)
+		return
+	}
+	cfg := f.cfg
+	t := cfg.Target
+	if lost := t.Lost(); lost != f.lost {
+		f.lost = lost
+		if lost {
+			f.holdPos, f.holdYaw = d.navPosition(), d.navRotation().Y
+			d.emit(Event{Kind: "follow.lost", Source: t.Name, Action: "Hold"})
+		} else {
+			d.emit(Event{Kind: "follow.regained", Source: t.Name})
+		}
+	}
+	if f.lost {
+		d.trackSetpoint(holdSetpoint(f.holdPos, f.holdYaw), dt)
+		return
+	}
+
+	station := followStation(cfg)
+	acc := t.Acceleration()
+	if cfg.Lead > 0 {
+		// Phase lead: the smoothed estimate and the tilt response both lag
+		acc = acc.Add(t.Jerk().Mul(cfg.Lead))
+	}
+	yaw := d.navRotation().Y
+	switch cfg.Heading {
+	case FollowFaceTarget:
+		if horizontalDistance(d.navPosition(), t.Position) > followMinFaceRange {
+			yaw = headingTo(d.navPosition(), t.Position)
+		}
+	case FollowTargetCourse:
+		yaw = t.Heading
+	case FollowFixedHeading:
+		yaw = cfg.Yaw
+	}
+	d.trackSetpoint(NavSetpoint{
+		Position:     station,
+		Velocity:     Vec3{X: t.Velocity.X, Z: t.Velocity.Z},
+		Acceleration: Vec3{X: acc.X, Z: acc.Z},
+		Yaw:          yaw,
+		MaxSpeed:     cfg.MaxSpeed,
+		ClimbRate:    math.Abs(t.Velocity.Y) + navClimbRate,
+	}, dt)
+}
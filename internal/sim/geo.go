@@ -0,0 +1,135 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// GeoPoint is a WGS84 position: latitude and longitude in degrees, altitude
+// in meters above mean sea level.
+type GeoPoint struct {
+	Lat, Lon, Alt float64
+}
+
+// WGS84 ellipsoid
+const (
+	wgs84A  = 6378137.0
+	wgs84E2 = 6.69437999014e-3
+)
+
+// DefaultOrigin anchors worlds that were not given an origin (the usual
+// ArduPilot SITL home).
+var DefaultOrigin = GeoPoint{Lat: -35.3632621, Lon: 149.1652374, Alt: 584}
+
+// The sim's local frame is a right-handed tangent plane at a GeoPoint
+// origin: +X east, +Y up, +Z south. A yaw of 0 points the nose south and
+// positive yaw turns it clockwise seen from above (toward west), so compass
+// heading = yaw + 180°.
+
+// Validate reports a latitude or longitude out of range.
+func (p GeoPoint) Validate() error {
+	if math.IsNaN(p.Lat) || math.Abs(p.Lat) > 90 {
+		return fmt.Errorf("latitude %v out of range", p.Lat)
+	}
+	if math.IsNaN(p.Lon) || math.Abs(p.Lon) > 180 {
+		return fmt.Errorf("longitude %v out of range", p.Lon)
+	}
+	if math.IsNaN(p.Alt) {
+		return fmt.Errorf("altitude is NaN")
+	}
+	return nil
+}
+
+// ParseGeoPoint parses "lat,lon[,alt]" in degrees and meters MSL.
+func ParseGeoPoint(s string) (GeoPoint, error) {
+	f := strings.Split(s, ",")
+	if len(f) < 2 || len(f) > 3 {
+		return GeoPoint{}, fmt.Errorf("geo point %q: want lat,lon[,alt]", s)
+	}
+	var v [3]float64
+	for i, x := range f {
+		n, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		if err != nil {
+			return GeoPoint{}, fmt.Errorf("geo point %q: %w", s, err)
+		}
+		v[i] = n
+	}
+	p := GeoPoint{Lat: v[0], Lon: v[1], Alt: v[2]}
+	if err := p.Validate(); err != nil {
+		return GeoPoint{}, fmt.Errorf("geo point %q: %w", s, err)
+	}
+	return p, nil
+}
+
+// LocalToENU reorders a local vector as east, north, up in X, Y, Z.
+func LocalToENU(v Vec3) Vec3 { return Vec3{X: v.X, Y: -v.Z, Z: v.Y} }
+
+// ENUToLocal is the inverse of LocalToENU.
+func ENUToLocal(v Vec3) Vec3 { return Vec3{X: v.X, Y: v.Z, Z: -v.Y} }
+
+// LocalToNED reorders a local vector as north, east, down in X, Y, Z, the
+// frame of MAVLink and most flight stacks.
+func LocalToNED(v Vec3) Vec3 { return Vec3{X: -v.Z, Y: v.X, Z: -v.Y} }
+
+// NEDToLocal is the inverse of LocalToNED.
+func NEDToLocal(v Vec3) Vec3 { return Vec3{X: v.Y, Y: -v.Z, Z: -v.X} }
+
+// ToNED maps p to north, east, down meters from o.
+func (o GeoPoint) ToNED(p GeoPoint) Vec3 { return LocalToNED(o.ToLocal(p)) }
+
+// FromNED is the inverse of ToNED.
+func (o GeoPoint) FromNED(ned Vec3) GeoPoint { return o.ToGeo(NEDToLocal(ned)) }
+
+// radii returns the meridian and prime-vertical radii of curvature at the
+// origin latitude.
+func (o GeoPoint) radii() (float64, float64) {
+	s := math.Sin(DegToRad(o.Lat))
+	w := 1 - wgs84E2*s*s
+	rn := wgs84A / math.Sqrt(w)
+	rm := rn * (1 - wgs84E2) / w
+	return rm, rn
+}
+
+// ToLocal maps p into the local frame anchored at o. The flat-earth
+// approximation is accurate to centimeters over a few kilometers.
+func (o GeoPoint) ToLocal(p GeoPoint) Vec3 {
+	rm, rn := o.radii()
+	north := DegToRad(p.Lat-o.Lat) * (rm + o.Alt)
+	east := DegToRad(p.Lon-o.Lon) * (rn + o.Alt) * math.Cos(DegToRad(o.Lat))
+	return Vec3{X: east, Y: p.Alt - o.Alt, Z: -north}
+}
+
+// ToGeo is the inverse of ToLocal.
+func (o GeoPoint) ToGeo(v Vec3) GeoPoint {
+	rm, rn := o.radii()
+	lat := o.Lat + RadToDeg(-v.Z/(rm+o.Alt))
+	lon := o.Lon + RadToDeg(v.X/((rn+o.Alt)*math.Cos(DegToRad(o.Lat))))
+	return GeoPoint{Lat: lat, Lon: lon, Alt: o.Alt + v.Y}
+}
+
+// HeadingToYaw converts a compass heading in degrees (clockwise from north)
+// to a sim yaw in radians in [-pi, pi].
+func HeadingToYaw(heading float64) float64 {
+	return math.Remainder(DegToRad(heading-180), 2*math.Pi)
+}
+
+// YawToHeading converts a sim yaw in radians to a compass heading in [0, 360).
+func YawToHeading(yaw float64) float64 {
+	h := math.Mod(RadToDeg(yaw)+180, 360)
+	if h < 0 {
+		h += 360
+	}
+	return h
+}
+
+// Geo returns the drone's true WGS84 position.
+func (d *Drone) Geo() GeoPoint {
+	return d.World.GeoOrigin().ToGeo(d.Position)
+}
+
+// Heading returns the drone's true compass heading in degrees.
+func (d *Drone) Heading() float64 {
+	return YawToHeading(d.Rotation.Y)
+}
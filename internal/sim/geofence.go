@@ -0,0 +1,182 @@
+package sim
+
+import (
+	"math"
+)
+
+// FenceShape selects the horizontal outline of a geofence.
+type FenceShape int
+
+const (
+	FenceCylinder FenceShape = iota
+	FencePolygon
+)
+
+// Geofence is a vertical prism the drone must stay inside (inclusion) or out
+// of (exclusion). On breach the drone runs Action; ActionNone only warns.
+type Geofence struct {
+	Name      string
+	Shape     FenceShape
+	Inclusion bool
+	Center    Vec3    // Cylinder centre (X/Z used)
+	Radius    float64 // Cylinder radius in meters
+	Polygon   []Vec3  // Polygon vertices in X/Z, either winding
+	Floor     float64 // Bottom of the volume in meters
+	Ceiling   float64 // Top of the volume in meters (0 = unbounded)
+	Action    AutoAction
+}
+
+// Fence check parameters
+const (
+	fenceReactionTime = 0.5 // s of travel assumed before braking starts
+	fenceMaxDrawTop   = 60  // m, where unbounded fences are drawn up to
+)
+
+type fenceStatus uint8
+
+const (
+	fenceClear fenceStatus = iota
+	fencePredicted
+	fenceBreached
+)
+
+// Contains reports whether p lies inside the fence volume.
+func (f *Geofence) Contains(p Vec3) bool {
+	if p.Y < f.Floor || (f.Ceiling > 0 && p.Y > f.Ceiling) {
+		return false
+	}
+	switch f.Shape {
+	case FenceCylinder:
+		return horizontalDistance(p, f.Center) <= f.Radius
+	case FencePolygon:
+		return pointInPolygon(p, f.Polygon)
+	}
+	return false
+}
+
+// Violated reports whether being at p breaks the fence.
+func (f *Geofence) Violated(p Vec3) bool {
+	return f.Contains(p) != f.Inclusion
+}
+
+// pointInPolygon is an even-odd ray cast in the X/Z plane.
+func pointInPolygon(p Vec3, poly []Vec3) bool {
+	inside := false
+	for i, j := 0, len(poly)-1; i < len(poly); j, i = i, i+1 {
+		a, b := poly[i], poly[j]
+		if (a.Z > p.Z) != (b.Z > p.Z) && p.X < (b.X-a.X)*(p.Z-a.Z)/(b.Z-a.Z)+a.X {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// stoppingPoint predicts where the drone comes to rest if it starts braking
+// after the reaction time at the position controller's deceleration limit.
+func (d *Drone) stoppingPoint() Vec3 {
+	v := d.Velocity.Length()
+	if v < 1e-3 {
+		return d.Position
+	}
+	dist := v*fenceReactionTime + v*v/(2*navMaxAccel)
+	return d.Position.Add(d.Velocity.Mul(dist / v))
+}
+
+// updateGeofences checks every fence against the current position and the
+// predicted stopping point and raises an event on each state change. It does
+// not act itself: it sets fenceAlert/fenceAction for the geofence failsafe
+// trigger. A predicted breach of a Hold fence already asks for Hold so the
+// drone stops short of the boundary. While the trigger stays latched the
+// requested action only escalates.
+func (d *Drone) updateGeofences() {
+	if !d.IsArmed || len(d.Geofences) == 0 {
+		d.fenceAlert = false
+		return
+	}
+	if d.fenceStatus == nil {
+		d.fenceStatus = make(map[*Geofence]fenceStatus)
+	}
+	stop := d.stoppingPoint()
+	alert, action := false, ActionNone
+	for _, f := range d.Geofences {
+		st := fenceClear
+		if f.Violated(d.Position) {
+			st = fenceBreached
+		} else if f.Violated(stop) {
+			st = fencePredicted
+		}
+
+		switch st {
+		case fenceBreached:
+			alert = true
+			action = moreSevere(action, f.Action)
+		case fencePredicted:
+			if f.Action == ActionHold {
+				alert = true
+				action = moreSevere(action, ActionHold)
+			}
+		}
+
+		if st == d.fenceStatus[f] {
+			continue
+		}
+		d.fenceStatus[f] = st
+		switch st {
+		case fenceBreached:
+			d.emit(Event{Kind: "geofence.breach", Source: f.Name, Action: f.Action.String()})
+		case fencePredicted:
+			d.emit(Event{Kind: "geofence.predicted", Source: f.Name, Detail: "stopping point outside fence"})
+		case fenceClear:
+			d.emit(Event{Kind: "geofence.clear", Source: f.Name})
+		}
+	}
+	if d.Failsafe.Triggered(FailsafeGeofence) {
+		action = moreSevere(action, d.fenceAction)
+	}
+	d.fenceAlert = alert
+	d.fenceAction = action
+}
+
+// FenceBreached reports whether the drone is currently outside any fence.
+func (d *Drone) FenceBreached() bool {
+	for _, st := range d.fenceStatus {
+		if st == fenceBreached {
+			return true
+		}
+	}
+	return false
+}
+
+// wireframe returns line segments (pairs of points) outlining the fence.
+func (f *Geofence) wireframe() []Vec3 {
+	top := f.Ceiling
+	if top <= 0 {
+		top = math.Max(f.Floor+fenceMaxDrawTop, fenceMaxDrawTop)
+	}
+	var ring []Vec3
+	switch f.Shape {
+	case FenceCylinder:
+		const n = 48
+		for i := 0; i < n; i++ {
+			a := 2 * math.Pi * float64(i) / n
+			ring = append(ring, Vec3{X: f.Center.X + f.Radius*math.Cos(a), Z: f.Center.Z + f.Radius*math.Sin(a)})
+		}
+	case FencePolygon:
+		ring = f.Polygon
+	}
+	lines := make([]Vec3, 0, len(ring)*6)
+	postEvery := 1
+	if len(ring) > 8 {
+		postEvery = len(ring) / 8
+	}
+	for i, a := range ring {
+		b := ring[(i+1)%len(ring)]
+		lo := Vec3{X: a.X, Y: f.Floor, Z: a.Z}
+		hi := Vec3{X: a.X, Y: top, Z: a.Z}
+		lines = append(lines, lo, Vec3{X: b.X, Y: f.Floor, Z: b.Z}, hi, Vec3{X: b.X, Y: top, Z: b.Z})
+		if i%postEvery == 0 {
+			lines = append(lines, lo, hi)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,141 @@
+package sim
+
+import "math"
+
+// GimbalMode is how the gimbal chooses where the camera points.
+type GimbalMode int
+
+const (
+	GimbalFollow GimbalMode = iota // pitch and roll held to the horizon, yaw follows the nose
+	GimbalLock                     // all three axes held in the world
+	GimbalROI                      // aimed at a region of interest
+	GimbalBody                     // joints held at the command, camera moves with the airframe
+)
+
+func (m GimbalMode) String() string {
+	switch m {
+	case GimbalFollow:
+		return "Follow"
+	case GimbalLock:
+		return "Lock"
+	case GimbalROI:
+		return "ROI"
+	case GimbalBody:
+		return "Body"
+	}
+	return "Unknown"
+}
+
+// Gimbal is a 3-axis camera mount. Its joints turn the camera from the body
+// by Euler angles like Drone.Rotation (pitch X, positive up; yaw Y; roll Z),
+// each within MinAngle..MaxAngle and at no more than MaxRate, so fast
+// airframe motion leaks into the picture until the joints catch up. The
+// camera looks along its own +Z with +Y up.
+type Gimbal struct {
+	Offset Vec3 // camera position from the drone's centre, body axes
+	Mode   GimbalMode
+
+	// Command is the camera attitude wanted: in the world for GimbalLock,
+	// pitch and roll in the world and yaw from the nose for GimbalFollow,
+	// joint angles for GimbalBody. GimbalROI aims at ROITarget when set, else
+	// at ROI.
+	Command   Vec3
+	ROI       Vec3
+	ROITarget *Target
+
+	MinAngle Vec3    // rad joint limits
+	MaxAngle Vec3    // rad
+	MaxRate  Vec3    // rad/s joint slew rates
+	FOV      float64 // rad vertical field of view of the camera
+
+	joint Vec3
+}
+
+// DefaultGimbal returns a small camera gimbal under the nose, horizon-level
+// and looking ahead.
+func DefaultGimbal() Gimbal {
+	return Gimbal{
+		Offset:   Vec3{Y: -0.06, Z: 0.12},
+		MinAngle: Vec3{X: -90 * math.Pi / 180, Y: -170 * math.Pi / 180, Z: -40 * math.Pi / 180},
+		MaxAngle: Vec3{X: 30 * math.Pi / 180, Y: 170 * math.Pi / 180, Z: 40 * math.Pi / 180},
+		MaxRate:  Vec3{X: 120 * math.Pi / 180, Y: 120 * math.Pi / 180, Z: 90 * math.Pi / 180},
+		FOV:      60 * math.Pi / 180,
+	}
+}
+
+// Joint returns the joint angles the gimbal holds.
+func (g *Gimbal) Joint() Vec3 { return g.joint }
+
+// Pose returns the camera's world position and attitude (Euler angles like
+// Drone.Rotation) on d.
+func (g *Gimbal) Pose(d *Drone) (Vec3, Vec3) {
+	pos, rot := g.pose(d)
+	return pos, rot.euler()
+}
+
+func (g *Gimbal) pose(d *Drone) (Vec3, mat3) {
+	body := mat3FromEuler(d.Rotation)
+	return d.Position.Add(body.mulVec(g.Offset)), body.mul(mat3FromEuler(g.joint))
+}
+
+// PointAt aims the camera at a world point.
+func (g *Gimbal) PointAt(roi Vec3) {
+	g.Mode, g.ROI, g.ROITarget = GimbalROI, roi, nil
+}
+
+// lookRotation returns the attitude, without roll, looking along dir.
+func lookRotation(dir Vec3) Vec3 {
+	dir = dir.Normalize()
+	return Vec3{X: math.Asin(clamp(dir.Y, -1, 1)), Y: -math.Atan2(dir.X, dir.Z)}
+}
+
+// update slews the joints toward the attitude the mode asks for.
+func (g *Gimbal) update(d *Drone, dt float64) {
+	body := mat3FromEuler(d.Rotation)
+	var want Vec3 // joint angles
+	switch g.Mode {
+	case GimbalBody:
+		want = g.Command
+	default:
+		var world Vec3
+		switch g.Mode {
+		case GimbalFollow:
+			world = Vec3{X: g.Command.X, Y: d.Rotation.Y + g.Command.Y, Z: g.Command.Z}
+		case GimbalLock:
+			world = g.Command
+		case GimbalROI:
+			roi := g.ROI
+			if g.ROITarget != nil {
+				roi = g.ROITarget.Position
+			}
+			pos := d.Position.Add(body.mulVec(g.Offset))
+			if roi.Sub(pos).Length() < 1e-6 {
+				return
+			}
+			world = lookRotation(roi.Sub(pos))
+		}
+		want = body.transpose().mul(mat3FromEuler(world)).euler()
+	}
+	want = Vec3{
+		X: clamp(want.X, g.MinAngle.X, g.MaxAngle.X),
+		Y: clamp(want.Y, g.MinAngle.Y, g.MaxAngle.Y),
+		Z: clamp(want.Z, g.MinAngle.Z, g.MaxAngle.Z),
+	}
+	// The joints have stops, so they turn the long way rather than wrap
+	slew := func(cur, target, rate float64) float64 {
+		if rate <= 0 {
+			return target
+		}
+		return cur + clamp(target-cur, -rate*dt, rate*dt)
+	}
+	g.joint = Vec3{
+		X: slew(g.joint.X, want.X, g.MaxRate.X),
+		Y: slew(g.joint.Y, want.Y, g.MaxRate.Y),
+		Z: slew(g.joint.Z, want.Z, g.MaxRate.Z),
+	}
+}
+
+// updateGimbal points the camera for the attitude this step produced.
+func (d *Drone) updateGimbal(dt float64) {
+	d.Gimbal.update(d, dt)
+}
@@ -0,0 +1,239 @@
+package sim
+
+import (
+	"math"
+	"math/rand"
+)
+
+// GNSSFix is the quality of a GNSS solution.
+type GNSSFix int
+
+const (
+	GNSSNoFix GNSSFix = iota
+	GNSSFix2D         // horizontal only; altitude is the last 3D value
+	GNSSFix3D
+)
+
+func (f GNSSFix) String() string {
+	switch f {
+	case GNSSFix2D:
+		return "2D"
+	case GNSSFix3D:
+		return "3D"
+	}
+	return "None"
+}
+
+// GNSSOutage blanks the receiver from Start to End seconds of simulation
+// (End 0: until further notice) and, with a Radius, only within that
+// horizontal distance of Center: a jammer, a tunnel or a hangar.
+type GNSSOutage struct {
+	Start, End float64
+	Center     Vec3
+	Radius     float64
+}
+
+func (o GNSSOutage) covers(t float64, p Vec3) bool {
+	if t < o.Start || (o.End > 0 && t >= o.End) {
+		return false
+	}
+	return o.Radius <= 0 || horizontalDistance(p, o.Center) <= o.Radius
+}
+
+// GNSS is a simulated satellite receiver. Position error is a first-order
+// Gauss-Markov process scaled by the dilution of precision; obstacles taller
+// than the drone within CanyonRange hide part of the sky (an urban canyon),
+// cutting the satellite count, raising the DOP and adding multipath error.
+// Each fix is delivered Latency seconds after it was taken.
+type GNSS struct {
+	Rate            float64 // Hz; 0 disables the receiver
+	Latency         float64 // s from measurement to output
+	HorizontalError float64 // m, 1σ per axis in open sky
+	VerticalError   float64 // m, 1σ in open sky
+	CorrelationTime float64 // s of the Gauss-Markov error
+	VelocityNoise   float64 // m/s, 1σ per axis
+	Satellites      int     // tracked in open sky
+	HDOP, VDOP      float64 // in open sky
+	CanyonRange     float64 // m; obstacles within it can mask satellites (0 = off)
+	CanyonMask      float64 // rad elevation below which satellites are ignored anyway
+	Multipath       float64 // m, 1σ extra error with the sky fully masked
+	Outages         []GNSSOutage
+	Faults          FaultSchedule
+
+	rng     *rand.Rand
+	err     Vec3 // Gauss-Markov position error
+	age     float64
+	pending []GNSSReading
+	reading GNSSReading
+	alt     float64 // last 3D altitude, held through 2D fixes
+}
+
+// GNSSReading is one receiver output.
+type GNSSReading struct {
+	Time       float64 // s of simulation when the fix was taken
+	Fix        GNSSFix
+	Satellites int
+	HDOP, VDOP float64
+	Position   Vec3     // local frame
+	Geo        GeoPoint // the same position in WGS84
+	Velocity   Vec3     // local frame, m/s
+	Count      int      // fixes output since the receiver started
+}
+
+// DefaultGNSS returns a consumer GPS/GLONASS receiver at 5 Hz.
+func DefaultGNSS() GNSS {
+	return GNSS{
+		Rate:            5,
+		Latency:         0.1,
+		HorizontalError: 1.0,
+		VerticalError:   2.0,
+		CorrelationTime: 60,
+		VelocityNoise:   0.05,
+		Satellites:      14,
+		HDOP:            0.8,
+		VDOP:            1.2,
+		CanyonRange:     50,
+		CanyonMask:      10 * math.Pi / 180,
+		Multipath:       3,
+	}
+}
+
+// Reading returns the latest fix delivered, which is Latency old.
+func (g *GNSS) Reading() GNSSReading { return g.reading }
+
+// Error returns the receiver's current Gauss-Markov position error, for
+// judging an estimator against it.
+func (g *GNSS) Error() Vec3 { return g.err }
+
+// gnssSkyRays is how many azimuths the sky is sampled at for masking.
+const gnssSkyRays = 32
+
+// skyMask returns the fraction of the sky's satellites hidden from p by
+// obstacles within reach. Along each azimuth the nearest wall taller than p
+// hides the satellites below its top edge; satellites are spread evenly in
+// elevation above CanyonMask.
+func (g *GNSS) skyMask(p Vec3, w *World) float64 {
+	if g.CanyonRange <= 0 || w == nil {
+		return 0
+	}
+	sum := 0.0
+	for i := 0; i < gnssSkyRays; i++ {
+		a := 2 * math.Pi * (float64(i) + 0.5) / gnssSkyRays
+		dx, dz := math.Sin(a), math.Cos(a)
+		hidden := 0.0
+		for _, o := range w.Obstacles {
+			if o.Max.Y <= p.Y {
+				continue
+			}
+			dist, ok := rayBox2D(p.X, p.Z, dx, dz, o)
+			if !ok || dist > g.CanyonRange {
+				continue
+			}
+			elev := math.Atan2(o.Max.Y-p.Y, math.Max(dist, 0.1))
+			hidden = math.Max(hidden, clamp((elev-g.CanyonMask)/(math.Pi/2-g.CanyonMask), 0, 1))
+		}
+		sum += hidden
+	}
+	return sum / gnssSkyRays
+}
+
+// rayBox2D returns the distance along the horizontal ray from x/z in
+// direction dx/dz to o's footprint (0 when inside it), and whether it hits.
+func rayBox2D(x, z, dx, dz float64, o Obstacle) (float64, bool) {
+	near, far := 0.0, math.Inf(1)
+	for _, ax := range [2][4]float64{{x, dx, o.Min.X, o.Max.X}, {z, dz, o.Min.Z, o.Max.Z}} {
+		p, d, lo, hi := ax[0], ax[1], ax[2], ax[3]
+		if math.Abs(d) < 1e-12 {
+			if p < lo || p > hi {
+				return 0, false
+			}
+			continue
+		}
+		t0, t1 := (lo-p)/d, (hi-p)/d
+		if t0 > t1 {
+			t0, t1 = t1, t0
+		}
+		near, far = math.Max(near, t0), math.Min(far, t1)
+	}
+	return near, near <= far
+}
+
+// update takes the fixes due in the dt up to now and releases the ones whose
+// latency has passed.
+func (g *GNSS) update(seed int64, now, dt float64, pos, vel Vec3, w *World) {
+	if g.Rate <= 0 {
+		return
+	}
+	if g.rng == nil {
+		g.rng = sensorRand(seed, "gnss")
+		g.err = Vec3{X: g.rng.NormFloat64() * g.HorizontalError, Y: g.rng.NormFloat64() * g.VerticalError, Z: g.rng.NormFloat64() * g.HorizontalError}
+		g.alt = pos.Y
+		g.Faults.init(seed, "gnss")
+	}
+	period := 1 / g.Rate
+	for g.age += dt; g.age >= period; {
+		g.age -= period
+		r, ok := applyFaults(&g.Faults, period, g.measure(now-g.age, period, pos, vel, w))
+		if !ok {
+			continue
+		}
+		if r.Fix != GNSSNoFix {
+			r.Geo = w.GeoOrigin().ToGeo(r.Position)
+		}
+		g.pending = append(g.pending, r)
+	}
+	for len(g.pending) > 0 && g.pending[0].Time+g.Latency <= now+1e-9 {
+		r := g.pending[0]
+		r.Count = g.reading.Count + 1
+		g.reading = r
+		g.pending = g.pending[1:]
+	}
+}
+
+// measure takes one fix at time t, period seconds after the last.
+func (g *GNSS) measure(t, period float64, pos, vel Vec3, w *World) GNSSReading {
+	// Gauss-Markov error: e ← e·φ + σ·√(1-φ²)·n keeps the variance at σ²
+	phi := 0.0
+	if g.CorrelationTime > 0 {
+		phi = math.Exp(-period / g.CorrelationTime)
+	}
+	q := math.Sqrt(1 - phi*phi)
+	g.err = Vec3{
+		X: g.err.X*phi + g.HorizontalError*q*g.rng.NormFloat64(),
+		Y: g.err.Y*phi + g.VerticalError*q*g.rng.NormFloat64(),
+		Z: g.err.Z*phi + g.HorizontalError*q*g.rng.NormFloat64(),
+	}
+
+	r := GNSSReading{Time: t}
+	for _, o := range g.Outages {
+		if o.covers(t, pos) {
+			return r
+		}
+	}
+	mask := g.skyMask(pos, w)
+	r.Satellites = int(math.Round(float64(g.Satellites) * (1 - mask)))
+	switch {
+	case r.Satellites >= 4:
+		r.Fix = GNSSFix3D
+	case r.Satellites == 3:
+		r.Fix = GNSSFix2D
+	default:
+		return r
+	}
+	// Fewer satellites weaken the geometry
+	geom := math.Sqrt(float64(g.Satellites) / float64(r.Satellites))
+	r.HDOP, r.VDOP = g.HDOP*geom, g.VDOP*geom
+	r.Position = pos.Add(g.err.Mul(geom)).Add(gaussVec(g.rng, g.Multipath*mask))
+	if r.Fix == GNSSFix2D {
+		r.Position.Y = g.alt
+	} else {
+		g.alt = r.Position.Y
+	}
+	r.Velocity = vel.Add(gaussVec(g.rng, g.VelocityNoise*geom))
+	return r
+}
+
+// updateGNSS feeds the receiver the drone's true motion.
+func (d *Drone) updateGNSS(dt float64) {
+	d.GNSS.update(d.Seed, d.clock, dt, d.Position, d.Velocity, d.World)
+}
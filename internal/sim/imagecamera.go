@@ -0,0 +1,61 @@
+package sim
+
+import "math"
+
+// ImageCamera renders the drone's view with World.Render at Rate: from the gimbal by default,
+// or from a fixed Mount with its own FOV.
+type ImageCamera struct {
+	Mount    *SensorMount // nil = the gimbal, with its FOV
+	FOV      float64      // rad vertical field of view of a fixed mount
+	Width    int          // pixels
+	Height   int
+	Rate     float64 // frames per second
+	MaxRange float64 // m
+
+	age   float64
+	image *CameraImage
+	count int
+}
+
+// DefaultImageCamera returns a 320×240 camera on the gimbal at 5 Hz.
+func DefaultImageCamera() ImageCamera {
+	return ImageCamera{FOV: 60 * math.Pi / 180, Width: 320, Height: 240, Rate: 5, MaxRange: 200}
+}
+
+// Image returns the newest frame, or nil before the first.
+func (c *ImageCamera) Image() *CameraImage { return c.image }
+
+// View returns the camera's pose and intrinsics on d.
+func (c *ImageCamera) View(d *Drone) CameraView {
+	view := CameraView{Width: c.Width, Height: c.Height, MaxRange: c.MaxRange}
+	if c.Mount != nil {
+		pos, rot := c.Mount.pose(d)
+		view.Position, view.Rotation, view.FOV = pos, rot.euler(), c.FOV
+	} else {
+		view.Position, view.Rotation = d.Gimbal.Pose(d)
+		view.FOV = d.Gimbal.FOV
+	}
+	return view
+}
+
+// Capture renders a frame now, whatever the rate, and keeps it as the newest.
+func (c *ImageCamera) Capture(d *Drone) *CameraImage {
+	img := d.World.Render(c.View(d), d)
+	if img == nil {
+		return nil
+	}
+	c.count++
+	img.Time, img.Count = d.clock, c.count
+	c.image = img
+	return img
+}
+
+// updateImageCamera renders a frame when one is due, after the gimbal has
+// moved for the step.
+func (d *Drone) updateImageCamera(dt float64) {
+	if c := d.ImageCamera; c != nil {
+		if ok, _ := sampleDue(&c.age, c.Rate, dt); ok {
+			c.Capture(d)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package sim
+
+import (
+	"math"
+	"math/rand"
+)
+
+// IMU is a simulated accelerometer and gyro in the body frame (X left, Y up,
+// Z along the nose). Each sample is the true specific force and body rate
+// passed through scale-factor and misalignment errors, plus a turn-on bias
+// that random-walks, white noise and rotor vibration, then clipped to the
+// sensor range. Zero fields disable that error.
+type IMU struct {
+	Rate float64 // Hz; 0 disables the IMU
+
+	AccelNoise    float64 // m/s², 1σ per sample
+	AccelBias     float64 // m/s², 1σ turn-on bias per axis
+	AccelBiasWalk float64 // m/s² per √s bias random walk
+	AccelScale    Vec3    // fractional scale-factor error per axis
+	AccelAlign    Vec3    // rad, small-angle misalignment of the triad
+	AccelRange    float64 // m/s², saturation
+
+	GyroNoise    float64 // rad/s, 1σ per sample
+	GyroBias     float64 // rad/s, 1σ turn-on bias per axis
+	GyroBiasWalk float64 // rad/s per √s bias random walk
+	GyroScale    Vec3
+	GyroAlign    Vec3
+	GyroRange    float64 // rad/s, saturation
+
+	// Rotor vibration at full speed: a balanced rotor shakes the frame by
+	// Vibration; each unit of Engine.Imbalance adds ImbalanceVibration
+	Vibration          float64 // m/s²
+	ImbalanceVibration float64 // m/s²
+	GyroVibration      float64 // rad/s of gyro vibration per m/s² of acceleration
+
+	Faults FaultSchedule
+
+	rng       *rand.Rand
+	accelBias Vec3
+	gyroBias  Vec3
+	phase     [4]float64 // rotor angles driving the vibration
+	age       float64
+	sample    IMUSample
+	sumAccel  Vec3 // samples since the last drain, for the estimator
+	sumGyro   Vec3
+	sumN      int
+}
+
+// IMUSample is one IMU output.
+type IMUSample struct {
+	Time      float64 // s of simulation
+	Accel     Vec3    // m/s² specific force (reads +9.81 on Y at rest)
+	Gyro      Vec3    // rad/s body rates
+	Vibration float64 // m/s² rotor vibration in this sample
+	Saturated bool    // an axis hit the sensor range
+	Count     int     // samples since the IMU started
+}
+
+// DefaultIMU returns a consumer-grade MEMS IMU sampled at 400 Hz.
+func DefaultIMU() IMU {
+	return IMU{
+		Rate:               400,
+		AccelNoise:         0.05,
+		AccelBias:          0.05,
+		AccelBiasWalk:      0.002,
+		AccelRange:         16 * 9.81,
+		GyroNoise:          0.005,
+		GyroBias:           0.003,
+		GyroBiasWalk:       0.0002,
+		GyroRange:          2000 * math.Pi / 180,
+		Vibration:          0.3,
+		ImbalanceVibration: 8,
+		GyroVibration:      0.01,
+	}
+}
+
+// Sample returns the latest IMU output.
+func (s *IMU) Sample() IMUSample { return s.sample }
+
+// Biases returns the IMU's current accelerometer and gyro biases, for
+// judging an estimator against them.
+func (s *IMU) Biases() (accel, gyro Vec3) { return s.accelBias, s.gyroBias }
+
+// update draws the samples due in the dt up to now from the true specific
+// force and body rate (both body frame). rpm and imbalance give each rotor's
+// speed and imbalance. Samples falling within one step share the step's
+// truth; the last one is kept.
+func (s *IMU) update(seed int64, now, dt float64, force, rate Vec3, rpm, imbalance [4]float64) {
+	if s.Rate <= 0 {
+		return
+	}
+	if s.rng == nil {
+		s.rng = sensorRand(seed, "imu")
+		s.accelBias = gaussVec(s.rng, s.AccelBias)
+		s.gyroBias = gaussVec(s.rng, s.GyroBias)
+		s.Faults.init(seed, "imu")
+		for i := range s.phase {
+			s.phase[i] = s.rng.Float64() * 2 * math.Pi
+		}
+	}
+	period := 1 / s.Rate
+	for s.age += dt; s.age >= period; {
+		s.age -= period
+		s.draw(now-s.age, period, force, rate, rpm, imbalance)
+	}
+}
+
+// draw produces one sample at time t, period seconds after the last.
+func (s *IMU) draw(t, period float64, force, rate Vec3, rpm, imbalance [4]float64) {
+	walk := math.Sqrt(period)
+	s.accelBias = s.accelBias.Add(gaussVec(s.rng, s.AccelBiasWalk*walk))
+	s.gyroBias = s.gyroBias.Add(gaussVec(s.rng, s.GyroBiasWalk*walk))
+
+	// Each rotor shakes the frame radially at its rotation rate, with a
+	// smaller vertical component at blade-pass frequency
+	var vib Vec3
+	for i, r := range rpm {
+		s.phase[i] = math.Mod(s.phase[i]+2*math.Pi*r/60*period, 2*math.Pi)
+		full := r / 8000
+		a := full * full * (s.Vibration + s.ImbalanceVibration*imbalance[i])
+		vib = vib.Add(Vec3{X: math.Cos(s.phase[i]), Y: 0.3 * math.Sin(2*s.phase[i]), Z: math.Sin(s.phase[i])}.Mul(a))
+	}
+
+	accel := sensorErrors(force, s.AccelScale, s.AccelAlign).Add(s.accelBias).Add(vib).Add(gaussVec(s.rng, s.AccelNoise))
+	gyro := sensorErrors(rate, s.GyroScale, s.GyroAlign).Add(s.gyroBias).Add(vib.Mul(s.GyroVibration)).Add(gaussVec(s.rng, s.GyroNoise))
+	accel, accelSat := clampAxes(accel, s.AccelRange)
+	gyro, gyroSat := clampAxes(gyro, s.GyroRange)
+	out, ok := applyFaults(&s.Faults, period, IMUSample{
+		Time:      t,
+		Accel:     accel,
+		Gyro:      gyro,
+		Vibration: vib.Length(),
+		Saturated: accelSat || gyroSat,
+	})
+	if !ok {
+		return
+	}
+	out.Count = s.sample.Count + 1
+	s.sumAccel, s.sumGyro, s.sumN = s.sumAccel.Add(out.Accel), s.sumGyro.Add(out.Gyro), s.sumN+1
+	s.sample = out
+}
+
+// drain returns the mean of the samples drawn since the last drain, or the
+// latest sample when none were.
+func (s *IMU) drain() (accel, gyro Vec3) {
+	if s.sumN == 0 {
+		return s.sample.Accel, s.sample.Gyro
+	}
+	n := 1 / float64(s.sumN)
+	accel, gyro = s.sumAccel.Mul(n), s.sumGyro.Mul(n)
+	s.sumAccel, s.sumGyro, s.sumN = Vec3{}, Vec3{}, 0
+	return accel, gyro
+}
+
+// sensorErrors applies per-axis scale factors and a small-angle misalignment
+// to v: (I + diag(scale) + [align]×)·v.
+func sensorErrors(v, scale, align Vec3) Vec3 {
+	return Vec3{X: v.X * (1 + scale.X), Y: v.Y * (1 + scale.Y), Z: v.Z * (1 + scale.Z)}.Add(align.Cross(v))
+}
+
+// updateIMU feeds the IMU the acceleration the drone just underwent.
+func (d *Drone) updateIMU(dt float64, accel Vec3) {
+	force := toBody(d.Rotation, accel.Add(Vec3{Y: 9.81}))
+	var imbalance [4]float64
+	for i := 0; i < len(d.Engines) && i < len(imbalance); i++ {
+		imbalance[i] = d.Engines[i].Imbalance
+	}
+	d.IMU.update(d.Seed, d.clock, dt, force, bodyRates(d.Rotation, d.AngularVel), d.PropSpeeds, imbalance)
+}
@@ -4,6 +4,8 @@
 package sim
 
 import (
+	"math"
+
 	"github.com/go-gl/glfw/v3.3/glfw"
 )
 
@@ -78,22 +80,34 @@ func (i *InputHandler) WasKeyPressed(key glfw.Key) bool {
 }
 
 func (i *InputHandler) ProcessInput(drone *Drone, camera *Camera, dt float64) {
-	// Flight control inputs
-	throttleInput := 0.0
+	// Flight control inputs, held on the drone for its flight controller
+	throttleRate := 0.0
 	torque := Vec3{0, 0, 0}
 
+	// Orbit: sticks steer the circle instead of the airframe
+	// (Q/E speed, Up/Down radius, W/S altitude)
+	if drone.FlightMode == FlightModeOrbit {
+		stick := func(pos, neg glfw.Key) float64 {
+			v := 0.0
+			if i.IsKeyPressed(pos) {
+				v++
+			}
+			if i.IsKeyPressed(neg) {
+				v--
+			}
+			return v
+		}
+		drone.SetOrbitSticks(stick(glfw.KeyQ, glfw.KeyE), stick(glfw.KeyW, glfw.KeyS), stick(glfw.KeyDown, glfw.KeyUp))
+	}
+
 	// Throttle control (only works when armed - realistic safety)
 	if drone.IsArmed {
 		if i.IsKeyPressed(glfw.KeyW) {
-			throttleInput += 80.0 * dt // Smooth throttle increase
+			throttleRate += 80.0 // Smooth throttle increase (%/s)
 		}
 		if i.IsKeyPressed(glfw.KeyS) {
-			throttleInput -= 60.0 * dt // Smooth throttle decrease
+			throttleRate -= 60.0 // Smooth throttle decrease (%/s)
 		}
-
-		// Apply throttle change
-		newThrottle := drone.ThrottlePercent + throttleInput
-		drone.SetThrottle(newThrottle)
 	}
 
 	// Rotation controls (pilot stick inputs)
@@ -117,7 +131,8 @@ func (i *InputHandler) ProcessInput(drone *Drone, camera *Camera, dt float64) {
 		torque.X += torqueScale // Pitch backward (unless Alt is held for camera control)
 	}
 
-	drone.AddTorque(torque, dt)
+	// The autopilot ignores the sticks while orbit or follow fly the airframe
+	drone.SetPilotInput(PilotInput{ThrottleRate: throttleRate, Torque: torque})
 
 	// SAFETY CONTROLS (Essential for realistic drone operation)
 
@@ -149,6 +164,16 @@ func (i *InputHandler) ProcessInput(drone *Drone, camera *Camera, dt float64) {
 	if i.WasKeyPressed(glfw.Key3) {
 		drone.SetFlightMode(FlightModeHover)
 	}
+	if i.WasKeyPressed(glfw.Key5) && drone.World != nil && len(drone.World.Targets) > 0 {
+		// Follow 8 m behind the first target
+		drone.StartFollow(FollowConfig{Target: drone.World.Targets[0], Offset: Vec3{Z: -8}, Altitude: 6})
+	}
+	if i.WasKeyPressed(glfw.Key4) {
+		// Orbit a point 10 m ahead of the nose
+		yaw := drone.Rotation.Y
+		center := drone.Position.Add(Vec3{X: -math.Sin(yaw) * 10, Z: math.Cos(yaw) * 10})
+		drone.StartOrbit(OrbitConfig{Center: center, Radius: 10})
+	}
 
 	// Emergency procedures
 	if i.WasKeyPressed(glfw.KeyH) {
@@ -0,0 +1,122 @@
+package sim
+
+import (
+	"math"
+	"math/rand"
+)
+
+// IGRF-13 (2020) first-degree Gauss coefficients in µT: a tilted dipole
+const (
+	igrfG10    = -29.4048
+	igrfG11    = -1.4509
+	igrfH11    = 4.6525
+	igrfRadius = 6371200.0 // m, reference radius
+)
+
+// EarthField returns the geomagnetic field at p in µT in the local frame
+// (X east, Y up, Z south) from the IGRF dipole. Declination and inclination
+// are within a few degrees of the full model at most places.
+func EarthField(p GeoPoint) Vec3 {
+	lat, lon := DegToRad(p.Lat), DegToRad(p.Lon)
+	sl, cl := math.Sin(lat), math.Cos(lat)
+	so, co := math.Sin(lon), math.Cos(lon)
+	// Earth-centred unit position and dipole axis
+	r := Vec3{X: cl * co, Y: cl * so, Z: sl}
+	b0 := math.Sqrt(igrfG10*igrfG10 + igrfG11*igrfG11 + igrfH11*igrfH11)
+	m := Vec3{X: igrfG11, Y: igrfH11, Z: igrfG10}.Mul(1 / b0)
+	scale := b0 * math.Pow(igrfRadius/(wgs84A+p.Alt), 3)
+	b := r.Mul(3 * m.Dot(r)).Sub(m).Mul(scale)
+	// Into east/up/south
+	east := Vec3{X: -so, Y: co}
+	north := Vec3{X: -sl * co, Y: -sl * so, Z: cl}
+	return Vec3{X: b.Dot(east), Y: b.Dot(r), Z: -b.Dot(north)}
+}
+
+// Magnetometer is a simulated three-axis compass in the body frame. It reads
+// the Earth's field for the world's origin distorted by the airframe: soft
+// iron (I + SoftIron)·B, a hard-iron offset, and a field from the motor
+// current proportional to the current drawn, plus white noise.
+type Magnetometer struct {
+	Rate     float64 // Hz; 0 disables the magnetometer
+	Noise    float64 // µT, 1σ per axis per sample
+	HardIron Vec3    // µT, fixed offset from magnetised parts
+	SoftIron [3]Vec3 // rows of the soft-iron distortion added to the identity
+	Current  Vec3    // µT per A of motor current, body axes
+	Field    *Vec3   // µT local-frame field overriding the Earth model
+	Faults   FaultSchedule
+
+	rng    *rand.Rand
+	age    float64
+	sample MagSample
+}
+
+// MagSample is one magnetometer output.
+type MagSample struct {
+	Time  float64 // s of simulation
+	Field Vec3    // µT, body frame
+	Count int
+}
+
+// DefaultMagnetometer returns a compass sampled at 50 Hz with a little
+// uncalibrated distortion and motor interference.
+func DefaultMagnetometer() Magnetometer {
+	return Magnetometer{
+		Rate:     50,
+		Noise:    0.3,
+		HardIron: Vec3{X: 1.5, Y: -0.8, Z: 0.6},
+		SoftIron: [3]Vec3{{X: 0.02, Y: 0.005}, {X: 0.005, Y: -0.01}, {Z: 0.015}},
+		Current:  Vec3{Y: 0.8, Z: 0.2},
+	}
+}
+
+// Sample returns the latest magnetometer output.
+func (m *Magnetometer) Sample() MagSample { return m.sample }
+
+// Heading returns the compass heading (degrees clockwise from magnetic
+// north) of a body-frame field measured at the given pitch and roll.
+func (s MagSample) Heading(pitch, roll float64) float64 {
+	// Level the measurement, then read the yaw that points the nose along
+	// horizontal north (-Z)
+	f := RotationXMat4(pitch).Mul(RotationZMat4(roll)).MulDirection(s.Field)
+	return YawToHeading(math.Atan2(-f.X, -f.Z))
+}
+
+// update draws the samples due in the dt up to now for the local-frame field
+// earth, attitude rot and motor current amps.
+func (m *Magnetometer) update(seed int64, now, dt float64, earth, rot Vec3, amps float64) {
+	if m.Rate <= 0 {
+		return
+	}
+	if m.rng == nil {
+		m.rng = sensorRand(seed, "mag")
+		m.Faults.init(seed, "mag")
+	}
+	if m.Field != nil {
+		earth = *m.Field
+	}
+	b := toBody(rot, earth)
+	b = Vec3{X: b.X + m.SoftIron[0].Dot(b), Y: b.Y + m.SoftIron[1].Dot(b), Z: b.Z + m.SoftIron[2].Dot(b)}
+	b = b.Add(m.HardIron).Add(m.Current.Mul(amps))
+	period := 1 / m.Rate
+	for m.age += dt; m.age >= period; {
+		m.age -= period
+		out, ok := applyFaults(&m.Faults, period, MagSample{Time: now - m.age, Field: b.Add(gaussVec(m.rng, m.Noise))})
+		if ok {
+			out.Count = m.sample.Count + 1
+			m.sample = out
+		}
+	}
+}
+
+// motorCurrent returns the battery current in A.
+func (d *Drone) motorCurrent() float64 {
+	if v := d.BatteryVoltage(); v > 0 {
+		return d.PowerDraw / v
+	}
+	return 0
+}
+
+// updateMag feeds the magnetometer the field at the world's origin.
+func (d *Drone) updateMag(dt float64) {
+	d.Mag.update(d.Seed, d.clock, dt, EarthField(d.World.GeoOrigin()), d.Rotation, d.motorCurrent())
+}
@@ -0,0 +1,497 @@
+package sim
+
+import (
+	"math"
+)
+
+// MissionItemType identifies what a mission item does when it becomes current.
+type MissionItemType int
+
+const (
+	MissionItemWaypoint MissionItemType = iota
+	MissionItemTakeoff
+	MissionItemLoiterTime
+	MissionItemLoiterTurns
+	MissionItemChangeSpeed
+	MissionItemReleasePayload
+	MissionItemReturnHome
+	MissionItemLand
+	MissionItemTrajectory
+)
+
+// MissionItem is a single step of a mission. Fields that do not apply to the
+// item's Type are ignored.
+type MissionItem struct {
+	Type         MissionItemType
+	Position     Vec3    // Target in the sim frame (Takeoff uses Position.Y as altitude)
+	AcceptRadius float64 // Waypoint acceptance radius in meters (0 = mission default)
+	Speed        float64 // Cruise speed toward this item in m/s (0 = current mission speed)
+	Yaw          float64 // Heading in radians, used when HoldYaw is set
+	HoldYaw      bool    // If false the nose follows the track
+	LoiterTime   float64 // Seconds to hold (LoiterTime)
+	LoiterTurns  float64 // Circles to fly (LoiterTurns)
+	LoiterRadius float64 // Circle radius for LoiterTurns (0 = default)
+	InPlace      bool    // Land: descend where the drone is instead of at Position
+	Straight     bool    // Waypoint: hold the line from the previous item instead of cutting toward Position
+
+	// Trajectory: a smooth path from wherever the item starts through Path to
+	// Position, flown with feed-forward. Speed caps the peak speed.
+	Path     []Vec3
+	Order    TrajectoryOrder
+	MaxAccel float64 // m/s^2 (0 = default)
+}
+
+type MissionState int
+
+const (
+	MissionIdle MissionState = iota
+	MissionRunning
+	MissionPaused
+	MissionComplete
+)
+
+// Default mission parameters
+const (
+	missionDefaultSpeed        = 5.0  // m/s
+	missionDefaultAccept       = 1.0  // m
+	missionDefaultLoiterRadius = 5.0  // m
+	missionLandSpeed           = 0.7  // m/s descent
+	missionAltitudeTolerance   = 0.5  // m
+	missionLandTargetY         = -1.0 // aim below ground so touchdown is positive
+)
+
+// Mission runs an ordered list of items on a single drone. It is driven from
+// Drone.Update while the drone is in FlightModeMission.
+type Mission struct {
+	Items       []MissionItem
+	Current     int
+	State       MissionState
+	CruiseSpeed float64
+
+	// Per-item progress
+	itemActive bool
+	anchor     Vec3    // position captured when the item started
+	elapsed    float64 // seconds spent on the current item
+	turned     float64 // accumulated orbit angle (rad) for LoiterTurns
+	lastAngle  float64
+	phase      int // sub-step for multi-phase items (Land, ReturnHome)
+
+	holdPos Vec3 // position held while paused or after completion
+	holdYaw float64
+
+	traj *Trajectory // generated when a Trajectory item starts
+}
+
+// MissionProgress summarises where a drone is within its mission.
+type MissionProgress struct {
+	State             MissionState
+	Current           int
+	Total             int
+	DistanceRemaining float64 // meters along the remaining legs
+	ETA               float64 // seconds, including loiter time
+}
+
+// NewMission creates an idle mission from the given items.
+func NewMission(items []MissionItem) *Mission {
+	return &Mission{Items: items, CruiseSpeed: missionDefaultSpeed}
+}
+
+// CurrentItem returns the active item, or nil when the mission is finished.
+func (m *Mission) CurrentItem() *MissionItem {
+	if m == nil || m.Current < 0 || m.Current >= len(m.Items) {
+		return nil
+	}
+	return &m.Items[m.Current]
+}
+
+// SetMission replaces the drone's mission. The new mission starts idle.
+func (d *Drone) SetMission(m *Mission) {
+	d.Mission = m
+}
+
+// StartMission begins (or restarts) the current mission from the first item.
+func (d *Drone) StartMission() bool {
+	if d.Mission == nil || len(d.Mission.Items) == 0 {
+		return false
+	}
+	m := d.Mission
+	m.Current = 0
+	m.itemActive = false
+	m.State = MissionRunning
+	d.SetFlightMode(FlightModeMission)
+	return true
+}
+
+// PauseMission holds position at the current location.
+func (d *Drone) PauseMission() bool {
+	d.releaseRouteHold()
+	if d.Mission == nil || d.Mission.State != MissionRunning {
+		return false
+	}
+	d.Mission.State = MissionPaused
+	d.Mission.holdPos = d.navPosition()
+	d.Mission.holdYaw = d.navRotation().Y
+	return true
+}
+
+// ResumeMission continues a paused mission with the item that was active.
+func (d *Drone) ResumeMission() bool {
+	d.releaseRouteHold()
+	if d.Mission == nil || d.Mission.State != MissionPaused {
+		return false
+	}
+	d.Mission.State = MissionRunning
+	d.SetFlightMode(FlightModeMission)
+	return true
+}
+
+// ClearMission drops the mission and falls back to holding the current altitude.
+func (d *Drone) ClearMission() {
+	d.Mission = nil
+	if d.FlightMode == FlightModeMission {
+		d.SetFlightMode(FlightModeHover)
+	}
+}
+
+// MissionProgress reports the current item, remaining distance and ETA.
+func (d *Drone) MissionProgress() MissionProgress {
+	m := d.Mission
+	if m == nil {
+		return MissionProgress{}
+	}
+	p := MissionProgress{State: m.State, Current: m.Current, Total: len(m.Items)}
+	if m.State == MissionComplete || m.State == MissionIdle {
+		return p
+	}
+	speed := m.CruiseSpeed
+	pos := d.navPosition()
+	for i := m.Current; i < len(m.Items); i++ {
+		it := m.Items[i]
+		if it.Type == MissionItemChangeSpeed && it.Speed > 0 {
+			speed = it.Speed
+			continue
+		}
+		legSpeed := speed
+		if it.Speed > 0 {
+			legSpeed = it.Speed
+		}
+		if it.Type == MissionItemTrajectory {
+			dist := 0.0
+			for _, via := range append(append([]Vec3{}, it.Path...), it.Position) {
+				dist += via.Sub(pos).Length()
+				pos = via
+			}
+			p.DistanceRemaining += dist
+			if i == m.Current && m.traj != nil {
+				p.ETA += math.Max(m.traj.Duration()-m.elapsed, 0)
+			} else if legSpeed > 0 {
+				p.ETA += dist / legSpeed
+			}
+			continue
+		}
+		target, ok := d.missionItemTarget(m, i, pos)
+		if ok {
+			dist := target.Sub(pos).Length()
+			p.DistanceRemaining += dist
+			if legSpeed > 0 {
+				p.ETA += dist / legSpeed
+			}
+			pos = target
+		}
+		switch it.Type {
+		case MissionItemLoiterTime:
+			rem := it.LoiterTime
+			if i == m.Current {
+				rem -= m.elapsed
+			}
+			if rem > 0 {
+				p.ETA += rem
+			}
+		case MissionItemLoiterTurns:
+			r := it.LoiterRadius
+			if r <= 0 {
+				r = missionDefaultLoiterRadius
+			}
+			rem := it.LoiterTurns * 2 * math.Pi
+			if i == m.Current {
+				rem -= m.turned
+			}
+			if rem > 0 {
+				arc := rem * r
+				p.DistanceRemaining += arc
+				if legSpeed > 0 {
+					p.ETA += arc / legSpeed
+				}
+			}
+		}
+	}
+	return p
+}
+
+// missionItemTarget returns where item i ends up, given the position the drone
+// will have when the item starts. Items without a location return ok=false.
+func (d *Drone) missionItemTarget(m *Mission, i int, from Vec3) (Vec3, bool) {
+	it := m.Items[i]
+	switch it.Type {
+	case MissionItemWaypoint, MissionItemLoiterTime, MissionItemLoiterTurns, MissionItemTrajectory:
+		return it.Position, true
+	case MissionItemTakeoff:
+		return Vec3{X: from.X, Y: it.Position.Y, Z: from.Z}, true
+	case MissionItemLand:
+		if it.InPlace {
+			return Vec3{X: from.X, Y: 0, Z: from.Z}, true
+		}
+		return Vec3{X: it.Position.X, Y: 0, Z: it.Position.Z}, true
+	case MissionItemReturnHome:
+		return Vec3{X: d.Home.X, Y: 0, Z: d.Home.Z}, true
+	}
+	return Vec3{}, false
+}
+
+// updateMission advances the drone's mission and steers the drone.
+func (d *Drone) updateMission(dt float64) {
+	if d.Mission != nil {
+		d.runMission(d.Mission, dt)
+	}
+}
+
+// runMission advances m's state machine and steers the drone.
+func (d *Drone) runMission(m *Mission, dt float64) {
+	switch m.State {
+	case MissionPaused, MissionComplete:
+		d.trackSetpoint(holdSetpoint(m.holdPos, m.holdYaw), dt)
+		return
+	case MissionIdle:
+		return
+	}
+
+	it := m.CurrentItem()
+	if it == nil {
+		d.finishMission(m)
+		return
+	}
+	speed := m.CruiseSpeed
+	if it.Speed > 0 {
+		speed = it.Speed
+	}
+	if !m.itemActive {
+		m.itemActive = true
+		m.anchor = d.navPosition()
+		m.elapsed = 0
+		m.turned = 0
+		m.phase = 0
+		m.lastAngle = math.Atan2(d.navPosition().Z-it.Position.Z, d.navPosition().X-it.Position.X)
+		m.traj = nil
+		if it.Type == MissionItemTrajectory {
+			pts := append(append([]Vec3{d.navPosition()}, it.Path...), it.Position)
+			// A failed fit (e.g. already at Position) falls back to a plain leg
+			m.traj, _ = NewTrajectory(pts, it.Order, TrajectoryLimits{MaxSpeed: speed, MaxAccel: it.MaxAccel})
+		}
+	}
+	m.elapsed += dt
+
+	accept := it.AcceptRadius
+	if accept <= 0 {
+		accept = missionDefaultAccept
+	}
+
+	done := false
+	switch it.Type {
+	case MissionItemWaypoint:
+		yaw := headingTo(d.navPosition(), it.Position)
+		if it.HoldYaw || horizontalDistance(d.navPosition(), it.Position) < accept {
+			yaw = d.itemYaw(it)
+		}
+		sp := NavSetpoint{Position: it.Position, Yaw: yaw, MaxSpeed: speed}
+		if it.Straight {
+			from := m.anchor
+			if m.Current > 0 {
+				if prev, ok := d.missionItemTarget(m, m.Current-1, m.anchor); ok {
+					from = prev
+				}
+			}
+			sp = legSetpoint(d.navPosition(), from, it.Position, speed)
+			sp.Yaw = yaw
+		}
+		d.trackSetpoint(sp, dt)
+		done = it.Position.Sub(d.navPosition()).Length() <= accept
+
+	case MissionItemTrajectory:
+		done = d.missionTrajectory(m, it, speed, accept, dt)
+
+	case MissionItemTakeoff:
+		target := Vec3{X: m.anchor.X, Y: it.Position.Y, Z: m.anchor.Z}
+		d.trackSetpoint(holdSetpoint(target, d.itemYaw(it)), dt)
+		done = math.Abs(d.navPosition().Y-it.Position.Y) <= missionAltitudeTolerance
+
+	case MissionItemLoiterTime:
+		yaw := d.itemYaw(it)
+		if horizontalDistance(d.navPosition(), it.Position) > accept && !it.HoldYaw {
+			yaw = headingTo(d.navPosition(), it.Position)
+		}
+		d.trackSetpoint(NavSetpoint{Position: it.Position, Yaw: yaw, MaxSpeed: speed}, dt)
+		if it.Position.Sub(d.navPosition()).Length() > accept && m.phase == 0 {
+			// Loiter clock starts once the drone is on station
+			m.elapsed = 0
+		} else {
+			m.phase = 1
+		}
+		done = m.phase == 1 && m.elapsed >= it.LoiterTime
+
+	case MissionItemLoiterTurns:
+		r := it.LoiterRadius
+		if r <= 0 {
+			r = missionDefaultLoiterRadius
+		}
+		sp := orbitSetpoint(d.navPosition(), it.Position, r, speed)
+		d.trackSetpoint(sp, dt)
+		a := math.Atan2(d.navPosition().Z-it.Position.Z, d.navPosition().X-it.Position.X)
+		if math.Abs(horizontalDistance(d.navPosition(), it.Position)-r) <= accept+1.0 {
+			m.turned += math.Abs(angleDiff(a, m.lastAngle))
+		}
+		m.lastAngle = a
+		done = m.turned >= it.LoiterTurns*2*math.Pi
+
+	case MissionItemChangeSpeed:
+		if it.Speed > 0 {
+			m.CruiseSpeed = it.Speed
+		}
+		done = true
+
+	case MissionItemReleasePayload:
+		d.ReleasePayload()
+		done = true
+
+	case MissionItemReturnHome:
+		done = d.missionReturnHome(m, speed, accept, dt)
+
+	case MissionItemLand:
+		target := it.Position
+		if it.InPlace {
+			target = m.anchor
+		}
+		done = d.missionLand(m, target, speed, accept, d.itemYaw(it), dt)
+	}
+
+	if done {
+		m.Current++
+		m.itemActive = false
+		if m.Current >= len(m.Items) {
+			d.finishMission(m)
+		}
+	}
+}
+
+// missionTrajectory flies the item's trajectory, sampled at the item's
+// elapsed time, and completes once the trajectory has ended with the drone
+// inside the acceptance radius. The nose follows the direction of travel.
+func (d *Drone) missionTrajectory(m *Mission, it *MissionItem, speed, accept, dt float64) bool {
+	if m.traj == nil {
+		d.trackSetpoint(NavSetpoint{Position: it.Position, Yaw: d.itemYaw(it), MaxSpeed: speed}, dt)
+		return it.Position.Sub(d.navPosition()).Length() <= accept
+	}
+	ref := m.traj.Sample(m.elapsed)
+	yaw := m.holdYaw
+	if it.HoldYaw {
+		yaw = it.Yaw
+	} else if math.Hypot(ref.Velocity.X, ref.Velocity.Z) > 0.5 {
+		yaw = math.Atan2(-ref.Velocity.X, ref.Velocity.Z)
+	} else if m.elapsed <= dt {
+		yaw = d.navRotation().Y
+	}
+	m.holdYaw = yaw
+	d.trackSetpoint(m.traj.Setpoint(m.elapsed, yaw), dt)
+	return m.elapsed >= m.traj.Duration() && it.Position.Sub(d.navPosition()).Length() <= accept
+}
+
+// itemYaw returns the heading an item asks for, defaulting to the current one.
+func (d *Drone) itemYaw(it *MissionItem) float64 {
+	if it.HoldYaw {
+		return it.Yaw
+	}
+	return d.navRotation().Y
+}
+
+// missionLand repositions over target (phase 0) and then descends until
+// touchdown, where the motors are disarmed.
+func (d *Drone) missionLand(m *Mission, target Vec3, speed, accept, yaw float64, dt float64) bool {
+	if m.phase == 0 {
+		over := Vec3{X: target.X, Y: d.AltitudeHold, Z: target.Z}
+		if horizontalDistance(d.navPosition(), target) > accept {
+			d.trackSetpoint(NavSetpoint{Position: over, Yaw: headingTo(d.navPosition(), target), MaxSpeed: speed}, dt)
+			return false
+		}
+		m.phase = 1
+		m.holdYaw = yaw
+	}
+	return d.descend(target, m.holdYaw, dt)
+}
+
+// descend lowers the drone over target until touchdown and then disarms.
+// The altitude target may only lead the drone by a short distance so the
+// altitude PID cannot build up a hard touchdown.
+func (d *Drone) descend(target Vec3, yaw float64, dt float64) bool {
+	rate := missionLandSpeed
+	if d.navPosition().Y-d.groundClearance() < 1.5 {
+		rate *= 0.5
+	}
+	d.AltitudeHold = math.Max(d.AltitudeHold, d.navPosition().Y-0.5)
+	ground := Vec3{X: target.X, Y: missionLandTargetY, Z: target.Z}
+	d.trackSetpoint(NavSetpoint{Position: ground, Yaw: yaw, ClimbRate: rate}, dt)
+	if d.OnGround {
+		d.Disarm()
+		return true
+	}
+	return false
+}
+
+// missionReturnHome climbs to ReturnAltitude, flies over Home and lands there.
+func (d *Drone) missionReturnHome(m *Mission, speed, accept, dt float64) bool {
+	if m.phase == 0 {
+		alt := math.Max(m.anchor.Y, d.ReturnAltitude)
+		climb := Vec3{X: m.anchor.X, Y: alt, Z: m.anchor.Z}
+		d.trackSetpoint(holdSetpoint(climb, d.navRotation().Y), dt)
+		if math.Abs(d.navPosition().Y-alt) > missionAltitudeTolerance {
+			return false
+		}
+		m.phase = 1
+	}
+	if m.phase == 1 {
+		over := Vec3{X: d.Home.X, Y: math.Max(m.anchor.Y, d.ReturnAltitude), Z: d.Home.Z}
+		d.trackSetpoint(NavSetpoint{Position: over, Yaw: headingTo(d.navPosition(), over), MaxSpeed: speed}, dt)
+		if horizontalDistance(d.navPosition(), over) > accept {
+			return false
+		}
+		m.phase = 2
+		m.holdYaw = d.navRotation().Y
+	}
+	return d.descend(d.Home, m.holdYaw, dt)
+}
+
+func (d *Drone) finishMission(m *Mission) {
+	m.State = MissionComplete
+	m.itemActive = false
+	m.holdPos = d.navPosition()
+	m.holdYaw = d.navRotation().Y
+}
+
+// AttachPayload adds a payload if it keeps the drone within MaxTakeoffMass.
+func (d *Drone) AttachPayload(mass float64) bool {
+	if mass <= 0 || d.Mass+mass > d.MaxTakeoffMass {
+		return false
+	}
+	d.Mass += mass
+	d.PayloadMass += mass
+	d.RecomputeInertia()
+	return true
+}
+
+// ReleasePayload drops any attached payload.
+func (d *Drone) ReleasePayload() {
+	if d.PayloadMass <= 0 {
+		return
+	}
+	d.Mass -= d.PayloadMass
+	d.PayloadMass = 0
+	d.RecomputeInertia()
+}
@@ -0,0 +1,387 @@
+package sim
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// MAV_CMD values understood by the mission file importers.
+const (
+	mavCmdNavWaypoint       = 16
+	mavCmdNavLoiterTurns    = 18
+	mavCmdNavLoiterTime     = 19
+	mavCmdNavReturnToLaunch = 20
+	mavCmdNavLand           = 21
+	mavCmdNavTakeoff        = 22
+	mavCmdDoChangeSpeed     = 178
+	mavCmdDoGripper         = 211
+)
+
+// MAV_FRAME values for item coordinates.
+const (
+	mavFrameGlobal            = 0 // altitude above mean sea level
+	mavFrameMission           = 2 // no coordinates
+	mavFrameGlobalRelativeAlt = 3 // altitude above home
+	mavFrameGlobalTerrainAlt  = 10
+	mavFrameGlobalInt         = 5
+	mavFrameGlobalRelAltInt   = 6
+	mavFrameGlobalTerrainInt  = 11
+)
+
+// mavItem is a MAVLink mission item in its wire form.
+type mavItem struct {
+	Seq     int
+	Frame   int
+	Command int
+	Params  [7]float64 // param1..4, lat, lon, alt; NaN = unset
+}
+
+// planFile mirrors the parts of a QGroundControl .plan document we use.
+type planFile struct {
+	FileType      string          `json:"fileType"`
+	Version       int             `json:"version"`
+	GroundStation string          `json:"groundStation"`
+	Mission       planMission     `json:"mission"`
+	GeoFence      json.RawMessage `json:"geoFence,omitempty"`
+	RallyPoints   json.RawMessage `json:"rallyPoints,omitempty"`
+}
+
+type planMission struct {
+	Version             int        `json:"version"`
+	FirmwareType        int        `json:"firmwareType"`
+	VehicleType         int        `json:"vehicleType"`
+	CruiseSpeed         float64    `json:"cruiseSpeed"`
+	HoverSpeed          float64    `json:"hoverSpeed"`
+	PlannedHomePosition []float64  `json:"plannedHomePosition"`
+	Items               []planItem `json:"items"`
+}
+
+type planItem struct {
+	Type         string     `json:"type"`
+	Command      int        `json:"command"`
+	Frame        int        `json:"frame"`
+	Params       []*float64 `json:"params"` // QGC writes NaN as null
+	AutoContinue bool       `json:"autoContinue"`
+	DoJumpID     int        `json:"doJumpId"`
+	ComplexType  string     `json:"complexItemType,omitempty"`
+}
+
+// LoadMissionFile reads a .plan or QGC WPL mission from disk, detecting the
+// format from the file contents.
+func LoadMissionFile(path string, origin *GeoPoint) (*Mission, GeoPoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, GeoPoint{}, err
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("QGC WPL")) {
+		return ParseWPL(data, origin)
+	}
+	return ParsePlan(data, origin)
+}
+
+// ParsePlan reads a QGroundControl .plan file. Coordinates are mapped into the
+// local frame anchored at origin, or at the plan's home position when origin
+// is nil. The origin actually used is returned.
+func ParsePlan(data []byte, origin *GeoPoint) (*Mission, GeoPoint, error) {
+	var pf planFile
+	if err := json.Unmarshal(data, &pf); err != nil {
+		return nil, GeoPoint{}, fmt.Errorf("plan: %w", err)
+	}
+	if pf.FileType != "Plan" {
+		return nil, GeoPoint{}, fmt.Errorf("plan: unexpected fileType %q", pf.FileType)
+	}
+	hp := pf.Mission.PlannedHomePosition
+	if len(hp) < 3 {
+		return nil, GeoPoint{}, fmt.Errorf("plan: missing plannedHomePosition")
+	}
+	home := GeoPoint{Lat: hp[0], Lon: hp[1], Alt: hp[2]}
+
+	items := make([]mavItem, 0, len(pf.Mission.Items))
+	for i, pi := range pf.Mission.Items {
+		if pi.Type != "SimpleItem" {
+			return nil, GeoPoint{}, fmt.Errorf("plan: item %d: unsupported %s %s", i, pi.Type, pi.ComplexType)
+		}
+		it := mavItem{Seq: i, Frame: pi.Frame, Command: pi.Command}
+		for k := range it.Params {
+			it.Params[k] = math.NaN()
+			if k < len(pi.Params) && pi.Params[k] != nil {
+				it.Params[k] = *pi.Params[k]
+			}
+		}
+		items = append(items, it)
+	}
+
+	o := home
+	if origin != nil {
+		o = *origin
+	}
+	m, err := missionFromMav(items, home, o)
+	if err != nil {
+		return nil, GeoPoint{}, fmt.Errorf("plan: %w", err)
+	}
+	if pf.Mission.HoverSpeed > 0 {
+		m.CruiseSpeed = pf.Mission.HoverSpeed
+	}
+	return m, o, nil
+}
+
+// ParseWPL reads a "QGC WPL 110" text mission. Line 0 is the home position;
+// it anchors the local frame unless origin is given.
+func ParseWPL(data []byte, origin *GeoPoint) (*Mission, GeoPoint, error) {
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	if !sc.Scan() || !strings.HasPrefix(strings.TrimSpace(sc.Text()), "QGC WPL 110") {
+		return nil, GeoPoint{}, fmt.Errorf("wpl: missing QGC WPL 110 header")
+	}
+	var items []mavItem
+	line := 1
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" {
+			continue
+		}
+		f := strings.Fields(text)
+		if len(f) < 12 {
+			return nil, GeoPoint{}, fmt.Errorf("wpl: line %d: want 12 fields, got %d", line, len(f))
+		}
+		var v [12]float64
+		for k := 0; k < 12; k++ {
+			x, err := strconv.ParseFloat(f[k], 64)
+			if err != nil {
+				return nil, GeoPoint{}, fmt.Errorf("wpl: line %d: field %d: %w", line, k+1, err)
+			}
+			v[k] = x
+		}
+		it := mavItem{Seq: int(v[0]), Frame: int(v[2]), Command: int(v[3])}
+		// A yaw of 0 is a north heading; writers mark "don't care" as nan
+		copy(it.Params[:], v[4:11])
+		items = append(items, it)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, GeoPoint{}, fmt.Errorf("wpl: %w", err)
+	}
+	if len(items) == 0 {
+		return nil, GeoPoint{}, fmt.Errorf("wpl: no home item")
+	}
+	home := GeoPoint{Lat: items[0].Params[4], Lon: items[0].Params[5], Alt: items[0].Params[6]}
+
+	o := home
+	if origin != nil {
+		o = *origin
+	}
+	m, err := missionFromMav(items[1:], home, o)
+	if err != nil {
+		return nil, GeoPoint{}, fmt.Errorf("wpl: %w", err)
+	}
+	return m, o, nil
+}
+
+// missionFromMav converts MAVLink items into a Mission. Relative altitudes are
+// measured from home; positions are mapped into the frame anchored at origin.
+func missionFromMav(items []mavItem, home, origin GeoPoint) (*Mission, error) {
+	out := make([]MissionItem, 0, len(items))
+	for _, it := range items {
+		p := it.Params
+		pos, err := mavPosition(it, home, origin)
+		if err != nil {
+			return nil, err
+		}
+		yaw, holdYaw := 0.0, false
+		// Loiter items use param4 for the exit track, not a heading
+		if !math.IsNaN(p[3]) && it.Command != mavCmdNavLoiterTime && it.Command != mavCmdNavLoiterTurns {
+			yaw, holdYaw = HeadingToYaw(p[3]), true
+		}
+
+		switch it.Command {
+		case mavCmdNavWaypoint:
+			mi := MissionItem{Type: MissionItemWaypoint, Position: pos, Yaw: yaw, HoldYaw: holdYaw}
+			if !math.IsNaN(p[1]) && p[1] > 0 {
+				mi.AcceptRadius = p[1]
+			}
+			// A hold time makes the waypoint a timed loiter at the same spot
+			if !math.IsNaN(p[0]) && p[0] > 0 {
+				mi.Type = MissionItemLoiterTime
+				mi.LoiterTime = p[0]
+			}
+			out = append(out, mi)
+
+		case mavCmdNavLoiterTime:
+			out = append(out, MissionItem{Type: MissionItemLoiterTime, Position: pos, LoiterTime: nanZero(p[0])})
+
+		case mavCmdNavLoiterTurns:
+			out = append(out, MissionItem{Type: MissionItemLoiterTurns, Position: pos, LoiterTurns: nanZero(p[0]), LoiterRadius: math.Abs(nanZero(p[2]))})
+
+		case mavCmdNavTakeoff:
+			out = append(out, MissionItem{Type: MissionItemTakeoff, Position: pos, Yaw: yaw, HoldYaw: holdYaw})
+
+		case mavCmdNavLand:
+			// Lat/lon of zero (or unset) means land at the current position
+			inPlace := nanZero(p[4]) == 0 && nanZero(p[5]) == 0
+			out = append(out, MissionItem{Type: MissionItemLand, Position: pos, Yaw: yaw, HoldYaw: holdYaw, InPlace: inPlace})
+
+		case mavCmdNavReturnToLaunch:
+			out = append(out, MissionItem{Type: MissionItemReturnHome})
+
+		case mavCmdDoChangeSpeed:
+			if s := nanZero(p[1]); s > 0 {
+				out = append(out, MissionItem{Type: MissionItemChangeSpeed, Speed: s})
+			}
+
+		case mavCmdDoGripper:
+			// param2: 0 = release, 1 = grab
+			if nanZero(p[1]) == 0 {
+				out = append(out, MissionItem{Type: MissionItemReleasePayload})
+			}
+
+		default:
+			return nil, fmt.Errorf("item %d: unsupported MAV_CMD %d", it.Seq, it.Command)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("mission has no items")
+	}
+	return NewMission(out), nil
+}
+
+// mavPosition maps an item's lat/lon/alt into the local frame.
+func mavPosition(it mavItem, home, origin GeoPoint) (Vec3, error) {
+	lat, lon, alt := nanZero(it.Params[4]), nanZero(it.Params[5]), nanZero(it.Params[6])
+	switch it.Frame {
+	case mavFrameGlobalInt, mavFrameGlobalRelAltInt, mavFrameGlobalTerrainInt:
+		// Integer frames carry degrees * 1e7 in some exports
+		if math.Abs(lat) > 90 || math.Abs(lon) > 180 {
+			lat, lon = lat/1e7, lon/1e7
+		}
+	}
+	switch it.Frame {
+	case mavFrameGlobal, mavFrameGlobalInt:
+	case mavFrameGlobalRelativeAlt, mavFrameGlobalRelAltInt, mavFrameGlobalTerrainAlt, mavFrameGlobalTerrainInt:
+		// Terrain-relative is treated as home-relative over the flat ground
+		alt += home.Alt
+	case mavFrameMission:
+		return Vec3{}, nil
+	default:
+		return Vec3{}, fmt.Errorf("item %d: unsupported MAV_FRAME %d", it.Seq, it.Frame)
+	}
+	if lat == 0 && lon == 0 {
+		lat, lon = home.Lat, home.Lon
+	}
+	return origin.ToLocal(GeoPoint{Lat: lat, Lon: lon, Alt: alt}), nil
+}
+
+func nanZero(v float64) float64 {
+	if math.IsNaN(v) {
+		return 0
+	}
+	return v
+}
+
+// planItems expands items QGC has no equivalent for: a Trajectory becomes
+// plain waypoints through its path, so the smoothing is lost on export.
+func planItems(items []MissionItem) []MissionItem {
+	out := make([]MissionItem, 0, len(items))
+	for _, it := range items {
+		if it.Type != MissionItemTrajectory {
+			out = append(out, it)
+			continue
+		}
+		for _, p := range append(append([]Vec3{}, it.Path...), it.Position) {
+			out = append(out, MissionItem{Type: MissionItemWaypoint, Position: p, Speed: it.Speed, Yaw: it.Yaw, HoldYaw: it.HoldYaw})
+		}
+	}
+	return out
+}
+
+// ExportPlan writes m as a QGroundControl .plan document. Positions are
+// converted to geodetic coordinates around origin; altitudes are written
+// relative to the ground below home, the drone's launch point in the local
+// frame.
+func ExportPlan(m *Mission, origin GeoPoint, home Vec3) ([]byte, error) {
+	if m == nil || len(m.Items) == 0 {
+		return nil, fmt.Errorf("plan: empty mission")
+	}
+	hg := origin.ToGeo(Vec3{X: home.X, Z: home.Z})
+	pf := planFile{
+		FileType:      "Plan",
+		Version:       1,
+		GroundStation: "QGroundControl",
+		Mission: planMission{
+			Version:             2,
+			FirmwareType:        12, // PX4
+			VehicleType:         2,  // quadrotor
+			CruiseSpeed:         m.CruiseSpeed,
+			HoverSpeed:          m.CruiseSpeed,
+			PlannedHomePosition: []float64{hg.Lat, hg.Lon, hg.Alt},
+		},
+		GeoFence:    json.RawMessage(`{"circles":[],"polygons":[],"version":2}`),
+		RallyPoints: json.RawMessage(`{"points":[],"version":2}`),
+	}
+
+	pv := func(v float64) *float64 {
+		if math.IsNaN(v) {
+			return nil
+		}
+		return &v
+	}
+	for _, it := range planItems(m.Items) {
+		g := origin.ToGeo(it.Position)
+		heading := math.NaN()
+		if it.HoldYaw {
+			heading = YawToHeading(it.Yaw)
+		}
+		cmd, frame := 0, mavFrameGlobalRelativeAlt
+		p := [7]float64{0, 0, 0, 0, g.Lat, g.Lon, it.Position.Y}
+
+		switch it.Type {
+		case MissionItemWaypoint:
+			cmd = mavCmdNavWaypoint
+			p[1], p[3] = it.AcceptRadius, heading
+		case MissionItemTakeoff:
+			cmd = mavCmdNavTakeoff
+			p[3] = heading
+			p[4], p[5] = hg.Lat, hg.Lon
+		case MissionItemLoiterTime:
+			cmd = mavCmdNavLoiterTime
+			p[0] = it.LoiterTime
+		case MissionItemLoiterTurns:
+			cmd = mavCmdNavLoiterTurns
+			p[0], p[2] = it.LoiterTurns, it.LoiterRadius
+		case MissionItemLand:
+			cmd = mavCmdNavLand
+			p[3] = heading
+			p[6] = 0
+			if it.InPlace {
+				p[4], p[5] = 0, 0
+			}
+		case MissionItemReturnHome:
+			cmd, frame = mavCmdNavReturnToLaunch, mavFrameMission
+			p[4], p[5], p[6] = 0, 0, 0
+		case MissionItemChangeSpeed:
+			cmd, frame = mavCmdDoChangeSpeed, mavFrameMission
+			p = [7]float64{1, it.Speed, -1, 0, 0, 0, 0}
+		case MissionItemReleasePayload:
+			cmd, frame = mavCmdDoGripper, mavFrameMission
+			p = [7]float64{1, 0, 0, 0, 0, 0, 0}
+		}
+
+		params := make([]*float64, len(p))
+		for k := range p {
+			params[k] = pv(p[k])
+		}
+		pf.Mission.Items = append(pf.Mission.Items, planItem{
+			Type:         "SimpleItem",
+			Command:      cmd,
+			Frame:        frame,
+			Params:       params,
+			AutoContinue: true,
+			DoJumpID:     len(pf.Mission.Items) + 1,
+		})
+	}
+	return json.MarshalIndent(pf, "", "    ")
+}
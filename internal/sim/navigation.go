@@ -0,0 +1,167 @@
+package sim
+
+import (
+	"math"
+)
+
+// NavSetpoint is a target for the drone's position controller. Velocity and
+// Acceleration are optional feed-forward terms (world frame).
+type NavSetpoint struct {
+	Position     Vec3
+	Velocity     Vec3
+	Acceleration Vec3
+	Yaw          float64 // heading in radians (0 = nose along +Z)
+	YawRate      float64 // heading-rate feed-forward in rad/s
+	MaxSpeed     float64 // horizontal speed cap in m/s; 0 uses the drone's MaxSpeed
+	ClimbRate    float64 // vertical rate limit for the altitude target; 0 uses a default
+}
+
+// Position controller gains (cascaded: position -> velocity -> tilt -> torque).
+const (
+	navKpPos      = 0.9  // m -> m/s
+	navKpVel      = 1.6  // (m/s) -> m/s^2
+	navMaxAccel   = 3.5  // m/s^2 lateral (~g*tan(navMaxTiltDeg))
+	navMaxTiltDeg = 20.0 // tilt command limit
+	navKpAtt      = 36.0 // rad -> rad/s^2
+	navKdAtt      = 10.0 // (rad/s) -> rad/s^2
+	navKpYaw      = 2.0  // rad -> rad/s
+	navKdYaw      = 6.0  // (rad/s) -> rad/s^2
+	navMaxYawRate = 1.5  // rad/s
+	navClimbRate  = 2.5  // m/s default altitude target slew
+	navLegDecel   = 1.5  // m/s^2 braking assumed at the end of a straight leg
+)
+
+// headingTo returns the yaw that points the nose from 'from' toward 'to'.
+// The nose is body +Z, which R_y(yaw) maps to (-sin(yaw), 0, cos(yaw)).
+func headingTo(from, to Vec3) float64 {
+	return math.Atan2(-(to.X - from.X), to.Z-from.Z)
+}
+
+// horizontalDistance returns the XZ-plane distance between two points.
+func horizontalDistance(a, b Vec3) float64 {
+	return math.Hypot(b.X-a.X, b.Z-a.Z)
+}
+
+// trackSetpoint steers the drone toward sp. Altitude is held through the
+// altitude PID by slewing AltitudeHold toward sp.Position.Y; lateral motion and
+// heading are produced by tilting the thrust vector with body torques.
+func (d *Drone) trackSetpoint(sp NavSetpoint, dt float64) {
+	if !d.IsArmed || dt <= 0 {
+		return
+	}
+	// Keep the rotors at hover so tilt produces lateral force; the altitude
+	// PID trims the vertical balance.
+	if d.ThrottlePercent < d.HoverThrottlePercent() {
+		d.SetThrottle(d.HoverThrottlePercent())
+	}
+
+	climb := sp.ClimbRate
+	if climb <= 0 {
+		climb = navClimbRate
+	}
+	d.AltitudeHold = slew(d.AltitudeHold, sp.Position.Y, climb, dt)
+	// Vertical feed-forward rides on top of the altitude PID
+	d.altitudeFF = d.Mass * sp.Acceleration.Y
+
+	g := 9.81
+	maxSpeed := sp.MaxSpeed
+	if maxSpeed <= 0 || maxSpeed > d.MaxSpeed {
+		maxSpeed = d.MaxSpeed
+	}
+
+	// 1) Position -> velocity target, capped to the commanded speed
+	vtx := sp.Velocity.X + navKpPos*(sp.Position.X-d.navPosition().X)
+	vtz := sp.Velocity.Z + navKpPos*(sp.Position.Z-d.navPosition().Z)
+	if h := math.Hypot(vtx, vtz); h > maxSpeed {
+		vtx *= maxSpeed / h
+		vtz *= maxSpeed / h
+	}
+	// 2) Velocity error -> acceleration command
+	ax := sp.Acceleration.X + navKpVel*(vtx-d.navVelocity().X)
+	az := sp.Acceleration.Z + navKpVel*(vtz-d.navVelocity().Z)
+	if h := math.Hypot(ax, az); h > navMaxAccel {
+		ax *= navMaxAccel / h
+		az *= navMaxAccel / h
+	}
+	// 3) Acceleration -> tilt targets in the yawed body frame (inverse of R_y)
+	rot := d.navRotation()
+	cy, sy := math.Cos(rot.Y), math.Sin(rot.Y)
+	bx := cy*ax + sy*az
+	bz := -sy*ax + cy*az
+	maxTilt := navMaxTiltDeg * math.Pi / 180
+	rollTarget := clamp(math.Atan2(bx, g), -maxTilt, maxTilt)
+	pitchTarget := clamp(-math.Atan2(bz, g), -maxTilt, maxTilt)
+	yawRate := clamp(sp.YawRate+navKpYaw*angleDiff(sp.Yaw, rot.Y), -navMaxYawRate, navMaxYawRate)
+	d.steerAttitude(pitchTarget, rollTarget, yawRate, sp.YawRate, dt)
+}
+
+// steerAttitude tracks pitch/roll targets and a yaw rate with body torques.
+// yawRateFF is the part of yawRate that is a sustained turn, which must also
+// overcome the airframe's rate damping.
+func (d *Drone) steerAttitude(pitchTarget, rollTarget, yawRate, yawRateFF, dt float64) {
+	// No lateral authority until the drone is clear of the ground
+	agl := d.navPosition().Y - d.groundClearance()
+	attScale := clamp((agl-0.05)/0.35, 0.0, 1.0)
+	if d.OnGround {
+		attScale = 0
+	}
+	rollTarget *= attScale
+	pitchTarget *= attScale
+
+	// Attitude tracking: desired angular acceleration times inertia
+	rot, rates := d.navRotation(), d.navAngularVel()
+	accX := navKpAtt*(pitchTarget-rot.X) - navKdAtt*rates.X
+	accZ := navKpAtt*(rollTarget-rot.Z) - navKdAtt*rates.Z
+	accY := (navKdYaw*(yawRate-rates.Y) + angularDampingRate*yawRateFF) * attScale
+
+	d.AddTorque(Vec3{X: accX * d.Inertia.X, Y: accY * d.Inertia.Y, Z: accZ * d.Inertia.Z}, dt)
+}
+
+// legSetpoint tracks the straight line from→to at speed: the target is the
+// drone's projection onto the leg, carrying the along-track velocity as
+// feed-forward, so position error is cross-track only. The speed tapers so
+// the drone comes to rest at to.
+func legSetpoint(pos, from, to Vec3, speed float64) NavSetpoint {
+	leg := to.Sub(from)
+	length := leg.Length()
+	if length < 1e-6 {
+		return NavSetpoint{Position: to, MaxSpeed: speed}
+	}
+	dir := leg.Mul(1 / length)
+	along := clamp(pos.Sub(from).Dot(dir), 0, length)
+	v := math.Min(speed, math.Sqrt(2*navLegDecel*(length-along)))
+	return NavSetpoint{
+		Position:  from.Add(dir.Mul(along)),
+		Velocity:  Vec3{X: dir.X * v, Z: dir.Z * v},
+		MaxSpeed:  speed + 1,
+		ClimbRate: math.Abs(dir.Y)*speed + 0.5,
+	}
+}
+
+// holdSetpoint returns a setpoint that keeps the drone at p with heading yaw.
+func holdSetpoint(p Vec3, yaw float64) NavSetpoint {
+	return NavSetpoint{Position: p, Yaw: yaw}
+}
+
+// orbitSetpoint returns a setpoint that circles center at radius with the given
+// tangential speed (positive = clockwise seen from above). The target
+// leads the drone along the circle and carries the centripetal acceleration as
+// feed-forward so the loop does not lag outward.
+func orbitSetpoint(pos, center Vec3, radius, speed float64) NavSetpoint {
+	if radius < 0.5 {
+		radius = 0.5
+	}
+	// Keep the centripetal demand inside the tilt budget
+	if vmax := math.Sqrt(0.7 * navMaxAccel * radius); math.Abs(speed) > vmax {
+		speed = math.Copysign(vmax, speed)
+	}
+	phi := math.Atan2(pos.Z-center.Z, pos.X-center.X)
+	omega := speed / radius
+	lead := omega * 0.5 // half a second ahead on the circle
+	a := phi + lead
+	p := Vec3{X: center.X + radius*math.Cos(a), Y: center.Y, Z: center.Z + radius*math.Sin(a)}
+	// Tangent direction for positive omega is d/dphi of (cos, sin)
+	v := Vec3{X: -math.Sin(a) * speed, Z: math.Cos(a) * speed}
+	acc := Vec3{X: -math.Cos(a) * speed * omega, Z: -math.Sin(a) * speed * omega}
+	return NavSetpoint{Position: p, Velocity: v, Acceleration: acc, Yaw: headingTo(p, center), MaxSpeed: math.Abs(speed) + 2}
+}
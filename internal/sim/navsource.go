@@ -0,0 +1,138 @@
+package sim
+
+// NavSource selects the position and velocity the flight software flies on.
+// Guidance, position hold, altitude hold and custom controllers all see the
+// navigation solution; physics, contact and the geofence referee keep using
+// the true state. Under NavEKF the attitude loop also flies on the filter's
+// attitude and rates.
+type NavSource int
+
+const (
+	NavTruth NavSource = iota // the simulator's true state
+	NavGNSS                   // GNSS fixes, dead-reckoned on GNSS velocity between them
+	NavEKF                    // the EKF's fusion of every sensor
+)
+
+func (s NavSource) String() string {
+	switch s {
+	case NavGNSS:
+		return "GNSS"
+	case NavEKF:
+		return "EKF"
+	}
+	return "Truth"
+}
+
+// GNSS navigation tuning
+const (
+	navGNSSGain    = 0.5 // blend of each (latency-compensated) fix into the solution
+	navGNSSTimeout = 1.0 // s without a usable fix before the solution is invalid
+)
+
+type navState struct {
+	pos, vel Vec3
+	rot      Vec3 // attitude and Euler rates, under NavEKF
+	rates    Vec3
+	valid    bool
+	fixes    int     // GNSS reading count already fused
+	age      float64 // s since the last usable fix
+	lostSet  bool    // PositionLost was raised by the navigation
+}
+
+// Navigation returns the position and velocity the flight software is flying
+// on and whether they are valid.
+func (d *Drone) Navigation() (pos, vel Vec3, ok bool) {
+	if d.NavSource == NavTruth {
+		return d.Position, d.Velocity, true
+	}
+	return d.nav.pos, d.nav.vel, d.nav.valid
+}
+
+// navPosition returns the position guidance flies on.
+func (d *Drone) navPosition() Vec3 {
+	if d.NavSource == NavTruth {
+		return d.Position
+	}
+	return d.nav.pos
+}
+
+// navVelocity returns the velocity guidance flies on.
+func (d *Drone) navVelocity() Vec3 {
+	if d.NavSource == NavTruth {
+		return d.Velocity
+	}
+	return d.nav.vel
+}
+
+// navRotation returns the attitude the attitude loop flies on.
+func (d *Drone) navRotation() Vec3 {
+	if d.NavSource == NavEKF && d.nav.valid {
+		return d.nav.rot
+	}
+	return d.Rotation
+}
+
+// navAngularVel returns the Euler angle rates the attitude loop damps.
+func (d *Drone) navAngularVel() Vec3 {
+	if d.NavSource == NavEKF && d.nav.valid {
+		return d.nav.rates
+	}
+	return d.AngularVel
+}
+
+// updateNavigation advances the navigation solution to the current step,
+// raising PositionLost while it is invalid.
+func (d *Drone) updateNavigation(dt float64) {
+	n := &d.nav
+	switch d.NavSource {
+	case NavTruth:
+		n.pos, n.vel, n.valid = d.Position, d.Velocity, true
+		if n.lostSet {
+			d.PositionLost, n.lostSet = false, false
+		}
+		return
+	case NavEKF:
+		// The filter ran on the last step's sensors; fly on it while its
+		// position is trustworthy
+		est := d.EKF.Estimate()
+		n.pos, n.vel, n.rot = est.Position, est.Velocity, est.Rotation
+		n.rates = eulerRates(est.Rotation, d.EKF.rates)
+		n.valid = d.EKF.usable()
+		d.updatePositionLost()
+		return
+	}
+
+	n.pos = n.pos.Add(n.vel.Mul(dt))
+	n.age += dt
+	if r := d.GNSS.Reading(); r.Count != n.fixes {
+		n.fixes = r.Count
+		if r.Fix != GNSSNoFix {
+			// Carry the fix forward over its latency
+			now := r.Position.Add(r.Velocity.Mul(d.clock - r.Time))
+			if n.valid {
+				n.pos = n.pos.Add(now.Sub(n.pos).Mul(navGNSSGain))
+			} else {
+				n.pos = now
+			}
+			n.vel = r.Velocity
+			n.valid = true
+			n.age = 0
+		}
+	}
+	if n.age > navGNSSTimeout {
+		n.valid = false
+	}
+	d.updatePositionLost()
+}
+
+// updatePositionLost raises PositionLost while the solution is invalid and
+// clears it once valid again if the navigation raised it.
+func (d *Drone) updatePositionLost() {
+	n := &d.nav
+	switch {
+	case !n.valid:
+		d.PositionLost, n.lostSet = true, true
+	case n.lostSet:
+		d.PositionLost, n.lostSet = false, false
+	}
+}
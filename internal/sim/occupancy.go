@@ -0,0 +1,171 @@
+package sim
+
+import (
+	"math"
+)
+
+// Occupancy grid limits
+const (
+	occupancyMaxCells = 2_000_000 // the resolution is coarsened beyond this
+	occupancySegStep  = 0.25      // segment checks sample every res*step
+)
+
+// OccupancyGrid is a voxel map of the world over a bounded box. A cell is
+// occupied when any point inside it could be closer than Inflate to an
+// obstacle or the terrain, so every point in a free cell keeps at least
+// Inflate of clearance. Points outside the grid count as occupied.
+type OccupancyGrid struct {
+	Origin     Vec3 // minimum corner
+	Resolution float64
+	Inflate    float64
+	NX, NY, NZ int
+
+	cells []bool
+}
+
+// NewOccupancyGrid voxelises w between lo and hi at res meters per cell,
+// inflating obstacles and terrain by inflate. The resolution is coarsened if
+// the box would need too many cells.
+func NewOccupancyGrid(w *World, lo, hi Vec3, res, inflate float64) *OccupancyGrid {
+	size := hi.Sub(lo)
+	size = Vec3{X: math.Max(size.X, res), Y: math.Max(size.Y, res), Z: math.Max(size.Z, res)}
+	for size.X*size.Y*size.Z/(res*res*res) > occupancyMaxCells {
+		res *= 1.25
+	}
+	g := &OccupancyGrid{
+		Origin:     lo,
+		Resolution: res,
+		Inflate:    inflate,
+		NX:         int(math.Ceil(size.X / res)),
+		NY:         int(math.Ceil(size.Y / res)),
+		NZ:         int(math.Ceil(size.Z / res)),
+	}
+	g.cells = make([]bool, g.NX*g.NY*g.NZ)
+
+	// A cell centre within inflate + half the cell diagonal may have a point
+	// closer than inflate.
+	reach := inflate + res*math.Sqrt(3)/2
+	for _, o := range w.obstacles() {
+		i0, j0, k0 := g.cellOf(o.Min.Sub(Vec3{X: reach, Y: reach, Z: reach}))
+		i1, j1, k1 := g.cellOf(o.Max.Add(Vec3{X: reach, Y: reach, Z: reach}))
+		for i := max(i0, 0); i <= min(i1, g.NX-1); i++ {
+			for j := max(j0, 0); j <= min(j1, g.NY-1); j++ {
+				for k := max(k0, 0); k <= min(k1, g.NZ-1); k++ {
+					if o.Distance(g.Center(i, j, k)) <= reach {
+						g.cells[g.index(i, j, k)] = true
+					}
+				}
+			}
+		}
+	}
+	// Terrain: mark every column up to the highest ground under the cell
+	for i := 0; i < g.NX; i++ {
+		for k := 0; k < g.NZ; k++ {
+			c := g.Center(i, 0, k)
+			ground := math.Inf(-1)
+			for _, dx := range []float64{-0.5, 0.5} {
+				for _, dz := range []float64{-0.5, 0.5} {
+					ground = math.Max(ground, w.GroundHeight(c.X+dx*res, c.Z+dz*res))
+				}
+			}
+			for j := 0; j < g.NY; j++ {
+				if g.Center(i, j, k).Y-res/2 > ground+inflate {
+					break
+				}
+				g.cells[g.index(i, j, k)] = true
+			}
+		}
+	}
+	return g
+}
+
+// obstacles tolerates a nil world.
+func (w *World) obstacles() []Obstacle {
+	if w == nil {
+		return nil
+	}
+	return w.Obstacles
+}
+
+func (g *OccupancyGrid) index(i, j, k int) int { return (i*g.NY+j)*g.NZ + k }
+
+func (g *OccupancyGrid) inBounds(i, j, k int) bool {
+	return i >= 0 && j >= 0 && k >= 0 && i < g.NX && j < g.NY && k < g.NZ
+}
+
+// cellOf returns the (possibly out-of-range) cell containing p.
+func (g *OccupancyGrid) cellOf(p Vec3) (i, j, k int) {
+	d := p.Sub(g.Origin)
+	return int(math.Floor(d.X / g.Resolution)), int(math.Floor(d.Y / g.Resolution)), int(math.Floor(d.Z / g.Resolution))
+}
+
+// Center returns the centre of cell (i, j, k).
+func (g *OccupancyGrid) Center(i, j, k int) Vec3 {
+	return g.Origin.Add(Vec3{X: (float64(i) + 0.5), Y: (float64(j) + 0.5), Z: (float64(k) + 0.5)}.Mul(g.Resolution))
+}
+
+// cellFree reports whether cell (i, j, k) is inside the grid and unoccupied.
+func (g *OccupancyGrid) cellFree(i, j, k int) bool {
+	return g.inBounds(i, j, k) && !g.cells[g.index(i, j, k)]
+}
+
+// Occupied reports whether p lies in an occupied cell or outside the grid.
+func (g *OccupancyGrid) Occupied(p Vec3) bool {
+	i, j, k := g.cellOf(p)
+	return !g.cellFree(i, j, k)
+}
+
+// SegmentFree reports whether the straight line a→b crosses only free cells.
+func (g *OccupancyGrid) SegmentFree(a, b Vec3) bool {
+	d := b.Sub(a)
+	n := int(math.Ceil(d.Length()/(g.Resolution*occupancySegStep))) + 1
+	for s := 0; s <= n; s++ {
+		if g.Occupied(a.Add(d.Mul(float64(s) / float64(n)))) {
+			return false
+		}
+	}
+	return true
+}
+
+// PathFree reports whether every leg of path is free.
+func (g *OccupancyGrid) PathFree(path []Vec3) bool {
+	for i := 1; i < len(path); i++ {
+		if !g.SegmentFree(path[i-1], path[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// nearestFree returns the centre of the free cell closest to p, searching
+// outward shell by shell up to maxCells away.
+func (g *OccupancyGrid) nearestFree(p Vec3, maxCells int) (Vec3, bool) {
+	ci, cj, ck := g.cellOf(p)
+	for r := 0; r <= maxCells; r++ {
+		best, bestD := Vec3{}, math.Inf(1)
+		for i := ci - r; i <= ci+r; i++ {
+			for j := cj - r; j <= cj+r; j++ {
+				for k := ck - r; k <= ck+r; k++ {
+					if max(max(absInt(i-ci), absInt(j-cj)), absInt(k-ck)) != r || !g.cellFree(i, j, k) {
+						continue
+					}
+					c := g.Center(i, j, k)
+					if dist := c.Sub(p).Length(); dist < bestD {
+						best, bestD = c, dist
+					}
+				}
+			}
+		}
+		if bestD < math.Inf(1) {
+			return best, true
+		}
+	}
+	return Vec3{}, false
+}
+
+func absInt(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,166 @@
+package sim
+
+import (
+	"errors"
+	"math"
+)
+
+// Orbit (point-of-interest) flight: the drone circles a centre at a set
+// radius, altitude and tangential speed with its nose on the centre. The
+// pilot can change radius, speed and altitude while orbiting; the circle is
+// tracked with centripetal feed-forward and a slow integrator that learns
+// the steady push of the wind.
+
+// Orbit parameters
+const (
+	orbitMinRadius     = 2.0   // m
+	orbitMaxRadius     = 200.0 // m
+	orbitDefaultRadius = 10.0  // m
+	orbitDefaultSpeed  = 3.0   // m/s
+	orbitRadiusRate    = 3.0   // m/s of radius change at full stick
+	orbitSpeedRate     = 1.5   // m/s^2 of speed change at full stick
+	orbitClimbRate     = 1.5   // m/s at full stick
+	orbitCentripetal   = 0.7   // share of navMaxAccel the turn may use
+	orbitKiWind        = 0.3   // m -> m/s^3 wind integrator gain
+	orbitMaxTrim       = 2.5   // m/s^2 wind trim limit
+)
+
+// OrbitConfig describes a circle around a point of interest.
+type OrbitConfig struct {
+	Center   Vec3    // point of interest; the nose points at it
+	Radius   float64 // m (0 = current distance, or a default when close)
+	Altitude float64 // circle altitude in m (0 = current altitude)
+	Speed    float64 // tangential m/s, positive = clockwise seen from above
+}
+
+// OrbitStatus reports the orbit being flown.
+type OrbitStatus struct {
+	OrbitConfig
+	EffectiveSpeed float64 // Speed after the centripetal limit for Radius
+	RadiusError    float64 // current distance from the centre minus Radius (m)
+}
+
+// orbitState is the running orbit. sticks hold pilot inputs in [-1, 1]:
+// X changes speed, Y altitude and Z radius.
+type orbitState struct {
+	cfg    OrbitConfig
+	sticks Vec3
+	trim   Vec3 // learned horizontal acceleration (wind) in m/s^2
+}
+
+// ErrOrbitNotArmed is returned when an orbit is requested on a disarmed drone.
+var ErrOrbitNotArmed = errors.New("orbit: drone is not armed")
+
+// StartOrbit switches the drone to FlightModeOrbit around cfg.Center. Zero
+// radius and altitude are taken from where the drone is.
+func (d *Drone) StartOrbit(cfg OrbitConfig) error {
+	if !d.IsArmed {
+		return ErrOrbitNotArmed
+	}
+	if d.action != ActionNone || d.ftActive {
+		return errors.New("orbit: a safety action is in control")
+	}
+	if cfg.Radius <= 0 {
+		cfg.Radius = horizontalDistance(d.navPosition(), cfg.Center)
+		if cfg.Radius < orbitMinRadius {
+			cfg.Radius = orbitDefaultRadius
+		}
+	}
+	if cfg.Altitude <= 0 {
+		cfg.Altitude = d.navPosition().Y
+	}
+	if cfg.Speed == 0 {
+		cfg.Speed = orbitDefaultSpeed
+	}
+	d.orbit = &orbitState{}
+	d.SetOrbit(cfg)
+	d.SetFlightMode(FlightModeOrbit)
+	return nil
+}
+
+// SetOrbit changes the circle while orbiting; the wind estimate is kept.
+func (d *Drone) SetOrbit(cfg OrbitConfig) {
+	if d.orbit == nil {
+		return
+	}
+	cfg.Radius = clamp(cfg.Radius, orbitMinRadius, orbitMaxRadius)
+	cfg.Speed = clamp(cfg.Speed, -d.MaxSpeed, d.MaxSpeed)
+	d.orbit.cfg = cfg
+}
+
+// SetOrbitSticks sets the pilot's orbit inputs, each in [-1, 1]: speed
+// accelerates the drone along the circle, climb changes altitude and radius
+// widens (positive) or tightens the circle. They hold until changed.
+func (d *Drone) SetOrbitSticks(speed, climb, radius float64) {
+	if d.orbit == nil {
+		return
+	}
+	d.orbit.sticks = Vec3{X: clamp(speed, -1, 1), Y: clamp(climb, -1, 1), Z: clamp(radius, -1, 1)}
+}
+
+// OrbitStatus returns the current orbit and whether one is being flown.
+func (d *Drone) OrbitStatus() (OrbitStatus, bool) {
+	if d.orbit == nil || d.FlightMode != FlightModeOrbit {
+		return OrbitStatus{}, false
+	}
+	cfg := d.orbit.cfg
+	return OrbitStatus{
+		OrbitConfig:    cfg,
+		EffectiveSpeed: orbitSpeedLimit(cfg.Speed, cfg.Radius),
+		RadiusError:    horizontalDistance(d.navPosition(), cfg.Center) - cfg.Radius,
+	}, true
+}
+
+// orbitSpeedLimit caps speed so the centripetal acceleration v²/r stays
+// within the share of the tilt budget reserved for the turn.
+func orbitSpeedLimit(speed, radius float64) float64 {
+	vmax := math.Sqrt(orbitCentripetal * navMaxAccel * radius)
+	return clamp(speed, -vmax, vmax)
+}
+
+// updateOrbit applies the pilot inputs and tracks the circle.
+func (d *Drone) updateOrbit(dt float64) {
+	o := d.orbit
+	if o == nil {
+		d.SetFlightMode(FlightModeHover)
+		return
+	}
+	cfg := o.cfg
+	cfg.Radius += o.sticks.Z * orbitRadiusRate * dt
+	cfg.Speed += o.sticks.X * orbitSpeedRate * dt
+	cfg.Altitude = math.Max(cfg.Altitude+o.sticks.Y*orbitClimbRate*dt, 0)
+	d.SetOrbit(cfg)
+	cfg = o.cfg
+
+	// Target: the nearest point on the circle, moving along the tangent with
+	// the centripetal acceleration as feed-forward, so the position error is
+	// purely radial.
+	r := horizontalDistance(d.navPosition(), cfg.Center)
+	radial := Vec3{X: 1}
+	if r > 1e-3 {
+		radial = Vec3{X: (d.navPosition().X - cfg.Center.X) / r, Z: (d.navPosition().Z - cfg.Center.Z) / r}
+	}
+	tangent := Vec3{X: -radial.Z, Z: radial.X} // clockwise seen from above (X east, Z south)
+	v := orbitSpeedLimit(cfg.Speed, cfg.Radius)
+	// Ease in the tangential speed while far off the circle
+	v *= clamp(1-math.Abs(r-cfg.Radius)/cfg.Radius, 0, 1)
+	target := Vec3{X: cfg.Center.X + radial.X*cfg.Radius, Y: cfg.Altitude, Z: cfg.Center.Z + radial.Z*cfg.Radius}
+
+	// Wind: a steady push shows up as a constant world-frame position error;
+	// integrate it into an acceleration trim.
+	e := Vec3{X: target.X - d.navPosition().X, Z: target.Z - d.navPosition().Z}
+	o.trim = o.trim.Add(e.Mul(orbitKiWind * dt))
+	if l := o.trim.Length(); l > orbitMaxTrim {
+		o.trim = o.trim.Mul(orbitMaxTrim / l)
+	}
+
+	d.trackSetpoint(NavSetpoint{
+		Position:     target,
+		Velocity:     tangent.Mul(v),
+		Acceleration: radial.Mul(-v * v / cfg.Radius).Add(o.trim),
+		Yaw:          headingTo(d.navPosition(), cfg.Center),
+		YawRate:      v / cfg.Radius, // the bearing to the centre turns with the orbit
+		MaxSpeed:     math.Abs(v) + 2,
+		ClimbRate:    orbitClimbRate + 1,
+	}, dt)
+}
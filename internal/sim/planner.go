@@ -0,0 +1,532 @@
+package sim
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Path planning around obstacles and terrain.
+//
+// PlanPath voxelises the world around start and goal (see OccupancyGrid),
+// searches it with A* (26-connected) or RRT*, then shortcuts the result
+// greedily so the path is a few straight legs between free-space corners.
+// A drone flying a planned goto re-checks its remaining legs whenever the
+// world changes and plans again from where it is if they are blocked.
+
+// PlannerAlgorithm selects the search.
+type PlannerAlgorithm int
+
+const (
+	PlanAStar PlannerAlgorithm = iota
+	PlanRRTStar
+)
+
+func (a PlannerAlgorithm) String() string {
+	if a == PlanRRTStar {
+		return "RRT*"
+	}
+	return "A*"
+}
+
+// PlanOptions tune a plan. Zero values pick defaults.
+type PlanOptions struct {
+	Algorithm  PlannerAlgorithm
+	Resolution float64 // voxel size in meters
+	Inflate    float64 // clearance kept from obstacles and terrain (m; 0 = sized to the drone)
+	Margin     float64 // how far the search box extends past start and goal (m)
+	Ceiling    float64 // top of the search box (0 = highest of start/goal/terrain + Margin)
+	Iterations int     // RRT* samples
+	Seed       int64   // RRT* random seed (plans are deterministic per seed)
+}
+
+// Planner defaults
+const (
+	planDefaultResolution = 1.0
+	planDefaultInflate    = 0.75 // clearance for plans not made for a particular drone
+	planTrackMargin       = 0.5  // m added to a drone's half diagonal for tracking error
+	planDefaultMargin     = 20.0
+	planDefaultIterations = 4000
+	planEscapeCells       = 6   // how far an occupied start may be moved to free space
+	rrtGoalBias           = 0.1 // fraction of samples drawn at the goal
+	rrtStepCells          = 4   // RRT* extension length in voxels
+	rrtNearCells          = 8   // RRT* rewiring radius in voxels
+	routeAccept           = 0.3 // m, tight so corners are not cut into obstacles
+	routeReplanDelay      = 0.5 // s of flight a blocked route holds before the new one is flown
+)
+
+// Planner errors
+var (
+	ErrGoalBlocked  = errors.New("planner: goal is inside an obstacle")
+	ErrStartBlocked = errors.New("planner: start is boxed in by obstacles")
+	ErrNoPath       = errors.New("planner: no collision-free path")
+)
+
+func (o PlanOptions) withDefaults() PlanOptions {
+	if o.Resolution <= 0 {
+		o.Resolution = planDefaultResolution
+	}
+	if o.Inflate <= 0 {
+		o.Inflate = planDefaultInflate
+	}
+	if o.Margin <= 0 {
+		o.Margin = planDefaultMargin
+	}
+	if o.Iterations <= 0 {
+		o.Iterations = planDefaultIterations
+	}
+	return o
+}
+
+// planGrid voxelises w over the box around pts extended by the margin.
+func planGrid(w *World, pts []Vec3, o PlanOptions) *OccupancyGrid {
+	lo, hi := pts[0], pts[0]
+	for _, p := range pts[1:] {
+		lo = Vec3{X: math.Min(lo.X, p.X), Y: math.Min(lo.Y, p.Y), Z: math.Min(lo.Z, p.Z)}
+		hi = Vec3{X: math.Max(hi.X, p.X), Y: math.Max(hi.Y, p.Y), Z: math.Max(hi.Z, p.Z)}
+	}
+	lo = Vec3{X: lo.X - o.Margin, Y: math.Min(lo.Y, 0) - o.Resolution, Z: lo.Z - o.Margin}
+	top := math.Max(hi.Y, w.terrainTop()) + o.Margin
+	if o.Ceiling > 0 {
+		top = o.Ceiling
+	}
+	hi = Vec3{X: hi.X + o.Margin, Y: top, Z: hi.Z + o.Margin}
+	return NewOccupancyGrid(w, lo, hi, o.Resolution, o.Inflate)
+}
+
+func (w *World) terrainTop() float64 {
+	if w == nil {
+		return 0
+	}
+	return w.Terrain.maxHeight()
+}
+
+// PlanPath returns a collision-free path from start to goal through w (nil
+// for an empty world). The path begins at start and ends at goal; every leg
+// keeps opts.Inflate of clearance except, when start itself is too close to
+// an obstacle or the ground, the first leg out to free space.
+func PlanPath(w *World, start, goal Vec3, opts PlanOptions) ([]Vec3, error) {
+	o := opts.withDefaults()
+	g := planGrid(w, []Vec3{start, goal}, o)
+	if g.Occupied(goal) {
+		return nil, ErrGoalBlocked
+	}
+	from := start
+	var prefix []Vec3
+	if g.Occupied(start) {
+		free, ok := g.nearestFree(start, planEscapeCells)
+		if !ok {
+			return nil, ErrStartBlocked
+		}
+		prefix = []Vec3{start}
+		from = free
+	}
+
+	var path []Vec3
+	if g.SegmentFree(from, goal) {
+		path = []Vec3{from, goal}
+	} else {
+		var ok bool
+		if o.Algorithm == PlanRRTStar {
+			path, ok = g.rrtStar(from, goal, o.Iterations, o.Seed)
+		} else {
+			path, ok = g.aStar(from, goal)
+		}
+		if !ok {
+			return nil, ErrNoPath
+		}
+		path = g.shortcut(path)
+	}
+	return append(prefix, path...), nil
+}
+
+// shortcut greedily replaces runs of legs with the longest straight leg that
+// stays free.
+func (g *OccupancyGrid) shortcut(path []Vec3) []Vec3 {
+	if len(path) < 3 {
+		return path
+	}
+	out := []Vec3{path[0]}
+	for i := 0; i < len(path)-1; {
+		j := len(path) - 1
+		for j > i+1 && !g.SegmentFree(path[i], path[j]) {
+			j--
+		}
+		out = append(out, path[j])
+		i = j
+	}
+	return out
+}
+
+// aStar searches the 26-connected voxel graph from the cell containing start
+// to the one containing goal and returns start, the intermediate cell
+// centres and goal.
+func (g *OccupancyGrid) aStar(start, goal Vec3) ([]Vec3, bool) {
+	si, sj, sk := g.cellOf(start)
+	gi, gj, gk := g.cellOf(goal)
+	if !g.cellFree(si, sj, sk) || !g.cellFree(gi, gj, gk) {
+		return nil, false
+	}
+	n := len(g.cells)
+	cost := make([]float64, n)
+	parent := make([]int32, n)
+	closed := make([]bool, n)
+	for i := range cost {
+		cost[i] = math.Inf(1)
+		parent[i] = -1
+	}
+	goalIdx := g.index(gi, gj, gk)
+	h := func(i, j, k int) float64 {
+		return math.Sqrt(float64((i-gi)*(i-gi)+(j-gj)*(j-gj)+(k-gk)*(k-gk))) * g.Resolution
+	}
+	startIdx := g.index(si, sj, sk)
+	cost[startIdx] = 0
+	open := &nodeQueue{{idx: startIdx, f: h(si, sj, sk)}}
+
+	var step [27]float64
+	for di := -1; di <= 1; di++ {
+		for dj := -1; dj <= 1; dj++ {
+			for dk := -1; dk <= 1; dk++ {
+				step[(di+1)*9+(dj+1)*3+dk+1] = math.Sqrt(float64(di*di+dj*dj+dk*dk)) * g.Resolution
+			}
+		}
+	}
+
+	found := false
+	for open.Len() > 0 {
+		cur := heap.Pop(open).(queuedNode).idx
+		if closed[cur] {
+			continue
+		}
+		if cur == goalIdx {
+			found = true
+			break
+		}
+		closed[cur] = true
+		ci, cj, ck := cur/(g.NY*g.NZ), (cur/g.NZ)%g.NY, cur%g.NZ
+		for di := -1; di <= 1; di++ {
+			for dj := -1; dj <= 1; dj++ {
+				for dk := -1; dk <= 1; dk++ {
+					i, j, k := ci+di, cj+dj, ck+dk
+					if (di == 0 && dj == 0 && dk == 0) || !g.cellFree(i, j, k) {
+						continue
+					}
+					next := g.index(i, j, k)
+					if closed[next] {
+						continue
+					}
+					c := cost[cur] + step[(di+1)*9+(dj+1)*3+dk+1]
+					if c < cost[next] {
+						cost[next] = c
+						parent[next] = int32(cur)
+						heap.Push(open, queuedNode{idx: next, f: c + h(i, j, k)})
+					}
+				}
+			}
+		}
+	}
+	if !found {
+		return nil, false
+	}
+	var cells []Vec3
+	for c := parent[goalIdx]; c >= 0 && int(c) != startIdx; c = parent[c] {
+		idx := int(c)
+		cells = append(cells, g.Center(idx/(g.NY*g.NZ), (idx/g.NZ)%g.NY, idx%g.NZ))
+	}
+	path := []Vec3{start}
+	for i := len(cells) - 1; i >= 0; i-- {
+		path = append(path, cells[i])
+	}
+	return append(path, goal), true
+}
+
+type queuedNode struct {
+	idx int
+	f   float64
+}
+
+// nodeQueue is a min-heap on f.
+type nodeQueue []queuedNode
+
+func (q nodeQueue) Len() int           { return len(q) }
+func (q nodeQueue) Less(i, j int) bool { return q[i].f < q[j].f }
+func (q nodeQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *nodeQueue) Push(x any)        { *q = append(*q, x.(queuedNode)) }
+func (q *nodeQueue) Pop() any {
+	old := *q
+	n := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return n
+}
+
+type rrtNode struct {
+	p      Vec3
+	parent int
+	cost   float64
+}
+
+// rrtStar grows an RRT* tree from start through the free space of g for the
+// given number of samples and returns the cheapest path found to goal.
+func (g *OccupancyGrid) rrtStar(start, goal Vec3, iterations int, seed int64) ([]Vec3, bool) {
+	rng := rand.New(rand.NewSource(seed))
+	stepLen := rrtStepCells * g.Resolution
+	nearR := rrtNearCells * g.Resolution
+	size := Vec3{X: float64(g.NX), Y: float64(g.NY), Z: float64(g.NZ)}.Mul(g.Resolution)
+	nodes := []rrtNode{{p: start, parent: -1}}
+	best, bestCost := -1, math.Inf(1)
+
+	for it := 0; it < iterations; it++ {
+		sample := goal
+		if rng.Float64() >= rrtGoalBias {
+			sample = g.Origin.Add(Vec3{X: rng.Float64() * size.X, Y: rng.Float64() * size.Y, Z: rng.Float64() * size.Z})
+		}
+		nearest, nd := 0, math.Inf(1)
+		for i, n := range nodes {
+			if d := n.p.Sub(sample).Length(); d < nd {
+				nearest, nd = i, d
+			}
+		}
+		p := sample
+		if nd > stepLen {
+			p = nodes[nearest].p.Add(sample.Sub(nodes[nearest].p).Mul(stepLen / nd))
+		}
+		if g.Occupied(p) || !g.SegmentFree(nodes[nearest].p, p) {
+			continue
+		}
+
+		// Choose the cheapest parent among the neighbours, then rewire them
+		// through the new node where that is shorter.
+		var near []int
+		for i, n := range nodes {
+			if n.p.Sub(p).Length() <= nearR {
+				near = append(near, i)
+			}
+		}
+		parent := nearest
+		cost := nodes[nearest].cost + p.Sub(nodes[nearest].p).Length()
+		for _, i := range near {
+			if c := nodes[i].cost + p.Sub(nodes[i].p).Length(); c < cost && g.SegmentFree(nodes[i].p, p) {
+				parent, cost = i, c
+			}
+		}
+		nodes = append(nodes, rrtNode{p: p, parent: parent, cost: cost})
+		id := len(nodes) - 1
+		for _, i := range near {
+			if i == parent {
+				continue
+			}
+			if c := cost + nodes[i].p.Sub(p).Length(); c < nodes[i].cost && g.SegmentFree(p, nodes[i].p) {
+				nodes[i].parent = id
+				nodes[i].cost = c
+			}
+		}
+
+		if d := goal.Sub(p).Length(); d <= stepLen && cost+d < bestCost && g.SegmentFree(p, goal) {
+			best, bestCost = id, cost+d
+		}
+	}
+	if best < 0 {
+		return nil, false
+	}
+	path := []Vec3{goal}
+	for i := best; i >= 0; i = nodes[i].parent {
+		path = append(path, nodes[i].p)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, true
+}
+
+// plannedRoute is a planned goto the drone is flying as a waypoint mission.
+type plannedRoute struct {
+	goal    Vec3
+	speed   float64
+	opts    PlanOptions
+	version int // world version the path was last checked against
+	mission *Mission
+	held    bool              // the planner paused the mission, not the operator
+	replan  chan replanResult // search in flight, nil when idle
+	due     float64           // drone clock at which the search result is applied
+}
+
+// replanResult is what a background search for a blocked route found.
+type replanResult struct {
+	path []Vec3
+	err  error
+}
+
+// RoutePlan is a planned goto taken apart so that the search can run without
+// the simulator lock: PlanRoute snapshots the world under the lock, Search
+// runs A* or RRT* on the snapshot, and FlyRoute starts the result.
+type RoutePlan struct {
+	Path []Vec3 // set by Search; begins where the drone was when planned
+
+	world       *World
+	start, goal Vec3
+	speed       float64
+	opts        PlanOptions
+}
+
+// PlanRoute snapshots what a planned goto to goal at speed (0 = mission
+// default) needs. Obstacles are inflated by the drone's size unless
+// opts.Inflate says otherwise. Callers must hold the simulator lock.
+func (d *Drone) PlanRoute(goal Vec3, speed float64, opts PlanOptions) *RoutePlan {
+	if opts.Inflate <= 0 {
+		opts.Inflate = d.planInflate()
+	}
+	return &RoutePlan{world: d.World.planSnapshot(), start: d.navPosition(), goal: goal, speed: speed, opts: opts}
+}
+
+// Search plans the route. It touches only the snapshot, so it may run
+// without the simulator lock.
+func (p *RoutePlan) Search() error {
+	path, err := PlanPath(p.world, p.start, p.goal, p.opts)
+	p.Path = path
+	return err
+}
+
+// FlyRoute starts flying a searched plan as a waypoint mission. The route is
+// re-planned if the world changes and blocks it, including changes made
+// while it was being searched. Callers must hold the simulator lock.
+func (d *Drone) FlyRoute(p *RoutePlan) error {
+	if len(p.Path) < 2 {
+		return ErrNoPath
+	}
+	m := NewMission(routeItems(p.Path, p.speed))
+	d.SetMission(m)
+	d.StartMission()
+	d.route = &plannedRoute{goal: p.goal, speed: p.speed, opts: p.opts, version: p.world.Version(), mission: m}
+	return nil
+}
+
+// GotoPlanned plans a path from the drone's position to goal through its
+// world and starts flying it as a waypoint mission at speed (0 = mission
+// default). It searches while the caller holds the lock; servers that must
+// not block the simulation use PlanRoute, Search and FlyRoute instead.
+func (d *Drone) GotoPlanned(goal Vec3, speed float64, opts PlanOptions) ([]Vec3, error) {
+	p := d.PlanRoute(goal, speed, opts)
+	if err := p.Search(); err != nil {
+		return nil, err
+	}
+	return p.Path, d.FlyRoute(p)
+}
+
+// planInflate is the clearance a planned route keeps: the drone's half
+// diagonal plus room for tracking error.
+func (d *Drone) planInflate() float64 {
+	half := 0.5 * math.Hypot(d.Dimensions.X, d.Dimensions.Y)
+	if half <= 0 {
+		return planDefaultInflate
+	}
+	return half + planTrackMargin
+}
+
+// planSnapshot copies the scenery a plan reads, so the search can run while
+// the world keeps changing. Terrain maps are replaced, never edited, so the
+// pointer is shared.
+func (w *World) planSnapshot() *World {
+	if w == nil {
+		return nil
+	}
+	return &World{Obstacles: append([]Obstacle(nil), w.Obstacles...), Terrain: w.Terrain, Origin: w.Origin, version: w.version}
+}
+
+// routeItems turns a planned path (which starts at the drone) into waypoints.
+func routeItems(path []Vec3, speed float64) []MissionItem {
+	items := make([]MissionItem, 0, len(path)-1)
+	for _, p := range path[1:] {
+		items = append(items, MissionItem{Type: MissionItemWaypoint, Position: p, Speed: speed, Straight: true, AcceptRadius: routeAccept})
+	}
+	return items
+}
+
+// PlannedPath returns the remaining legs of the active planned goto, starting
+// at the drone, or nil when none is being flown.
+func (d *Drone) PlannedPath() []Vec3 {
+	r := d.route
+	if r == nil || d.Mission != r.mission || r.mission.State == MissionComplete {
+		return nil
+	}
+	path := []Vec3{d.navPosition()}
+	for i := r.mission.Current; i < len(r.mission.Items); i++ {
+		path = append(path, r.mission.Items[i].Position)
+	}
+	return path
+}
+
+// updateRoute re-plans the active planned goto when the world has changed
+// and an obstacle now blocks the remaining legs. The check is made at once;
+// only a blocked route holds the drone, while A* or RRT* runs in the
+// background on a snapshot of the world. The result is applied after
+// routeReplanDelay of flight, waiting for the search if need be, so a run
+// does not depend on how fast the host is. If no path exists any more the
+// drone keeps holding.
+func (d *Drone) updateRoute() {
+	r := d.route
+	if r == nil {
+		return
+	}
+	if d.Mission != r.mission {
+		d.route = nil
+		return
+	}
+	if r.replan != nil {
+		if d.clock < r.due {
+			return
+		}
+		res := <-r.replan
+		r.replan = nil
+		d.applyReplan(res)
+		return
+	}
+	if r.mission.State != MissionRunning || d.World.Version() == r.version {
+		return
+	}
+	r.version = d.World.Version()
+	remaining := d.PlannedPath()
+	if len(remaining) < 2 {
+		return
+	}
+	w, goal, opts := d.World.planSnapshot(), r.goal, r.opts
+	if planGrid(w, remaining, opts.withDefaults()).PathFree(remaining) {
+		return
+	}
+	d.PauseMission()
+	r.held = true
+	r.due = d.clock + routeReplanDelay
+	r.replan = make(chan replanResult, 1)
+	go func(out chan<- replanResult) {
+		var res replanResult
+		res.path, res.err = PlanPath(w, remaining[0], goal, opts)
+		out <- res
+	}(r.replan)
+}
+
+// applyReplan installs the outcome of a background re-plan. The mission
+// resumes only if the planner was the one holding it.
+func (d *Drone) applyReplan(res replanResult) {
+	r := d.route
+	if res.err != nil {
+		d.emit(Event{Kind: "plan.failed", Source: "planner", Action: "Hold", Detail: res.err.Error()})
+		return
+	}
+	r.mission.Items = routeItems(res.path, r.speed)
+	r.mission.Current = 0
+	r.mission.itemActive = false
+	d.emit(Event{Kind: "plan.replan", Source: "planner", Detail: fmt.Sprintf("%d legs", len(res.path)-1)})
+	if r.held {
+		r.held = false
+		d.ResumeMission()
+	}
+}
+
+// releaseRouteHold hands a mission the planner paused over to the operator,
+// so that their pause or resume stands when the re-plan finishes.
+func (d *Drone) releaseRouteHold() {
+	if d.route != nil {
+		d.route.held = false
+	}
+}
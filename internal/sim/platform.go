@@ -0,0 +1,198 @@
+package sim
+
+import "math"
+
+// Moving landing platforms: a ship deck or a vehicle roof. A platform is a
+// flat rectangular deck carried along by a Track (a scripted or externally
+// reported Target) at a height above it, and rocked by scripted heave, pitch
+// and roll. Drones touch down on decks through the same contact code as the
+// ground, and ride a deck once landed.
+
+// Oscillation is a sinusoidal deck motion: Amplitude·sin(2π·t/Period + Phase).
+type Oscillation struct {
+	Amplitude float64 // m for heave, rad for pitch and roll
+	Period    float64 // s
+	Phase     float64 // rad
+}
+
+// at returns the value and rate of change at time t.
+func (o Oscillation) at(t float64) (float64, float64) {
+	if o.Amplitude == 0 || o.Period <= 0 {
+		return 0, 0
+	}
+	w := 2 * math.Pi / o.Period
+	return o.Amplitude * math.Sin(w*t+o.Phase), o.Amplitude * w * math.Cos(w*t+o.Phase)
+}
+
+// Platform is a moving landing deck. Rotation follows the drone convention:
+// pitch (X) raises the bow, yaw (Y) is the heading, roll (Z) lowers the right
+// side. Marker is the landing fiducial in deck coordinates (X right, Z ahead).
+type Platform struct {
+	Name   string
+	Track  *Target // horizontal motion; moved by the platform, not the world
+	Length float64 // m along the heading
+	Width  float64 // m across
+	Deck   float64 // m of deck above the track point
+	Heave  Oscillation
+	Pitch  Oscillation
+	Roll   Oscillation
+	Marker Vec3
+
+	Position   Vec3 // deck centre
+	Velocity   Vec3
+	Rotation   Vec3
+	AngularVel Vec3
+	clock      float64
+}
+
+// platformContactSnap is how far below a drone's underside a deck still
+// catches it, so a deck sinking between steps keeps its passenger.
+const platformContactSnap = 0.3
+
+// NewPlatform returns a length×width deck at deck metres above track.
+func NewPlatform(name string, track *Target, length, width, deck float64) *Platform {
+	p := &Platform{Name: name, Track: track, Length: length, Width: width, Deck: deck}
+	p.Update(0)
+	return p
+}
+
+// Update moves the track and the deck by dt.
+func (p *Platform) Update(dt float64) {
+	p.clock += dt
+	var base, vel Vec3
+	heading := p.Rotation.Y
+	if p.Track != nil {
+		p.Track.Update(dt)
+		base, vel, heading = p.Track.Position, p.Track.Velocity, p.Track.Heading
+	} else {
+		base = Vec3{X: p.Position.X, Z: p.Position.Z}
+	}
+	heave, heaveRate := p.Heave.at(p.clock)
+	pitch, pitchRate := p.Pitch.at(p.clock)
+	roll, rollRate := p.Roll.at(p.clock)
+	yawRate := 0.0
+	if dt > 0 {
+		yawRate = angleDiff(heading, p.Rotation.Y) / dt
+	}
+	p.Position = Vec3{X: base.X, Y: base.Y + p.Deck + heave, Z: base.Z}
+	p.Velocity = Vec3{X: vel.X, Y: vel.Y + heaveRate, Z: vel.Z}
+	p.Rotation = Vec3{X: pitch, Y: heading, Z: roll}
+	p.AngularVel = Vec3{X: pitchRate, Y: yawRate, Z: rollRate}
+}
+
+// local returns (right, ahead) deck coordinates of world x/z.
+func (p *Platform) local(x, z float64) (float64, float64) {
+	dx, dz := x-p.Position.X, z-p.Position.Z
+	c, s := math.Cos(p.Rotation.Y), math.Sin(p.Rotation.Y)
+	return dx*c + dz*s, -dx*s + dz*c
+}
+
+// world returns the world position of deck coordinates (right, ahead).
+func (p *Platform) world(right, ahead float64) Vec3 {
+	c, s := math.Cos(p.Rotation.Y), math.Sin(p.Rotation.Y)
+	return Vec3{
+		X: p.Position.X + right*c - ahead*s,
+		Y: p.Position.Y + ahead*math.Tan(p.Rotation.X) - right*math.Tan(p.Rotation.Z),
+		Z: p.Position.Z + right*s + ahead*c,
+	}
+}
+
+// Surface returns the deck height and the velocity of the deck point at x/z,
+// and whether x/z is over the deck.
+func (p *Platform) Surface(x, z float64) (float64, Vec3, bool) {
+	r, f := p.local(x, z)
+	if math.Abs(r) > p.Width/2 || math.Abs(f) > p.Length/2 {
+		return 0, Vec3{}, false
+	}
+	cp, cr := math.Cos(p.Rotation.X), math.Cos(p.Rotation.Z)
+	h := p.world(r, f).Y
+	// Horizontal point velocity from the turn, vertical from heave and tilt
+	v := p.Velocity.Add(Vec3{
+		X: -p.AngularVel.Y * (z - p.Position.Z),
+		Y: f*p.AngularVel.X/(cp*cp) - r*p.AngularVel.Z/(cr*cr),
+		Z: p.AngularVel.Y * (x - p.Position.X),
+	})
+	return h, v, true
+}
+
+// MarkerPosition returns the landing fiducial in world coordinates.
+func (p *Platform) MarkerPosition() Vec3 {
+	return p.world(p.Marker.X, p.Marker.Z)
+}
+
+// corners returns the deck outline, clockwise from the front left.
+func (p *Platform) corners() [4]Vec3 {
+	l, w := p.Length/2, p.Width/2
+	return [4]Vec3{p.world(-w, l), p.world(w, l), p.world(w, -l), p.world(-w, -l)}
+}
+
+// Platform returns the world's platform called name, or nil.
+func (w *World) Platform(name string) *Platform {
+	if w == nil {
+		return nil
+	}
+	for _, p := range w.Platforms {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// AddPlatform adds p, replacing any platform with the same name.
+func (w *World) AddPlatform(p *Platform) {
+	for i, old := range w.Platforms {
+		if old.Name == p.Name {
+			w.Platforms[i] = p
+			return
+		}
+	}
+	w.Platforms = append(w.Platforms, p)
+}
+
+// RemovePlatform drops the platform called name.
+func (w *World) RemovePlatform(name string) {
+	for i, p := range w.Platforms {
+		if p.Name == name {
+			w.Platforms = append(w.Platforms[:i], w.Platforms[i+1:]...)
+			return
+		}
+	}
+}
+
+// surfaceUnder returns the height and velocity of whatever p rests on: the
+// highest deck under it within reach of bottom (its underside), or flat
+// ground. deck reports which.
+func (w *World) surfaceUnder(p Vec3, bottom float64) (h float64, vel Vec3, deck bool) {
+	if w == nil {
+		return 0, Vec3{}, false
+	}
+	for _, pl := range w.Platforms {
+		top, v, ok := pl.Surface(p.X, p.Z)
+		if ok && top > h && top <= bottom+platformContactSnap {
+			h, vel, deck = top, v, true
+		}
+	}
+	return h, vel, deck
+}
+
+// platformOutlines returns line-segment endpoints (pairs) outlining every
+// deck with a cross on its marker, for Renderer.RenderLines.
+func (w *World) platformOutlines() []Vec3 {
+	if w == nil {
+		return nil
+	}
+	var out []Vec3
+	for _, p := range w.Platforms {
+		c := p.corners()
+		for i := range c {
+			out = append(out, c[i], c[(i+1)%4])
+		}
+		m := p.Marker
+		out = append(out,
+			p.world(m.X-0.5, m.Z), p.world(m.X+0.5, m.Z),
+			p.world(m.X, m.Z-0.5), p.world(m.X, m.Z+0.5),
+		)
+	}
+	return out
+}
@@ -0,0 +1,155 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Pre-arm checks: Arm refuses to start the motors while any check fails and
+// reports every failing check, so operators see all problems at once.
+
+// PreArmCheck identifies one pre-arm check.
+type PreArmCheck int
+
+const (
+	CheckLanded PreArmCheck = iota
+	CheckBattery
+	CheckVoltage
+	CheckTilt
+	CheckEngines
+	CheckPosition
+	CheckGeofence
+	CheckCalibration
+)
+
+func (c PreArmCheck) String() string {
+	switch c {
+	case CheckLanded:
+		return "Landed"
+	case CheckBattery:
+		return "Battery"
+	case CheckVoltage:
+		return "Voltage"
+	case CheckTilt:
+		return "Tilt"
+	case CheckEngines:
+		return "Engines"
+	case CheckPosition:
+		return "Position"
+	case CheckGeofence:
+		return "Geofence"
+	case CheckCalibration:
+		return "Calibration"
+	}
+	return "Unknown"
+}
+
+// PreArmFailure is a failing check and a human-readable reason.
+type PreArmFailure struct {
+	Check  PreArmCheck
+	Reason string
+}
+
+// ArmResult reports the outcome of Arm.
+type ArmResult struct {
+	Armed    bool
+	Failures []PreArmFailure
+}
+
+// Reasons joins the failure reasons into one line.
+func (r ArmResult) Reasons() string {
+	reasons := make([]string, len(r.Failures))
+	for i, f := range r.Failures {
+		reasons[i] = f.Reason
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// Err returns nil when armed, otherwise an error listing the failures.
+func (r ArmResult) Err() error {
+	if r.Armed {
+		return nil
+	}
+	return fmt.Errorf("arming denied: %s", r.Reasons())
+}
+
+// PreArmConfig holds the pre-arm thresholds.
+type PreArmConfig struct {
+	MinCellVoltage float64 // V per cell at rest
+	MaxTiltDeg     float64 // pitch or roll
+	MinEngineEff   float64 // lowest acceptable engine efficiency 0..1
+}
+
+// DefaultPreArm returns the stock pre-arm thresholds.
+func DefaultPreArm() PreArmConfig {
+	return PreArmConfig{MinCellVoltage: 3.5, MaxTiltDeg: 25, MinEngineEff: 0.9}
+}
+
+// Calibration records which sensors have been calibrated. An uncalibrated
+// sensor blocks arming.
+type Calibration struct {
+	Accel   bool
+	Gyro    bool
+	Compass bool
+}
+
+// PreArmChecks runs every pre-arm check and returns the failures.
+func (d *Drone) PreArmChecks() []PreArmFailure {
+	var out []PreArmFailure
+	fail := func(c PreArmCheck, format string, args ...any) {
+		out = append(out, PreArmFailure{Check: c, Reason: fmt.Sprintf(format, args...)})
+	}
+	cfg := d.PreArm
+
+	if !d.OnGround {
+		fail(CheckLanded, "not on the ground")
+	}
+	if d.Destroyed {
+		fail(CheckEngines, "airframe destroyed")
+	}
+	if d.BatteryPercent <= d.CriticalBattery {
+		fail(CheckBattery, "battery %.0f%% at or below critical %.0f%%", d.BatteryPercent, d.CriticalBattery)
+	}
+	if cell := d.BatteryVoltage() / batteryCells; cell < cfg.MinCellVoltage {
+		fail(CheckVoltage, "cell voltage %.2f V below %.2f V", cell, cfg.MinCellVoltage)
+	}
+	maxTilt := cfg.MaxTiltDeg * math.Pi / 180
+	if tilt := math.Max(math.Abs(d.Rotation.X), math.Abs(d.Rotation.Z)); tilt > maxTilt {
+		fail(CheckTilt, "tilted %.0f deg (max %.0f deg)", tilt*180/math.Pi, cfg.MaxTiltDeg)
+	}
+	for i, e := range d.Engines {
+		switch {
+		case !e.Functional:
+			fail(CheckEngines, "engine %d failed", i)
+		case e.Efficiency < cfg.MinEngineEff:
+			fail(CheckEngines, "engine %d at %.0f%% thrust", i, e.Efficiency*100)
+		}
+	}
+	if d.PositionLost {
+		fail(CheckPosition, "no position estimate")
+	}
+	for _, f := range d.Geofences {
+		if f.Violated(d.Position) {
+			if f.Inclusion {
+				fail(CheckGeofence, "outside geofence %s", f.Name)
+			} else {
+				fail(CheckGeofence, "inside exclusion zone %s", f.Name)
+			}
+		}
+	}
+	var uncal []string
+	if !d.Calibration.Accel {
+		uncal = append(uncal, "accelerometer")
+	}
+	if !d.Calibration.Gyro {
+		uncal = append(uncal, "gyro")
+	}
+	if !d.Calibration.Compass {
+		uncal = append(uncal, "compass")
+	}
+	if len(uncal) > 0 {
+		fail(CheckCalibration, "%s not calibrated", strings.Join(uncal, ", "))
+	}
+	return out
+}
@@ -0,0 +1,274 @@
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Precision landing on a (moving) platform. The approach flies to the
+// platform's reported position; once a downward camera sees the deck's
+// fiducial, the drone tracks the marker's measured relative position and
+// velocity instead, descends while it is centred over it, and in the last
+// few decimetres pushes down onto the deck while matching its motion. If the
+// marker is lost the drone climbs back to the approach height and reacquires.
+
+// PrecisionLandPhase is a step of the landing.
+type PrecisionLandPhase int
+
+const (
+	PrecLandApproach  PrecisionLandPhase = iota // flying to the platform, marker not tracked
+	PrecLandDescend                             // tracking the marker, descending
+	PrecLandTouchdown                           // committed: pushing onto the deck
+	PrecLandLanded                              // on the deck and disarmed
+)
+
+func (p PrecisionLandPhase) String() string {
+	switch p {
+	case PrecLandDescend:
+		return "Descend"
+	case PrecLandTouchdown:
+		return "Touchdown"
+	case PrecLandLanded:
+		return "Landed"
+	}
+	return "Approach"
+}
+
+// Precision-land defaults
+const (
+	precLandDefaultAltitude = 8.0  // m above the deck for the approach
+	precLandDefaultDescent  = 0.8  // m/s relative to the deck
+	precLandDefaultOffset   = 0.4  // m horizontal error above which the descent pauses
+	precLandCommitHeight    = 0.4  // m above the deck where touchdown commits
+	precLandLostTimeout     = 1.0  // s without a fix before going around
+	precLandAcquireRange    = 2.0  // m horizontal from the marker to start descending
+	precLandAlpha           = 0.6  // fix blend into the relative-position estimate
+	precLandBeta            = 0.25 // fix blend into the relative-velocity estimate
+)
+
+// FiducialSensor is a camera on a stabilised downward mount that measures
+// the position of a landing marker relative to the drone.
+type FiducialSensor struct {
+	FOV   float64 // half-angle from straight down in radians
+	Range float64 // m, beyond which the marker cannot be resolved
+	Rate  float64 // Hz
+	Noise float64 // m, 1σ per axis at 10 m range (scales with range)
+	Seed  int64   // noise seed
+	rng   *rand.Rand
+	age   float64
+}
+
+// DefaultFiducial returns a typical marker camera.
+func DefaultFiducial() FiducialSensor {
+	return FiducialSensor{FOV: 35 * math.Pi / 180, Range: 25, Rate: 20, Noise: 0.05, Seed: 1}
+}
+
+// measure returns a noisy relative position of marker from pos when a new
+// frame is due and the marker is in view.
+func (s *FiducialSensor) measure(pos, marker Vec3, dt float64) (Vec3, bool) {
+	s.age += dt
+	if s.Rate > 0 && s.age < 1/s.Rate {
+		return Vec3{}, false
+	}
+	s.age = 0
+	rel := marker.Sub(pos)
+	dist := rel.Length()
+	if dist > s.Range || rel.Y >= 0 || math.Atan2(math.Hypot(rel.X, rel.Z), -rel.Y) > s.FOV {
+		return Vec3{}, false
+	}
+	if s.rng == nil {
+		s.rng = rand.New(rand.NewSource(s.Seed))
+	}
+	sigma := s.Noise * math.Max(dist, 1) / 10
+	return rel.Add(Vec3{X: s.rng.NormFloat64(), Y: s.rng.NormFloat64(), Z: s.rng.NormFloat64()}.Mul(sigma)), true
+}
+
+// PrecisionLandConfig describes a precision landing.
+type PrecisionLandConfig struct {
+	Platform    *Platform
+	Altitude    float64 // m above the deck for the approach (0 = default)
+	DescentRate float64 // m/s relative to the deck (0 = default)
+	MaxOffset   float64 // m horizontal error above which the descent pauses (0 = default)
+}
+
+// PrecisionLandStatus reports the landing.
+type PrecisionLandStatus struct {
+	Platform       string
+	Phase          PrecisionLandPhase
+	Locked         bool    // the marker is being tracked
+	Relative       Vec3    // estimated marker position minus drone position
+	RelVelocity    Vec3    // estimated marker velocity minus drone velocity
+	Height         float64 // m above the deck (from the estimate while locked)
+	FixAge         float64 // s since the last marker fix
+	TouchdownError float64 // m from the marker at touchdown (Landed)
+	TouchdownSpeed float64 // m/s relative to the deck at touchdown (Landed)
+}
+
+type precLandState struct {
+	cfg    PrecisionLandConfig
+	phase  PrecisionLandPhase
+	locked bool
+	rel    Vec3 // alpha-beta estimate of marker minus drone
+	relVel Vec3
+	fixAge float64
+	height float64 // commanded height above the deck
+	tdErr  float64
+	tdVel  float64
+}
+
+// StartPrecisionLand switches the drone to FlightModePrecisionLand.
+func (d *Drone) StartPrecisionLand(cfg PrecisionLandConfig) error {
+	if !d.IsArmed {
+		return errors.New("precision land: drone is not armed")
+	}
+	if cfg.Platform == nil {
+		return errors.New("precision land: no platform")
+	}
+	if d.action != ActionNone || d.ftActive {
+		return errors.New("precision land: a safety action is in control")
+	}
+	if cfg.Altitude <= 0 {
+		cfg.Altitude = precLandDefaultAltitude
+	}
+	if cfg.DescentRate <= 0 {
+		cfg.DescentRate = precLandDefaultDescent
+	}
+	if cfg.MaxOffset <= 0 {
+		cfg.MaxOffset = precLandDefaultOffset
+	}
+	d.precLand = &precLandState{cfg: cfg, fixAge: math.Inf(1), height: cfg.Altitude}
+	d.SetFlightMode(FlightModePrecisionLand)
+	return nil
+}
+
+// PrecisionLandStatus returns the landing state and whether the drone is
+// precision landing (or has just landed that way).
+func (d *Drone) PrecisionLandStatus() (PrecisionLandStatus, bool) {
+	p := d.precLand
+	if p == nil || d.FlightMode != FlightModePrecisionLand {
+		return PrecisionLandStatus{}, false
+	}
+	st := PrecisionLandStatus{
+		Platform:       p.cfg.Platform.Name,
+		Phase:          p.phase,
+		Locked:         p.locked,
+		Relative:       p.rel,
+		RelVelocity:    p.relVel,
+		FixAge:         p.fixAge,
+		TouchdownError: p.tdErr,
+		TouchdownSpeed: p.tdVel,
+	}
+	if p.locked {
+		st.Height = -p.rel.Y
+	} else {
+		st.Height = d.navPosition().Y - p.cfg.Platform.Position.Y
+	}
+	return st, true
+}
+
+// updatePrecisionLand runs the marker tracker and flies the current phase.
+func (d *Drone) updatePrecisionLand(dt float64) {
+	p := d.precLand
+	if p == nil || p.cfg.Platform == nil {
+		d.SetFlightMode(FlightModeHover)
+		return
+	}
+	if p.phase == PrecLandLanded {
+		return
+	}
+	pl := p.cfg.Platform
+	name := pl.Name
+	if d.World != nil && d.World.Platform(name) != pl {
+		// The platform was removed or replaced: nothing left to land on
+		d.emit(Event{Kind: "precland.aborted", Source: name, Action: "Hover"})
+		d.SetFlightMode(FlightModeHover)
+		return
+	}
+
+	// Alpha-beta tracker on the marker's relative position
+	p.rel = p.rel.Add(p.relVel.Mul(dt))
+	p.fixAge += dt
+	if z, ok := d.Fiducial.measure(d.Position, pl.MarkerPosition(), dt); ok {
+		if !p.locked {
+			p.rel, p.relVel = z, Vec3{}
+			p.locked = true
+		} else {
+			r := z.Sub(p.rel)
+			p.rel = p.rel.Add(r.Mul(precLandAlpha))
+			p.relVel = p.relVel.Add(r.Mul(precLandBeta / math.Max(p.fixAge, dt)))
+		}
+		p.fixAge = 0
+	}
+	if p.locked && p.fixAge > precLandLostTimeout && p.phase != PrecLandTouchdown {
+		p.locked = false
+		if p.phase == PrecLandDescend {
+			p.phase = PrecLandApproach
+			p.height = p.cfg.Altitude
+			d.emit(Event{Kind: "precland.lost", Source: name, Action: "Climb"})
+		}
+	}
+
+	switch p.phase {
+	case PrecLandApproach:
+		// Fly over the platform's reported position
+		over := pl.MarkerPosition().Add(Vec3{Y: p.cfg.Altitude})
+		d.trackSetpoint(NavSetpoint{
+			Position:  over,
+			Velocity:  Vec3{X: pl.Velocity.X, Z: pl.Velocity.Z},
+			Yaw:       pl.Rotation.Y,
+			ClimbRate: math.Abs(pl.Velocity.Y) + navClimbRate,
+		}, dt)
+		if p.locked && math.Hypot(p.rel.X, p.rel.Z) < precLandAcquireRange {
+			p.phase = PrecLandDescend
+			p.height = math.Min(-p.rel.Y, p.cfg.Altitude)
+			d.emit(Event{Kind: "precland.acquired", Source: name})
+		}
+	case PrecLandDescend, PrecLandTouchdown:
+		deckVel := d.navVelocity().Add(p.relVel)
+		offset := math.Hypot(p.rel.X, p.rel.Z)
+		if p.phase == PrecLandDescend {
+			if offset < p.cfg.MaxOffset {
+				p.height = math.Max(p.height-p.cfg.DescentRate*dt, 0)
+			}
+			if -p.rel.Y < precLandCommitHeight+d.groundClearance() && offset < p.cfg.MaxOffset {
+				p.phase = PrecLandTouchdown
+			}
+		}
+		target := d.navPosition().Add(p.rel)
+		climb := math.Abs(deckVel.Y) + p.cfg.DescentRate + 0.5
+		if p.phase == PrecLandTouchdown {
+			// Aim below the deck so contact is positive; the altitude target
+			// rides the deck's heave
+			target.Y -= 0.3
+			climb = 10
+		} else {
+			target.Y += p.height + d.groundClearance()
+		}
+		d.trackSetpoint(NavSetpoint{
+			Position:  target,
+			Velocity:  Vec3{X: deckVel.X, Z: deckVel.Z},
+			Yaw:       pl.Rotation.Y,
+			ClimbRate: climb,
+		}, dt)
+	}
+
+	if p.phase == PrecLandApproach {
+		return
+	}
+	v := pl.Velocity
+	if _, sv, ok := pl.Surface(d.Position.X, d.Position.Z); ok {
+		v = sv
+	}
+	if !d.OnGround {
+		// Closing speed on the deck, kept from the last step before contact
+		p.tdVel = d.Velocity.Sub(v).Length()
+		return
+	}
+	p.tdErr = horizontalDistance(d.Position, pl.MarkerPosition())
+	p.phase = PrecLandLanded
+	d.Disarm()
+	d.emit(Event{Kind: "precland.landed", Source: name, Position: d.Position,
+		Detail: fmt.Sprintf("offset %.2f m, closing %.2f m/s", p.tdErr, p.tdVel)})
+}
@@ -0,0 +1,396 @@
+package sim
+
+import (
+	"image"
+	"image/png"
+	"io"
+	"math"
+	"os"
+)
+
+// CameraView is a pinhole camera for Render. Like Gimbal and SensorMount it
+// looks along its own +Z with +Y up the picture; the frame is right-handed,
+// so +X is to its left.
+type CameraView struct {
+	Position Vec3
+	Rotation Vec3    // Euler angles like Drone.Rotation
+	FOV      float64 // rad vertical field of view
+	Width    int     // pixels
+	Height   int
+	MaxRange float64 // m along the optical axis; farther scenery is sky (0 = 500)
+}
+
+// FocalLength returns the focal length in pixels; the principal point is the
+// centre of the image.
+func (v CameraView) FocalLength() float64 {
+	return float64(v.Height) / 2 / math.Tan(v.FOV/2)
+}
+
+// CameraImage is one rendered frame.
+type CameraImage struct {
+	Time  float64 // s of simulation
+	View  CameraView
+	RGB   *image.RGBA
+	Depth []float32 // m along the optical axis, row by row; 0 where nothing is within MaxRange
+	Count int
+}
+
+// DepthAt returns the depth of pixel x, y (0 where nothing is in range).
+func (img *CameraImage) DepthAt(x, y int) float64 {
+	return float64(img.Depth[y*img.View.Width+x])
+}
+
+// DepthImage returns the depth in millimetres, saturating at 65.535 m, with 0
+// where nothing is in range: the usual 16-bit depth-camera encoding.
+func (img *CameraImage) DepthImage() *image.Gray16 {
+	out := image.NewGray16(image.Rect(0, 0, img.View.Width, img.View.Height))
+	for i, z := range img.Depth {
+		mm := math.Min(math.Round(float64(z)*1000), math.MaxUint16)
+		out.Pix[2*i], out.Pix[2*i+1] = uint8(uint16(mm)>>8), uint8(uint16(mm))
+	}
+	return out
+}
+
+// WritePNG encodes the colour image as PNG.
+func (img *CameraImage) WritePNG(w io.Writer) error { return png.Encode(w, img.RGB) }
+
+// WriteDepthPNG encodes the depth image as a 16-bit greyscale PNG.
+func (img *CameraImage) WriteDepthPNG(w io.Writer) error { return png.Encode(w, img.DepthImage()) }
+
+// SavePNG writes the colour image to rgbPath and the depth image to
+// depthPath; an empty path skips that image.
+func (img *CameraImage) SavePNG(rgbPath, depthPath string) error {
+	for _, out := range []struct {
+		path  string
+		write func(io.Writer) error
+	}{{rgbPath, img.WritePNG}, {depthPath, img.WriteDepthPNG}} {
+		if out.path == "" {
+			continue
+		}
+		f, err := os.Create(out.path)
+		if err != nil {
+			return err
+		}
+		if err := out.write(f); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Scene colours follow the OpenGL renderer.
+var (
+	skyHorizon   = [3]float64{0.5, 0.7, 0.9}
+	skyZenith    = [3]float64{0.3, 0.5, 0.85}
+	groundA      = [3]float64{0.28, 0.65, 0.28}
+	groundB      = [3]float64{0.24, 0.58, 0.24}
+	groundLine   = [3]float64{0.18, 0.42, 0.18}
+	obstacleGrey = [3]float64{0.6, 0.6, 0.62}
+	deckGrey     = [3]float64{0.35, 0.35, 0.38}
+	droneRed     = [3]float64{0.8, 0.2, 0.2}
+	markerWhite  = [3]float64{0.95, 0.95, 0.95}
+	markerBlack  = [3]float64{0.05, 0.05, 0.05}
+)
+
+const (
+	renderNear    = 0.05  // m near clipping plane
+	groundTile    = 2.0   // m checker squares, as drawn by the OpenGL renderer
+	groundLineW   = 0.04  // m grid lines between them
+	renderAmbient = 0.45  // light on faces turned from the sun
+	markerLift    = 0.02  // m the landing marker sits above its deck
+	defaultRange  = 500.0 // m when CameraView.MaxRange is 0
+)
+
+// sunDir is the unit direction toward the sun.
+var sunDir = Vec3{X: 0.4, Y: 1, Z: -0.3}.Normalize()
+
+// Render draws the world from view in software, needing no GPU or window:
+// the terrain (flat ground without one), obstacle boxes, platform decks and
+// the airframes of the world's drones other than skip, lit by a fixed sun
+// under a sky gradient and fading into haze toward MaxRange. Terrain is
+// drawn only over its grid. The ground carries the renderer's 2 m checker so
+// image motion can be tracked.
+func (w *World) Render(view CameraView, skip *Drone) *CameraImage {
+	if view.Width < 1 || view.Height < 1 || view.FOV <= 0 || view.FOV >= math.Pi {
+		return nil
+	}
+	r := newRaster(view)
+	r.background(w == nil || w.Terrain == nil)
+	if w == nil {
+		return r.img
+	}
+	if w.Terrain != nil {
+		r.terrain(w.Terrain)
+	}
+	level := mat3FromEuler(Vec3{})
+	for _, o := range w.Obstacles {
+		r.box(o.Min.Add(o.Max).Mul(0.5), level, o.Max.Sub(o.Min).Mul(0.5), obstacleGrey)
+	}
+	for _, p := range w.Platforms {
+		r.platform(p)
+	}
+	for _, d := range w.Drones {
+		if d == nil || d == skip {
+			continue
+		}
+		half := Vec3{X: d.Dimensions.X / 2, Y: d.Dimensions.Z / 2, Z: d.Dimensions.Y / 2}
+		r.box(d.Position, mat3FromEuler(d.Rotation), half, droneRed)
+	}
+	return r.img
+}
+
+// raster is the state of one Render call.
+type raster struct {
+	img      *CameraImage
+	w, h     int
+	f        float64 // focal length, pixels
+	cx, cy   float64 // principal point
+	far      float64
+	pos      Vec3
+	rot, inv mat3 // camera to world and back
+}
+
+func newRaster(view CameraView) *raster {
+	if view.MaxRange <= 0 {
+		view.MaxRange = defaultRange
+	}
+	rot := mat3FromEuler(view.Rotation)
+	return &raster{
+		img: &CameraImage{
+			View:  view,
+			RGB:   image.NewRGBA(image.Rect(0, 0, view.Width, view.Height)),
+			Depth: make([]float32, view.Width*view.Height),
+		},
+		w:   view.Width,
+		h:   view.Height,
+		f:   view.FocalLength(),
+		cx:  float64(view.Width) / 2,
+		cy:  float64(view.Height) / 2,
+		far: view.MaxRange,
+		pos: view.Position,
+		rot: rot,
+		inv: rot.transpose(),
+	}
+}
+
+// ray returns the world direction through the centre of pixel x, y, scaled so
+// its component along the optical axis is 1. Image x runs along -X.
+func (r *raster) ray(x, y int) Vec3 {
+	return r.rot.mulVec(Vec3{X: (r.cx - float64(x) - 0.5) / r.f, Y: (r.cy - float64(y) - 0.5) / r.f, Z: 1})
+}
+
+// set writes pixel i at depth z, hazing it toward the horizon colour with
+// distance.
+func (r *raster) set(i int, z float64, c [3]float64) {
+	haze := clamp((z-0.5*r.far)/(0.5*r.far), 0, 1)
+	p := r.img.RGB.Pix[4*i : 4*i+4]
+	for k := 0; k < 3; k++ {
+		p[k] = uint8(clamp(c[k]+(skyHorizon[k]-c[k])*haze, 0, 1)*255 + 0.5)
+	}
+	p[3] = 255
+	r.img.Depth[i] = float32(z)
+}
+
+// lighting returns the brightness of a surface of unit normal n.
+func lighting(n Vec3) float64 {
+	return renderAmbient + (1-renderAmbient)*math.Max(n.Dot(sunDir), 0)
+}
+
+// groundColour returns the checker and grid-line colour of the ground at x, z.
+func groundColour(x, z float64) [3]float64 {
+	fx, fz := math.Floor(x/groundTile), math.Floor(z/groundTile)
+	c := groundA
+	if int64(fx+fz)%2 != 0 {
+		c = groundB
+	}
+	// Distance to the nearest tile edge
+	ex := math.Min(x-fx*groundTile, (fx+1)*groundTile-x)
+	ez := math.Min(z-fz*groundTile, (fz+1)*groundTile-z)
+	if math.Min(ex, ez) < groundLineW/2 {
+		for k := range c {
+			c[k] += (groundLine[k] - c[k]) * 0.6
+		}
+	}
+	return c
+}
+
+func scale(c [3]float64, s float64) [3]float64 {
+	return [3]float64{c[0] * s, c[1] * s, c[2] * s}
+}
+
+// background fills the sky and, over flat ground, solves each pixel's ray
+// against the ground plane.
+func (r *raster) background(flat bool) {
+	sun := lighting(Vec3{Y: 1})
+	for y := 0; y < r.h; y++ {
+		for x := 0; x < r.w; x++ {
+			i := y*r.w + x
+			d := r.ray(x, y)
+			if flat && r.pos.Y > 0 && d.Y < 0 {
+				if z := -r.pos.Y / d.Y; z <= r.far && z >= renderNear {
+					p := r.pos.Add(d.Mul(z))
+					r.set(i, z, scale(groundColour(p.X, p.Z), sun))
+					continue
+				}
+			}
+			up := math.Max(d.Y/d.Length(), 0)
+			var c [3]float64
+			for k := range c {
+				c[k] = skyHorizon[k] + (skyZenith[k]-skyHorizon[k])*up
+			}
+			p := r.img.RGB.Pix[4*i : 4*i+4]
+			p[0], p[1], p[2], p[3] = uint8(c[0]*255+0.5), uint8(c[1]*255+0.5), uint8(c[2]*255+0.5), 255
+		}
+	}
+}
+
+// terrain draws the height map's cells within range, two triangles each.
+func (r *raster) terrain(h *HeightMap) {
+	if h.Cols < 2 || h.Rows < 2 || h.Spacing <= 0 || len(h.Heights) < h.Cols*h.Rows {
+		return
+	}
+	at := func(c, row int) Vec3 {
+		return Vec3{X: h.Origin.X + float64(c)*h.Spacing, Y: h.Heights[row*h.Cols+c], Z: h.Origin.Z + float64(row)*h.Spacing}
+	}
+	reach := r.far + h.Spacing
+	for row := 0; row < h.Rows-1; row++ {
+		for c := 0; c < h.Cols-1; c++ {
+			a, b, cc, d := at(c, row), at(c+1, row), at(c+1, row+1), at(c, row+1)
+			mid := a.Add(cc).Mul(0.5)
+			if math.Hypot(mid.X-r.pos.X, mid.Z-r.pos.Z) > reach {
+				continue
+			}
+			r.triangle(a, b, cc, groundA, true)
+			r.triangle(a, cc, d, groundA, true)
+		}
+	}
+}
+
+// boxFaces lists each face of a box as four corners, corner k at
+// (±1, ±1, ±1) with bit 0 for X, 1 for Y and 2 for Z.
+var boxFaces = [6][4]int{
+	{0, 2, 6, 4}, {1, 3, 7, 5}, // -X, +X
+	{0, 1, 5, 4}, {2, 3, 7, 6}, // -Y, +Y
+	{0, 1, 3, 2}, {4, 5, 7, 6}, // -Z, +Z
+}
+
+// box draws a box of half extents half about centre, turned by rot.
+func (r *raster) box(centre Vec3, rot mat3, half Vec3, col [3]float64) {
+	var corner [8]Vec3
+	for k := range corner {
+		v := half
+		if k&1 == 0 {
+			v.X = -v.X
+		}
+		if k&2 == 0 {
+			v.Y = -v.Y
+		}
+		if k&4 == 0 {
+			v.Z = -v.Z
+		}
+		corner[k] = centre.Add(rot.mulVec(v))
+	}
+	for _, f := range boxFaces {
+		r.quad(corner[f[0]], corner[f[1]], corner[f[2]], corner[f[3]], col)
+	}
+}
+
+func (r *raster) quad(a, b, c, d Vec3, col [3]float64) {
+	r.triangle(a, b, c, col, false)
+	r.triangle(a, c, d, col, false)
+}
+
+// platform draws a deck with its landing marker: a white square with a black
+// centre.
+func (r *raster) platform(p *Platform) {
+	c := p.corners()
+	r.quad(c[0], c[1], c[2], c[3], deckGrey)
+	m := p.Marker
+	square := func(half, lift float64, col [3]float64) {
+		at := func(dx, dz float64) Vec3 { return p.world(m.X+dx, m.Z+dz).Add(Vec3{Y: lift}) }
+		r.quad(at(-half, -half), at(half, -half), at(half, half), at(-half, half), col)
+	}
+	square(0.5, markerLift, markerWhite)
+	square(0.25, 2*markerLift, markerBlack)
+}
+
+// triangle lights, clips and fills one world triangle. Textured triangles
+// take the ground checker in place of col.
+func (r *raster) triangle(a, b, c Vec3, col [3]float64, textured bool) {
+	n := b.Sub(a).Cross(c.Sub(a))
+	if n.Length() < 1e-12 {
+		return
+	}
+	n = n.Normalize()
+	if n.Dot(r.pos.Sub(a)) < 0 {
+		n = n.Mul(-1) // the side facing the camera
+	}
+	light := lighting(n)
+
+	poly := make([]Vec3, 0, 4)
+	in := [3]Vec3{r.inv.mulVec(a.Sub(r.pos)), r.inv.mulVec(b.Sub(r.pos)), r.inv.mulVec(c.Sub(r.pos))}
+	for i := range in {
+		p, q := in[i], in[(i+1)%3]
+		if p.Z >= renderNear {
+			poly = append(poly, p)
+		}
+		if (p.Z >= renderNear) != (q.Z >= renderNear) {
+			t := (renderNear - p.Z) / (q.Z - p.Z)
+			poly = append(poly, p.Add(q.Sub(p).Mul(t)))
+		}
+	}
+	for i := 1; i+1 < len(poly); i++ {
+		r.fill(poly[0], poly[i], poly[i+1], scale(col, light), light, textured)
+	}
+}
+
+// fill rasterises a camera-frame triangle in front of the near plane,
+// interpolating 1/depth across the screen.
+func (r *raster) fill(a, b, c Vec3, col [3]float64, light float64, textured bool) {
+	type vert struct{ x, y, iz float64 }
+	proj := func(p Vec3) vert {
+		return vert{x: r.cx - r.f*p.X/p.Z, y: r.cy - r.f*p.Y/p.Z, iz: 1 / p.Z}
+	}
+	v0, v1, v2 := proj(a), proj(b), proj(c)
+	if a.Z > r.far && b.Z > r.far && c.Z > r.far {
+		return
+	}
+	edge := func(p, q vert, x, y float64) float64 {
+		return (q.x-p.x)*(y-p.y) - (q.y-p.y)*(x-p.x)
+	}
+	area := edge(v0, v1, v2.x, v2.y)
+	if math.Abs(area) < 1e-12 {
+		return
+	}
+	x0 := max(int(math.Floor(math.Min(v0.x, math.Min(v1.x, v2.x)))), 0)
+	x1 := min(int(math.Ceil(math.Max(v0.x, math.Max(v1.x, v2.x)))), r.w-1)
+	y0 := max(int(math.Floor(math.Min(v0.y, math.Min(v1.y, v2.y)))), 0)
+	y1 := min(int(math.Ceil(math.Max(v0.y, math.Max(v1.y, v2.y)))), r.h-1)
+	for y := y0; y <= y1; y++ {
+		py := float64(y) + 0.5
+		for x := x0; x <= x1; x++ {
+			px := float64(x) + 0.5
+			w0 := edge(v1, v2, px, py) / area
+			w1 := edge(v2, v0, px, py) / area
+			w2 := 1 - w0 - w1
+			if w0 < 0 || w1 < 0 || w2 < 0 {
+				continue
+			}
+			z := 1 / (w0*v0.iz + w1*v1.iz + w2*v2.iz)
+			i := y*r.w + x
+			if z > r.far || (r.img.Depth[i] != 0 && float64(r.img.Depth[i]) <= z) {
+				continue
+			}
+			shade := col
+			if textured {
+				p := r.pos.Add(r.ray(x, y).Mul(z))
+				shade = scale(groundColour(p.X, p.Z), light)
+			}
+			r.set(i, z, shade)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package sim
+
+import "math"
+
+// RayHitKind is what a ray struck.
+type RayHitKind int
+
+const (
+	RayMiss RayHitKind = iota
+	RayGround
+	RayObstacle
+	RayPlatform
+	RayDrone
+)
+
+func (k RayHitKind) String() string {
+	switch k {
+	case RayGround:
+		return "Ground"
+	case RayObstacle:
+		return "Obstacle"
+	case RayPlatform:
+		return "Platform"
+	case RayDrone:
+		return "Drone"
+	}
+	return "None"
+}
+
+// RayHit is the first surface along a ray.
+type RayHit struct {
+	Kind     RayHitKind
+	Distance float64 // m from the ray origin; 0 on a miss
+	Point    Vec3
+	Velocity Vec3   // of the surface struck (decks and drones move)
+	Name     string // of the obstacle or platform struck
+}
+
+// Raycast returns the first surface within maxRange along the ray from origin
+// in the unit direction dir: the terrain (flat ground without one), obstacle
+// boxes, platform decks and the airframes of the world's drones other than
+// skip.
+func (w *World) Raycast(origin, dir Vec3, maxRange float64, skip *Drone) RayHit {
+	return w.rayCaster(skip).cast(origin, dir, maxRange)
+}
+
+// rayCaster holds what a batch of rays against one world shares.
+type rayCaster struct {
+	w          *World
+	skip       *Drone
+	terrainTop float64
+}
+
+func (w *World) rayCaster(skip *Drone) rayCaster {
+	rc := rayCaster{w: w, skip: skip}
+	if w != nil {
+		rc.terrainTop = w.Terrain.maxHeight()
+	}
+	return rc
+}
+
+// cast traces one ray.
+func (rc rayCaster) cast(origin, dir Vec3, maxRange float64) RayHit {
+	hit := RayHit{}
+	best := maxRange
+	if t, ok := rc.ground(origin, dir, best); ok {
+		hit, best = RayHit{Kind: RayGround, Distance: t}, t
+	}
+	if w := rc.w; w != nil {
+		for _, o := range w.Obstacles {
+			if t, ok := rayBox(origin, dir, o.Min, o.Max); ok && t < best {
+				hit, best = RayHit{Kind: RayObstacle, Distance: t, Name: o.Name}, t
+			}
+		}
+		for _, p := range w.Platforms {
+			if t, ok := p.raycast(origin, dir); ok && t < best {
+				x := origin.Add(dir.Mul(t))
+				_, v, _ := p.Surface(x.X, x.Z)
+				hit, best = RayHit{Kind: RayPlatform, Distance: t, Velocity: v, Name: p.Name}, t
+			}
+		}
+		for _, d := range w.Drones {
+			if d == nil || d == rc.skip {
+				continue
+			}
+			lo, hi := d.bounds()
+			if t, ok := rayBox(origin, dir, lo, hi); ok && t < best {
+				hit, best = RayHit{Kind: RayDrone, Distance: t, Velocity: d.Velocity}, t
+			}
+		}
+	}
+	if hit.Kind != RayMiss {
+		hit.Point = origin.Add(dir.Mul(hit.Distance))
+	}
+	return hit
+}
+
+// rayTerrainSteps bounds the march along a ray over terrain.
+const rayTerrainSteps = 2000
+
+// ground returns the distance along the ray to the terrain within maxRange.
+// Flat ground is solved exactly; terrain is marched at half its sample
+// spacing and the crossing refined by bisection.
+func (rc rayCaster) ground(origin, dir Vec3, maxRange float64) (float64, bool) {
+	var terrain *HeightMap
+	if rc.w != nil {
+		terrain = rc.w.Terrain
+	}
+	if terrain == nil {
+		if origin.Y <= 0 {
+			return 0, true
+		}
+		if dir.Y >= 0 {
+			return 0, false
+		}
+		t := -origin.Y / dir.Y
+		return t, t <= maxRange
+	}
+
+	above := func(t float64) float64 {
+		p := origin.Add(dir.Mul(t))
+		return p.Y - terrain.Height(p.X, p.Z)
+	}
+	if above(0) <= 0 {
+		return 0, true
+	}
+	// Nothing to hit until the ray has come down to the highest sample
+	start := 0.0
+	if origin.Y > rc.terrainTop {
+		if dir.Y >= 0 {
+			return 0, false
+		}
+		start = (origin.Y - rc.terrainTop) / -dir.Y
+	}
+	step := math.Max(terrain.Spacing/2, (maxRange-start)/rayTerrainSteps)
+	for t0 := start; t0 < maxRange; t0 += step {
+		t1 := math.Min(t0+step, maxRange)
+		if above(t1) > 0 {
+			continue
+		}
+		for i := 0; i < 20; i++ {
+			mid := (t0 + t1) / 2
+			if above(mid) > 0 {
+				t0 = mid
+			} else {
+				t1 = mid
+			}
+		}
+		return t1, true
+	}
+	return 0, false
+}
+
+// rayBox returns the distance along the ray to the box lo..hi (0 from
+// inside it), and whether it hits.
+func rayBox(origin, dir, lo, hi Vec3) (float64, bool) {
+	near, far := 0.0, math.Inf(1)
+	for _, ax := range [3][4]float64{
+		{origin.X, dir.X, lo.X, hi.X},
+		{origin.Y, dir.Y, lo.Y, hi.Y},
+		{origin.Z, dir.Z, lo.Z, hi.Z},
+	} {
+		p, d, a, b := ax[0], ax[1], ax[2], ax[3]
+		if math.Abs(d) < 1e-12 {
+			if p < a || p > b {
+				return 0, false
+			}
+			continue
+		}
+		t0, t1 := (a-p)/d, (b-p)/d
+		if t0 > t1 {
+			t0, t1 = t1, t0
+		}
+		near, far = math.Max(near, t0), math.Min(far, t1)
+		if near > far {
+			return 0, false
+		}
+	}
+	return near, true
+}
+
+// raycast returns the distance along the ray to the deck, and whether it
+// hits. The deck is a plane through its centre, tilted by pitch and roll.
+func (p *Platform) raycast(origin, dir Vec3) (float64, bool) {
+	// Deck height is linear in x/z: y = P.y + a·(x-P.x) + b·(z-P.z)
+	c, s := math.Cos(p.Rotation.Y), math.Sin(p.Rotation.Y)
+	tp, tr := math.Tan(p.Rotation.X), math.Tan(p.Rotation.Z)
+	a, b := -s*tp-c*tr, c*tp-s*tr
+	den := dir.Y - a*dir.X - b*dir.Z
+	if math.Abs(den) < 1e-12 {
+		return 0, false
+	}
+	t := (p.Position.Y + a*(origin.X-p.Position.X) + b*(origin.Z-p.Position.Z) - origin.Y) / den
+	if t < 0 {
+		return 0, false
+	}
+	x := origin.Add(dir.Mul(t))
+	_, _, on := p.Surface(x.X, x.Z)
+	return t, on
+}
+
+// bounds returns the drone's airframe as a world box, level and wide enough
+// for any heading.
+func (d *Drone) bounds() (Vec3, Vec3) {
+	r := math.Max(d.Dimensions.X, d.Dimensions.Y) / 2
+	h := d.Dimensions.Z / 2
+	return d.Position.Sub(Vec3{X: r, Y: h, Z: r}), d.Position.Add(Vec3{X: r, Y: h, Z: r})
+}
@@ -0,0 +1,311 @@
+package sim
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Ray-cast sensors see the world geometrically: the ground, terrain,
+// obstacles, platform decks and other drones. Each is mounted on the airframe
+// with a SensorMount and sampled at its own Rate; a drone carries at most one
+// of each, nil until mounted.
+
+// SensorMount places a sensor on the airframe: Offset from the drone's centre
+// in body axes (X left, Y up, Z nose) and Rotation of the sensor frame from
+// the body (pitch X, yaw Y, roll Z, like Drone.Rotation). A sensor looks
+// along its own +Z.
+type SensorMount struct {
+	Offset   Vec3
+	Rotation Vec3
+}
+
+// DownwardMount looks straight down, sensor X to the left and Y to the nose.
+func DownwardMount() SensorMount {
+	return SensorMount{Rotation: Vec3{X: -math.Pi / 2}}
+}
+
+// pose returns the sensor's world position and sensor-to-world rotation on d.
+func (m SensorMount) pose(d *Drone) (Vec3, mat3) {
+	body := mat3FromEuler(d.Rotation)
+	return d.Position.Add(body.mulVec(m.Offset)), body.mul(mat3FromEuler(m.Rotation))
+}
+
+// Rangefinder is a single-beam distance sensor (laser or sonar).
+type Rangefinder struct {
+	Mount    SensorMount
+	Rate     float64 // Hz
+	MinRange float64 // m; nearer returns are invalid
+	MaxRange float64 // m
+	Noise    float64 // m, 1σ
+	Faults   FaultSchedule
+
+	rng     *rand.Rand
+	age     float64
+	reading RangeReading
+}
+
+// RangeReading is one rangefinder output.
+type RangeReading struct {
+	Time     float64 // s of simulation
+	Distance float64 // m along the beam; 0 without a valid return
+	Valid    bool
+	Target   RayHitKind
+	Count    int
+}
+
+// DefaultRangefinder returns a downward lidar altimeter at 50 Hz.
+func DefaultRangefinder() Rangefinder {
+	return Rangefinder{Mount: DownwardMount(), Rate: 50, MinRange: 0.05, MaxRange: 12, Noise: 0.02}
+}
+
+// Reading returns the latest rangefinder output.
+func (r *Rangefinder) Reading() RangeReading { return r.reading }
+
+// OpticalFlow is a downward camera tracking the image motion of the surface
+// below, with its own range to it. The flow is the angular rate at which the
+// surface crosses the image centre, along the sensor's X and Y axes: -v/h for
+// translation over height, plus the body rotation, which the sensor reports
+// alongside for compensation. Looking down, flying forward gives a negative
+// FlowY and sliding left a negative FlowX, as do pitching nose up and rolling
+// right. Tracking quality falls with poor texture, with range and with fast
+// image motion, and the noise rises as it falls.
+type OpticalFlow struct {
+	Mount      SensorMount
+	Rate       float64 // Hz
+	MaxRange   float64 // m at which the image is too coarse to track
+	MaxFlow    float64 // rad/s of image motion at which tracking fails
+	Noise      float64 // rad/s, 1σ at full quality
+	Texture    float64 // 0..1, how much texture the surface below shows
+	MinQuality float64 // 0..1, below which the reading is invalid
+	Faults     FaultSchedule
+
+	rng     *rand.Rand
+	age     float64
+	reading FlowReading
+}
+
+// FlowReading is one optical-flow output.
+type FlowReading struct {
+	Time     float64 // s of simulation
+	FlowX    float64 // rad/s image motion along sensor X
+	FlowY    float64 // rad/s image motion along sensor Y
+	BodyRate Vec3    // rad/s, the sensor's own rotation in its frame
+	Distance float64 // m to the surface; 0 without one in range
+	Quality  float64 // 0..1
+	Valid    bool
+	Count    int
+}
+
+// DefaultOpticalFlow returns a downward flow sensor at 20 Hz over a
+// moderately textured surface.
+func DefaultOpticalFlow() OpticalFlow {
+	return OpticalFlow{Mount: DownwardMount(), Rate: 20, MaxRange: 20, MaxFlow: 6, Noise: 0.02, Texture: 0.8, MinQuality: 0.2}
+}
+
+// Reading returns the latest optical-flow output.
+func (f *OpticalFlow) Reading() FlowReading { return f.reading }
+
+// Lidar is a scanning range sensor. Each scan casts Channels rows of Samples
+// beams: azimuths spread over HorizontalFOV about the sensor's +Z (positive
+// toward +X), channels spread over VerticalFOV in elevation. One channel is a
+// 2D planar scanner, several a 3D one.
+type Lidar struct {
+	Mount         SensorMount
+	Rate          float64 // scans per second
+	HorizontalFOV float64 // rad; 2π for a full turn
+	Samples       int     // beams per channel
+	Channels      int
+	VerticalFOV   float64 // rad between the lowest and highest channel
+	MinRange      float64 // m
+	MaxRange      float64 // m
+	Noise         float64 // m, 1σ
+	Faults        FaultSchedule
+
+	rng  *rand.Rand
+	age  float64
+	scan LidarScan
+}
+
+// LidarScan is one lidar sweep.
+type LidarScan struct {
+	Time     float64 // s of simulation
+	Origin   Vec3    // sensor world position
+	Rotation Vec3    // sensor world attitude, Euler angles like Drone.Rotation
+	Channels int
+	Samples  int
+	Ranges   []float64 // Channels rows of Samples; 0 without a return
+	Points   []Vec3    // returns in the sensor frame
+	Count    int
+}
+
+// Range returns the range of beam sample of channel (0 without a return).
+func (s LidarScan) Range(channel, sample int) float64 {
+	return s.Ranges[channel*s.Samples+sample]
+}
+
+// DefaultLidar returns a 2D scanner: 360 beams in the plane of the mount at
+// 10 Hz.
+func DefaultLidar() Lidar {
+	return Lidar{Rate: 10, HorizontalFOV: 2 * math.Pi, Samples: 360, Channels: 1, MinRange: 0.1, MaxRange: 30, Noise: 0.02}
+}
+
+// DefaultLidar3D returns a 16-channel spinning lidar covering ±15°.
+func DefaultLidar3D() Lidar {
+	l := DefaultLidar()
+	l.Samples, l.Channels, l.VerticalFOV, l.MaxRange = 720, 16, 30*math.Pi/180, 50
+	return l
+}
+
+// Scan returns the latest lidar sweep.
+func (l *Lidar) Scan() LidarScan { return l.scan }
+
+// direction returns the sensor-frame unit vector of beam sample of channel.
+func (l *Lidar) direction(channel, sample int) Vec3 {
+	az := 0.0
+	if l.HorizontalFOV >= 2*math.Pi {
+		az = 2*math.Pi*float64(sample)/float64(l.Samples) - math.Pi
+	} else {
+		az = l.HorizontalFOV * ((float64(sample)+0.5)/float64(l.Samples) - 0.5)
+	}
+	el := 0.0
+	if l.Channels > 1 {
+		el = l.VerticalFOV * (float64(channel)/float64(l.Channels-1) - 0.5)
+	}
+	return Vec3{X: math.Sin(az) * math.Cos(el), Y: math.Sin(el), Z: math.Cos(az) * math.Cos(el)}
+}
+
+// sampleDue advances a sensor's clock by dt and reports whether a sample at
+// rate is due, and how long ago it fell due. Missed samples are not replayed:
+// ray casting is dear and the newest is the one wanted.
+func sampleDue(age *float64, rate, dt float64) (bool, float64) {
+	if rate <= 0 {
+		return false, 0
+	}
+	period := 1 / rate
+	*age += dt
+	if *age < period {
+		return false, 0
+	}
+	*age = math.Mod(*age, period)
+	return true, *age
+}
+
+// updateRaySensors samples the mounted ray-cast sensors.
+func (d *Drone) updateRaySensors(dt float64) {
+	if d.Rangefinder == nil && d.Flow == nil && d.Lidar == nil {
+		return
+	}
+	rc := d.World.rayCaster(d)
+	if r := d.Rangefinder; r != nil {
+		if ok, late := sampleDue(&r.age, r.Rate, dt); ok {
+			r.measure(d, rc, d.clock-late)
+		}
+	}
+	if f := d.Flow; f != nil {
+		if ok, late := sampleDue(&f.age, f.Rate, dt); ok {
+			f.measure(d, rc, d.clock-late)
+		}
+	}
+	if l := d.Lidar; l != nil {
+		if ok, late := sampleDue(&l.age, l.Rate, dt); ok {
+			l.measure(d, rc, d.clock-late)
+		}
+	}
+}
+
+func (r *Rangefinder) measure(d *Drone, rc rayCaster, t float64) {
+	if r.rng == nil {
+		r.rng = sensorRand(d.Seed, "rangefinder")
+		r.Faults.init(d.Seed, "rangefinder")
+	}
+	pos, rot := r.Mount.pose(d)
+	hit := rc.cast(pos, rot.mulVec(Vec3{Z: 1}), r.MaxRange)
+	out := RangeReading{Time: t, Target: hit.Kind}
+	if hit.Kind != RayMiss && hit.Distance >= r.MinRange {
+		out.Distance = math.Max(hit.Distance+r.Noise*r.rng.NormFloat64(), 0)
+		out.Valid = true
+	}
+	if out, ok := applyFaults(&r.Faults, 1/r.Rate, out); ok {
+		out.Count = r.reading.Count + 1
+		r.reading = out
+	}
+}
+
+func (f *OpticalFlow) measure(d *Drone, rc rayCaster, t float64) {
+	if f.rng == nil {
+		f.rng = sensorRand(d.Seed, "flow")
+		f.Faults.init(d.Seed, "flow")
+	}
+	pos, rot := f.Mount.pose(d)
+	// Body rates into the sensor frame: what the camera itself turns at
+	w := mat3FromEuler(f.Mount.Rotation).transpose().mulVec(bodyRates(d.Rotation, d.AngularVel))
+	out := FlowReading{Time: t, BodyRate: w}
+	hit := rc.cast(pos, rot.mulVec(Vec3{Z: 1}), f.MaxRange)
+	if hit.Kind == RayMiss || hit.Distance <= 0 {
+		f.output(out)
+		return
+	}
+	// Surface point under the image centre moves by -v - ω×(0,0,h)
+	v := rot.transpose().mulVec(d.Velocity.Sub(hit.Velocity))
+	h := hit.Distance
+	fx, fy := -v.X/h-w.Y, -v.Y/h+w.X
+	q := clamp(f.Texture, 0, 1) * (1 - (h/f.MaxRange)*(h/f.MaxRange))
+	if f.MaxFlow > 0 {
+		q *= clamp(1-math.Hypot(fx, fy)/f.MaxFlow, 0, 1)
+	}
+	sigma := f.Noise / math.Max(q, 0.1)
+	out.FlowX, out.FlowY = fx+sigma*f.rng.NormFloat64(), fy+sigma*f.rng.NormFloat64()
+	out.Distance, out.Quality = h, q
+	out.Valid = q >= f.MinQuality
+	f.output(out)
+}
+
+func (f *OpticalFlow) output(r FlowReading) {
+	if r, ok := applyFaults(&f.Faults, 1/f.Rate, r); ok {
+		r.Count = f.reading.Count + 1
+		f.reading = r
+	}
+}
+
+func (l *Lidar) measure(d *Drone, rc rayCaster, t float64) {
+	if l.rng == nil {
+		l.rng = sensorRand(d.Seed, "lidar")
+		l.Faults.init(d.Seed, "lidar")
+	}
+	channels, samples := l.Channels, l.Samples
+	if channels < 1 || samples < 1 {
+		return
+	}
+	pos, rot := l.Mount.pose(d)
+	scan := LidarScan{
+		Time:     t,
+		Origin:   pos,
+		Rotation: rot.euler(),
+		Channels: channels,
+		Samples:  samples,
+		Ranges:   make([]float64, channels*samples),
+	}
+	for c := 0; c < channels; c++ {
+		for s := 0; s < samples; s++ {
+			hit := rc.cast(pos, rot.mulVec(l.direction(c, s)), l.MaxRange)
+			if hit.Kind == RayMiss || hit.Distance < l.MinRange {
+				continue
+			}
+			scan.Ranges[c*samples+s] = math.Max(hit.Distance+l.Noise*l.rng.NormFloat64(), 0)
+		}
+	}
+	scan, ok := applyFaults(&l.Faults, 1/l.Rate, scan)
+	if !ok {
+		return
+	}
+	// Points from the ranges as output, faults and all
+	for c := 0; c < scan.Channels; c++ {
+		for s := 0; s < scan.Samples; s++ {
+			if r := scan.Range(c, s); r > 0 {
+				scan.Points = append(scan.Points, l.direction(c, s).Mul(r))
+			}
+		}
+	}
+	scan.Count = l.scan.Count + 1
+	l.scan = scan
+}
@@ -79,6 +79,8 @@ type Renderer struct {
 	shaderProgram uint32
 	cubeVAO       uint32
 	groundVAO     uint32
+	lineVAO       uint32
+	lineVBO       uint32
 	modelLoc      int32
 	viewLoc       int32
 	projectionLoc int32
@@ -222,6 +224,31 @@ func (r *Renderer) initGeometry() {
 	gl.EnableVertexAttribArray(1)
 }
 
+// RenderLines draws world-space line segments. verts holds x,y,z,r,g,b per
+// vertex, two vertices per segment; the model matrix should be identity.
+func (r *Renderer) RenderLines(verts []float32) {
+	if len(verts) == 0 {
+		return
+	}
+	if r.lineVAO == 0 {
+		gl.GenVertexArrays(1, &r.lineVAO)
+		gl.GenBuffers(1, &r.lineVBO)
+		gl.BindVertexArray(r.lineVAO)
+		gl.BindBuffer(gl.ARRAY_BUFFER, r.lineVBO)
+		gl.VertexAttribPointer(0, 3, gl.FLOAT, false, 6*4, gl.PtrOffset(0))
+		gl.EnableVertexAttribArray(0)
+		gl.VertexAttribPointer(1, 3, gl.FLOAT, false, 6*4, gl.PtrOffset(3*4))
+		gl.EnableVertexAttribArray(1)
+	}
+	gl.BindVertexArray(r.lineVAO)
+	gl.BindBuffer(gl.ARRAY_BUFFER, r.lineVBO)
+	gl.BufferData(gl.ARRAY_BUFFER, len(verts)*4, gl.Ptr(verts), gl.STREAM_DRAW)
+	gl.UseProgram(r.shaderProgram)
+	gl.Uniform1i(r.useCheckerLoc, 0)
+	gl.DrawArrays(gl.LINES, 0, int32(len(verts)/6))
+	gl.BindVertexArray(0)
+}
+
 func (r *Renderer) SetMatrices(model, view, projection Mat4) {
     gl.UseProgram(r.shaderProgram)
     m32 := toGLMat4(model)
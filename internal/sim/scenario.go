@@ -0,0 +1,94 @@
+package sim
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Scenario is a script of events scheduled by simulation time, read from a
+// JSON file. For now it schedules sensor faults:
+//
+//	{"faults": [
+//	  {"drone": 0, "sensor": "gnss", "type": "bias", "start": 30, "end": 60, "magnitude": 5, "axis": 1},
+//	  {"drone": 0, "sensor": "baro", "type": "spikes", "start": 45, "duration": 10, "magnitude": 200, "rate": 2}
+//	]}
+type Scenario struct {
+	Faults []ScenarioFault
+}
+
+// ScenarioFault is a sensor fault for one drone, by index.
+type ScenarioFault struct {
+	Drone  int
+	Sensor string
+	Fault  SensorFault
+}
+
+// scenarioFile is the JSON form of a Scenario.
+type scenarioFile struct {
+	Faults []struct {
+		Drone     int     `json:"drone"`
+		Sensor    string  `json:"sensor"`
+		Type      string  `json:"type"`
+		Start     float64 `json:"start"`
+		End       float64 `json:"end"`
+		Duration  float64 `json:"duration"` // alternative to end
+		Magnitude float64 `json:"magnitude"`
+		Rate      float64 `json:"rate"`
+		Axis      int     `json:"axis"`
+	} `json:"faults"`
+}
+
+// LoadScenario reads a scenario file from disk.
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseScenario(data)
+}
+
+// ParseScenario decodes a scenario document.
+func ParseScenario(data []byte) (*Scenario, error) {
+	var sf scenarioFile
+	if err := json.Unmarshal(data, &sf); err != nil {
+		return nil, fmt.Errorf("scenario: %w", err)
+	}
+	sc := &Scenario{}
+	for i, f := range sf.Faults {
+		kind, ok := ParseSensorFaultKind(f.Type)
+		if !ok {
+			return nil, fmt.Errorf("scenario: fault %d: unknown type %q", i, f.Type)
+		}
+		end := f.End
+		if f.Duration > 0 {
+			end = f.Start + f.Duration
+		}
+		if end > 0 && end <= f.Start {
+			return nil, fmt.Errorf("scenario: fault %d: ends at %.1f s before it starts", i, end)
+		}
+		sc.Faults = append(sc.Faults, ScenarioFault{
+			Drone:  f.Drone,
+			Sensor: f.Sensor,
+			Fault:  SensorFault{Kind: kind, Start: f.Start, End: end, Magnitude: f.Magnitude, Rate: f.Rate, Axis: f.Axis},
+		})
+	}
+	return sc, nil
+}
+
+// Apply schedules the scenario's faults on drones, by index. Nothing is
+// scheduled if any fault names a missing drone or sensor.
+func (sc *Scenario) Apply(drones []*Drone) error {
+	for i, f := range sc.Faults {
+		if f.Drone < 0 || f.Drone >= len(drones) {
+			return fmt.Errorf("scenario: fault %d: no drone %d", i, f.Drone)
+		}
+		if _, err := drones[f.Drone].FaultSchedule(f.Sensor); err != nil {
+			return fmt.Errorf("scenario: fault %d: drone %d: %w", i, f.Drone, err)
+		}
+	}
+	for _, f := range sc.Faults {
+		drones[f.Drone].InjectFault(f.Sensor, f.Fault)
+	}
+	return nil
+}
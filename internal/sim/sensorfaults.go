@@ -0,0 +1,408 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+)
+
+// Sensor faults corrupt a sensor's output as it is produced, so everything
+// downstream (the estimator, the navigation sources, telemetry) sees the
+// failure. Each sensor carries a FaultSchedule; faults run by simulation time
+// and several may overlap, applied in schedule order.
+
+// SensorFaultKind is how an injected fault corrupts a sensor.
+type SensorFaultKind int
+
+const (
+	FaultStuck   SensorFaultKind = iota // output frozen at its value when the fault began
+	FaultBias                           // Magnitude added
+	FaultDrift                          // Magnitude per second since Start added
+	FaultNoise                          // white noise of σ Magnitude added
+	FaultSpikes                         // outliers of ±Magnitude at Rate per second
+	FaultDropout                        // no output at all
+	FaultDelay                          // output Magnitude seconds late, stamped as fresh
+)
+
+var sensorFaultKinds = map[SensorFaultKind]string{
+	FaultStuck:   "stuck",
+	FaultBias:    "bias",
+	FaultDrift:   "drift",
+	FaultNoise:   "noise",
+	FaultSpikes:  "spikes",
+	FaultDropout: "dropout",
+	FaultDelay:   "delay",
+}
+
+func (k SensorFaultKind) String() string {
+	if s, ok := sensorFaultKinds[k]; ok {
+		return s
+	}
+	return "unknown"
+}
+
+// ParseSensorFaultKind returns the kind named s (stuck, bias, drift, noise,
+// spikes, dropout or delay).
+func ParseSensorFaultKind(s string) (SensorFaultKind, bool) {
+	for k, name := range sensorFaultKinds {
+		if strings.EqualFold(s, name) {
+			return k, true
+		}
+	}
+	return 0, false
+}
+
+// SensorFault corrupts a sensor from Start to End seconds of simulation
+// (End 0: until cleared). Magnitude is in the units of the channels it hits
+// (see FaultSchedule), or seconds for FaultDelay. Axis picks one channel,
+// counting from 1; 0 hits them all.
+type SensorFault struct {
+	Kind       SensorFaultKind
+	Start, End float64
+	Magnitude  float64
+	Rate       float64 // spikes per second (FaultSpikes)
+	Axis       int
+
+	began bool
+	held  any // output when a stuck fault began
+}
+
+// ActiveAt reports whether the fault is in force at time t.
+func (f SensorFault) ActiveAt(t float64) bool {
+	return t >= f.Start && (f.End <= 0 || t < f.End)
+}
+
+// channels returns the range of the n channels the fault hits.
+func (f SensorFault) channels(n int) (int, int) {
+	if f.Axis <= 0 {
+		return 0, n
+	}
+	if f.Axis > n {
+		return 0, 0
+	}
+	return f.Axis - 1, f.Axis
+}
+
+// FaultSchedule holds the faults injected into one sensor. The channels
+// faults act on are, by sensor: IMU accel X/Y/Z (m/s²) then gyro X/Y/Z
+// (rad/s); GNSS position X/Y/Z (m) then velocity X/Y/Z (m/s); barometer
+// pressure (Pa); magnetometer field X/Y/Z (µT); rangefinder distance (m);
+// optical flow X/Y (rad/s); lidar every beam's range (m). Readings without a
+// measurement (no fix, no return) pass additive faults untouched.
+type FaultSchedule struct {
+	Faults []SensorFault
+
+	rng     *rand.Rand
+	delayed []delayedSample
+}
+
+type delayedSample struct {
+	due    float64
+	sample any
+}
+
+// Add schedules f.
+func (s *FaultSchedule) Add(f SensorFault) {
+	f.began, f.held = false, nil
+	s.Faults = append(s.Faults, f)
+}
+
+// Clear removes every fault and drops any output held back by a delay.
+func (s *FaultSchedule) Clear() {
+	s.Faults, s.delayed = nil, nil
+}
+
+// Active returns the faults in force at time t.
+func (s *FaultSchedule) Active(t float64) []SensorFault {
+	var out []SensorFault
+	for _, f := range s.Faults {
+		if f.ActiveAt(t) {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func (s *FaultSchedule) init(seed int64, name string) {
+	s.rng = sensorRand(seed, name+" faults")
+}
+
+// faultSample is a sensor output faults can corrupt: its time, a fresh copy
+// of its channels (nil without a measurement) and a copy carrying new ones.
+type faultSample[T any] interface {
+	faultTime() float64
+	faultChannels() []float64
+	withFaults(t float64, v []float64) T
+}
+
+// applyFaults passes the output s, produced period seconds after the last,
+// through the schedule, and reports whether anything is output at all.
+func applyFaults[T faultSample[T]](s *FaultSchedule, period float64, out T) (T, bool) {
+	if len(s.Faults) == 0 && len(s.delayed) == 0 {
+		return out, true
+	}
+	t := out.faultTime()
+	delay := 0.0
+	for i := range s.Faults {
+		f := &s.Faults[i]
+		if !f.ActiveAt(t) {
+			f.began, f.held = false, nil
+			continue
+		}
+		first := !f.began
+		f.began = true
+		switch f.Kind {
+		case FaultStuck:
+			if first {
+				f.held = out
+			}
+			if held, ok := f.held.(T); ok {
+				out = held.withFaults(t, held.faultChannels())
+			}
+		case FaultDropout:
+			var zero T
+			return zero, false
+		case FaultDelay:
+			delay = math.Max(delay, f.Magnitude)
+		default:
+			v := out.faultChannels()
+			lo, hi := f.channels(len(v))
+			if lo == hi || !f.corrupt(s.rng, t, period, v[lo:hi]) {
+				continue
+			}
+			out = out.withFaults(t, v)
+		}
+	}
+	if delay <= 0 {
+		s.delayed = nil
+		return out, true
+	}
+	// Hold the output back and release the newest one now due
+	s.delayed = append(s.delayed, delayedSample{due: t + delay, sample: out})
+	n := 0
+	for n < len(s.delayed) && s.delayed[n].due <= t+1e-9 {
+		n++
+	}
+	if n == 0 {
+		var zero T
+		return zero, false
+	}
+	late := s.delayed[n-1].sample.(T)
+	s.delayed = s.delayed[n:]
+	return late.withFaults(t, late.faultChannels()), true
+}
+
+// corrupt applies an additive fault to the channels v at time t and reports
+// whether it changed them.
+func (f *SensorFault) corrupt(rng *rand.Rand, t, period float64, v []float64) bool {
+	switch f.Kind {
+	case FaultBias:
+		for i := range v {
+			v[i] += f.Magnitude
+		}
+	case FaultDrift:
+		for i := range v {
+			v[i] += f.Magnitude * (t - f.Start)
+		}
+	case FaultNoise:
+		for i := range v {
+			v[i] += f.Magnitude * rng.NormFloat64()
+		}
+	case FaultSpikes:
+		if rng.Float64() >= f.Rate*period {
+			return false
+		}
+		for i := range v {
+			if rng.Float64() < 0.5 {
+				v[i] -= f.Magnitude
+			} else {
+				v[i] += f.Magnitude
+			}
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// SensorNames lists the sensors faults can be injected into.
+var SensorNames = []string{"imu", "gnss", "baro", "mag", "rangefinder", "flow", "lidar"}
+
+// FaultSchedule returns the fault schedule of the sensor called name (see
+// SensorNames). Ray-cast sensors must be mounted.
+func (d *Drone) FaultSchedule(name string) (*FaultSchedule, error) {
+	switch strings.ToLower(name) {
+	case "imu":
+		return &d.IMU.Faults, nil
+	case "gnss":
+		return &d.GNSS.Faults, nil
+	case "baro":
+		return &d.Baro.Faults, nil
+	case "mag":
+		return &d.Mag.Faults, nil
+	case "rangefinder":
+		if d.Rangefinder != nil {
+			return &d.Rangefinder.Faults, nil
+		}
+	case "flow":
+		if d.Flow != nil {
+			return &d.Flow.Faults, nil
+		}
+	case "lidar":
+		if d.Lidar != nil {
+			return &d.Lidar.Faults, nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown sensor %q", name)
+	}
+	return nil, fmt.Errorf("no %s mounted", name)
+}
+
+// InjectFault schedules f on the sensor called name.
+func (d *Drone) InjectFault(name string, f SensorFault) error {
+	s, err := d.FaultSchedule(name)
+	if err != nil {
+		return err
+	}
+	s.Add(f)
+	return nil
+}
+
+// ClearFaults removes the faults of the sensor called name, or of every
+// sensor when name is empty.
+func (d *Drone) ClearFaults(name string) error {
+	if name != "" {
+		s, err := d.FaultSchedule(name)
+		if err != nil {
+			return err
+		}
+		s.Clear()
+		return nil
+	}
+	for _, n := range SensorNames {
+		if s, err := d.FaultSchedule(n); err == nil {
+			s.Clear()
+		}
+	}
+	return nil
+}
+
+// ActiveSensorFault is a fault in force and the sensor it corrupts.
+type ActiveSensorFault struct {
+	Sensor string
+	SensorFault
+}
+
+// ActiveFaults returns the sensor faults in force now.
+func (d *Drone) ActiveFaults() []ActiveSensorFault {
+	var out []ActiveSensorFault
+	for _, n := range SensorNames {
+		s, err := d.FaultSchedule(n)
+		if err != nil {
+			continue
+		}
+		for _, f := range s.Active(d.clock) {
+			out = append(out, ActiveSensorFault{Sensor: n, SensorFault: f})
+		}
+	}
+	return out
+}
+
+func (s IMUSample) faultTime() float64 { return s.Time }
+
+func (s IMUSample) faultChannels() []float64 {
+	return []float64{s.Accel.X, s.Accel.Y, s.Accel.Z, s.Gyro.X, s.Gyro.Y, s.Gyro.Z}
+}
+
+func (s IMUSample) withFaults(t float64, v []float64) IMUSample {
+	s.Time = t
+	s.Accel, s.Gyro = Vec3{X: v[0], Y: v[1], Z: v[2]}, Vec3{X: v[3], Y: v[4], Z: v[5]}
+	return s
+}
+
+func (r GNSSReading) faultTime() float64 { return r.Time }
+
+func (r GNSSReading) faultChannels() []float64 {
+	if r.Fix == GNSSNoFix {
+		return nil
+	}
+	return []float64{r.Position.X, r.Position.Y, r.Position.Z, r.Velocity.X, r.Velocity.Y, r.Velocity.Z}
+}
+
+func (r GNSSReading) withFaults(t float64, v []float64) GNSSReading {
+	r.Time = t
+	if len(v) == 6 {
+		r.Position, r.Velocity = Vec3{X: v[0], Y: v[1], Z: v[2]}, Vec3{X: v[3], Y: v[4], Z: v[5]}
+	}
+	return r
+}
+
+func (s BaroSample) faultTime() float64 { return s.Time }
+
+func (s BaroSample) faultChannels() []float64 { return []float64{s.Pressure} }
+
+func (s BaroSample) withFaults(t float64, v []float64) BaroSample {
+	s.Time, s.Pressure = t, math.Max(v[0], 0)
+	s.Altitude = pressureAltitude(s.Pressure)
+	return s
+}
+
+func (s MagSample) faultTime() float64 { return s.Time }
+
+func (s MagSample) faultChannels() []float64 { return []float64{s.Field.X, s.Field.Y, s.Field.Z} }
+
+func (s MagSample) withFaults(t float64, v []float64) MagSample {
+	s.Time, s.Field = t, Vec3{X: v[0], Y: v[1], Z: v[2]}
+	return s
+}
+
+func (r RangeReading) faultTime() float64 { return r.Time }
+
+func (r RangeReading) faultChannels() []float64 {
+	if !r.Valid {
+		return nil
+	}
+	return []float64{r.Distance}
+}
+
+func (r RangeReading) withFaults(t float64, v []float64) RangeReading {
+	r.Time = t
+	if len(v) == 1 {
+		r.Distance = math.Max(v[0], 0)
+	}
+	return r
+}
+
+func (r FlowReading) faultTime() float64 { return r.Time }
+
+func (r FlowReading) faultChannels() []float64 {
+	if r.Distance <= 0 {
+		return nil
+	}
+	return []float64{r.FlowX, r.FlowY}
+}
+
+func (r FlowReading) withFaults(t float64, v []float64) FlowReading {
+	r.Time = t
+	if len(v) == 2 {
+		r.FlowX, r.FlowY = v[0], v[1]
+	}
+	return r
+}
+
+func (s LidarScan) faultTime() float64 { return s.Time }
+
+func (s LidarScan) faultChannels() []float64 { return append([]float64(nil), s.Ranges...) }
+
+// withFaults keeps beams without a return empty.
+func (s LidarScan) withFaults(t float64, v []float64) LidarScan {
+	ranges := make([]float64, len(s.Ranges))
+	for i, r := range s.Ranges {
+		if r > 0 && i < len(v) {
+			ranges[i] = math.Max(v[i], 0)
+		}
+	}
+	s.Time, s.Ranges = t, ranges
+	return s
+}
@@ -0,0 +1,87 @@
+package sim
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand"
+)
+
+// Simulated sensors read the true state and add the errors of real hardware.
+// Every sensor draws its noise from its own stream of the drone's Seed, so a
+// run is reproducible and adding a sensor does not change another's noise.
+
+// SensorReadings is the latest output of every onboard sensor.
+type SensorReadings struct {
+	IMU   IMUSample
+	GNSS  GNSSReading
+	Baro  BaroSample
+	Mag   MagSample
+	Range RangeReading // zero without a rangefinder
+	Flow  FlowReading  // zero without an optical-flow sensor
+}
+
+// Sensors returns the latest output of the drone's sensors.
+func (d *Drone) Sensors() SensorReadings {
+	s := SensorReadings{IMU: d.IMU.Sample(), GNSS: d.GNSS.Reading(), Baro: d.Baro.Sample(), Mag: d.Mag.Sample()}
+	if d.Rangefinder != nil {
+		s.Range = d.Rangefinder.Reading()
+	}
+	if d.Flow != nil {
+		s.Flow = d.Flow.Reading()
+	}
+	return s
+}
+
+// updateSensors samples every sensor on the motion of the step just
+// simulated; accel is the acceleration it produced, ground reaction included.
+func (d *Drone) updateSensors(dt float64, accel Vec3) {
+	d.updateIMU(dt, accel)
+	d.updateGNSS(dt)
+	d.updateBaro(dt)
+	d.updateMag(dt)
+	d.updateRaySensors(dt)
+	d.updateEKF(dt)
+}
+
+// sensorRand returns the random stream called name for seed.
+func sensorRand(seed int64, name string) *rand.Rand {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return rand.New(rand.NewSource(seed ^ int64(h.Sum64())))
+}
+
+// gaussVec returns a vector of independent N(0, sigma²) draws.
+func gaussVec(rng *rand.Rand, sigma float64) Vec3 {
+	if sigma == 0 {
+		return Vec3{}
+	}
+	return Vec3{X: rng.NormFloat64(), Y: rng.NormFloat64(), Z: rng.NormFloat64()}.Mul(sigma)
+}
+
+// toBody rotates the world vector v into the body frame of rot (pitch X, yaw
+// Y, roll Z): the inverse of the R = R_y·R_x·R_z the thrust uses.
+func toBody(rot, v Vec3) Vec3 {
+	return RotationZMat4(-rot.Z).Mul(RotationXMat4(-rot.X)).Mul(RotationYMat4(-rot.Y)).MulDirection(v)
+}
+
+// bodyRates converts Euler angle rates (AngularVel) into body angular
+// velocity, what a gyro measures. The rotation matrices turn by minus their
+// angle, so a positive Euler rate is a negative right-handed rate.
+func bodyRates(rot, eulerRates Vec3) Vec3 {
+	// ω_b = -(φ̇·z + θ̇·R_zᵀx + ψ̇·R_zᵀR_xᵀy)
+	rz := RotationZMat4(-rot.Z)
+	w := Vec3{Z: eulerRates.Z}
+	w = w.Add(rz.MulDirection(Vec3{X: eulerRates.X}))
+	return w.Add(rz.Mul(RotationXMat4(-rot.X)).MulDirection(Vec3{Y: eulerRates.Y})).Mul(-1)
+}
+
+// clampAxes limits each component of v to ±limit and reports whether any was
+// clipped. A non-positive limit disables clipping.
+func clampAxes(v Vec3, limit float64) (Vec3, bool) {
+	if limit <= 0 {
+		return v, false
+	}
+	clip := func(x float64) float64 { return math.Max(-limit, math.Min(limit, x)) }
+	c := Vec3{X: clip(v.X), Y: clip(v.Y), Z: clip(v.Z)}
+	return c, c != v
+}
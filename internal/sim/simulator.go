@@ -8,6 +8,7 @@ import (
 	"log"
 	"math"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -30,6 +31,7 @@ type Simulator struct {
 	uiVisible  bool
 	swarm      *Swarm
 	audio      *AudioSystem
+	world      *World
 
 	mu sync.RWMutex
 
@@ -40,6 +42,10 @@ type Simulator struct {
 	uiTopArmed bool
 	uiTopMode  FlightMode
 	uiTopCam   CameraMode
+
+	// Scratch buffers for fence and world wireframes
+	fenceVerts []float32
+	worldVerts []float32
 }
 
 func (s *Simulator) activeDrone() *Drone {
@@ -80,6 +86,7 @@ func NewSimulator() *Simulator {
 	for i := 0; i < n; i++ {
 		d := NewDrone()
 		d.Position = Vec3{float64(i%2) * 1.5, 0.05, float64(i/2) * 1.5}
+		d.Seed = int64(i + 1)
 		drones = append(drones, d)
 	}
 	camera := NewCamera()
@@ -98,6 +105,7 @@ func NewSimulator() *Simulator {
 		uiVisible:  true,
 	}
 	s.swarm = NewSwarm(s.drones)
+	s.SetWorld(NewWorld())
 	return s
 }
 
@@ -109,6 +117,7 @@ func NewSimulatorHeadless() *Simulator {
 	for i := 0; i < n; i++ {
 		d := NewDrone()
 		d.Position = Vec3{float64(i%2) * 1.5, 0.05, float64(i/2) * 1.5}
+		d.Seed = int64(i + 1)
 		drones = append(drones, d)
 	}
 	camera := NewCamera()
@@ -127,6 +136,7 @@ func NewSimulatorHeadless() *Simulator {
 		uiVisible:  false,
 	}
 	s.swarm = NewSwarm(s.drones)
+	s.SetWorld(NewWorld())
 	return s
 }
 
@@ -155,7 +165,8 @@ func (s *Simulator) Run(window *glfw.Window) {
 	fmt.Println("  Z - Zero throttle  X - Hover throttle  [/] - Select drone")
 	fmt.Println()
 	fmt.Println("FLIGHT MODES:")
-	fmt.Println("  1 - Manual  2 - Altitude Hold  3 - Hover")
+	fmt.Println("  1 - Manual  2 - Altitude Hold  3 - Hover  4 - Orbit 10 m ahead (Q/E speed, Up/Down radius, W/S altitude)")
+	fmt.Println("  5 - Follow the first world target")
 	fmt.Println()
 	fmt.Println("CAMERA MODES:")
 	fmt.Println("  C - Cycle camera modes (Follow → Top-Down → FPV)")
@@ -166,7 +177,7 @@ func (s *Simulator) Run(window *glfw.Window) {
 	fmt.Println()
 	fmt.Println("FLIGHT ENVELOPE:")
 	fmt.Printf("  Max Speed: %.0f m/s | Max Altitude: %.0fm\n", s.activeDrone().MaxSpeed, s.activeDrone().MaxAltitude)
-	fmt.Printf("  Max Climb Rate: %.0f m/s | Battery Life: ~25min\n", s.activeDrone().MaxVerticalSpeed)
+	fmt.Printf("  Max Climb Rate: %.0f m/s | Hover Endurance: ~%.0fmin\n", s.activeDrone().MaxVerticalSpeed, s.activeDrone().EnergyEstimate().Endurance/60)
 	fmt.Println()
 
 	// Fixed timestep configuration
@@ -346,6 +357,14 @@ func (s *Simulator) displayTelemetry() {
 		mode = "ALT HOLD"
 	case FlightModeHover:
 		mode = "HOVER"
+	case FlightModeMission:
+		mode = "MISSION"
+	case FlightModeOrbit:
+		mode = "ORBIT"
+	case FlightModeFollow:
+		mode = "FOLLOW"
+	case FlightModePrecisionLand:
+		mode = "PREC LAND"
 	}
 
 	// Camera mode
@@ -373,10 +392,30 @@ func (s *Simulator) displayTelemetry() {
 		status, mode, cameraMode, drone.BatteryPercent, batteryStatus,
 		drone.Position.Y, horizontalSpeed, drone.ThrottlePercent, drone.PowerDraw)
 
+	if drone.Mission != nil {
+		p := drone.MissionProgress()
+		fmt.Printf(" | Mission: %d/%d %.0fm ETA %.0fs", p.Current, p.Total, p.DistanceRemaining, p.ETA)
+	}
+	if e := drone.EnergyEstimate(); drone.IsArmed {
+		fmt.Printf(" | Endurance: %.0fs Range: %.0fm", e.Endurance, e.Range)
+		if e.MustReturn {
+			fmt.Print(" | ⚠ RETURN NOW")
+		}
+	}
+
 	// Warnings
 	if drone.Position.Y > drone.MaxAltitude*0.9 {
 		fmt.Print(" | ⚠ ALTITUDE LIMIT")
 	}
+	if drone.FenceBreached() {
+		fmt.Print(" | ⚠ FENCE BREACH")
+	}
+	if f := drone.MotorFault(); f.Motor >= 0 {
+		fmt.Printf(" | ⚠ MOTOR %d LOST", f.Motor)
+	}
+	if t := drone.Failsafe.Active(); t != FailsafeNone {
+		fmt.Printf(" | ⚠ FAILSAFE %s: %s", t, drone.ActiveAction())
+	}
 	if speed > drone.MaxSpeed*0.9 {
 		fmt.Print(" | ⚠ MAX SPEED")
 	}
@@ -390,6 +429,7 @@ func (s *Simulator) processInput(dt float64) {
 	}
 	// Cycle selected drone for control and camera focus
 	if s.input.WasKeyPressed(glfw.KeyLeftBracket) {
+		s.releaseSticks()
 		s.selected--
 		if s.selected < 0 {
 			s.selected = len(s.drones) - 1
@@ -399,6 +439,7 @@ func (s *Simulator) processInput(dt float64) {
 		}
 	}
 	if s.input.WasKeyPressed(glfw.KeyRightBracket) {
+		s.releaseSticks()
 		s.selected++
 		if s.selected >= len(s.drones) {
 			s.selected = 0
@@ -420,6 +461,8 @@ func (s *Simulator) update(dt float64) {
 	if s.swarm != nil {
 		s.swarm.Update(dt)
 	}
+	// Targets move before the drones that follow them
+	s.world.Update(dt)
 	// Physics update for all drones
 	for _, d := range s.drones {
 		d.Update(dt)
@@ -518,6 +561,8 @@ func (s *Simulator) render(window *glfw.Window) {
 		// Optionally: could render selected highlight later
 		_ = idx
 	}
+	s.renderFences(view, projection)
+	s.renderWorld(view, projection)
 
 	// Draw UI overlay panel with telemetry on top of 3D
 	if s.uiVisible {
@@ -549,6 +594,8 @@ func (s *Simulator) renderInterpolated(window *glfw.Window, alpha float64) {
 		s.renderer.SetMatrices(droneModel, view, projection)
 		s.renderer.RenderDrone()
 	}
+	s.renderFences(view, projection)
+	s.renderWorld(view, projection)
 
 	if s.uiVisible {
 		s.renderUI(width, height)
@@ -556,6 +603,106 @@ func (s *Simulator) renderInterpolated(window *glfw.Window, alpha float64) {
 	s.mu.RUnlock()
 }
 
+// renderFences draws the selected drone's geofences as wireframes: inclusion
+// fences in cyan, exclusion fences in orange, and any breached fence in red.
+func (s *Simulator) renderFences(view, projection Mat4) {
+	d := s.activeDrone()
+	if d == nil || len(d.Geofences) == 0 {
+		return
+	}
+	s.fenceVerts = s.fenceVerts[:0]
+	for _, f := range d.Geofences {
+		c := [3]float32{0.3, 0.9, 1.0}
+		if !f.Inclusion {
+			c = [3]float32{1.0, 0.6, 0.2}
+		}
+		if d.fenceStatus[f] == fenceBreached {
+			c = [3]float32{1.0, 0.2, 0.2}
+		}
+		for _, p := range f.wireframe() {
+			s.fenceVerts = append(s.fenceVerts, float32(p.X), float32(p.Y), float32(p.Z), c[0], c[1], c[2])
+		}
+	}
+	s.renderer.SetMatrices(IdentityMat4(), view, projection)
+	s.renderer.RenderLines(s.fenceVerts)
+}
+
+// renderWorld draws obstacles as grey wireframes, targets as yellow markers,
+// landing decks in cyan and the selected drone's planned path in green.
+func (s *Simulator) renderWorld(view, projection Mat4) {
+	s.worldVerts = s.worldVerts[:0]
+	for _, p := range s.world.obstacleWireframe() {
+		s.worldVerts = append(s.worldVerts, float32(p.X), float32(p.Y), float32(p.Z), 0.6, 0.6, 0.65)
+	}
+	for _, p := range s.world.targetMarkers() {
+		s.worldVerts = append(s.worldVerts, float32(p.X), float32(p.Y), float32(p.Z), 1.0, 0.85, 0.2)
+	}
+	for _, p := range s.world.platformOutlines() {
+		s.worldVerts = append(s.worldVerts, float32(p.X), float32(p.Y), float32(p.Z), 0.3, 0.9, 1.0)
+	}
+	if d := s.activeDrone(); d != nil {
+		path := d.PlannedPath()
+		for i := 1; i < len(path); i++ {
+			for _, p := range path[i-1 : i+1] {
+				s.worldVerts = append(s.worldVerts, float32(p.X), float32(p.Y), float32(p.Z), 0.3, 1.0, 0.4)
+			}
+		}
+	}
+	if len(s.worldVerts) == 0 {
+		return
+	}
+	s.renderer.SetMatrices(IdentityMat4(), view, projection)
+	s.renderer.RenderLines(s.worldVerts)
+}
+
+// World returns the scenery shared by the simulator's drones.
+func (s *Simulator) World() *World { return s.world }
+
+// SetWorld replaces the scenery for every drone.
+func (s *Simulator) SetWorld(w *World) {
+	s.world = w
+	w.Drones = s.drones
+	for _, d := range s.drones {
+		d.World = w
+	}
+}
+
+// releaseSticks centres the held stick input of the drone losing focus.
+func (s *Simulator) releaseSticks() {
+	if d := s.activeDrone(); d != nil {
+		d.SetPilotInput(PilotInput{})
+	}
+}
+
+// SetController installs flight software on drone i. A custom controller
+// also takes the drone out of the swarm formation. nil restores the default:
+// the leader flies the built-in autopilot, and a follower gets the formation
+// controller back at the next swarm step.
+func (s *Simulator) SetController(i int, c Controller) error {
+	if i < 0 || i >= len(s.drones) {
+		return fmt.Errorf("no drone %d", i)
+	}
+	s.drones[i].Controller = c
+	return nil
+}
+
+// Controller returns the flight software installed on drone i (a swarm
+// follower's formation controller included), or nil when it flies the
+// built-in autopilot or is out of range.
+func (s *Simulator) Controller(i int) Controller {
+	if i < 0 || i >= len(s.drones) {
+		return nil
+	}
+	return s.drones[i].Controller
+}
+
+// SetGeofences installs the same fence list on every drone.
+func (s *Simulator) SetGeofences(fences []*Geofence) {
+	for _, d := range s.drones {
+		d.Geofences = fences
+	}
+}
+
 // RunHeadless executes fixed-step updates without creating a window.
 // Returns the number of simulation steps performed.
 func (s *Simulator) RunHeadless(steps int, ups int, dur time.Duration) int {
@@ -663,6 +810,58 @@ func (s *Simulator) renderUI(width, height int) {
 	}
 	s.ui.DrawText(x, y, "BAT "+itoa(batPct)+"%  "+batt, scaleBody, batColor)
 	y += lineHeight
+	// Energy budget: time left, range at this speed and the cost of going home
+	if e := s.activeDrone().EnergyEstimate(); s.activeDrone().IsArmed {
+		end := int(e.Endurance + 0.5)
+		line := "END " + itoa(end/60) + "M" + itoa(end%60) + "S  RNG " + itoa(int(e.Range+0.5)) + "M"
+		s.ui.DrawText(x, y, line, scaleBody, Color{0.8, 1, 0.8, 1})
+		y += lineHeight
+		line, col := "RTH "+itoa(int(e.ReturnTime+0.5))+"S  SPARE "+itoa(int(e.Margin/batteryCapacityWh*100+0.5))+"%", Color{0.8, 1, 0.8, 1}
+		switch {
+		case !e.Reachable:
+			line, col = "RTH UNREACHABLE IN WIND", Color{1, 0.35, 0.3, 1}
+		case e.MustReturn:
+			line, col = "RTH NOW", Color{1, 0.35, 0.3, 1}
+		}
+		s.ui.DrawText(x, y, line, scaleBody, col)
+		y += lineHeight
+	}
+	// Satellite fix; the navigation source when it is not the truth
+	if g := s.activeDrone().GNSS; g.Rate > 0 {
+		r := g.Reading()
+		line, col := "GPS "+strings.ToUpper(r.Fix.String())+"  "+itoa(r.Satellites)+" SAT  HDOP "+fmt1(r.HDOP), Color{0.8, 1, 0.8, 1}
+		if r.Fix != GNSSFix3D {
+			col = Color{1.0, 0.8, 0.3, 1}
+		}
+		if src := s.activeDrone().NavSource; src != NavTruth {
+			line += "  NAV " + strings.ToUpper(src.String())
+		}
+		s.ui.DrawText(x, y, line, scaleBody, col)
+		y += lineHeight
+	}
+	// Sensor fusion: how far the estimate is off
+	if est := s.activeDrone().EKF.Estimate(); est.Valid {
+		e := s.activeDrone().EKFError()
+		line := "EKF ERR " + fmt1(e.Position.Length()) + "M  YAW " + fmt1(math.Abs(e.Attitude.Y)*180/math.Pi) + "DEG"
+		s.ui.DrawText(x, y, line, scaleBody, Color{0.8, 1, 0.8, 1})
+		y += lineHeight
+	}
+	// Mounted ray-cast sensors
+	if d := s.activeDrone(); d.Rangefinder != nil || d.Flow != nil {
+		line := ""
+		if d.Rangefinder != nil {
+			if r := d.Rangefinder.Reading(); r.Valid {
+				line = "LIDAR AGL " + fmt1(r.Distance) + "M"
+			} else {
+				line = "LIDAR AGL --"
+			}
+		}
+		if d.Flow != nil {
+			line += "  FLOW Q " + itoa(int(d.Flow.Reading().Quality*100+0.5)) + "%"
+		}
+		s.ui.DrawText(x, y, strings.TrimSpace(line), scaleBody, Color{0.8, 1, 0.8, 1})
+		y += lineHeight
+	}
 
 	// Health summary: DESTROYED / DAMAGED / OK
 	healthText := "OK"
@@ -736,10 +935,94 @@ func (s *Simulator) renderUI(width, height int) {
 	y += lineHeight
 	s.ui.DrawText(x, y, "LIM ALT "+itoa(int(s.activeDrone().MaxAltitude+0.5)), scaleBody, Color{1, 0.9, 1, 1})
 	y += lineHeight
-	if s.activeDrone().FlightMode == FlightModeAltitudeHold || s.activeDrone().FlightMode == FlightModeHover {
+	if s.activeDrone().holdsAltitude() {
 		s.ui.DrawText(x, y, "ALT TGT "+itoa(int(s.activeDrone().AltitudeHold+0.5)), scaleBody, Color{0.95, 1, 0.95, 1})
 		y += lineHeight
 	}
+	// Mission progress: item, remaining distance and ETA
+	if s.activeDrone().Mission != nil {
+		p := s.activeDrone().MissionProgress()
+		item := p.Current + 1
+		if item > p.Total {
+			item = p.Total
+		}
+		s.ui.DrawText(x, y, "MIS "+itoa(item)+" OF "+itoa(p.Total)+"  DST "+itoa(int(p.DistanceRemaining+0.5))+"M  ETA "+itoa(int(p.ETA+0.5))+"S", scaleBody, Color{0.95, 1, 0.95, 1})
+		y += lineHeight
+	}
+	// Safety action in control, the failsafe behind it and fence state
+	if t := s.activeDrone().Failsafe.Active(); t != FailsafeNone {
+		s.ui.DrawText(x, y, "FAILSAFE "+strings.ToUpper(t.String()), scaleBody, Color{1, 0.45, 0.3, 1})
+		y += lineHeight
+	}
+	if a := s.activeDrone().ActiveAction(); a != ActionNone {
+		s.ui.DrawText(x, y, "ACTION "+strings.ToUpper(a.String()), scaleBody, Color{1, 0.7, 0.3, 1})
+		y += lineHeight
+	}
+	if s.activeDrone().FenceBreached() {
+		s.ui.DrawText(x, y, "FENCE BREACH", scaleBody, Color{1, 0.35, 0.3, 1})
+		y += lineHeight
+	}
+	if f := s.activeDrone().MotorFault(); f.Motor >= 0 {
+		line := "MOTOR " + itoa(f.Motor) + " LOST"
+		if f.Active {
+			line += "  SPIN " + itoa(int(math.Abs(f.YawRate)*60/(2*math.Pi)+0.5)) + " RPM"
+		}
+		s.ui.DrawText(x, y, line, scaleBody, Color{1, 0.35, 0.3, 1})
+		y += lineHeight
+	}
+	if s.camera.Mode == CameraModeFPV {
+		g := &s.activeDrone().Gimbal
+		_, att := g.Pose(s.activeDrone())
+		line := "GIMBAL " + strings.ToUpper(g.Mode.String()) + "  P " + itoa(int(math.Round(RadToDeg(att.X)))) + "  Y " + itoa(int(math.Round(YawToHeading(att.Y))))
+		s.ui.DrawText(x, y, line, scaleBody, Color{0.6, 0.9, 1, 1})
+		y += lineHeight
+	}
+	for _, f := range s.activeDrone().ActiveFaults() {
+		s.ui.DrawText(x, y, "SENSOR "+strings.ToUpper(f.Sensor)+" "+strings.ToUpper(f.Kind.String()), scaleBody, Color{1, 0.45, 0.3, 1})
+		y += lineHeight
+	}
+	if st := s.activeDrone().AutotuneStatus(); st.State == AutotuneRunning {
+		s.ui.DrawText(x, y, "AUTOTUNE "+strings.ToUpper(st.Axis.String())+" "+itoa(st.Cycle)+"/"+itoa(st.Cycles+1), scaleBody, Color{0.6, 0.9, 1, 1})
+		y += lineHeight
+	}
+	if o, ok := s.activeDrone().OrbitStatus(); ok {
+		s.ui.DrawText(x, y, "ORBIT R "+itoa(int(o.Radius+0.5))+"M  V "+itoa(int(math.Abs(o.EffectiveSpeed)+0.5))+"M/S", scaleBody, Color{0.6, 0.9, 1, 1})
+		y += lineHeight
+	}
+	if f, ok := s.activeDrone().FollowStatus(); ok {
+		line := "FOLLOW " + strings.ToUpper(f.Target) + "  ERR " + itoa(int(f.ErrorDistance+0.5)) + "M"
+		if f.Lost {
+			line = "FOLLOW " + strings.ToUpper(f.Target) + " LOST - HOLDING"
+		}
+		s.ui.DrawText(x, y, line, scaleBody, Color{0.6, 0.9, 1, 1})
+		y += lineHeight
+	}
+	if p, ok := s.activeDrone().PrecisionLandStatus(); ok {
+		line := "PRECLAND " + strings.ToUpper(p.Phase.String()) + "  H " + fmt1(p.Height) + "M"
+		if p.Locked {
+			line += "  OFF " + fmt1(math.Hypot(p.Relative.X, p.Relative.Z)) + "M"
+		} else if p.Phase != PrecLandLanded {
+			line += "  NO MARKER"
+		}
+		s.ui.DrawText(x, y, line, scaleBody, Color{0.6, 0.9, 1, 1})
+		y += lineHeight
+	}
+	if st := s.activeDrone().StickStats(); st.Active {
+		line := "STICKS " + itoa(int(st.RateHz+0.5)) + "HZ  " + itoa(int(st.Latency*1000+0.5)) + "MS"
+		col := Color{0.6, 0.9, 1, 1}
+		if st.Stale {
+			line, col = "STICKS LOST", Color{1, 0.4, 0.3, 1}
+		}
+		s.ui.DrawText(x, y, line, scaleBody, col)
+		y += lineHeight
+	}
+	// Failing pre-arm checks while disarmed
+	if !s.activeDrone().IsArmed {
+		for _, f := range s.activeDrone().PreArmChecks() {
+			s.ui.DrawText(x, y, "PREARM "+f.Reason, scaleBody, Color{1, 0.35, 0.3, 1})
+			y += lineHeight
+		}
+	}
 	// Ground contact
 	ground := "NO"
 	if s.activeDrone().OnGround {
@@ -861,6 +1144,12 @@ func (s *Simulator) topStatusText() string {
 		modeStr = "ALT"
 	case FlightModeHover:
 		modeStr = "HOV"
+	case FlightModeMission:
+		modeStr = "MIS"
+	case FlightModeOrbit:
+		modeStr = "ORB"
+	case FlightModeFollow:
+		modeStr = "FOL"
 	}
 
 	camStr := "FOL"
@@ -0,0 +1,221 @@
+package sim
+
+import "math"
+
+// Remote joystick flying. Stick positions arrive as messages (e.g. over NATS)
+// and are held between them; the autopilot interprets them according to the
+// flight mode, like a hobby flight controller:
+//
+//	Manual        angle mode: roll/pitch set the tilt, yaw the turn rate,
+//	              throttle the collective directly
+//	AltitudeHold  angle mode, throttle away from mid-stick climbs or descends
+//	Hover         position hold: roll/pitch fly body-frame velocity and the
+//	              drone stops and holds where the sticks are centred
+//	Orbit         roll changes speed, pitch the radius, throttle the altitude
+//
+// Missions, follow and safety actions ignore the sticks. If messages stop for
+// longer than Failsafe.InputTimeout the deadman centres them and the
+// input-loss failsafe trips.
+
+// Sticks is one joystick sample: Throttle 0..1, Roll (right), Pitch
+// (forward) and Yaw (clockwise) -1..1.
+type Sticks struct {
+	Throttle float64
+	Roll     float64
+	Pitch    float64
+	Yaw      float64
+}
+
+// Stick response
+const (
+	stickMaxTiltDeg  = 30.0 // angle-mode tilt at full stick
+	stickMaxYawRate  = 2.0  // rad/s at full stick
+	stickMaxSpeed    = 6.0  // m/s body-frame velocity at full stick (Hover)
+	stickMaxClimb    = 3.0  // m/s at full throttle deflection from mid-stick
+	stickDeadband    = 0.05 // centred below this deflection
+	stickRateTau     = 1.0  // s, smoothing of the message-rate estimate
+	stickLatencyTau  = 1.0  // s, smoothing of the latency estimate
+	stickNeutralHold = 0.5  // throttle that holds altitude in the assisted modes
+	stickHoldSpeed   = 0.3  // m/s below which a released Hover latches its position
+)
+
+// StickStats describes the remote stick link.
+type StickStats struct {
+	Active     bool    // sticks are engaged (until ReleaseSticks)
+	Messages   int     // samples received since engaging
+	Age        float64 // s since the last sample
+	RateHz     float64 // smoothed sample rate
+	Latency    float64 // smoothed sender-to-sim delay in s (0 if unknown)
+	MaxLatency float64 // s
+	Stale      bool    // deadman expired; sticks are centred
+}
+
+type remoteSticks struct {
+	in      Sticks
+	stats   StickStats
+	hold    Vec3    // Hover: position held once braked with roll/pitch centred
+	yaw     float64 // heading held while the yaw stick is centred
+	holding bool    // hold is latched
+	yawHeld bool    // yaw is latched
+	mode    FlightMode
+	flownAt float64 // drone clock when the sticks last flew it
+}
+
+// SetSticks records a joystick sample. latency is the delay between the
+// sender and the simulator in s, negative when unknown.
+func (d *Drone) SetSticks(s Sticks, latency float64) {
+	r := d.sticks
+	if r == nil {
+		r = &remoteSticks{}
+		d.sticks = r
+	}
+	st := &r.stats
+	if st.Active && st.Age > 0 {
+		k := math.Min(st.Age/stickRateTau, 1)
+		st.RateHz += (1/st.Age - st.RateHz) * k
+	}
+	if latency >= 0 {
+		if st.Messages == 0 || st.Latency == 0 {
+			st.Latency = latency
+		} else {
+			st.Latency += (latency - st.Latency) * math.Min(math.Max(st.Age, 0.01)/stickLatencyTau, 1)
+		}
+		st.MaxLatency = math.Max(st.MaxLatency, latency)
+	}
+	st.Active = true
+	st.Messages++
+	st.Age = 0
+	st.Stale = false
+	r.in = Sticks{
+		Throttle: clamp(s.Throttle, 0, 1),
+		Roll:     clamp(s.Roll, -1, 1),
+		Pitch:    clamp(s.Pitch, -1, 1),
+		Yaw:      clamp(s.Yaw, -1, 1),
+	}
+}
+
+// ReleaseSticks disengages remote sticks: they stop steering the drone and
+// the deadman stops watching them.
+func (d *Drone) ReleaseSticks() {
+	d.sticks = nil
+}
+
+// Sticks returns the held stick positions (centred once stale) and whether
+// remote sticks are engaged.
+func (d *Drone) Sticks() (Sticks, bool) {
+	if d.sticks == nil {
+		return Sticks{}, false
+	}
+	return d.sticks.effective(d.FlightMode), true
+}
+
+// StickStats returns the remote stick link statistics.
+func (d *Drone) StickStats() StickStats {
+	if d.sticks == nil {
+		return StickStats{}
+	}
+	return d.sticks.stats
+}
+
+// effective returns the sticks to fly: the held sample, or centred sticks
+// once the deadman has expired. A centred throttle holds altitude in the
+// assisted modes and keeps the last collective in Manual.
+func (r *remoteSticks) effective(mode FlightMode) Sticks {
+	if !r.stats.Stale {
+		return r.in
+	}
+	thr := stickNeutralHold
+	if mode == FlightModeManual {
+		thr = r.in.Throttle
+	}
+	return Sticks{Throttle: thr}
+}
+
+// ageSticks advances the deadman clock.
+func (d *Drone) ageSticks(dt float64) {
+	if d.sticks == nil {
+		return
+	}
+	st := &d.sticks.stats
+	st.Age += dt
+	if st.Age > d.Failsafe.InputTimeout {
+		st.Stale = true
+	}
+}
+
+// stickAxis applies the deadband and rescales the rest of the travel.
+func stickAxis(v float64) float64 {
+	if math.Abs(v) < stickDeadband {
+		return 0
+	}
+	return math.Copysign((math.Abs(v)-stickDeadband)/(1-stickDeadband), v)
+}
+
+// flySticks interprets the remote sticks for the current flight mode. It
+// reports false when the mode does not take stick input.
+func (d *Drone) flySticks(dt float64) bool {
+	r := d.sticks
+	if r == nil {
+		return false
+	}
+	// Re-latch holds after a mode change or an action took over
+	if r.mode != d.FlightMode || d.clock-r.flownAt > 1.5*dt {
+		r.holding, r.yawHeld = false, false
+		r.mode = d.FlightMode
+	}
+	r.flownAt = d.clock
+	s := r.effective(d.FlightMode)
+	roll, pitch, yaw := stickAxis(s.Roll), stickAxis(s.Pitch), stickAxis(s.Yaw)
+	climb := stickAxis(s.Throttle*2-1) * stickMaxClimb
+
+	switch d.FlightMode {
+	case FlightModeOrbit:
+		// Forward pitch tightens the circle
+		d.SetOrbitSticks(roll, stickAxis(s.Throttle*2-1), -pitch)
+		return false // updateOrbit still flies the circle
+	case FlightModeManual, FlightModeAltitudeHold:
+		if d.FlightMode == FlightModeManual {
+			d.SetThrottle(s.Throttle * 100)
+		} else {
+			if d.ThrottlePercent < d.HoverThrottlePercent() {
+				d.SetThrottle(d.HoverThrottlePercent())
+			}
+			d.AltitudeHold = math.Max(d.AltitudeHold+climb*dt, 0)
+		}
+		maxTilt := stickMaxTiltDeg * math.Pi / 180
+		yawRate := yaw * stickMaxYawRate // clockwise is increasing yaw
+		// Negative roll tilts toward the drone's right
+		d.steerAttitude(-pitch*maxTilt, -roll*maxTilt, yawRate, yawRate, dt)
+		return true
+	case FlightModeHover:
+		if !r.holding {
+			r.hold.Y = d.AltitudeHold
+		}
+		r.hold.Y = math.Max(r.hold.Y+climb*dt, 0)
+		sp := NavSetpoint{Position: d.navPosition(), ClimbRate: stickMaxClimb + 0.5}
+		sp.Position.Y = r.hold.Y
+		switch {
+		case roll != 0 || pitch != 0:
+			// Body-frame velocity: right is (-cos, -sin), forward (-sin, cos)
+			c, sn := math.Cos(d.navRotation().Y), math.Sin(d.navRotation().Y)
+			sp.Velocity = Vec3{X: -roll*c - pitch*sn, Z: -roll*sn + pitch*c}.Mul(stickMaxSpeed)
+			r.holding = false
+		case r.holding:
+			sp.Position.X, sp.Position.Z = r.hold.X, r.hold.Z
+		case math.Hypot(d.navVelocity().X, d.navVelocity().Z) < stickHoldSpeed:
+			// Latch the hold once braked, so the drone does not overshoot back to
+			// where the sticks were released
+			r.hold.X, r.hold.Z = d.navPosition().X, d.navPosition().Z
+			r.holding = true
+		}
+		if yaw != 0 || !r.yawHeld {
+			r.yaw = d.navRotation().Y
+			r.yawHeld = yaw == 0
+		}
+		sp.Yaw = r.yaw
+		sp.YawRate = yaw * navMaxYawRate
+		d.trackSetpoint(sp, dt)
+		return true
+	}
+	return false
+}
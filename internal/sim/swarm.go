@@ -14,6 +14,8 @@ type Swarm struct {
     queue     []scheduledMsg
     last      LeaderState
     hasLast   bool
+    lastAt    float64 // send time of last
+    trackVel  Vec3    // leader velocity over ground, from successive messages
     leaderIdx int
     // per-follower control state for smoothing targets
     ctrl map[int]*followerCtrlState
@@ -36,6 +38,10 @@ func (s *Swarm) Update(dt float64) {
 	}
 	s.simTime += dt
 	leader := s.drones[s.leaderIdx]
+	// The leader flies itself
+	if c, ok := s.ctrl[s.leaderIdx]; ok && leader.Controller == c {
+		leader.Controller = nil
+	}
 
 	// Broadcast leader state with latency
 	msg := LeaderState{
@@ -49,19 +55,35 @@ func (s *Swarm) Update(dt float64) {
 	s.queue = append(s.queue, scheduledMsg{deliverAt: s.simTime + s.latency, state: msg})
 	// Deliver due messages
 	for len(s.queue) > 0 && s.queue[0].deliverAt <= s.simTime {
-		s.last = s.queue[0].state
+		m := s.queue[0]
+		sentAt := m.deliverAt - s.latency
+		// Followers match the leader's track rather than its reported
+		// velocity, which a scripted or wind-blown leader may not fly
+		if !s.hasLast {
+			s.trackVel = m.state.Velocity
+		} else if span := sentAt - s.lastAt; span > 0 {
+			v := m.state.Position.Sub(s.last.Position).Mul(1 / span)
+			s.trackVel = s.trackVel.Add(v.Sub(s.trackVel).Mul(clamp(span/0.2, 0, 1)))
+		}
+		s.last = m.state
+		s.lastAt = sentAt
 		s.hasLast = true
 		s.queue = s.queue[1:]
 	}
 
-	// Auto-arm/disarm followers to mirror leader's armed state
-	for i := 0; i < len(s.drones); i++ {
+	// Auto-arm/disarm followers to mirror leader's armed state, once the
+	// leader's state has arrived
+	for i := 0; i < len(s.drones) && s.hasLast; i++ {
 		if i == s.leaderIdx {
 			continue
 		}
 		d := s.drones[i]
+		if d.autonomous() {
+			continue // flying its own mission or a safety action
+		}
 		if s.last.IsArmed {
-			if !d.IsArmed {
+			// Wrecks fail the pre-arm checks for good
+			if !d.IsArmed && !d.Destroyed {
 				d.Arm()
 			}
 		} else {
@@ -76,26 +98,12 @@ func (s *Swarm) Update(dt float64) {
 	count := len(s.drones)
 	followers := count - 1
 	rank := 0
-    // Controller gains (cascaded: position -> velocity -> attitude)
-    // Outer loop: position error -> velocity target
-    kpPosV := 0.6 // m -> m/s
-    vMax := 3.0   // cap commanded lateral speed
-    // Middle loop: velocity error -> acceleration (maps to tilt)
-    kpVel := 1.1  // (m/s) -> m/s^2
-    kdVel := 0.6  // D on relative velocity
-    // Inner loop: attitude tracking -> torque
-    kpAtt := 4.0  // attitude P
-    kdAtt := 3.0  // rate damping
-    // Yaw loop
-    kpYaw := 1.2
-    kdYaw := 0.8
-	g := 9.81
 	base := leader.Position
 	lvel := leader.Velocity
 	lyaw := leader.Rotation.Y
 	if s.hasLast {
 		base = s.last.Position
-		lvel = s.last.Velocity
+		lvel = s.trackVel
 		lyaw = s.last.Yaw
 	}
 	// Allow followers to form up only after leader starts moving horizontally
@@ -108,12 +116,24 @@ func (s *Swarm) Update(dt float64) {
 			continue
 		}
         follower := s.drones[i]
+		// Followers on a mission or safety action leave the formation but keep their slot
+		if follower.autonomous() {
+			rank++
+			continue
+		}
         // Init follower control state if absent
         st := s.ctrl[i]
         if st == nil {
-            st = &followerCtrlState{prevPitch: follower.Rotation.X, prevRoll: follower.Rotation.Z}
+            st = &followerCtrlState{d: follower, prevPitch: follower.Rotation.X, prevRoll: follower.Rotation.Z}
             s.ctrl[i] = st
         }
+		// Followers running their own flight software leave the formation
+		if follower.Controller == nil {
+			follower.Controller = st
+		} else if follower.Controller != Controller(st) {
+			rank++
+			continue
+		}
 		angle := 0.0
 		if followers > 0 {
 			angle = 2 * math.Pi * float64(rank) / float64(followers)
@@ -132,68 +152,21 @@ func (s *Swarm) Update(dt float64) {
 		}
             follower.AltitudeHold = altTarget
 
-        // Cascaded lateral control
-        // 1) Position -> velocity target (in leader frame)
-        ex := targetPos.X - follower.Position.X
-        ez := targetPos.Z - follower.Position.Z
-        vtx := clamp(kpPosV*ex, -vMax, vMax)
-        vtz := clamp(kpPosV*ez, -vMax, vMax)
-        // 2) Velocity error (relative to leader) -> acceleration command
-        vxRel := follower.Velocity.X - lvel.X
-        vzRel := follower.Velocity.Z - lvel.Z
-        ax := kpVel*(vtx-vxRel) - kdVel*vxRel
-        az := kpVel*(vtz-vzRel) - kdVel*vzRel
-        // Limit commanded acceleration to avoid aggressive tilts
-        maxAcc := 3.0
-        if ax > maxAcc { ax = maxAcc } else if ax < -maxAcc { ax = -maxAcc }
-        if az > maxAcc { az = maxAcc } else if az < -maxAcc { az = -maxAcc }
-        // 3) Acceleration -> tilt targets (small-angle assumption)
-        pitchCmd := clamp(-math.Atan2(az, g), -10*math.Pi/180, 10*math.Pi/180)
-        rollCmd  := clamp(math.Atan2(ax, g), -10*math.Pi/180, 10*math.Pi/180)
-        // Rate-limit tilt targets to avoid jitter/excitation
-        maxTiltRate := 120.0 * math.Pi / 180.0 // rad/s
-        pitchTarget := slew(st.prevPitch, pitchCmd, maxTiltRate, dt)
-        rollTarget  := slew(st.prevRoll,  rollCmd,  maxTiltRate, dt)
-        st.prevPitch = pitchTarget
-        st.prevRoll  = rollTarget
-
-        // Attitude PD tracking for pitch/roll (single objective: track tilt targets)
-        torqueX := kpAtt*(pitchTarget-follower.Rotation.X) - kdAtt*follower.AngularVel.X
-        torqueZ := kpAtt*(rollTarget-follower.Rotation.Z) - kdAtt*follower.AngularVel.Z
-        // Align yaw with leader
-        yawErr := angleDiff(lyaw, follower.Rotation.Y)
-        yawTorque := kpYaw*yawErr - kdYaw*follower.AngularVel.Y
 		// Additional gate: require follower to have some altitude clearance before allowing lateral control
 		followerClear := !follower.OnGround && (follower.Position.Y > follower.Dimensions.Z/2.0+0.2)
 		active := allowFormation && followerClear
-        // Additional altitude-based scaling to avoid tipping on/near ground
-        agl := follower.Position.Y - follower.groundClearance()
-        attScale := clamp((agl-0.05)/0.35, 0.0, 1.0)
-        if attScale < 0 { attScale = 0 }
-        torqueX *= attScale
-        torqueZ *= attScale
-        yawTorque *= clamp(attScale*1.2, 0.0, 1.0)
-
-        // If formation is not yet allowed or follower lacks clearance, keep calm and avoid lateral/yaw commands
+        // If formation is not yet allowed or follower lacks clearance, keep calm
         if !active {
-            torqueX = 0
-            torqueZ = 0
-            yawTorque = 0
             // Light lateral damping to prevent drift
             follower.Velocity.X *= 0.95
             follower.Velocity.Z *= 0.95
         }
-        // Disable lateral torques while on ground to avoid skittering
-        if follower.OnGround {
-            torqueX = 0
-            torqueZ = 0
-        }
-        // Saturate torques to avoid violent angular accelerations
-        tMax := 0.35
-        if torqueX > tMax { torqueX = tMax } else if torqueX < -tMax { torqueX = -tMax }
-        if torqueZ > tMax { torqueZ = tMax } else if torqueZ < -tMax { torqueZ = -tMax }
-        if yawTorque > 0.25 { yawTorque = 0.25 } else if yawTorque < -0.25 { yawTorque = -0.25 }
-        follower.AddTorque(Vec3{X: torqueX, Y: yawTorque, Z: torqueZ}, dt)
+		// The follower's controller tracks the slot on its next step
+		st.engaged = true
+		st.active = active
+		st.target = targetPos
+		st.lvel = lvel
+		st.lyaw = lyaw
 		// Gentle clamp toward slot and velocity damping when far
 		delta := follower.Position.Sub(targetPos)
 		if active {
@@ -218,10 +191,97 @@ func (s *Swarm) Update(dt float64) {
 	}
 }
 
-// followerCtrlState holds per-follower control memory for smoothing
+// followerCtrlState is a follower's formation controller: the built-in
+// autopilot (altitude hold) plus cascaded lateral control toward the slot
+// Swarm.Update assigned it.
 type followerCtrlState struct {
-    prevPitch float64
-    prevRoll  float64
+	d         *Drone
+	prevPitch float64
+	prevRoll  float64
+	// Set by Swarm.Update for the next step
+	engaged bool
+	active  bool // formation allowed and follower clear of the ground
+	target  Vec3
+	lvel    Vec3
+	lyaw    float64
+}
+
+// Update implements Controller.
+func (st *followerCtrlState) Update(state ControllerState, dt float64) Actuators {
+	out := st.d.Autopilot().Update(state, dt)
+	if !st.engaged {
+		return out
+	}
+	st.engaged = false
+	// Altitude hold trims around hover thrust, which the tilt loop below
+	// needs to move the follower sideways
+	if out.Throttle < state.Hover {
+		out.Throttle = state.Hover
+	}
+	// Controller gains (cascaded: position -> velocity -> attitude)
+	// Outer loop: position error -> velocity target
+	kpPosV := 0.8 // m -> m/s
+	vMax := 3.0   // cap commanded lateral speed
+	// Middle loop: velocity error -> acceleration (maps to tilt)
+	kpVel := 1.5 // (m/s) -> m/s^2
+	// Inner loop: attitude tracking -> angular acceleration, as steerAttitude
+	kpAtt := navKpAtt
+	kdAtt := navKdAtt
+	// Yaw loop
+	kpYaw := 1.2
+	kdYaw := 0.8
+	g := 9.81
+
+	// 1) Position -> velocity target (in leader frame)
+	ex := st.target.X - state.Position.X
+	ez := st.target.Z - state.Position.Z
+	vtx := clamp(kpPosV*ex, -vMax, vMax)
+	vtz := clamp(kpPosV*ez, -vMax, vMax)
+	// 2) Velocity error (relative to leader) -> acceleration command
+	vxRel := state.Velocity.X - st.lvel.X
+	vzRel := state.Velocity.Z - st.lvel.Z
+	// Limit commanded acceleration to avoid aggressive tilts
+	maxAcc := 3.0
+	ax := clamp(kpVel*(vtx-vxRel), -maxAcc, maxAcc)
+	az := clamp(kpVel*(vtz-vzRel), -maxAcc, maxAcc)
+	// 3) Acceleration -> tilt targets (small-angle assumption)
+	pitchCmd := clamp(-math.Atan2(az, g), -15*math.Pi/180, 15*math.Pi/180)
+	rollCmd := clamp(math.Atan2(ax, g), -15*math.Pi/180, 15*math.Pi/180)
+	// Rate-limit tilt targets to avoid jitter/excitation
+	maxTiltRate := 120.0 * math.Pi / 180.0 // rad/s
+	pitchTarget := slew(st.prevPitch, pitchCmd, maxTiltRate, dt)
+	rollTarget := slew(st.prevRoll, rollCmd, maxTiltRate, dt)
+	st.prevPitch = pitchTarget
+	st.prevRoll = rollTarget
+
+	// Attitude PD tracking for pitch/roll (single objective: track tilt
+	// targets); torque is the desired angular acceleration times inertia
+	torqueX := (kpAtt*(pitchTarget-state.Rotation.X) - kdAtt*state.AngularVel.X) * state.Inertia.X
+	torqueZ := (kpAtt*(rollTarget-state.Rotation.Z) - kdAtt*state.AngularVel.Z) * state.Inertia.Z
+	// Align yaw with leader
+	yawTorque := (kpYaw*angleDiff(st.lyaw, state.Rotation.Y) - kdYaw*state.AngularVel.Y) * state.Inertia.Y
+	// Additional altitude-based scaling to avoid tipping on/near ground
+	agl := state.Position.Y - st.d.groundClearance()
+	attScale := clamp((agl-0.05)/0.35, 0.0, 1.0)
+	torqueX *= attScale
+	torqueZ *= attScale
+	yawTorque *= clamp(attScale*1.2, 0.0, 1.0)
+
+	// Keep calm while the formation is not allowed, and avoid skittering on the ground
+	if !st.active {
+		torqueX, torqueZ, yawTorque = 0, 0, 0
+	}
+	if state.OnGround {
+		torqueX, torqueZ = 0, 0
+	}
+	// Saturate torques to avoid violent angular accelerations
+	tMax := 0.35
+	out.Torque = out.Torque.Add(Vec3{
+		X: clamp(torqueX, -tMax, tMax),
+		Y: clamp(yawTorque, -0.25, 0.25),
+		Z: clamp(torqueZ, -tMax, tMax),
+	})
+	return out
 }
 
 // initializeFollower sets safe initial conditions on arming.
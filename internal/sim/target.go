@@ -0,0 +1,204 @@
+package sim
+
+import (
+	"math"
+)
+
+// Target is a moving ground entity (vehicle, person) that drones can follow.
+// A scripted target drives along Path at Speed; an external target is moved
+// by SetState (e.g. from NATS) and dead-reckoned between updates.
+type Target struct {
+	Name     string
+	Position Vec3
+	Velocity Vec3
+	Heading  float64 // course in radians, same convention as drone yaw
+
+	// Scripted motion
+	Path  []Vec3
+	Speed float64 // m/s along Path
+	Loop  bool    // restart from the first point after the last
+
+	leg      int     // index of the path point being driven to
+	external bool    // position comes from SetState
+	age      float64 // s since the last SetState
+	accel    Vec3    // estimated acceleration
+	jerk     Vec3    // rate of change of accel
+}
+
+// Target parameters
+const (
+	targetTimeout  = 3.0 // s without an update before an external target is lost
+	targetAccelTau = 0.5 // s, smoothing of the acceleration estimate
+)
+
+// NewScriptedTarget returns a target that drives along path at speed,
+// starting at its first point.
+func NewScriptedTarget(name string, path []Vec3, speed float64, loop bool) *Target {
+	t := &Target{Name: name, Path: append([]Vec3(nil), path...), Speed: speed, Loop: loop}
+	if len(path) > 0 {
+		t.Position = path[0]
+		t.leg = 1
+	}
+	return t
+}
+
+// SetState moves an external target to a reported position and velocity.
+func (t *Target) SetState(pos, vel Vec3) {
+	if t.external && t.age > 0 && t.age < targetTimeout {
+		// Smooth acceleration estimate from successive velocity reports
+		a := vel.Sub(t.Velocity).Mul(1 / t.age)
+		t.accel = t.accel.Mul(0.5).Add(a.Mul(0.5))
+	} else {
+		t.accel = Vec3{}
+	}
+	t.external = true
+	t.Path = nil
+	t.Position = pos
+	t.Velocity = vel
+	t.age = 0
+	t.updateHeading()
+}
+
+// Lost reports whether an external target has stopped sending updates.
+func (t *Target) Lost() bool {
+	return t.external && t.age > targetTimeout
+}
+
+// Acceleration returns the target's estimated acceleration.
+func (t *Target) Acceleration() Vec3 {
+	if t.Lost() {
+		return Vec3{}
+	}
+	return t.accel
+}
+
+// Jerk returns the rate of change of the acceleration estimate; zero for
+// external targets, whose reports are too coarse to differentiate twice.
+func (t *Target) Jerk() Vec3 {
+	if t.external {
+		return Vec3{}
+	}
+	return t.jerk
+}
+
+// Update advances the target by dt.
+func (t *Target) Update(dt float64) {
+	if t.external {
+		t.age += dt
+		if !t.Lost() {
+			// Dead-reckon between reports
+			t.Position = t.Position.Add(t.Velocity.Mul(dt))
+		}
+		return
+	}
+	prevVel := t.Velocity
+	t.drive(dt)
+	if dt > 0 {
+		// Low-pass the finite difference; path corners are instantaneous
+		a := t.Velocity.Sub(prevVel).Mul(1 / dt)
+		k := math.Min(dt/targetAccelTau, 1)
+		prevAcc := t.accel
+		t.accel = t.accel.Add(a.Sub(t.accel).Mul(k))
+		t.jerk = t.jerk.Add(t.accel.Sub(prevAcc).Mul(1 / dt).Sub(t.jerk).Mul(k))
+	}
+	t.updateHeading()
+}
+
+// drive moves a scripted target dt seconds along its path.
+func (t *Target) drive(dt float64) {
+	if t.leg >= len(t.Path) || t.Speed <= 0 {
+		t.Velocity = Vec3{}
+		return
+	}
+	remaining := t.Speed * dt
+	for hops := 0; remaining > 0 && t.leg < len(t.Path) && hops <= len(t.Path); hops++ {
+		to := t.Path[t.leg].Sub(t.Position)
+		dist := to.Length()
+		if dist <= remaining {
+			t.Position = t.Path[t.leg]
+			remaining -= dist
+			t.leg++
+			if t.leg >= len(t.Path) && t.Loop {
+				t.leg = 0
+			}
+			continue
+		}
+		t.Position = t.Position.Add(to.Mul(remaining / dist))
+		t.Velocity = to.Mul(t.Speed / dist)
+		remaining = 0
+	}
+	if t.leg >= len(t.Path) {
+		t.Velocity = Vec3{}
+	}
+}
+
+func (t *Target) updateHeading() {
+	if math.Hypot(t.Velocity.X, t.Velocity.Z) > 0.1 {
+		t.Heading = headingTo(Vec3{}, t.Velocity)
+	}
+}
+
+// Target returns the world's target called name, or nil.
+func (w *World) Target(name string) *Target {
+	if w == nil {
+		return nil
+	}
+	for _, t := range w.Targets {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// AddTarget adds t, replacing any target with the same name.
+func (w *World) AddTarget(t *Target) {
+	for i, old := range w.Targets {
+		if old.Name == t.Name {
+			w.Targets[i] = t
+			return
+		}
+	}
+	w.Targets = append(w.Targets, t)
+}
+
+// RemoveTarget drops the target called name.
+func (w *World) RemoveTarget(name string) {
+	for i, t := range w.Targets {
+		if t.Name == name {
+			w.Targets = append(w.Targets[:i], w.Targets[i+1:]...)
+			return
+		}
+	}
+}
+
+// Update moves every target and platform by dt.
+func (w *World) Update(dt float64) {
+	if w == nil {
+		return
+	}
+	for _, t := range w.Targets {
+		t.Update(dt)
+	}
+	for _, p := range w.Platforms {
+		p.Update(dt)
+	}
+}
+
+// targetMarkers returns line-segment endpoints (pairs) marking every target
+// with a ground cross and a short pole, for Renderer.RenderLines.
+func (w *World) targetMarkers() []Vec3 {
+	if w == nil {
+		return nil
+	}
+	var out []Vec3
+	for _, t := range w.Targets {
+		p := t.Position
+		out = append(out,
+			p.Add(Vec3{X: -1}), p.Add(Vec3{X: 1}),
+			p.Add(Vec3{Z: -1}), p.Add(Vec3{Z: 1}),
+			p, p.Add(Vec3{Y: 2}),
+		)
+	}
+	return out
+}
@@ -0,0 +1,336 @@
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Piecewise-polynomial minimum-snap / minimum-jerk trajectories.
+//
+// A trajectory through M+1 waypoints is M polynomial segments, one per leg.
+// Minimising the integral of the squared r-th derivative (r=4 snap, r=3 jerk)
+// with the waypoints as the only interior constraints gives polynomials of
+// degree 2r-1 that are continuous up to derivative 2r-2 at every interior
+// waypoint. With the drone at rest at both ends this is a square linear
+// system, solved once per trajectory for all three axes.
+//
+// Each segment is parameterised on normalised time τ ∈ [0,1] to keep the
+// system well conditioned; a derivative of order m picks up 1/T^m.
+
+// TrajectoryOrder selects the minimised derivative.
+type TrajectoryOrder int
+
+const (
+	TrajectoryMinSnap TrajectoryOrder = iota // 7th-order segments
+	TrajectoryMinJerk                        // 5th-order segments
+)
+
+func (o TrajectoryOrder) String() string {
+	if o == TrajectoryMinJerk {
+		return "MinJerk"
+	}
+	return "MinSnap"
+}
+
+// derivative returns r, the order of the minimised derivative.
+func (o TrajectoryOrder) derivative() int {
+	if o == TrajectoryMinJerk {
+		return 3
+	}
+	return 4
+}
+
+// TrajectoryLimits bound the time allocation. Zero values pick defaults.
+type TrajectoryLimits struct {
+	MaxSpeed float64 // m/s
+	MaxAccel float64 // m/s^2
+}
+
+// Trajectory defaults
+const (
+	trajDefaultAccel = 2.0 // m/s^2, leaves tilt budget for the feedback loop
+	trajMinSegment   = 0.2 // s
+	trajPeakSamples  = 64  // samples per segment when measuring peaks
+	trajMinLeg       = 1e-3
+)
+
+// Trajectory is a smooth time-parameterised path starting and ending at rest.
+type Trajectory struct {
+	Waypoints []Vec3
+	Order     TrajectoryOrder
+	Times     []float64 // duration of each segment (s)
+
+	coeffs   [][3][]float64 // per segment, per axis, τ-polynomial coefficients
+	duration float64
+}
+
+// TrajectorySample is the reference state at one instant (world frame).
+type TrajectorySample struct {
+	Position     Vec3
+	Velocity     Vec3
+	Acceleration Vec3
+}
+
+// NewTrajectory fits a trajectory through points. Consecutive duplicate
+// points are dropped. Segment times start from a trapezoidal speed profile
+// per leg and are then stretched uniformly until the peak speed and
+// acceleration sit at the limits (uniform scaling keeps the optimal shape).
+func NewTrajectory(points []Vec3, order TrajectoryOrder, limits TrajectoryLimits) (*Trajectory, error) {
+	var pts []Vec3
+	for _, p := range points {
+		if len(pts) == 0 || p.Sub(pts[len(pts)-1]).Length() > trajMinLeg {
+			pts = append(pts, p)
+		}
+	}
+	if len(pts) < 2 {
+		return nil, errors.New("trajectory: need at least two distinct waypoints")
+	}
+	if limits.MaxSpeed <= 0 {
+		limits.MaxSpeed = missionDefaultSpeed
+	}
+	if limits.MaxAccel <= 0 {
+		limits.MaxAccel = trajDefaultAccel
+	}
+
+	t := &Trajectory{Waypoints: pts, Order: order, Times: make([]float64, len(pts)-1)}
+	for i := range t.Times {
+		t.Times[i] = math.Max(trapezoidTime(pts[i+1].Sub(pts[i]).Length(), limits.MaxSpeed, limits.MaxAccel), trajMinSegment)
+	}
+	if err := t.solve(); err != nil {
+		return nil, err
+	}
+	vPeak, aPeak := t.peaks()
+	if k := math.Max(vPeak/limits.MaxSpeed, math.Sqrt(aPeak/limits.MaxAccel)); k > 0 {
+		for i := range t.Times {
+			t.Times[i] *= k
+		}
+	}
+	t.duration = 0
+	for _, T := range t.Times {
+		t.duration += T
+	}
+	return t, nil
+}
+
+// trapezoidTime is the rest-to-rest time for dist under vmax and amax.
+func trapezoidTime(dist, vmax, amax float64) float64 {
+	if dist < vmax*vmax/amax {
+		return 2 * math.Sqrt(dist/amax)
+	}
+	return dist/vmax + vmax/amax
+}
+
+// Duration returns the total trajectory time in seconds.
+func (t *Trajectory) Duration() float64 { return t.duration }
+
+// Sample evaluates the trajectory at time s since its start. Times outside
+// [0, Duration] clamp to the end points (where the drone is at rest).
+func (t *Trajectory) Sample(s float64) TrajectorySample {
+	if s <= 0 {
+		return TrajectorySample{Position: t.Waypoints[0]}
+	}
+	if s >= t.duration {
+		return TrajectorySample{Position: t.Waypoints[len(t.Waypoints)-1]}
+	}
+	i := 0
+	for i < len(t.Times)-1 && s > t.Times[i] {
+		s -= t.Times[i]
+		i++
+	}
+	T := t.Times[i]
+	tau := clamp(s/T, 0, 1)
+	var out TrajectorySample
+	for axis := 0; axis < 3; axis++ {
+		p, v, a := evalPoly(t.coeffs[i][axis], tau)
+		setAxis(&out.Position, axis, p)
+		setAxis(&out.Velocity, axis, v/T)
+		setAxis(&out.Acceleration, axis, a/(T*T))
+	}
+	return out
+}
+
+// Setpoint returns the controller target at time s: the sampled position
+// with velocity and acceleration feed-forward.
+func (t *Trajectory) Setpoint(s float64, yaw float64) NavSetpoint {
+	ref := t.Sample(s)
+	return NavSetpoint{
+		Position:     ref.Position,
+		Velocity:     ref.Velocity,
+		Acceleration: ref.Acceleration,
+		Yaw:          yaw,
+		MaxSpeed:     math.Hypot(ref.Velocity.X, ref.Velocity.Z) + 2,
+		ClimbRate:    math.Abs(ref.Velocity.Y) + 2,
+	}
+}
+
+// evalPoly returns the value and first two τ-derivatives of Σ c_k τ^k.
+func evalPoly(c []float64, tau float64) (p, v, a float64) {
+	for k := len(c) - 1; k >= 0; k-- {
+		a = a*tau + 2*v
+		v = v*tau + p
+		p = p*tau + c[k]
+	}
+	return p, v, a
+}
+
+func setAxis(v *Vec3, axis int, x float64) {
+	switch axis {
+	case 0:
+		v.X = x
+	case 1:
+		v.Y = x
+	default:
+		v.Z = x
+	}
+}
+
+func getAxis(v Vec3, axis int) float64 {
+	switch axis {
+	case 0:
+		return v.X
+	case 1:
+		return v.Y
+	}
+	return v.Z
+}
+
+// falling returns k!/(k-m)!, the coefficient of τ^(k-m) in d^m/dτ^m τ^k.
+func falling(k, m int) float64 {
+	if m > k {
+		return 0
+	}
+	f := 1.0
+	for j := 0; j < m; j++ {
+		f *= float64(k - j)
+	}
+	return f
+}
+
+// solve builds and solves the constraint system for the current Times.
+func (t *Trajectory) solve() error {
+	r := t.Order.derivative()
+	n := 2 * r // coefficients per segment
+	segs := len(t.Times)
+	size := n * segs
+	a := make([][]float64, size)
+	for i := range a {
+		a[i] = make([]float64, size)
+	}
+	b := make([][3]float64, size)
+	row := 0
+	col := func(seg, k int) int { return seg*n + k }
+
+	for i := 0; i < segs; i++ {
+		// Position at both ends of each segment
+		a[row][col(i, 0)] = 1
+		for axis := 0; axis < 3; axis++ {
+			b[row][axis] = getAxis(t.Waypoints[i], axis)
+		}
+		row++
+		for k := 0; k < n; k++ {
+			a[row][col(i, k)] = 1
+		}
+		for axis := 0; axis < 3; axis++ {
+			b[row][axis] = getAxis(t.Waypoints[i+1], axis)
+		}
+		row++
+	}
+	// At rest at both ends: derivatives 1..r-1 vanish
+	for m := 1; m < r; m++ {
+		a[row][col(0, m)] = falling(m, m)
+		row++
+		for k := m; k < n; k++ {
+			a[row][col(segs-1, k)] = falling(k, m)
+		}
+		row++
+	}
+	// Interior continuity of derivatives 1..2r-2, scaled by T_i^m
+	for i := 0; i < segs-1; i++ {
+		ratio := t.Times[i] / t.Times[i+1]
+		for m := 1; m <= 2*r-2; m++ {
+			for k := m; k < n; k++ {
+				a[row][col(i, k)] = falling(k, m)
+			}
+			a[row][col(i+1, m)] = -math.Pow(ratio, float64(m)) * falling(m, m)
+			row++
+		}
+	}
+	if row != size {
+		return fmt.Errorf("trajectory: %d constraints for %d unknowns", row, size)
+	}
+	if err := solveLinear(a, b); err != nil {
+		return err
+	}
+	t.coeffs = make([][3][]float64, segs)
+	for i := 0; i < segs; i++ {
+		for axis := 0; axis < 3; axis++ {
+			c := make([]float64, n)
+			for k := 0; k < n; k++ {
+				c[k] = b[col(i, k)][axis]
+			}
+			t.coeffs[i][axis] = c
+		}
+	}
+	return nil
+}
+
+// peaks returns the largest speed and acceleration magnitudes along the
+// trajectory at the current Times.
+func (t *Trajectory) peaks() (vPeak, aPeak float64) {
+	for i, T := range t.Times {
+		for s := 0; s <= trajPeakSamples; s++ {
+			tau := float64(s) / trajPeakSamples
+			var v, a Vec3
+			for axis := 0; axis < 3; axis++ {
+				_, dv, da := evalPoly(t.coeffs[i][axis], tau)
+				setAxis(&v, axis, dv/T)
+				setAxis(&a, axis, da/(T*T))
+			}
+			vPeak = math.Max(vPeak, v.Length())
+			aPeak = math.Max(aPeak, a.Length())
+		}
+	}
+	return vPeak, aPeak
+}
+
+// solveLinear solves a·x = b in place (Gaussian elimination with partial
+// pivoting); the solution replaces b.
+func solveLinear(a [][]float64, b [][3]float64) error {
+	n := len(a)
+	for c := 0; c < n; c++ {
+		p := c
+		for r := c + 1; r < n; r++ {
+			if math.Abs(a[r][c]) > math.Abs(a[p][c]) {
+				p = r
+			}
+		}
+		if math.Abs(a[p][c]) < 1e-12 {
+			return errors.New("trajectory: singular constraint system")
+		}
+		a[c], a[p] = a[p], a[c]
+		b[c], b[p] = b[p], b[c]
+		for r := c + 1; r < n; r++ {
+			f := a[r][c] / a[c][c]
+			if f == 0 {
+				continue
+			}
+			for k := c; k < n; k++ {
+				a[r][k] -= f * a[c][k]
+			}
+			for axis := 0; axis < 3; axis++ {
+				b[r][axis] -= f * b[c][axis]
+			}
+		}
+	}
+	for c := n - 1; c >= 0; c-- {
+		for axis := 0; axis < 3; axis++ {
+			s := b[c][axis]
+			for k := c + 1; k < n; k++ {
+				s -= a[c][k] * b[k][axis]
+			}
+			b[c][axis] = s / a[c][c]
+		}
+	}
+	return nil
+}
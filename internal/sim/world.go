@@ -0,0 +1,169 @@
+package sim
+
+import (
+	"math"
+)
+
+// Obstacle is a solid axis-aligned box in the sim frame. Obstacles are
+// scenery for planning and display; the flight model does not collide with
+// them.
+type Obstacle struct {
+	Name     string
+	Min, Max Vec3
+}
+
+// Distance returns how far p is from the box (0 inside it).
+func (o Obstacle) Distance(p Vec3) float64 {
+	dx := math.Max(math.Max(o.Min.X-p.X, 0), p.X-o.Max.X)
+	dy := math.Max(math.Max(o.Min.Y-p.Y, 0), p.Y-o.Max.Y)
+	dz := math.Max(math.Max(o.Min.Z-p.Z, 0), p.Z-o.Max.Z)
+	return math.Sqrt(dx*dx + dy*dy + dz*dz)
+}
+
+// HeightMap is terrain sampled on a regular X/Z grid. Heights[r*Cols+c] is
+// the ground height at (Origin.X + c*Spacing, Origin.Z + r*Spacing); queries
+// outside the grid clamp to its edge.
+type HeightMap struct {
+	Origin     Vec3 // X/Z of sample (0,0)
+	Spacing    float64
+	Cols, Rows int
+	Heights    []float64
+}
+
+// Height returns the bilinearly interpolated ground height at x/z.
+func (h *HeightMap) Height(x, z float64) float64 {
+	if h == nil || h.Cols < 1 || h.Rows < 1 || h.Spacing <= 0 || len(h.Heights) < h.Cols*h.Rows {
+		return 0
+	}
+	fx := clamp((x-h.Origin.X)/h.Spacing, 0, float64(h.Cols-1))
+	fz := clamp((z-h.Origin.Z)/h.Spacing, 0, float64(h.Rows-1))
+	c0, r0 := int(fx), int(fz)
+	c1, r1 := min(c0+1, h.Cols-1), min(r0+1, h.Rows-1)
+	tx, tz := fx-float64(c0), fz-float64(r0)
+	at := func(c, r int) float64 { return h.Heights[r*h.Cols+c] }
+	top := at(c0, r0)*(1-tx) + at(c1, r0)*tx
+	bottom := at(c0, r1)*(1-tx) + at(c1, r1)*tx
+	return top*(1-tz) + bottom*tz
+}
+
+// maxHeight returns the highest terrain sample.
+func (h *HeightMap) maxHeight() float64 {
+	top := 0.0
+	if h != nil {
+		for _, y := range h.Heights {
+			top = math.Max(top, y)
+		}
+	}
+	return top
+}
+
+// World holds the scenery shared by every drone of a simulator. Version
+// increases with every obstacle or terrain change so planners can notice new
+// obstacles; moving targets and platforms do not affect it.
+type World struct {
+	Obstacles []Obstacle
+	Terrain   *HeightMap // nil = flat ground at Y=0
+	Targets   []*Target
+	Platforms []*Platform
+	Drones    []*Drone // airframes the ray-cast sensors can see
+	Origin    GeoPoint // WGS84 position of the local frame's origin
+
+	version int
+}
+
+// NewWorld returns an empty world over flat ground.
+func NewWorld() *World {
+	return &World{Origin: DefaultOrigin}
+}
+
+// GeoOrigin returns the WGS84 anchor of the local frame.
+func (w *World) GeoOrigin() GeoPoint {
+	if w == nil || w.Origin == (GeoPoint{}) {
+		return DefaultOrigin
+	}
+	return w.Origin
+}
+
+// Version returns a counter that changes whenever the scenery does.
+func (w *World) Version() int {
+	if w == nil {
+		return 0
+	}
+	return w.version
+}
+
+// AddObstacle adds a box to the world.
+func (w *World) AddObstacle(o Obstacle) {
+	w.Obstacles = append(w.Obstacles, o)
+	w.version++
+}
+
+// SetObstacles replaces every obstacle.
+func (w *World) SetObstacles(obs []Obstacle) {
+	w.Obstacles = append([]Obstacle(nil), obs...)
+	w.version++
+}
+
+// RemoveObstacle drops the obstacles called name and reports whether any were.
+func (w *World) RemoveObstacle(name string) bool {
+	kept := w.Obstacles[:0]
+	for _, o := range w.Obstacles {
+		if o.Name != name {
+			kept = append(kept, o)
+		}
+	}
+	removed := len(kept) != len(w.Obstacles)
+	w.Obstacles = kept
+	if removed {
+		w.version++
+	}
+	return removed
+}
+
+// SetTerrain replaces the terrain (nil for flat ground).
+func (w *World) SetTerrain(h *HeightMap) {
+	w.Terrain = h
+	w.version++
+}
+
+// GroundHeight returns the terrain height at x/z.
+func (w *World) GroundHeight(x, z float64) float64 {
+	if w == nil {
+		return 0
+	}
+	return w.Terrain.Height(x, z)
+}
+
+// Clearance returns the distance from p to the nearest obstacle and its
+// height above the terrain, whichever is smaller.
+func (w *World) Clearance(p Vec3) float64 {
+	c := p.Y - w.GroundHeight(p.X, p.Z)
+	if w == nil {
+		return c
+	}
+	for _, o := range w.Obstacles {
+		c = math.Min(c, o.Distance(p))
+	}
+	return c
+}
+
+// obstacleWireframe returns line-segment endpoints (pairs) outlining every
+// obstacle box, for rendering with Renderer.RenderLines.
+func (w *World) obstacleWireframe() []Vec3 {
+	if w == nil {
+		return nil
+	}
+	var out []Vec3
+	for _, o := range w.Obstacles {
+		a, b := o.Min, o.Max
+		c := [8]Vec3{
+			{X: a.X, Y: a.Y, Z: a.Z}, {X: b.X, Y: a.Y, Z: a.Z}, {X: b.X, Y: a.Y, Z: b.Z}, {X: a.X, Y: a.Y, Z: b.Z},
+			{X: a.X, Y: b.Y, Z: a.Z}, {X: b.X, Y: b.Y, Z: a.Z}, {X: b.X, Y: b.Y, Z: b.Z}, {X: a.X, Y: b.Y, Z: b.Z},
+		}
+		for i := 0; i < 4; i++ {
+			j := (i + 1) % 4
+			out = append(out, c[i], c[j], c[i+4], c[j+4], c[i], c[i+4])
+		}
+	}
+	return out
+}
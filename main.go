@@ -9,8 +9,10 @@ import (
 	"log"
 	"os"
 	"runtime"
+	"strings"
 	"time"
 
+	"drone-simulator/systems/mocap"
 	natsclient "drone-simulator/systems/nats"
 	sim "drone-simulator/internal/sim"
 	"github.com/go-gl/gl/v4.1-core/gl"
@@ -30,11 +32,62 @@ func main() {
 	decoupled := flag.Bool("decoupled", true, "Run decoupled simulation/render loops (default true; pass -decoupled=false for legacy loop)")
 	arm := flag.Bool("arm", true, "Auto-arm drones in headless mode")
 	natsURL := flag.String("nats-url", "", "NATS server URL (e.g., nats://localhost:4222)")
+	originFlag := flag.String("origin", "", "WGS84 origin of the local frame as lat,lon[,alt] (default: the -mission home, else the ArduPilot SITL home)")
+	scenarioFile := flag.String("scenario", "", "Load a scenario file scheduling sensor faults by simulation time")
+	missionFile := flag.String("mission", "", "Load a QGC .plan or WPL mission onto the active drone (headless: flown after auto-arm)")
+	autotuneAxis := flag.String("autotune", "", "Headless: hover the active drone and relay-autotune an axis (altitude, pitch, roll, yaw)")
+	autotuneRule := flag.String("autotune-rule", "classic", "Gain rule for -autotune (classic, someovershoot, noovershoot)")
+	autotuneApply := flag.Bool("autotune-apply", false, "Apply the -autotune gains to the drone's altitude PID controller (altitude only)")
+	mocapFormat := flag.String("mocap", "", "Stream drone poses as a motion-capture source (natnet, vrpn)")
+	mocapAddr := flag.String("mocap-addr", "", "Where every mocap frame also goes (default: NatNet multicast 239.255.42.99:1511, none for VRPN; \"none\" for connected clients only)")
+	mocapCommand := flag.String("mocap-command", "", "Port mocap clients connect to (default: NatNet command :1510, VRPN :3883; \"none\" to disable)")
+	mocapRate := flag.Float64("mocap-rate", 120, "Mocap frames per second")
+	mocapJitter := flag.Duration("mocap-jitter", 0, "Largest extra delay of a mocap frame (e.g. 2ms)")
+	mocapDropout := flag.Float64("mocap-dropout", 0, "Chance (0..1) a mocap frame is lost")
+	mocapOcclusion := flag.Float64("mocap-occlusion", 0, "Chance (0..1) a body is untracked in a mocap frame")
+	mocapUp := flag.String("mocap-up", "y", "Up axis of the mocap frame (y as in Motive's default, z)")
+	snapshot := flag.String("snapshot", "", "Headless: after the run, render the active drone's camera to this PNG, with depth beside it as <name>_depth.png")
 	flag.Parse()
 
+	var origin *sim.GeoPoint
+	if *originFlag != "" {
+		o, err := sim.ParseGeoPoint(*originFlag)
+		if err != nil {
+			log.Fatalf("Invalid -origin: %v", err)
+		}
+		origin = &o
+	}
+
+	var mission *sim.Mission
+	if *missionFile != "" {
+		m, o, err := sim.LoadMissionFile(*missionFile, origin)
+		if err != nil {
+			log.Fatalf("Failed to load mission %s: %v", *missionFile, err)
+		}
+		mission, origin = m, &o
+		fmt.Printf("Loaded mission %s: %d items (origin %.7f, %.7f, %.1fm)\n", *missionFile, len(m.Items), origin.Lat, origin.Lon, origin.Alt)
+	}
+
+	var scenario *sim.Scenario
+	if *scenarioFile != "" {
+		sc, err := sim.LoadScenario(*scenarioFile)
+		if err != nil {
+			log.Fatalf("Failed to load scenario %s: %v", *scenarioFile, err)
+		}
+		scenario = sc
+	}
+
 	if *headless {
 		fmt.Println("Drone Simulator (headless benchmark) ...")
 		s := sim.NewSimulatorHeadless()
+		if origin != nil {
+			s.World().Origin = *origin
+		}
+		if scenario != nil {
+			if err := scenario.Apply(s.Drones()); err != nil {
+				log.Fatal(err)
+			}
+		}
 		if *arm {
 			for _, d := range s.Drones() {
 				d.Arm()
@@ -42,6 +95,14 @@ func main() {
 				d.SetThrottle(d.HoverThrottlePercent())
 			}
 		}
+		if mission != nil {
+			s.ActiveDrone().SetMission(mission)
+			s.ActiveDrone().StartMission()
+		}
+		if *autotuneAxis != "" {
+			runAutotune(s, *autotuneAxis, *autotuneRule, *autotuneApply, *ups)
+			return
+		}
 		start := time.Now()
 		performed := s.RunHeadless(*steps, *ups, *duration)
 		elapsed := time.Since(start)
@@ -49,6 +110,9 @@ func main() {
 		ad := s.ActiveDrone()
 		fmt.Printf("Completed %d steps in %s (achieved ~%.1f UPS)\n", performed, elapsed.Truncate(time.Millisecond), achievedUPS)
 		fmt.Printf("Leader pos=(%.2f, %.2f, %.2f) battery=%.1f%% throttle=%.0f%%\n", ad.Position.X, ad.Position.Y, ad.Position.Z, ad.BatteryPercent, ad.ThrottlePercent)
+		if *snapshot != "" {
+			writeSnapshot(ad, *snapshot)
+		}
 		return
 	}
 
@@ -92,6 +156,18 @@ func main() {
 	fmt.Printf("GLSL version: %s\n", gl.GoStr(gl.GetString(gl.SHADING_LANGUAGE_VERSION)))
 
 	simulator := sim.NewSimulator()
+	if origin != nil {
+		simulator.World().Origin = *origin
+	}
+	if scenario != nil {
+		if err := scenario.Apply(simulator.Drones()); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if mission != nil {
+		// Started with drone.<id>.mission.start
+		simulator.ActiveDrone().SetMission(mission)
+	}
 
 	// Connect to NATS if URL provided (flag takes precedence, then env var)
 	natsAddr := *natsURL
@@ -119,12 +195,27 @@ func main() {
 		}
 	}
 
+	var mocapServer *mocap.Server
+	if *mocapFormat != "" {
+		mocapServer, err = startMocap(simulator, mocapFlags{
+			format: *mocapFormat, addr: *mocapAddr, command: *mocapCommand, up: *mocapUp,
+			rate: *mocapRate, jitter: *mocapJitter, dropout: *mocapDropout, occlusion: *mocapOcclusion,
+		})
+		if err != nil {
+			log.Printf("Warning: Failed to start mocap stream: %v", err)
+		}
+	}
+
 	if *decoupled {
 		simulator.RunDecoupled(window)
 	} else {
 		simulator.Run(window)
 	}
 
+	if mocapServer != nil {
+		mocapServer.Stop()
+	}
+
 	// Cleanup NATS on exit
 	if microService != nil {
 		microService.Stop()
@@ -133,3 +224,115 @@ func main() {
 		natsClient.Stop()
 	}
 }
+
+// mocapFlags holds the -mocap-* flags.
+type mocapFlags struct {
+	format, addr, command, up string
+	rate                      float64
+	jitter                    time.Duration
+	dropout, occlusion        float64
+}
+
+// startMocap streams s's drone poses as a motion-capture source.
+func startMocap(s *sim.Simulator, f mocapFlags) (*mocap.Server, error) {
+	format, err := mocap.ParseFormat(f.format)
+	if err != nil {
+		return nil, err
+	}
+	up, err := mocap.ParseUpAxis(f.up)
+	if err != nil {
+		return nil, err
+	}
+	cfg := mocap.Config{
+		Format: format, Up: up, Dest: f.addr, Command: f.command,
+		Rate: f.rate, Jitter: f.jitter, Dropout: f.dropout, Occlusion: f.occlusion,
+		Seed: time.Now().UnixNano(),
+	}
+	switch {
+	case cfg.Dest == "none":
+		cfg.Dest = ""
+	case cfg.Dest == "" && format == mocap.FormatNatNet:
+		cfg.Dest = mocap.NatNetMulticast
+	}
+	switch {
+	case cfg.Command == "none":
+		cfg.Command = ""
+	case cfg.Command == "" && format == mocap.FormatVRPN:
+		cfg.Command = mocap.VRPNPort
+	case cfg.Command == "":
+		cfg.Command = mocap.NatNetCommand
+	}
+	server, err := mocap.New(cfg, func() []mocap.Pose {
+		s.RLock()
+		defer s.RUnlock()
+		return mocap.Poses(s.Drones())
+	})
+	if err != nil {
+		return nil, err
+	}
+	server.Start()
+	to := cfg.Dest
+	if a := server.CommandAddr(); to == "" && a != nil {
+		to = "clients of " + a.String()
+	}
+	fmt.Printf("Streaming %s mocap at %.0f Hz to %s\n", format, cfg.Rate, to)
+	return server, nil
+}
+
+// writeSnapshot renders d's camera, or a default one on its gimbal, to path
+// and its depth to path's <name>_depth.png.
+func writeSnapshot(d *sim.Drone, path string) {
+	cam := d.ImageCamera
+	if cam == nil {
+		c := sim.DefaultImageCamera()
+		cam = &c
+	}
+	img := cam.Capture(d)
+	if img == nil {
+		log.Fatalf("Snapshot: camera has no picture (%dx%d)", cam.Width, cam.Height)
+	}
+	depth := strings.TrimSuffix(path, ".png") + "_depth.png"
+	if err := img.SavePNG(path, depth); err != nil {
+		log.Fatalf("Snapshot: %v", err)
+	}
+	fmt.Printf("Camera snapshot written to %s and %s\n", path, depth)
+}
+
+// runAutotune climbs the active drone to a hover and runs a relay autotune,
+// printing the identified loop and the resulting gains.
+func runAutotune(s *sim.Simulator, axisName, ruleName string, apply bool, ups int) {
+	axis, err := sim.ParseTuneAxis(axisName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rule, err := sim.ParseTuneRule(ruleName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if ups <= 0 {
+		ups = 120
+	}
+	d := s.ActiveDrone()
+	if !d.IsArmed {
+		d.Arm()
+		d.SetThrottle(d.HoverThrottlePercent())
+	}
+	d.SetFlightMode(sim.FlightModeAltitudeHold)
+	d.AltitudeHold = 10
+	s.RunHeadless(15*ups, ups, 0)
+
+	if err := d.StartAutotune(sim.AutotuneConfig{Axis: axis, Rule: rule, Apply: apply}); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Autotuning %s (%s rule) at %.1fm ...\n", axis, rule, d.Position.Y)
+	for d.AutotuneStatus().State == sim.AutotuneRunning {
+		s.RunHeadless(ups/10, ups, 0)
+	}
+	st := d.AutotuneStatus()
+	if st.State != sim.AutotuneDone {
+		log.Fatalf("Autotune failed: %s", st.Err)
+	}
+	r := st.Result
+	fmt.Printf("Ku=%.4g Tu=%.3fs amplitude=%.4g\n", r.Ku, r.Tu, r.Amplitude)
+	fmt.Printf("Kp=%.4g Ki=%.4g Kd=%.4g applied=%v\n", r.Gains.Kp, r.Gains.Ki, r.Gains.Kd, r.Applied)
+}
@@ -0,0 +1,165 @@
+package mocap
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Client receives a stream, as an offboard stack would.
+type Client struct {
+	Format Format
+	Server string // NatNet server name, once a connect has been answered
+
+	conn    *net.UDPConn
+	vrpn    *VRPNDecoder
+	command *net.UDPAddr
+	buf     []byte
+
+	tcp    net.Conn      // VRPN connection, when dialled
+	mu     sync.Mutex    // guards vrpn against the TCP reader
+	closed chan struct{} // closed when the VRPN connection ends
+}
+
+// Listen receives a stream of format on addr: a multicast group to join, or
+// a local host:port (":0" for any port, as a NatNet unicast client).
+func Listen(format Format, addr string) (*Client, error) {
+	a, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("mocap: %w", err)
+	}
+	var conn *net.UDPConn
+	if a.IP != nil && a.IP.IsMulticast() {
+		conn, err = net.ListenMulticastUDP("udp", nil, a)
+	} else {
+		conn, err = net.ListenUDP("udp", a)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mocap: %w", err)
+	}
+	return &Client{Format: format, conn: conn, vrpn: NewVRPNDecoder(), buf: make([]byte, natMaxPacket)}, nil
+}
+
+// DialVRPN connects to the VRPN server at server the way vrpn_Connection
+// does: TCP to its port, a cookie exchange, then a description of the local
+// UDP socket (listen, "" for any port) that reports are to be sent to.
+func DialVRPN(listen, server string) (*Client, error) {
+	if listen == "" {
+		listen = ":0"
+	}
+	c, err := Listen(FormatVRPN, listen)
+	if err != nil {
+		return nil, err
+	}
+	tcp, err := net.DialTimeout("tcp", server, vrpnHandshake)
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("mocap: %w", err)
+	}
+	c.tcp, c.closed = tcp, make(chan struct{})
+	if err := vrpnGreet(tcp); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("mocap: vrpn handshake: %w", err)
+	}
+	host, _, _ := net.SplitHostPort(tcp.LocalAddr().String())
+	var desc bytes.Buffer
+	vrpnMessage(&desc, time.Now(), int32(c.conn.LocalAddr().(*net.UDPAddr).Port), vrpnUDPDesc, append([]byte(host), 0))
+	if _, err := tcp.Write(desc.Bytes()); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("mocap: %w", err)
+	}
+	go c.readTCP()
+	return c, nil
+}
+
+// readTCP learns the descriptions the server sends over the connection.
+func (c *Client) readTCP() {
+	defer close(c.closed)
+	for {
+		m, err := readVRPNMessage(c.tcp)
+		if err != nil {
+			return
+		}
+		c.mu.Lock()
+		c.vrpn.Decode(m)
+		c.mu.Unlock()
+	}
+}
+
+// LocalAddr returns the address the client receives on.
+func (c *Client) LocalAddr() net.Addr { return c.conn.LocalAddr() }
+
+// Connect asks a NatNet server to stream to this client by unicast: it sends
+// a connect and a keep-alive to the server's command port from the socket
+// frames are read on.
+func (c *Client) Connect(command string) error {
+	a, err := net.ResolveUDPAddr("udp", command)
+	if err != nil {
+		return fmt.Errorf("mocap: %w", err)
+	}
+	c.command = a
+	if _, err := c.conn.WriteToUDP(connectPacket(), a); err != nil {
+		return fmt.Errorf("mocap: %w", err)
+	}
+	return c.KeepAlive()
+}
+
+// KeepAlive repeats the keep-alive of a connected NatNet client.
+func (c *Client) KeepAlive() error {
+	if c.command == nil {
+		return nil
+	}
+	_, err := c.conn.WriteToUDP(keepAlivePacket(), c.command)
+	return err
+}
+
+// Next returns the next frame to arrive within timeout, skipping other
+// messages, with its arrival time.
+func (c *Client) Next(timeout time.Duration) (Frame, time.Time, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		if c.closed != nil {
+			select {
+			case <-c.closed:
+				return Frame{}, time.Time{}, errVRPNClosed
+			default:
+			}
+		}
+		c.conn.SetReadDeadline(deadline)
+		n, _, err := c.conn.ReadFromUDP(c.buf)
+		if err != nil {
+			return Frame{}, time.Time{}, err
+		}
+		at := time.Now()
+		pkt := c.buf[:n]
+		if c.Format == FormatVRPN {
+			c.mu.Lock()
+			f, err := c.vrpn.Decode(pkt)
+			c.mu.Unlock()
+			if err != nil {
+				return Frame{}, at, err
+			}
+			if len(f.Bodies) > 0 {
+				return f, at, nil
+			}
+			continue
+		}
+		if name, ok := isServerInfo(pkt); ok {
+			c.Server = name
+			continue
+		}
+		if f, err := DecodeNatNet(pkt); err == nil {
+			return f, at, nil
+		}
+	}
+}
+
+// Close closes the client's socket and any VRPN connection.
+func (c *Client) Close() error {
+	if c.tcp != nil {
+		c.tcp.Close()
+	}
+	return c.conn.Close()
+}
@@ -0,0 +1,398 @@
+// Package mocap makes the simulator a motion-capture source: it streams the
+// drones' rigid-body poses as OptiTrack NatNet frames or to VRPN connections
+// as tracker messages, so an offboard stack built for an indoor lab can fly
+// against the sim unchanged.
+package mocap
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	sim "drone-simulator/internal/sim"
+)
+
+// Format is the wire format of the stream.
+type Format int
+
+const (
+	FormatNatNet Format = iota // OptiTrack NatNet 3.1 frames of data
+	FormatVRPN                 // VRPN tracker position/quaternion messages
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatNatNet:
+		return "natnet"
+	case FormatVRPN:
+		return "vrpn"
+	}
+	return "unknown"
+}
+
+// ParseFormat parses "natnet" or "vrpn".
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "natnet", "optitrack":
+		return FormatNatNet, nil
+	case "vrpn":
+		return FormatVRPN, nil
+	}
+	return 0, fmt.Errorf("mocap: unknown format %q (natnet or vrpn)", s)
+}
+
+// UpAxis picks the streamed frame. Both are right-handed with X east:
+// YUp (Motive's default) has Z south and is the simulator's own frame, ZUp
+// has Y north. A rigid body's identity orientation is the drone's at yaw 0:
+// level with its nose south.
+type UpAxis int
+
+const (
+	YUp UpAxis = iota
+	ZUp
+)
+
+// ParseUpAxis parses "y" or "z".
+func ParseUpAxis(s string) (UpAxis, error) {
+	switch strings.ToLower(s) {
+	case "y", "yup":
+		return YUp, nil
+	case "z", "zup":
+		return ZUp, nil
+	}
+	return 0, fmt.Errorf("mocap: unknown up axis %q (y or z)", s)
+}
+
+// convert maps a simulator-frame vector (X east, Y up, Z south) into the
+// streamed frame.
+func (u UpAxis) convert(v sim.Vec3) sim.Vec3 {
+	if u == ZUp {
+		return sim.Vec3{X: v.X, Y: -v.Z, Z: v.Y}
+	}
+	return v
+}
+
+// unconvert is the inverse of convert.
+func (u UpAxis) unconvert(v sim.Vec3) sim.Vec3 {
+	if u == ZUp {
+		return sim.Vec3{X: v.X, Y: v.Z, Z: -v.Y}
+	}
+	return v
+}
+
+// Pose is a drone's pose in the simulator frame, copied out under the
+// simulator's lock.
+type Pose struct {
+	Position sim.Vec3
+	Rotation sim.Vec3 // Euler angles like Drone.Rotation
+}
+
+// Source returns the current pose of every drone, in drone ID order.
+type Source func() []Pose
+
+// Poses copies the poses of drones. Callers must hold the simulator lock.
+func Poses(drones []*sim.Drone) []Pose {
+	out := make([]Pose, len(drones))
+	for i, d := range drones {
+		out[i] = Pose{Position: d.Position, Rotation: d.Rotation}
+	}
+	return out
+}
+
+// RigidBody is one tracked body of a frame in the streamed frame.
+type RigidBody struct {
+	ID          int // NatNet streaming ID: drone ID + 1
+	Name        string
+	Position    sim.Vec3   // m
+	Orientation [4]float64 // unit quaternion x, y, z, w
+	Tracked     bool
+}
+
+// Frame is one motion-capture sample.
+type Frame struct {
+	Number int
+	Time   float64 // s since the stream started, at capture
+	Bodies []RigidBody
+}
+
+// BodyName returns the rigid-body (VRPN tracker) name of drone id.
+func BodyName(id int) string { return fmt.Sprintf("drone%d", id) }
+
+// rigidBody returns drone id's pose as a rigid body in the frame of up.
+func rigidBody(id int, p Pose, up UpAxis) RigidBody {
+	rot := sim.RotationYMat4(p.Rotation.Y).Mul(sim.RotationXMat4(p.Rotation.X)).Mul(sim.RotationZMat4(p.Rotation.Z))
+	// Column j of the streamed rotation is the streamed axis j turned by
+	// the drone's rotation
+	var m [3][3]float64
+	for j, axis := range []sim.Vec3{{X: 1}, {Y: 1}, {Z: 1}} {
+		c := up.convert(rot.MulDirection(up.unconvert(axis)))
+		m[0][j], m[1][j], m[2][j] = c.X, c.Y, c.Z
+	}
+	return RigidBody{
+		ID:          id + 1,
+		Name:        BodyName(id),
+		Position:    up.convert(p.Position),
+		Orientation: quaternion(m),
+		Tracked:     true,
+	}
+}
+
+// quaternion returns the unit quaternion (x, y, z, w) of rotation matrix m.
+func quaternion(m [3][3]float64) [4]float64 {
+	var q [4]float64
+	switch tr := m[0][0] + m[1][1] + m[2][2]; {
+	case tr > 0:
+		s := 2 * math.Sqrt(tr+1)
+		q = [4]float64{(m[2][1] - m[1][2]) / s, (m[0][2] - m[2][0]) / s, (m[1][0] - m[0][1]) / s, s / 4}
+	case m[0][0] > m[1][1] && m[0][0] > m[2][2]:
+		s := 2 * math.Sqrt(1+m[0][0]-m[1][1]-m[2][2])
+		q = [4]float64{s / 4, (m[0][1] + m[1][0]) / s, (m[0][2] + m[2][0]) / s, (m[2][1] - m[1][2]) / s}
+	case m[1][1] > m[2][2]:
+		s := 2 * math.Sqrt(1+m[1][1]-m[0][0]-m[2][2])
+		q = [4]float64{(m[0][1] + m[1][0]) / s, s / 4, (m[1][2] + m[2][1]) / s, (m[0][2] - m[2][0]) / s}
+	default:
+		s := 2 * math.Sqrt(1+m[2][2]-m[0][0]-m[1][1])
+		q = [4]float64{(m[0][2] + m[2][0]) / s, (m[1][2] + m[2][1]) / s, s / 4, (m[1][0] - m[0][1]) / s}
+	}
+	if q[3] < 0 {
+		q = [4]float64{-q[0], -q[1], -q[2], -q[3]}
+	}
+	return q
+}
+
+// Default addresses.
+const (
+	NatNetMulticast = "239.255.42.99:1511" // Motive's default data group and port
+	NatNetCommand   = ":1510"              // Motive's command port
+	VRPNPort        = ":3883"              // VRPN's connection port, TCP and UDP
+)
+
+// Config configures a Server.
+type Config struct {
+	Format Format
+	Up     UpAxis
+
+	// Dest is where every frame is sent: a multicast group or a unicast
+	// host:port. Empty sends only to clients that connected on the command
+	// port.
+	Dest string
+	// Command is the address listened on for clients ("" = none): the NatNet
+	// command port, or the VRPN connection port (TCP, and UDP for lobs).
+	Command string
+
+	Rate      float64       // frames per second (0 = 120)
+	Jitter    time.Duration // each frame leaves up to this late, below the frame period
+	Dropout   float64       // 0..1 chance a whole frame is lost
+	Occlusion float64       // 0..1 chance a body is untracked in a frame
+	Seed      int64
+}
+
+// Server streams frames from a Source.
+type Server struct {
+	cfg    Config
+	source Source
+	rng    *rand.Rand
+
+	data    *net.UDPConn // frames leave from here
+	command *net.UDPConn // NatNet commands and replies
+	dest    *net.UDPAddr
+	vrpn    *net.TCPListener // VRPN connections
+	lob     *net.UDPConn     // VRPN connection requests on the same port
+
+	mu      sync.Mutex
+	clients map[string]*net.UDPAddr // NatNet unicast clients by host
+	peers   map[*vrpnPeer]bool      // VRPN connections past the handshake
+	bodies  []RigidBody             // last frame, for model definitions
+
+	start  time.Time
+	frame  int
+	sent   int
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// New opens the sockets of a stream of source's poses.
+func New(cfg Config, source Source) (*Server, error) {
+	if cfg.Rate <= 0 {
+		cfg.Rate = 120
+	}
+	if cfg.Dropout < 0 || cfg.Dropout > 1 || cfg.Occlusion < 0 || cfg.Occlusion > 1 {
+		return nil, fmt.Errorf("mocap: dropout and occlusion are probabilities")
+	}
+	if period := time.Duration(float64(time.Second) / cfg.Rate); cfg.Jitter >= period {
+		return nil, fmt.Errorf("mocap: jitter %v not below the frame period %v", cfg.Jitter, period)
+	}
+	if cfg.Format == FormatVRPN && cfg.Dest == "" && cfg.Command == "" {
+		return nil, fmt.Errorf("mocap: vrpn needs a connection port or a destination address")
+	}
+	s := &Server{
+		cfg:     cfg,
+		source:  source,
+		rng:     rand.New(rand.NewSource(cfg.Seed)),
+		clients: make(map[string]*net.UDPAddr),
+		peers:   make(map[*vrpnPeer]bool),
+		stopCh:  make(chan struct{}),
+	}
+	var err error
+	if cfg.Dest != "" {
+		if s.dest, err = net.ResolveUDPAddr("udp", cfg.Dest); err != nil {
+			return nil, fmt.Errorf("mocap: destination: %w", err)
+		}
+	}
+	if s.data, err = net.ListenUDP("udp", nil); err != nil {
+		return nil, fmt.Errorf("mocap: %w", err)
+	}
+	if cfg.Format == FormatNatNet && cfg.Command != "" {
+		addr, err := net.ResolveUDPAddr("udp", cfg.Command)
+		if err == nil {
+			s.command, err = net.ListenUDP("udp", addr)
+		}
+		if err != nil {
+			s.data.Close()
+			return nil, fmt.Errorf("mocap: command port: %w", err)
+		}
+	}
+	if cfg.Format == FormatVRPN && cfg.Command != "" {
+		if err := s.listenVRPN(cfg.Command); err != nil {
+			s.data.Close()
+			return nil, fmt.Errorf("mocap: vrpn port: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// CommandAddr returns the address clients connect to, or nil: the NatNet
+// command port or the VRPN connection port.
+func (s *Server) CommandAddr() net.Addr {
+	switch {
+	case s.command != nil:
+		return s.command.LocalAddr()
+	case s.vrpn != nil:
+		return s.vrpn.Addr()
+	}
+	return nil
+}
+
+// Start begins streaming.
+func (s *Server) Start() {
+	s.start = time.Now()
+	s.wg.Add(1)
+	go s.streamLoop()
+	if s.command != nil {
+		s.wg.Add(1)
+		go s.commandLoop()
+	}
+	if s.vrpn != nil {
+		s.wg.Add(2)
+		go s.acceptLoop()
+		go s.lobLoop()
+	}
+}
+
+// Stop ends the stream and closes its sockets.
+func (s *Server) Stop() {
+	close(s.stopCh)
+	s.data.Close()
+	if s.command != nil {
+		s.command.Close()
+	}
+	if s.vrpn != nil {
+		s.vrpn.Close()
+		s.lob.Close()
+	}
+	s.mu.Lock()
+	for p := range s.peers {
+		p.conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Sent returns how many frames have left.
+func (s *Server) Sent() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sent
+}
+
+func (s *Server) streamLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / s.cfg.Rate))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+		}
+		f := s.capture()
+		// Lost frames still use up a number, so clients can count gaps
+		if s.rng.Float64() < s.cfg.Dropout {
+			continue
+		}
+		if s.cfg.Jitter > 0 {
+			select {
+			case <-s.stopCh:
+				return
+			case <-time.After(time.Duration(s.rng.Float64() * float64(s.cfg.Jitter))):
+			}
+		}
+		s.send(f)
+	}
+}
+
+// capture samples the source into the next frame.
+func (s *Server) capture() Frame {
+	poses := s.source()
+	f := Frame{Time: time.Since(s.start).Seconds(), Bodies: make([]RigidBody, len(poses))}
+	for i, p := range poses {
+		f.Bodies[i] = rigidBody(i, p, s.cfg.Up)
+		if s.cfg.Occlusion > 0 && s.rng.Float64() < s.cfg.Occlusion {
+			f.Bodies[i].Tracked = false
+		}
+	}
+	s.mu.Lock()
+	s.frame++
+	f.Number = s.frame
+	s.bodies = f.Bodies
+	s.mu.Unlock()
+	return f
+}
+
+func (s *Server) send(f Frame) {
+	var pkt []byte
+	if s.cfg.Format == FormatVRPN {
+		// Names are described once a second so late clients can map IDs
+		describe := f.Number == 1 || f.Number%int(math.Max(s.cfg.Rate, 1)) == 0
+		pkt = encodeVRPN(f, s.start, describe)
+	} else {
+		pkt = encodeFrame(f)
+	}
+	if len(pkt) == 0 {
+		return // every body occluded
+	}
+	if s.dest != nil {
+		s.data.WriteToUDP(pkt, s.dest)
+	}
+	s.mu.Lock()
+	clients := make([]*net.UDPAddr, 0, len(s.clients))
+	for _, c := range s.clients {
+		clients = append(clients, c)
+	}
+	peers := make([]*vrpnPeer, 0, len(s.peers))
+	for p := range s.peers {
+		peers = append(peers, p)
+	}
+	s.sent++
+	s.mu.Unlock()
+	for _, c := range clients {
+		s.data.WriteToUDP(pkt, c)
+	}
+	for _, p := range peers {
+		s.sendPeer(p, pkt)
+	}
+}
@@ -0,0 +1,291 @@
+package mocap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// NatNet message IDs.
+const (
+	natConnect       = 0
+	natServerInfo    = 1
+	natRequest       = 2
+	natResponse      = 3
+	natRequestModels = 4
+	natModelDef      = 5
+	natRequestFrame  = 6
+	natFrameOfData   = 7
+	natDisconnect    = 9
+	natKeepAlive     = 10
+	natUnrecognized  = 100
+)
+
+const (
+	natDatasetRigid   = 1    // model definition of a rigid body
+	natRigidValidFlag = 0x01 // rigid-body params: tracked this frame
+	natMaxPacket      = 65503
+	natNetVersionMaj  = 3
+	natNetVersionMin  = 1
+	natServerName     = "drone-simulator"
+)
+
+// natWriter writes NatNet's little-endian fields.
+type natWriter struct{ bytes.Buffer }
+
+func (w *natWriter) i16(v int16)   { binary.Write(w, binary.LittleEndian, v) }
+func (w *natWriter) u16(v uint16)  { binary.Write(w, binary.LittleEndian, v) }
+func (w *natWriter) i32(v int32)   { binary.Write(w, binary.LittleEndian, v) }
+func (w *natWriter) u32(v uint32)  { binary.Write(w, binary.LittleEndian, v) }
+func (w *natWriter) u64(v uint64)  { binary.Write(w, binary.LittleEndian, v) }
+func (w *natWriter) f32(v float64) { binary.Write(w, binary.LittleEndian, float32(v)) }
+func (w *natWriter) f64(v float64) { binary.Write(w, binary.LittleEndian, v) }
+func (w *natWriter) cstring(s string) {
+	w.WriteString(s)
+	w.WriteByte(0)
+}
+
+// natPacket prefixes a payload with its message ID and length.
+func natPacket(id uint16, payload []byte) []byte {
+	var w natWriter
+	w.u16(id)
+	w.u16(uint16(len(payload)))
+	w.Write(payload)
+	return w.Bytes()
+}
+
+// encodeFrame returns f as a NatNet 3.1 frame of data: rigid bodies only, no
+// markers, skeletons or devices.
+func encodeFrame(f Frame) []byte {
+	var w natWriter
+	w.i32(int32(f.Number))
+	w.i32(0) // marker sets
+	w.i32(0) // unlabelled markers
+	w.i32(int32(len(f.Bodies)))
+	for _, b := range f.Bodies {
+		w.i32(int32(b.ID))
+		w.f32(b.Position.X)
+		w.f32(b.Position.Y)
+		w.f32(b.Position.Z)
+		for _, q := range b.Orientation {
+			w.f32(q)
+		}
+		w.f32(0) // mean marker error
+		var params int16
+		if b.Tracked {
+			params |= natRigidValidFlag
+		}
+		w.i16(params)
+	}
+	w.i32(0) // skeletons
+	w.i32(0) // labelled markers
+	w.i32(0) // force plates
+	w.i32(0) // devices
+	w.u32(0) // timecode
+	w.u32(0) // timecode subframe
+	w.f64(f.Time)
+	us := uint64(f.Time * 1e6) // high-resolution clock ticks at 1 MHz
+	w.u64(us)                  // mid-exposure
+	w.u64(us)                  // data received
+	w.u64(us)                  // transmit
+	w.i16(0)                   // frame params
+	w.i32(0)                   // end of data
+	return natPacket(natFrameOfData, w.Bytes())
+}
+
+// serverInfo returns the NAT_SERVERINFO reply to a connect.
+func (s *Server) serverInfo() []byte {
+	var w natWriter
+	name := make([]byte, 256)
+	copy(name, natServerName)
+	w.Write(name)
+	w.Write([]byte{1, 0, 0, 0})                               // application version
+	w.Write([]byte{natNetVersionMaj, natNetVersionMin, 0, 0}) // NatNet version
+	w.u64(1e6)                                                // high-resolution clock frequency
+	var port uint16
+	multicast := byte(0)
+	group := make([]byte, 4)
+	if s.dest != nil {
+		port = uint16(s.dest.Port)
+		if ip4 := s.dest.IP.To4(); ip4 != nil && ip4.IsMulticast() {
+			multicast = 1
+			copy(group, ip4)
+		}
+	}
+	w.u16(port)
+	w.WriteByte(multicast)
+	w.Write(group)
+	return natPacket(natServerInfo, w.Bytes())
+}
+
+// modelDefs returns the NAT_MODELDEF reply describing the rigid bodies.
+func (s *Server) modelDefs() []byte {
+	s.mu.Lock()
+	bodies := s.bodies
+	s.mu.Unlock()
+	var w natWriter
+	w.i32(int32(len(bodies)))
+	for _, b := range bodies {
+		w.i32(natDatasetRigid)
+		w.cstring(b.Name)
+		w.i32(int32(b.ID))
+		w.i32(-1) // parent
+		w.f32(0)  // offset from the parent
+		w.f32(0)
+		w.f32(0)
+		w.i32(0) // markers
+	}
+	return natPacket(natModelDef, w.Bytes())
+}
+
+// commandLoop answers NatNet clients on the command port. A connect or a
+// keep-alive makes its sender a unicast client; a keep-alive from another
+// port of the same host moves the stream there, as clients send one from
+// the socket they read data on.
+func (s *Server) commandLoop() {
+	defer s.wg.Done()
+	buf := make([]byte, natMaxPacket)
+	for {
+		n, from, err := s.command.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-s.stopCh:
+				return
+			default:
+				continue
+			}
+		}
+		if n < 4 {
+			continue
+		}
+		host := from.IP.String()
+		var reply []byte
+		switch id := binary.LittleEndian.Uint16(buf); id {
+		case natConnect:
+			s.mu.Lock()
+			if _, ok := s.clients[host]; !ok {
+				s.clients[host] = from
+			}
+			s.mu.Unlock()
+			reply = s.serverInfo()
+		case natKeepAlive:
+			s.mu.Lock()
+			s.clients[host] = from
+			s.mu.Unlock()
+		case natDisconnect:
+			s.mu.Lock()
+			delete(s.clients, host)
+			s.mu.Unlock()
+		case natRequestModels:
+			reply = s.modelDefs()
+		case natRequestFrame:
+			s.mu.Lock()
+			f := Frame{Number: s.frame, Bodies: s.bodies}
+			s.mu.Unlock()
+			reply = encodeFrame(f)
+		case natRequest:
+			reply = natPacket(natResponse, []byte{0, 0, 0, 0})
+		default:
+			reply = natPacket(natUnrecognized, nil)
+		}
+		if reply != nil {
+			s.command.WriteToUDP(reply, from)
+		}
+	}
+}
+
+// natReader reads NatNet's little-endian fields, failing once on a short
+// packet.
+type natReader struct {
+	b   []byte
+	err error
+}
+
+var errShort = errors.New("mocap: short packet")
+
+func (r *natReader) take(n int) []byte {
+	if r.err != nil || n < 0 || len(r.b) < n {
+		r.err = errShort
+		return make([]byte, max(n, 0))
+	}
+	out := r.b[:n]
+	r.b = r.b[n:]
+	return out
+}
+
+func (r *natReader) i16() int16 { return int16(binary.LittleEndian.Uint16(r.take(2))) }
+func (r *natReader) i32() int32 { return int32(binary.LittleEndian.Uint32(r.take(4))) }
+func (r *natReader) f32() float64 {
+	return float64(math.Float32frombits(binary.LittleEndian.Uint32(r.take(4))))
+}
+func (r *natReader) f64() float64 { return math.Float64frombits(binary.LittleEndian.Uint64(r.take(8))) }
+func (r *natReader) cstring() string {
+	i := bytes.IndexByte(r.b, 0)
+	if i < 0 {
+		r.err = errShort
+		return ""
+	}
+	return string(r.take(i + 1)[:i])
+}
+
+// DecodeNatNet decodes a NatNet 3.x frame of data into its rigid bodies.
+// Marker sets and unlabelled markers are skipped; frames carrying skeletons,
+// labelled markers, force plates or devices are not supported.
+func DecodeNatNet(pkt []byte) (Frame, error) {
+	if len(pkt) < 4 {
+		return Frame{}, errShort
+	}
+	if id := binary.LittleEndian.Uint16(pkt); id != natFrameOfData {
+		return Frame{}, fmt.Errorf("mocap: natnet message %d is not a frame", id)
+	}
+	r := &natReader{b: pkt[4:]}
+	f := Frame{Number: int(r.i32())}
+	for sets := r.i32(); sets > 0 && r.err == nil; sets-- {
+		r.cstring()
+		r.take(12 * int(r.i32()))
+	}
+	r.take(12 * int(r.i32()))
+	n := r.i32()
+	if r.err != nil || n < 0 || int(n) > len(r.b)/38 {
+		return Frame{}, errShort
+	}
+	for i := int32(0); i < n; i++ {
+		b := RigidBody{ID: int(r.i32())}
+		b.Position.X, b.Position.Y, b.Position.Z = r.f32(), r.f32(), r.f32()
+		for k := range b.Orientation {
+			b.Orientation[k] = r.f32()
+		}
+		r.f32() // mean marker error
+		b.Tracked = r.i16()&natRigidValidFlag != 0
+		b.Name = BodyName(b.ID - 1)
+		f.Bodies = append(f.Bodies, b)
+	}
+	for _, what := range []string{"skeletons", "labelled markers", "force plates", "devices"} {
+		if c := r.i32(); c != 0 && r.err == nil {
+			return Frame{}, fmt.Errorf("mocap: natnet frames with %s are not supported", what)
+		}
+	}
+	r.take(8) // timecode
+	f.Time = r.f64()
+	return f, r.err
+}
+
+// connectPacket and keepAlivePacket are what a NatNet client sends to the
+// command port.
+func connectPacket() []byte   { return natPacket(natConnect, nil) }
+func keepAlivePacket() []byte { return natPacket(natKeepAlive, nil) }
+
+// isServerInfo reports whether pkt is a NAT_SERVERINFO reply, and the server
+// name it gives.
+func isServerInfo(pkt []byte) (string, bool) {
+	if len(pkt) < 4+256 || binary.LittleEndian.Uint16(pkt) != natServerInfo {
+		return "", false
+	}
+	name := pkt[4 : 4+256]
+	if i := bytes.IndexByte(name, 0); i >= 0 {
+		name = name[:i]
+	}
+	return string(name), true
+}
@@ -0,0 +1,363 @@
+package mocap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// VRPN framing: every message has a five-word big-endian header (length,
+// seconds, microseconds, sender, type) padded to 8 bytes, and a body padded
+// to 8 bytes. Sender and type IDs are bound to names by description
+// messages, which this stream sends on connecting, with the first frame and
+// once a second.
+//
+// A connection starts as vrpn_Connection's does: the client opens TCP to
+// the server's port, or lobs a "host port" datagram at the same UDP port
+// for the server to open TCP back to it. Each side then sends a cookie with
+// its version, and a client that wants reports as datagrams describes its
+// UDP port in a system message. Reports go over TCP until it does.
+const (
+	vrpnAlign            = 8
+	vrpnHeaderLen        = 24 // five words padded
+	vrpnSenderDesc       = -1
+	vrpnTypeDesc         = -2
+	vrpnUDPDesc          = -3 // sender is the port, body the host
+	vrpnDisconnect       = -5
+	vrpnPosQuatType      = 0
+	vrpnPosQuatTypeName  = "vrpn_Tracker Pos_Quat"
+	vrpnPosQuatBodyBytes = 64
+	vrpnMaxMessage       = 1 << 16
+
+	vrpnMagic     = "vrpn: ver. 07.35"
+	vrpnMajor     = "vrpn: ver. 07." // peers must share the major version
+	vrpnCookieLen = 24
+	vrpnHandshake = 5 * time.Second
+)
+
+func vrpnPad(n int) int { return (n + vrpnAlign - 1) / vrpnAlign * vrpnAlign }
+
+// vrpnMessage appends one message to buf.
+func vrpnMessage(buf *bytes.Buffer, t time.Time, sender, typ int32, body []byte) {
+	var h [vrpnHeaderLen]byte
+	be := binary.BigEndian
+	be.PutUint32(h[0:], uint32(vrpnHeaderLen+len(body)))
+	be.PutUint32(h[4:], uint32(t.Unix()))
+	be.PutUint32(h[8:], uint32(t.Nanosecond()/1000))
+	be.PutUint32(h[12:], uint32(sender))
+	be.PutUint32(h[16:], uint32(typ))
+	buf.Write(h[:])
+	buf.Write(body)
+	buf.Write(make([]byte, vrpnPad(len(body))-len(body)))
+}
+
+// vrpnName returns a description body: the name's length with its NUL, then
+// the name.
+func vrpnName(name string) []byte {
+	b := make([]byte, 4, 4+len(name)+1)
+	binary.BigEndian.PutUint32(b, uint32(len(name)+1))
+	return append(append(b, name...), 0)
+}
+
+// encodeVRPN returns f as one datagram of VRPN tracker pos_quat messages, one
+// per tracked body from sender <drone ID> as sensor 0, stamped with the
+// capture time. describe prepends the sender and type descriptions.
+// Untracked bodies send nothing, as a tracker does when it loses a body.
+func encodeVRPN(f Frame, start time.Time, describe bool) []byte {
+	var buf bytes.Buffer
+	t := start.Add(time.Duration(f.Time * float64(time.Second)))
+	if describe {
+		vrpnDescribe(&buf, t, f.Bodies)
+	}
+	for _, b := range f.Bodies {
+		if !b.Tracked {
+			continue
+		}
+		body := make([]byte, vrpnPosQuatBodyBytes)
+		// Sensor 0 and a padding word, then position and quaternion
+		for i, v := range []float64{b.Position.X, b.Position.Y, b.Position.Z,
+			b.Orientation[0], b.Orientation[1], b.Orientation[2], b.Orientation[3]} {
+			binary.BigEndian.PutUint64(body[8+8*i:], math.Float64bits(v))
+		}
+		vrpnMessage(&buf, t, int32(b.ID-1), vrpnPosQuatType, body)
+	}
+	return buf.Bytes()
+}
+
+// vrpnDescribe appends the pos_quat type and each body's sender description.
+func vrpnDescribe(buf *bytes.Buffer, t time.Time, bodies []RigidBody) {
+	vrpnMessage(buf, t, vrpnPosQuatType, vrpnTypeDesc, vrpnName(vrpnPosQuatTypeName))
+	for _, b := range bodies {
+		vrpnMessage(buf, t, int32(b.ID-1), vrpnSenderDesc, vrpnName(b.Name))
+	}
+}
+
+// vrpnCookie returns the cookie sent on connecting: the magic, two spaces
+// and the remote logging mode (0, none), NUL-padded.
+func vrpnCookie() []byte {
+	b := make([]byte, vrpnCookieLen)
+	copy(b, vrpnMagic+"  0")
+	return b
+}
+
+// vrpnGreet exchanges cookies on a new connection.
+func vrpnGreet(conn net.Conn) error {
+	conn.SetDeadline(time.Now().Add(vrpnHandshake))
+	defer conn.SetDeadline(time.Time{})
+	if _, err := conn.Write(vrpnCookie()); err != nil {
+		return err
+	}
+	peer := make([]byte, vrpnCookieLen)
+	if _, err := io.ReadFull(conn, peer); err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(peer, []byte(vrpnMajor)) {
+		return fmt.Errorf("mocap: vrpn cookie %q", bytes.TrimRight(peer, "\x00"))
+	}
+	return nil
+}
+
+// readVRPNMessage reads one padded message from a connection.
+func readVRPNMessage(r io.Reader) ([]byte, error) {
+	h := make([]byte, vrpnHeaderLen)
+	if _, err := io.ReadFull(r, h); err != nil {
+		return nil, err
+	}
+	n := int(binary.BigEndian.Uint32(h))
+	if n < vrpnHeaderLen || n > vrpnMaxMessage {
+		return nil, fmt.Errorf("mocap: vrpn message length %d", n)
+	}
+	m := make([]byte, vrpnPad(n))
+	copy(m, h)
+	_, err := io.ReadFull(r, m[vrpnHeaderLen:])
+	return m, err
+}
+
+// vrpnPeer is a VRPN connection past the handshake.
+type vrpnPeer struct {
+	conn net.Conn
+	mu   sync.Mutex   // serialises writes
+	udp  *net.UDPAddr // where reports go once the peer described it
+}
+
+// listenVRPN opens the VRPN connection port: TCP, and UDP on the same port
+// for lobs.
+func (s *Server) listenVRPN(addr string) error {
+	a, err := net.ResolveTCPAddr("tcp", addr)
+	if err != nil {
+		return err
+	}
+	if s.vrpn, err = net.ListenTCP("tcp", a); err != nil {
+		return err
+	}
+	bound := s.vrpn.Addr().(*net.TCPAddr)
+	if s.lob, err = net.ListenUDP("udp", &net.UDPAddr{IP: bound.IP, Port: bound.Port}); err != nil {
+		s.vrpn.Close()
+		return err
+	}
+	return nil
+}
+
+// acceptLoop serves clients that connect to the VRPN port.
+func (s *Server) acceptLoop() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.vrpn.Accept()
+		if err != nil {
+			select {
+			case <-s.stopCh:
+				return
+			default:
+				continue
+			}
+		}
+		s.wg.Add(1)
+		go s.servePeer(conn)
+	}
+}
+
+// lobLoop connects back to clients that lob a "host port" datagram at the
+// VRPN port, as older vrpn_Connection clients do.
+func (s *Server) lobLoop() {
+	defer s.wg.Done()
+	buf := make([]byte, 1024)
+	for {
+		n, from, err := s.lob.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-s.stopCh:
+				return
+			default:
+				continue
+			}
+		}
+		f := strings.Fields(string(bytes.TrimRight(buf[:n], "\x00")))
+		if len(f) != 2 {
+			continue
+		}
+		host := f[0]
+		if net.ParseIP(host) == nil {
+			host = from.IP.String() // a name the client may not resolve the same way
+		}
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, f[1]), vrpnHandshake)
+		if err != nil {
+			continue
+		}
+		s.wg.Add(1)
+		go s.servePeer(conn)
+	}
+}
+
+// servePeer runs one VRPN connection: the handshake, the descriptions of the
+// bodies seen so far, then the client's system messages until it goes.
+func (s *Server) servePeer(conn net.Conn) {
+	defer s.wg.Done()
+	defer conn.Close()
+	if vrpnGreet(conn) != nil {
+		return
+	}
+	p := &vrpnPeer{conn: conn}
+	s.mu.Lock()
+	var desc bytes.Buffer
+	vrpnDescribe(&desc, time.Now(), s.bodies)
+	s.peers[p] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.peers, p)
+		s.mu.Unlock()
+	}()
+	select {
+	case <-s.stopCh:
+		return // Stop may have closed the peers before this one joined
+	default:
+	}
+	p.mu.Lock()
+	_, err := conn.Write(desc.Bytes())
+	p.mu.Unlock()
+	if err != nil {
+		return
+	}
+	be := binary.BigEndian
+	for {
+		m, err := readVRPNMessage(conn)
+		if err != nil {
+			return
+		}
+		n := int(be.Uint32(m))
+		switch int32(be.Uint32(m[16:])) {
+		case vrpnUDPDesc:
+			port := int(be.Uint32(m[12:]))
+			host := string(bytes.TrimRight(m[vrpnHeaderLen:n], "\x00"))
+			if host == "" {
+				host, _, _ = net.SplitHostPort(conn.RemoteAddr().String())
+			}
+			a, err := net.ResolveUDPAddr("udp", net.JoinHostPort(host, strconv.Itoa(port)))
+			if err != nil {
+				continue
+			}
+			p.mu.Lock()
+			p.udp = a
+			p.mu.Unlock()
+		case vrpnDisconnect:
+			return
+		}
+	}
+}
+
+// sendPeer sends one frame's messages to a peer: as a datagram once it has
+// described a UDP port, over its TCP connection until then.
+func (s *Server) sendPeer(p *vrpnPeer, pkt []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.udp != nil {
+		s.data.WriteToUDP(pkt, p.udp)
+		return
+	}
+	p.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	if _, err := p.conn.Write(pkt); err != nil {
+		p.conn.Close() // servePeer drops it
+	}
+}
+
+// errVRPNClosed is returned to a client whose server went away.
+var errVRPNClosed = errors.New("mocap: vrpn connection closed")
+
+// VRPNDecoder turns VRPN datagrams into frames, learning sender and type
+// names from description messages. Pos_quat messages from senders not yet
+// described are dropped.
+type VRPNDecoder struct {
+	senders map[int32]string
+	types   map[int32]string
+}
+
+// NewVRPNDecoder returns a decoder that has seen no descriptions.
+func NewVRPNDecoder() *VRPNDecoder {
+	return &VRPNDecoder{senders: make(map[int32]string), types: make(map[int32]string)}
+}
+
+// Decode returns the tracker reports in pkt as a frame timed by the first
+// report's timestamp (Unix seconds). The frame has no number: VRPN carries
+// none.
+func (d *VRPNDecoder) Decode(pkt []byte) (Frame, error) {
+	var f Frame
+	be := binary.BigEndian
+	for len(pkt) > 0 {
+		if len(pkt) < vrpnHeaderLen {
+			return f, errShort
+		}
+		n := int(be.Uint32(pkt))
+		if n < vrpnHeaderLen || vrpnPad(n) > len(pkt) {
+			return f, fmt.Errorf("mocap: vrpn message length %d", n)
+		}
+		sec, usec := be.Uint32(pkt[4:]), be.Uint32(pkt[8:])
+		sender, typ := int32(be.Uint32(pkt[12:])), int32(be.Uint32(pkt[16:]))
+		body := pkt[vrpnHeaderLen:n]
+		pkt = pkt[vrpnPad(n):]
+
+		switch {
+		case typ == vrpnSenderDesc || typ == vrpnTypeDesc:
+			if len(body) < 4 {
+				return f, errShort
+			}
+			l := int(be.Uint32(body))
+			if l < 1 || 4+l > len(body) {
+				return f, errShort
+			}
+			name := string(body[4 : 4+l-1])
+			if typ == vrpnSenderDesc {
+				d.senders[sender] = name
+			} else {
+				d.types[sender] = name
+			}
+		case d.types[typ] == vrpnPosQuatTypeName:
+			name, ok := d.senders[sender]
+			if !ok {
+				continue
+			}
+			if len(body) < vrpnPosQuatBodyBytes {
+				return f, errShort
+			}
+			var v [7]float64
+			for i := range v {
+				v[i] = math.Float64frombits(be.Uint64(body[8+8*i:]))
+			}
+			b := RigidBody{ID: int(sender) + 1, Name: name, Tracked: true}
+			b.Position.X, b.Position.Y, b.Position.Z = v[0], v[1], v[2]
+			copy(b.Orientation[:], v[3:])
+			if len(f.Bodies) == 0 {
+				f.Time = float64(sec) + float64(usec)/1e6
+			}
+			f.Bodies = append(f.Bodies, b)
+		}
+	}
+	return f, nil
+}
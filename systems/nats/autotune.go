@@ -0,0 +1,68 @@
+package nats
+
+import (
+	sim "drone-simulator/internal/sim"
+)
+
+// AutotuneCmd is received on drone.<id>.autotune. Zero values pick the
+// simulator defaults; {"cancel": true} stops a running experiment.
+type AutotuneCmd struct {
+	Axis       string  `json:"axis"`                 // altitude, pitch, roll, yaw
+	Rule       string  `json:"rule,omitempty"`       // classic, someovershoot, noovershoot
+	Amplitude  float64 `json:"amplitude,omitempty"`  // relay amplitude (N or N·m)
+	Hysteresis float64 `json:"hysteresis,omitempty"` // relay hysteresis (m or rad)
+	Cycles     int     `json:"cycles,omitempty"`
+	Apply      bool    `json:"apply,omitempty"`
+	Cancel     bool    `json:"cancel,omitempty"`
+}
+
+// AutotuneMsg reports autotune progress and results in telemetry.
+type AutotuneMsg struct {
+	State   string  `json:"state"`
+	Axis    string  `json:"axis"`
+	Cycle   int     `json:"cycle"`
+	Cycles  int     `json:"cycles"`
+	Ku      float64 `json:"ku,omitempty"`
+	Tu      float64 `json:"tu,omitempty"`
+	Kp      float64 `json:"kp,omitempty"`
+	Ki      float64 `json:"ki,omitempty"`
+	Kd      float64 `json:"kd,omitempty"`
+	Applied bool    `json:"applied,omitempty"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// autotuneConfigFromCmd converts and validates a command.
+func autotuneConfigFromCmd(cmd AutotuneCmd) (sim.AutotuneConfig, error) {
+	axis, err := sim.ParseTuneAxis(cmd.Axis)
+	if err != nil {
+		return sim.AutotuneConfig{}, err
+	}
+	rule, err := sim.ParseTuneRule(cmd.Rule)
+	if err != nil {
+		return sim.AutotuneConfig{}, err
+	}
+	return sim.AutotuneConfig{
+		Axis:       axis,
+		Rule:       rule,
+		Amplitude:  cmd.Amplitude,
+		Hysteresis: cmd.Hysteresis,
+		Cycles:     cmd.Cycles,
+		Apply:      cmd.Apply,
+	}, nil
+}
+
+// autotuneMsgFor returns autotune state for telemetry, or nil if the drone
+// has not been autotuned since arming.
+func autotuneMsgFor(d *sim.Drone) *AutotuneMsg {
+	st := d.AutotuneStatus()
+	if st.State == sim.AutotuneIdle {
+		return nil
+	}
+	m := &AutotuneMsg{State: st.State.String(), Axis: st.Axis.String(), Cycle: st.Cycle, Cycles: st.Cycles, Error: st.Err}
+	if r := st.Result; r != nil {
+		m.Ku, m.Tu = r.Ku, r.Tu
+		m.Kp, m.Ki, m.Kd = r.Gains.Kp, r.Gains.Ki, r.Gains.Kd
+		m.Applied = r.Applied
+	}
+	return m
+}
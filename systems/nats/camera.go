@@ -0,0 +1,126 @@
+package nats
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+
+	sim "drone-simulator/internal/sim"
+
+	"github.com/nats-io/nats.go"
+)
+
+// CameraCmd is received on drone.<id>.camera. It fits the software-rendered
+// camera with its defaults on first use; omitted fields keep their values.
+// The camera rides the gimbal unless fixed, when offset and rotation place it
+// on the body. {"remove": true} takes it off; {"snapshot": true} renders a
+// frame at once, so a rate of 0 gives frames only on demand.
+type CameraCmd struct {
+	Width    int      `json:"width,omitempty"`  // pixels
+	Height   int      `json:"height,omitempty"` // pixels
+	Rate     *float64 `json:"rate,omitempty"`   // frames per second
+	MaxRange float64  `json:"maxRange,omitempty"`
+	Fixed    *bool    `json:"fixed,omitempty"`    // body-mounted instead of on the gimbal
+	Offset   *Vec3Msg `json:"offset,omitempty"`   // m from the drone's centre: x left, y up, z nose
+	Rotation *Vec3Msg `json:"rotation,omitempty"` // degrees pitch (x), yaw (y), roll (z) from the body
+	FOV      *float64 `json:"fov,omitempty"`      // degrees vertical, fixed mount only
+	Snapshot bool     `json:"snapshot,omitempty"`
+	Remove   bool     `json:"remove,omitempty"`
+}
+
+// maxCameraPixels keeps a frame's PNG well inside a NATS payload.
+const maxCameraPixels = 1280 * 960
+
+// applyCameraCmd fits, configures or removes d's camera. Nothing changes on an
+// error. Callers must hold the simulator write lock.
+func applyCameraCmd(d *sim.Drone, cmd CameraCmd) error {
+	if cmd.Remove {
+		d.ImageCamera = nil
+		return nil
+	}
+	cam := sim.DefaultImageCamera()
+	if d.ImageCamera != nil {
+		cam = *d.ImageCamera
+	}
+	if cmd.Width > 0 {
+		cam.Width = cmd.Width
+	}
+	if cmd.Height > 0 {
+		cam.Height = cmd.Height
+	}
+	if cam.Width*cam.Height > maxCameraPixels {
+		return fmt.Errorf("camera: %dx%d above %d pixels", cam.Width, cam.Height, maxCameraPixels)
+	}
+	if cmd.Rate != nil {
+		cam.Rate = math.Max(*cmd.Rate, 0)
+	}
+	if cmd.MaxRange > 0 {
+		cam.MaxRange = cmd.MaxRange
+	}
+	if cmd.FOV != nil {
+		if *cmd.FOV <= 0 || *cmd.FOV >= 180 {
+			return fmt.Errorf("camera: fov %v out of range", *cmd.FOV)
+		}
+		cam.FOV = sim.DegToRad(*cmd.FOV)
+	}
+	if cmd.Fixed != nil {
+		if !*cmd.Fixed {
+			cam.Mount = nil
+		} else if cam.Mount == nil {
+			cam.Mount = &sim.SensorMount{}
+		}
+	}
+	if cam.Mount != nil {
+		m := *cam.Mount
+		if cmd.Offset != nil {
+			m.Offset = sim.Vec3{X: cmd.Offset.X, Y: cmd.Offset.Y, Z: cmd.Offset.Z}
+		}
+		if cmd.Rotation != nil {
+			m.Rotation = degVec(*cmd.Rotation)
+		}
+		cam.Mount = &m
+	}
+	d.ImageCamera = &cam
+	if cmd.Snapshot {
+		cam.Capture(d)
+	}
+	return nil
+}
+
+// cameraFrameMsgs encodes a frame as PNG messages for camera.<id>.rgb and
+// camera.<id>.depth. Headers carry the simulation time, frame count,
+// intrinsics and the camera's world pose, so the payload is the bare image.
+func cameraFrameMsgs(id int, img *sim.CameraImage) ([]*nats.Msg, error) {
+	v := img.View
+	f := strconv.FormatFloat
+	vec := func(p sim.Vec3) string {
+		return f(p.X, 'f', 4, 64) + "," + f(p.Y, 'f', 4, 64) + "," + f(p.Z, 'f', 4, 64)
+	}
+	var out []*nats.Msg
+	for _, enc := range []struct {
+		kind  string
+		write func(*bytes.Buffer) error
+	}{
+		{"rgb", func(b *bytes.Buffer) error { return img.WritePNG(b) }},
+		{"depth", func(b *bytes.Buffer) error { return img.WriteDepthPNG(b) }},
+	} {
+		var buf bytes.Buffer
+		if err := enc.write(&buf); err != nil {
+			return nil, err
+		}
+		m := nats.NewMsg(CameraSubject(id, enc.kind))
+		m.Data = buf.Bytes()
+		m.Header.Set("Content-Type", "image/png")
+		m.Header.Set("Camera-Time", f(img.Time, 'f', 3, 64))
+		m.Header.Set("Camera-Frame", strconv.Itoa(img.Count))
+		m.Header.Set("Camera-Focal", f(v.FocalLength(), 'f', 3, 64)) // pixels; principal point at the centre
+		m.Header.Set("Camera-Position", vec(v.Position))
+		m.Header.Set("Camera-Rotation", vec(v.Rotation)) // rad like the drone's
+		if enc.kind == "depth" {
+			m.Header.Set("Depth-Scale", "0.001") // m per unit; 0 = no return
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
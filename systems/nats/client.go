@@ -24,6 +24,8 @@ type Client struct {
 
 	// Telemetry config
 	telemetryHz float64
+	lidarScans  map[int]int // last lidar scan published per drone
+	frames      map[int]int // last camera frame published per drone
 }
 
 // TelemetryMsg is published to drone.<id>.telemetry
@@ -31,14 +33,48 @@ type TelemetryMsg struct {
 	ID         int       `json:"id"`
 	Timestamp  int64     `json:"timestamp"`
 	Position   Vec3Msg   `json:"position"`
+	Lat        float64   `json:"lat"`     // WGS84 degrees
+	Lon        float64   `json:"lon"`     // WGS84 degrees
+	AltMSL     float64   `json:"altMSL"`  // meters above sea level
+	Heading    float64   `json:"heading"` // compass degrees, clockwise from north
 	Velocity   Vec3Msg   `json:"velocity"`
 	Rotation   Vec3Msg   `json:"rotation"`
 	Battery    float64   `json:"battery"`
+	Voltage    float64   `json:"voltage"` // pack voltage under load
 	FlightMode string    `json:"flightMode"`
 	Throttle   float64   `json:"throttle"`
 	Armed      bool      `json:"armed"`
 	OnGround   bool      `json:"onGround"`
 	Destroyed  bool      `json:"destroyed"`
+	Mission    *MissionMsg `json:"mission,omitempty"`
+	Action     string      `json:"action,omitempty"`   // safety action in control (Hold, ReturnHome, Land, Terminate)
+	Failsafe   string      `json:"failsafe,omitempty"` // failsafe trigger in control
+	Fault      *FaultMsg   `json:"fault,omitempty"`    // detected motor failure
+	Autotune   *AutotuneMsg `json:"autotune,omitempty"` // relay autotune progress/result
+	Orbit      *OrbitMsg    `json:"orbit,omitempty"`    // point-of-interest orbit being flown
+	Follow     *FollowMsg   `json:"follow,omitempty"`   // target tracking state
+	PrecLand   *PrecisionLandMsg `json:"precland,omitempty"` // precision landing on a platform
+	Input      *InputMsg    `json:"input,omitempty"`    // remote stick link
+	PreArm     []PreArmMessage `json:"prearm,omitempty"` // failing pre-arm checks while disarmed
+	Energy     *EnergyMsg      `json:"energy"`           // endurance, range and return-home budget
+	IMU        *IMUMsg         `json:"imu,omitempty"`    // latest accelerometer and gyro sample
+	GNSS       *GNSSMsg        `json:"gnss,omitempty"`   // latest receiver fix
+	Baro       *BaroMsg        `json:"baro,omitempty"`   // latest barometer sample
+	Mag        *MagMsg         `json:"mag,omitempty"`    // latest magnetometer sample
+	EKF        *EKFMsg         `json:"ekf,omitempty"`    // sensor-fusion estimate and its error from the truth above
+	Range      *RangeMsg       `json:"range,omitempty"`  // mounted rangefinder
+	Flow       *FlowMsg        `json:"flow,omitempty"`   // mounted optical-flow sensor
+	Gimbal     *GimbalMsg      `json:"gimbal"`           // camera gimbal mode and pointing
+	SensorFaults []ActiveFaultMsg `json:"sensorFaults,omitempty"` // injected sensor faults in force
+	Nav        string          `json:"nav"`              // navigation source: Truth, GNSS or EKF
+}
+
+// FaultMsg reports a detected motor failure and the recovery state.
+type FaultMsg struct {
+	Motor   int     `json:"motor"`   // failed engine index
+	Loss    float64 `json:"loss"`    // estimated thrust fraction lost
+	Mode    string  `json:"mode"`    // "ReducedAttitude" while recovering, else "None"
+	YawRate float64 `json:"yawRate"` // rad/s
 }
 
 type Vec3Msg struct {
@@ -52,6 +88,14 @@ type GotoCmd struct {
 	X float64 `json:"x"`
 	Y float64 `json:"y"`
 	Z float64 `json:"z"`
+
+	// Plan flies a planned 3D path around world obstacles instead of only
+	// changing altitude
+	Plan      bool    `json:"plan,omitempty"`
+	Speed     float64 `json:"speed,omitempty"`     // m/s (0 = default)
+	Algorithm string  `json:"algorithm,omitempty"` // astar (default), rrtstar
+
+	GeoTargetMsg // optional WGS84 target in place of x/y/z
 }
 
 // TakeoffCmd is received on drone.<id>.takeoff
@@ -61,10 +105,12 @@ type TakeoffCmd struct {
 
 // InputCmd is received on drone.<id>.input
 type InputCmd struct {
-	Throttle float64 `json:"throttle"`
-	Yaw      float64 `json:"yaw"`
-	Pitch    float64 `json:"pitch"`
-	Roll     float64 `json:"roll"`
+	Throttle  float64 `json:"throttle"`            // 0..1
+	Yaw       float64 `json:"yaw"`                 // -1..1, positive clockwise
+	Pitch     float64 `json:"pitch"`               // -1..1, positive forward
+	Roll      float64 `json:"roll"`                // -1..1, positive right
+	Timestamp int64   `json:"timestamp,omitempty"` // sender time in Unix ms, for latency
+	Release   bool    `json:"release,omitempty"`   // stop remote sticks and the deadman
 }
 
 // ModeCmd is received on drone.<id>.mode
@@ -95,6 +141,8 @@ func New(url string, simulator *sim.Simulator) (*Client, error) {
 		simulator:   simulator,
 		stopCh:      make(chan struct{}),
 		telemetryHz: 10, // Default 10 Hz
+		lidarScans:  make(map[int]int),
+		frames:      make(map[int]int),
 	}
 
 	return c, nil
@@ -190,6 +238,122 @@ func (c *Client) subscribeCommands() error {
 	}
 	c.subs = append(c.subs, sub)
 
+	// drone.<id>.mission.<action> (upload, start, pause, resume, clear)
+	sub, err = c.nc.Subscribe("drone.*.mission.*", c.handleMission)
+	if err != nil {
+		return err
+	}
+	c.subs = append(c.subs, sub)
+
+	// drone.<id>.geofence
+	sub, err = c.nc.Subscribe("drone.*.geofence", c.handleGeofence)
+	if err != nil {
+		return err
+	}
+	c.subs = append(c.subs, sub)
+
+	// drone.<id>.failsafe
+	sub, err = c.nc.Subscribe("drone.*.failsafe", c.handleFailsafe)
+	if err != nil {
+		return err
+	}
+	c.subs = append(c.subs, sub)
+
+	// drone.<id>.autotune
+	sub, err = c.nc.Subscribe("drone.*.autotune", c.handleAutotune)
+	if err != nil {
+		return err
+	}
+	c.subs = append(c.subs, sub)
+
+	// drone.<id>.orbit
+	sub, err = c.nc.Subscribe("drone.*.orbit", c.handleOrbit)
+	if err != nil {
+		return err
+	}
+	c.subs = append(c.subs, sub)
+
+	// drone.<id>.follow
+	sub, err = c.nc.Subscribe("drone.*.follow", c.handleFollow)
+	if err != nil {
+		return err
+	}
+	c.subs = append(c.subs, sub)
+
+	// target.<name>
+	sub, err = c.nc.Subscribe(SubjectTargetPattern, c.handleTarget)
+	if err != nil {
+		return err
+	}
+	c.subs = append(c.subs, sub)
+
+	// drone.<id>.gnss
+	sub, err = c.nc.Subscribe("drone.*.gnss", c.handleGNSS)
+	if err != nil {
+		return err
+	}
+	c.subs = append(c.subs, sub)
+
+	// drone.<id>.precland
+	sub, err = c.nc.Subscribe("drone.*.gimbal", c.handleGimbal)
+	if err != nil {
+		return err
+	}
+	c.subs = append(c.subs, sub)
+
+	sub, err = c.nc.Subscribe("drone.*.camera", c.handleCamera)
+	if err != nil {
+		return err
+	}
+	c.subs = append(c.subs, sub)
+
+	sub, err = c.nc.Subscribe("drone.*.faults", c.handleSensorFaults)
+	if err != nil {
+		return err
+	}
+	c.subs = append(c.subs, sub)
+
+	sub, err = c.nc.Subscribe("drone.*.precland", c.handlePrecisionLand)
+	if err != nil {
+		return err
+	}
+	c.subs = append(c.subs, sub)
+
+	// platform.<name>
+	sub, err = c.nc.Subscribe(SubjectPlatformPattern, c.handlePlatform)
+	if err != nil {
+		return err
+	}
+	c.subs = append(c.subs, sub)
+
+	// drone.<id>.sensor.<kind> (rangefinder, flow, lidar)
+	sub, err = c.nc.Subscribe("drone.*.sensor.*", c.handleRaySensor)
+	if err != nil {
+		return err
+	}
+	c.subs = append(c.subs, sub)
+
+	// world.obstacles
+	sub, err = c.nc.Subscribe(SubjectWorldOrigin, c.handleOrigin)
+	if err != nil {
+		return err
+	}
+	c.subs = append(c.subs, sub)
+
+	sub, err = c.nc.Subscribe(SubjectWorldObstacles, c.handleWorld)
+	if err != nil {
+		return err
+	}
+	c.subs = append(c.subs, sub)
+
+	// Any drone.<id>.* message (including drone.<id>.heartbeat) keeps the
+	// command link alive for the link-loss failsafe
+	sub, err = c.nc.Subscribe("drone.*.>", c.handleLink)
+	if err != nil {
+		return err
+	}
+	c.subs = append(c.subs, sub)
+
 	return nil
 }
 
@@ -227,8 +391,12 @@ func (c *Client) handleArm(msg *nats.Msg) {
 	}
 
 	c.simulator.Lock()
-	drone.Arm()
+	res := drone.Arm()
 	c.simulator.Unlock()
+	if !res.Armed {
+		log.Printf("drone %d: %v", id, res.Err())
+		return
+	}
 	log.Printf("drone %d armed", id)
 }
 
@@ -318,8 +486,24 @@ func (c *Client) handleGoto(msg *nats.Msg) {
 		log.Printf("goto: invalid payload: %v", err)
 		return
 	}
+	target, err := cmd.resolve(worldOrigin(c.simulator), sim.Vec3{X: cmd.X, Y: cmd.Y, Z: cmd.Z})
+	if err != nil {
+		log.Printf("goto: %v", err)
+		return
+	}
+	cmd.X, cmd.Y, cmd.Z = target.X, target.Y, target.Z
+
+	if cmd.Plan {
+		path, err := gotoPlanned(c.simulator, drone, cmd)
+		if err != nil {
+			log.Printf("goto: drone %d: %v", id, err)
+			return
+		}
+		log.Printf("drone %d planned goto (%.1f, %.1f, %.1f): %d legs", id, cmd.X, cmd.Y, cmd.Z, len(path)-1)
+		return
+	}
 
-	// GOTO currently only supports altitude control.
+	// Without a plan GOTO only controls altitude.
 	// Lateral positioning (X, Z) is not implemented because the simulator's
 	// internal physics (stability damping, motor torques) conflicts with
 	// external torque control. Full 3D positioning would require either:
@@ -352,9 +536,7 @@ func (c *Client) handleInput(msg *nats.Msg) {
 	}
 
 	c.simulator.Lock()
-	drone.SetFlightMode(sim.FlightModeManual)
-	drone.SetThrottle(cmd.Throttle * 100) // Convert 0-1 to 0-100%
-	// Yaw/Pitch/Roll would need additional methods on Drone
+	applyInputCmd(drone, cmd, time.Now())
 	c.simulator.Unlock()
 }
 
@@ -413,6 +595,445 @@ func (c *Client) handleStop(msg *nats.Msg) {
 	log.Printf("drone %d emergency stop", id)
 }
 
+func (c *Client) handleMission(msg *nats.Msg) {
+	id, err := c.parseDroneID(msg.Subject)
+	if err != nil {
+		log.Printf("mission: %v", err)
+		return
+	}
+	drone := c.getDrone(id)
+	if drone == nil {
+		log.Printf("mission: drone %d not found", id)
+		return
+	}
+	parts := strings.Split(msg.Subject, ".")
+	action := parts[len(parts)-1]
+
+	var mission *sim.Mission
+	if action == "upload" {
+		var cmd MissionUploadCmd
+		if err := json.Unmarshal(msg.Data, &cmd); err != nil {
+			log.Printf("mission: invalid payload: %v", err)
+			return
+		}
+		mission, err = missionFromMsg(cmd, worldOrigin(c.simulator))
+		if err != nil {
+			log.Printf("mission: %v", err)
+			return
+		}
+	}
+
+	c.simulator.Lock()
+	ok := applyMissionAction(drone, action, mission)
+	c.simulator.Unlock()
+	if !ok {
+		log.Printf("drone %d mission %s rejected", id, action)
+		return
+	}
+	log.Printf("drone %d mission %s", id, action)
+}
+
+func (c *Client) handleGeofence(msg *nats.Msg) {
+	id, err := c.parseDroneID(msg.Subject)
+	if err != nil {
+		log.Printf("geofence: %v", err)
+		return
+	}
+	drone := c.getDrone(id)
+	if drone == nil {
+		log.Printf("geofence: drone %d not found", id)
+		return
+	}
+	var cmd GeofenceCmd
+	if err := json.Unmarshal(msg.Data, &cmd); err != nil {
+		log.Printf("geofence: invalid payload: %v", err)
+		return
+	}
+	fences, err := geofencesFromMsg(cmd)
+	if err != nil {
+		log.Printf("geofence: %v", err)
+		return
+	}
+
+	c.simulator.Lock()
+	drone.Geofences = fences
+	c.simulator.Unlock()
+	log.Printf("drone %d geofences set (%d)", id, len(fences))
+}
+
+func (c *Client) handleWorld(msg *nats.Msg) {
+	var cmd WorldCmd
+	if err := json.Unmarshal(msg.Data, &cmd); err != nil {
+		log.Printf("world: invalid payload: %v", err)
+		return
+	}
+
+	c.simulator.Lock()
+	w := c.simulator.World()
+	applyWorldCmd(w, cmd)
+	n := len(w.Obstacles)
+	c.simulator.Unlock()
+	log.Printf("world obstacles updated (%d)", n)
+}
+
+// handleOrigin moves the WGS84 anchor of the simulator frame. Local positions
+// are kept, so every geodetic position shifts with it. An empty payload only
+// asks; requests are answered with the anchor in force.
+func (c *Client) handleOrigin(msg *nats.Msg) {
+	if len(msg.Data) > 0 {
+		var cmd OriginMsg
+		if err := json.Unmarshal(msg.Data, &cmd); err != nil {
+			log.Printf("origin: invalid payload: %v", err)
+			return
+		}
+		o := sim.GeoPoint{Lat: cmd.Lat, Lon: cmd.Lon, Alt: cmd.Alt}
+		if err := o.Validate(); err != nil {
+			log.Printf("origin: %v", err)
+			return
+		}
+		c.simulator.Lock()
+		c.simulator.World().Origin = o
+		c.simulator.Unlock()
+		log.Printf("world origin %.7f, %.7f, %.1fm", o.Lat, o.Lon, o.Alt)
+	}
+	if msg.Reply != "" {
+		data, err := json.Marshal(originMsgFor(worldOrigin(c.simulator)))
+		if err == nil {
+			msg.Respond(data)
+		}
+	}
+}
+
+func (c *Client) handleFailsafe(msg *nats.Msg) {
+	id, err := c.parseDroneID(msg.Subject)
+	if err != nil {
+		log.Printf("failsafe: %v", err)
+		return
+	}
+	drone := c.getDrone(id)
+	if drone == nil {
+		log.Printf("failsafe: drone %d not found", id)
+		return
+	}
+	var cmd FailsafeCmd
+	if err := json.Unmarshal(msg.Data, &cmd); err != nil {
+		log.Printf("failsafe: invalid payload: %v", err)
+		return
+	}
+
+	c.simulator.Lock()
+	err = applyFailsafeCmd(&drone.Failsafe, cmd)
+	c.simulator.Unlock()
+	if err != nil {
+		log.Printf("failsafe: %v", err)
+		return
+	}
+	log.Printf("drone %d failsafe configured", id)
+}
+
+func (c *Client) handleAutotune(msg *nats.Msg) {
+	id, err := c.parseDroneID(msg.Subject)
+	if err != nil {
+		log.Printf("autotune: %v", err)
+		return
+	}
+	drone := c.getDrone(id)
+	if drone == nil {
+		log.Printf("autotune: drone %d not found", id)
+		return
+	}
+	var cmd AutotuneCmd
+	if err := json.Unmarshal(msg.Data, &cmd); err != nil {
+		log.Printf("autotune: invalid payload: %v", err)
+		return
+	}
+	if cmd.Cancel {
+		c.simulator.Lock()
+		drone.CancelAutotune()
+		c.simulator.Unlock()
+		log.Printf("drone %d autotune cancelled", id)
+		return
+	}
+	cfg, err := autotuneConfigFromCmd(cmd)
+	if err != nil {
+		log.Printf("autotune: %v", err)
+		return
+	}
+
+	c.simulator.Lock()
+	err = drone.StartAutotune(cfg)
+	c.simulator.Unlock()
+	if err != nil {
+		log.Printf("drone %d %v", id, err)
+		return
+	}
+	log.Printf("drone %d autotune %s started", id, cfg.Axis)
+}
+
+func (c *Client) handleOrbit(msg *nats.Msg) {
+	id, err := c.parseDroneID(msg.Subject)
+	if err != nil {
+		log.Printf("orbit: %v", err)
+		return
+	}
+	drone := c.getDrone(id)
+	if drone == nil {
+		log.Printf("orbit: drone %d not found", id)
+		return
+	}
+	var cmd OrbitCmd
+	if err := json.Unmarshal(msg.Data, &cmd); err != nil {
+		log.Printf("orbit: invalid payload: %v", err)
+		return
+	}
+
+	c.simulator.Lock()
+	err = applyOrbitCmd(drone, cmd)
+	c.simulator.Unlock()
+	if err != nil {
+		log.Printf("drone %d %v", id, err)
+		return
+	}
+	log.Printf("drone %d orbit updated", id)
+}
+
+func (c *Client) handleFollow(msg *nats.Msg) {
+	id, err := c.parseDroneID(msg.Subject)
+	if err != nil {
+		log.Printf("follow: %v", err)
+		return
+	}
+	drone := c.getDrone(id)
+	if drone == nil {
+		log.Printf("follow: drone %d not found", id)
+		return
+	}
+	var cmd FollowCmd
+	if err := json.Unmarshal(msg.Data, &cmd); err != nil {
+		log.Printf("follow: invalid payload: %v", err)
+		return
+	}
+
+	c.simulator.Lock()
+	err = applyFollowCmd(drone, c.simulator.World(), cmd)
+	c.simulator.Unlock()
+	if err != nil {
+		log.Printf("drone %d %v", id, err)
+		return
+	}
+	log.Printf("drone %d following %q", id, cmd.Target)
+}
+
+func (c *Client) handleTarget(msg *nats.Msg) {
+	name := strings.TrimPrefix(msg.Subject, "target.")
+	var cmd TargetCmd
+	if err := json.Unmarshal(msg.Data, &cmd); err != nil {
+		log.Printf("target: invalid payload: %v", err)
+		return
+	}
+
+	c.simulator.Lock()
+	err := applyTargetCmd(c.simulator.World(), name, cmd)
+	c.simulator.Unlock()
+	if err != nil {
+		log.Printf("%v", err)
+	}
+}
+
+func (c *Client) handleGNSS(msg *nats.Msg) {
+	id, err := c.parseDroneID(msg.Subject)
+	if err != nil {
+		log.Printf("gnss: %v", err)
+		return
+	}
+	drone := c.getDrone(id)
+	if drone == nil {
+		log.Printf("gnss: drone %d not found", id)
+		return
+	}
+	var cmd GNSSCmd
+	if err := json.Unmarshal(msg.Data, &cmd); err != nil {
+		log.Printf("gnss: invalid payload: %v", err)
+		return
+	}
+
+	c.simulator.Lock()
+	err = applyGNSSCmd(drone, cmd)
+	c.simulator.Unlock()
+	if err != nil {
+		log.Printf("drone %d %v", id, err)
+		return
+	}
+	log.Printf("drone %d gnss updated", id)
+}
+
+func (c *Client) handleGimbal(msg *nats.Msg) {
+	id, err := c.parseDroneID(msg.Subject)
+	if err != nil {
+		log.Printf("gimbal: %v", err)
+		return
+	}
+	drone := c.getDrone(id)
+	if drone == nil {
+		log.Printf("gimbal: drone %d not found", id)
+		return
+	}
+	var cmd GimbalCmd
+	if err := json.Unmarshal(msg.Data, &cmd); err != nil {
+		log.Printf("gimbal: invalid payload: %v", err)
+		return
+	}
+
+	c.simulator.Lock()
+	err = applyGimbalCmd(drone, c.simulator.World(), cmd)
+	mode := drone.Gimbal.Mode
+	c.simulator.Unlock()
+	if err != nil {
+		log.Printf("drone %d %v", id, err)
+		return
+	}
+	log.Printf("drone %d gimbal %s", id, mode)
+}
+
+func (c *Client) handleCamera(msg *nats.Msg) {
+	id, err := c.parseDroneID(msg.Subject)
+	if err != nil {
+		log.Printf("camera: %v", err)
+		return
+	}
+	drone := c.getDrone(id)
+	if drone == nil {
+		log.Printf("camera: drone %d not found", id)
+		return
+	}
+	var cmd CameraCmd
+	if err := json.Unmarshal(msg.Data, &cmd); err != nil {
+		log.Printf("camera: invalid payload: %v", err)
+		return
+	}
+
+	c.simulator.Lock()
+	err = applyCameraCmd(drone, cmd)
+	c.simulator.Unlock()
+	if err != nil {
+		log.Printf("drone %d %v", id, err)
+		return
+	}
+	log.Printf("drone %d camera updated", id)
+}
+
+func (c *Client) handleSensorFaults(msg *nats.Msg) {
+	id, err := c.parseDroneID(msg.Subject)
+	if err != nil {
+		log.Printf("faults: %v", err)
+		return
+	}
+	drone := c.getDrone(id)
+	if drone == nil {
+		log.Printf("faults: drone %d not found", id)
+		return
+	}
+	var cmd SensorFaultCmd
+	if err := json.Unmarshal(msg.Data, &cmd); err != nil {
+		log.Printf("faults: invalid payload: %v", err)
+		return
+	}
+
+	c.simulator.Lock()
+	err = applySensorFaultCmd(drone, cmd)
+	c.simulator.Unlock()
+	if err != nil {
+		log.Printf("drone %d faults: %v", id, err)
+		return
+	}
+	log.Printf("drone %d sensor faults updated (%d injected)", id, len(cmd.Inject))
+}
+
+func (c *Client) handlePrecisionLand(msg *nats.Msg) {
+	id, err := c.parseDroneID(msg.Subject)
+	if err != nil {
+		log.Printf("precland: %v", err)
+		return
+	}
+	drone := c.getDrone(id)
+	if drone == nil {
+		log.Printf("precland: drone %d not found", id)
+		return
+	}
+	var cmd PrecisionLandCmd
+	if err := json.Unmarshal(msg.Data, &cmd); err != nil {
+		log.Printf("precland: invalid payload: %v", err)
+		return
+	}
+
+	c.simulator.Lock()
+	err = applyPrecisionLandCmd(drone, c.simulator.World(), cmd)
+	c.simulator.Unlock()
+	if err != nil {
+		log.Printf("drone %d %v", id, err)
+		return
+	}
+	log.Printf("drone %d precision landing on %q", id, cmd.Platform)
+}
+
+func (c *Client) handlePlatform(msg *nats.Msg) {
+	name := strings.TrimPrefix(msg.Subject, "platform.")
+	var cmd PlatformCmd
+	if err := json.Unmarshal(msg.Data, &cmd); err != nil {
+		log.Printf("platform: invalid payload: %v", err)
+		return
+	}
+
+	c.simulator.Lock()
+	err := applyPlatformCmd(c.simulator.World(), name, cmd)
+	c.simulator.Unlock()
+	if err != nil {
+		log.Printf("%v", err)
+	}
+}
+
+func (c *Client) handleRaySensor(msg *nats.Msg) {
+	id, err := c.parseDroneID(msg.Subject)
+	if err != nil {
+		log.Printf("sensor: %v", err)
+		return
+	}
+	drone := c.getDrone(id)
+	if drone == nil {
+		log.Printf("sensor: drone %d not found", id)
+		return
+	}
+	kind := msg.Subject[strings.LastIndex(msg.Subject, ".")+1:]
+	var cmd RaySensorCmd
+	if err := json.Unmarshal(msg.Data, &cmd); err != nil {
+		log.Printf("sensor: invalid payload: %v", err)
+		return
+	}
+
+	c.simulator.Lock()
+	err = applyRaySensorCmd(drone, kind, cmd)
+	c.simulator.Unlock()
+	if err != nil {
+		log.Printf("drone %d %v", id, err)
+		return
+	}
+	log.Printf("drone %d %s updated", id, kind)
+}
+
+func (c *Client) handleLink(msg *nats.Msg) {
+	id, err := c.parseDroneID(msg.Subject)
+	if err != nil {
+		return
+	}
+	drone := c.getDrone(id)
+	if drone == nil {
+		return
+	}
+	c.simulator.Lock()
+	drone.Heartbeat()
+	c.simulator.Unlock()
+}
+
 func (c *Client) publishTelemetryLoop() {
 	defer c.wg.Done()
 
@@ -426,6 +1047,9 @@ func (c *Client) publishTelemetryLoop() {
 			return
 		case <-ticker.C:
 			c.publishTelemetry()
+			c.publishEvents()
+			c.publishLidar()
+			c.publishCamera()
 		}
 	}
 }
@@ -435,19 +1059,7 @@ func (c *Client) publishTelemetry() {
 	drones := c.simulator.Drones()
 
 	for i, d := range drones {
-		msg := TelemetryMsg{
-			ID:        i,
-			Timestamp: time.Now().UnixMilli(),
-			Position:  Vec3Msg{X: d.Position.X, Y: d.Position.Y, Z: d.Position.Z},
-			Velocity:  Vec3Msg{X: d.Velocity.X, Y: d.Velocity.Y, Z: d.Velocity.Z},
-			Rotation:  Vec3Msg{X: d.Rotation.X, Y: d.Rotation.Y, Z: d.Rotation.Z},
-			Battery:   d.BatteryPercent,
-			FlightMode: flightModeString(d.FlightMode),
-			Throttle:  d.ThrottlePercent,
-			Armed:     d.IsArmed,
-			OnGround:  d.OnGround,
-			Destroyed: d.Destroyed,
-		}
+		msg := newTelemetryMsg(i, d)
 
 		data, err := json.Marshal(msg)
 		if err != nil {
@@ -459,6 +1071,164 @@ func (c *Client) publishTelemetry() {
 	c.simulator.RUnlock()
 }
 
+// publishLidar publishes each drone's newest lidar scan once; scans faster
+// than the telemetry rate are thinned to it.
+func (c *Client) publishLidar() {
+	var out []LidarMsg
+	c.simulator.RLock()
+	for i, d := range c.simulator.Drones() {
+		if d.Lidar == nil {
+			continue
+		}
+		s := d.Lidar.Scan()
+		if s.Count == 0 || s.Count == c.lidarScans[i] {
+			continue
+		}
+		c.lidarScans[i] = s.Count
+		out = append(out, lidarMsgFor(i, s))
+	}
+	c.simulator.RUnlock()
+
+	for _, m := range out {
+		data, err := json.Marshal(m)
+		if err != nil {
+			continue
+		}
+		c.nc.Publish(LidarSubject(m.ID), data)
+	}
+}
+
+// publishCamera publishes each drone's newest camera frame once as PNG;
+// frames faster than the telemetry rate are thinned to it. Frames are never
+// changed once rendered, so they are encoded outside the lock.
+func (c *Client) publishCamera() {
+	type frame struct {
+		id  int
+		img *sim.CameraImage
+	}
+	var out []frame
+	c.simulator.RLock()
+	for i, d := range c.simulator.Drones() {
+		if d.ImageCamera == nil {
+			continue
+		}
+		img := d.ImageCamera.Image()
+		if img == nil || img.Count == c.frames[i] {
+			continue
+		}
+		c.frames[i] = img.Count
+		out = append(out, frame{i, img})
+	}
+	c.simulator.RUnlock()
+
+	for _, f := range out {
+		msgs, err := cameraFrameMsgs(f.id, f.img)
+		if err != nil {
+			log.Printf("camera %d: %v", f.id, err)
+			continue
+		}
+		for _, m := range msgs {
+			c.nc.PublishMsg(m)
+		}
+	}
+}
+
+// publishEvents drains queued drone events and publishes each one.
+func (c *Client) publishEvents() {
+	type pending struct {
+		id     int
+		events []sim.Event
+	}
+	var out []pending
+	c.simulator.Lock()
+	for i, d := range c.simulator.Drones() {
+		if ev := d.TakeEvents(); len(ev) > 0 {
+			out = append(out, pending{id: i, events: ev})
+		}
+	}
+	c.simulator.Unlock()
+
+	for _, p := range out {
+		for _, e := range p.events {
+			data, err := json.Marshal(newEventMsg(p.id, e))
+			if err != nil {
+				continue
+			}
+			c.nc.Publish(EventSubject(p.id, e.Kind), data)
+		}
+	}
+}
+
+// newTelemetryMsg snapshots a drone. Callers must hold the simulator read lock.
+func newTelemetryMsg(id int, d *sim.Drone) TelemetryMsg {
+	geo := d.Geo()
+	return TelemetryMsg{
+		ID:         id,
+		Timestamp:  time.Now().UnixMilli(),
+		Position:   Vec3Msg{X: d.Position.X, Y: d.Position.Y, Z: d.Position.Z},
+		Lat:        geo.Lat,
+		Lon:        geo.Lon,
+		AltMSL:     geo.Alt,
+		Heading:    d.Heading(),
+		Velocity:   Vec3Msg{X: d.Velocity.X, Y: d.Velocity.Y, Z: d.Velocity.Z},
+		Rotation:   Vec3Msg{X: d.Rotation.X, Y: d.Rotation.Y, Z: d.Rotation.Z},
+		Battery:    d.BatteryPercent,
+		Voltage:    d.BatteryVoltage(),
+		FlightMode: flightModeString(d.FlightMode),
+		Throttle:   d.ThrottlePercent,
+		Armed:      d.IsArmed,
+		OnGround:   d.OnGround,
+		Destroyed:  d.Destroyed,
+		Mission:    missionMsgFor(d),
+		Action:     actionString(d.ActiveAction()),
+		Failsafe:   failsafeString(d.Failsafe.Active()),
+		Fault:      faultMsgFor(d),
+		Autotune:   autotuneMsgFor(d),
+		Orbit:      orbitMsgFor(d),
+		Follow:     followMsgFor(d),
+		PrecLand:   precisionLandMsgFor(d),
+		Input:      inputMsgFor(d),
+		PreArm:     preArmMsgFor(d),
+		Energy:     energyMsgFor(d),
+		IMU:        imuMsgFor(d),
+		GNSS:       gnssMsgFor(d),
+		Baro:       baroMsgFor(d),
+		Mag:        magMsgFor(d),
+		EKF:        ekfMsgFor(d),
+		Range:      rangeMsgFor(d),
+		Flow:       flowMsgFor(d),
+		Gimbal:     gimbalMsgFor(d),
+		SensorFaults: sensorFaultsMsgFor(d),
+		Nav:        d.NavSource.String(),
+	}
+}
+
+func preArmMsgFor(d *sim.Drone) []PreArmMessage {
+	if d.IsArmed {
+		return nil
+	}
+	return preArmMessages(d.PreArmChecks())
+}
+
+func faultMsgFor(d *sim.Drone) *FaultMsg {
+	f := d.MotorFault()
+	if f.Motor < 0 {
+		return nil
+	}
+	mode := "None"
+	if f.Active {
+		mode = "ReducedAttitude"
+	}
+	return &FaultMsg{Motor: f.Motor, Loss: f.Loss, Mode: mode, YawRate: f.YawRate}
+}
+
+func actionString(a sim.AutoAction) string {
+	if a == sim.ActionNone {
+		return ""
+	}
+	return a.String()
+}
+
 func flightModeString(mode sim.FlightMode) string {
 	switch mode {
 	case sim.FlightModeManual:
@@ -467,6 +1237,14 @@ func flightModeString(mode sim.FlightMode) string {
 		return "AltitudeHold"
 	case sim.FlightModeHover:
 		return "Hover"
+	case sim.FlightModeMission:
+		return "Mission"
+	case sim.FlightModeOrbit:
+		return "Orbit"
+	case sim.FlightModeFollow:
+		return "Follow"
+	case sim.FlightModePrecisionLand:
+		return "PrecisionLand"
 	default:
 		return "Unknown"
 	}
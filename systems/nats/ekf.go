@@ -0,0 +1,72 @@
+package nats
+
+import (
+	"math"
+
+	sim "drone-simulator/internal/sim"
+)
+
+// EKFMsg reports the EKF's estimate in telemetry beside the true position,
+// velocity and rotation, with the error between them.
+type EKFMsg struct {
+	Position  Vec3Msg     `json:"position"`
+	Velocity  Vec3Msg     `json:"velocity"`
+	Rotation  Vec3Msg     `json:"rotation"`  // rad, like the true rotation
+	AccelBias Vec3Msg     `json:"accelBias"` // m/s², body frame
+	GyroBias  Vec3Msg     `json:"gyroBias"`  // rad/s, body frame
+	BaroBias  float64     `json:"baroBias"`  // m
+	Sigma     EKFSigmaMsg `json:"sigma"`
+	Error     EKFErrorMsg `json:"error"` // estimate minus truth
+	GNSS      EKFInnovMsg `json:"gnss"`
+	Baro      EKFInnovMsg `json:"baro"`
+	Mag       EKFInnovMsg `json:"mag"`
+}
+
+// EKFSigmaMsg is the filter's 1σ uncertainty per axis.
+type EKFSigmaMsg struct {
+	Position Vec3Msg `json:"position"` // m
+	Velocity Vec3Msg `json:"velocity"` // m/s
+	Attitude Vec3Msg `json:"attitude"` // degrees
+}
+
+// EKFErrorMsg is the estimate minus the truth.
+type EKFErrorMsg struct {
+	Position Vec3Msg `json:"position"` // m
+	Velocity Vec3Msg `json:"velocity"` // m/s
+	Attitude Vec3Msg `json:"attitude"` // degrees per Euler angle
+}
+
+// EKFInnovMsg summarises one measurement stream of the filter.
+type EKFInnovMsg struct {
+	Ratio    float64 `json:"ratio"` // last innovation in σ; above the gate it was rejected
+	Fused    int     `json:"fused"`
+	Rejected int     `json:"rejected"`
+}
+
+// ekfMsgFor returns the filter's estimate for telemetry, or nil until it has
+// aligned.
+func ekfMsgFor(d *sim.Drone) *EKFMsg {
+	est := d.EKF.Estimate()
+	if !est.Valid {
+		return nil
+	}
+	v := func(v sim.Vec3) Vec3Msg { return Vec3Msg{X: v.X, Y: v.Y, Z: v.Z} }
+	degs := func(r sim.Vec3) Vec3Msg { return v(r.Mul(180 / math.Pi)) }
+	innov := func(i sim.EKFInnovation) EKFInnovMsg {
+		return EKFInnovMsg{Ratio: i.Ratio, Fused: i.Fused, Rejected: i.Rejected}
+	}
+	e := d.EKFError()
+	return &EKFMsg{
+		Position:  v(est.Position),
+		Velocity:  v(est.Velocity),
+		Rotation:  v(est.Rotation),
+		AccelBias: v(est.AccelBias),
+		GyroBias:  v(est.GyroBias),
+		BaroBias:  est.BaroBias,
+		Sigma:     EKFSigmaMsg{Position: v(est.PositionSigma), Velocity: v(est.VelocitySigma), Attitude: degs(est.AttitudeSigma)},
+		Error:     EKFErrorMsg{Position: v(e.Position), Velocity: v(e.Velocity), Attitude: degs(e.Attitude)},
+		GNSS:      innov(est.GNSS),
+		Baro:      innov(est.Baro),
+		Mag:       innov(est.Mag),
+	}
+}
@@ -0,0 +1,46 @@
+package nats
+
+import (
+	"math"
+
+	sim "drone-simulator/internal/sim"
+)
+
+// EnergyMsg reports the energy budget in telemetry. Return figures are zero
+// when home cannot be reached against the wind.
+type EnergyMsg struct {
+	PowerW         float64 `json:"power"`
+	UsableWh       float64 `json:"usableWh"`  // above the landing reserve
+	EnduranceS     float64 `json:"endurance"` // at the current draw
+	RangeM         float64 `json:"range"`     // at the current speed
+	HomeDistanceM  float64 `json:"homeDistance"`
+	ReturnSpeed    float64 `json:"returnSpeed"` // ground speed toward home
+	ReturnTimeS    float64 `json:"returnTime"`
+	ReturnEnergyWh float64 `json:"returnWh"`
+	MarginWh       float64 `json:"marginWh"` // spare after the return and its reserve
+	Reachable      bool    `json:"reachable"`
+	MustReturn     bool    `json:"mustReturn,omitempty"`
+}
+
+func energyMsgFor(d *sim.Drone) *EnergyMsg {
+	e := d.EnergyEstimate()
+	finite := func(v float64) float64 {
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return 0
+		}
+		return v
+	}
+	return &EnergyMsg{
+		PowerW:         e.Power,
+		UsableWh:       e.Usable,
+		EnduranceS:     e.Endurance,
+		RangeM:         e.Range,
+		HomeDistanceM:  e.HomeDistance,
+		ReturnSpeed:    e.ReturnSpeed,
+		ReturnTimeS:    finite(e.ReturnTime),
+		ReturnEnergyWh: finite(e.ReturnEnergy),
+		MarginWh:       finite(e.Margin),
+		Reachable:      e.Reachable,
+		MustReturn:     e.MustReturn,
+	}
+}
@@ -0,0 +1,97 @@
+package nats
+
+import (
+	"fmt"
+	"strings"
+
+	sim "drone-simulator/internal/sim"
+)
+
+// FailsafeRuleMsg configures one failsafe trigger.
+type FailsafeRuleMsg struct {
+	Action     string   `json:"action,omitempty"` // continue, hold, rth, land
+	Delay      *float64 `json:"delay,omitempty"`
+	ClearDelay *float64 `json:"clearDelay,omitempty"`
+	Latch      *bool    `json:"latch,omitempty"`
+}
+
+// FailsafeCmd is received on drone.<id>.failsafe. Omitted fields keep their
+// current values.
+type FailsafeCmd struct {
+	LinkTimeout       float64                    `json:"linkTimeout,omitempty"`
+	InputTimeout      float64                    `json:"inputTimeout,omitempty"` // stick deadman (s)
+	BatteryHysteresis float64                    `json:"batteryHysteresis,omitempty"`
+	ReturnReserve     *float64                   `json:"returnReserve,omitempty"` // spare fraction of the return energy for smartRTH
+	Rules             map[string]FailsafeRuleMsg `json:"rules,omitempty"`         // keyed by trigger name
+}
+
+var failsafeTriggers = map[string]sim.FailsafeTrigger{
+	"lowbattery":      sim.FailsafeLowBattery,
+	"smartrth":        sim.FailsafeSmartRTH,
+	"geofence":        sim.FailsafeGeofence,
+	"inputloss":       sim.FailsafeInputLoss,
+	"linkloss":        sim.FailsafeLinkLoss,
+	"positionloss":    sim.FailsafePositionLoss,
+	"criticalbattery": sim.FailsafeCriticalBattery,
+}
+
+var failsafeActions = map[string]sim.AutoAction{
+	"continue": sim.ActionNone,
+	"hold":     sim.ActionHold,
+	"rth":      sim.ActionReturnHome,
+	"land":     sim.ActionLand,
+}
+
+// applyFailsafeCmd updates fs from cmd. It validates everything before
+// changing anything. Callers must hold the simulator write lock.
+func applyFailsafeCmd(fs *sim.Failsafe, cmd FailsafeCmd) error {
+	next := *fs
+	for name, rm := range cmd.Rules {
+		t, ok := failsafeTriggers[strings.ToLower(name)]
+		if !ok {
+			return fmt.Errorf("unknown trigger %q", name)
+		}
+		rule := next.Rules[t]
+		if rm.Action != "" {
+			a, ok := failsafeActions[strings.ToLower(rm.Action)]
+			if !ok {
+				return fmt.Errorf("%s: unknown action %q", name, rm.Action)
+			}
+			rule.Action = a
+		}
+		if rm.Delay != nil {
+			rule.Delay = *rm.Delay
+		}
+		if rm.ClearDelay != nil {
+			rule.ClearDelay = *rm.ClearDelay
+		}
+		if rm.Latch != nil {
+			rule.Latch = *rm.Latch
+		}
+		next.Rules[t] = rule
+	}
+	if cmd.LinkTimeout > 0 {
+		next.LinkTimeout = cmd.LinkTimeout
+	}
+	if cmd.InputTimeout > 0 {
+		next.InputTimeout = cmd.InputTimeout
+	}
+	if cmd.BatteryHysteresis > 0 {
+		next.BatteryHysteresis = cmd.BatteryHysteresis
+	}
+	if cmd.ReturnReserve != nil {
+		if *cmd.ReturnReserve < 0 {
+			return fmt.Errorf("returnReserve must not be negative")
+		}
+		next.ReturnReserve = *cmd.ReturnReserve
+	}
+	*fs = next
+	return nil
+}
+
+func failsafeString(t sim.FailsafeTrigger) string {
+	if t == sim.FailsafeNone {
+		return ""
+	}
+	return t.String()
+}
@@ -0,0 +1,100 @@
+package nats
+
+import (
+	"fmt"
+	"strings"
+
+	sim "drone-simulator/internal/sim"
+)
+
+// SensorFaultMsg schedules one fault in a SensorFaultCmd.
+type SensorFaultMsg struct {
+	Sensor    string   `json:"sensor"`             // imu, gnss, baro, mag, rangefinder, flow, lidar
+	Type      string   `json:"type"`               // stuck, bias, drift, noise, spikes, dropout, delay
+	Start     *float64 `json:"start,omitempty"`    // s of simulation; default now
+	End       float64  `json:"end,omitempty"`      // s of simulation; 0 until cleared
+	Duration  float64  `json:"duration,omitempty"` // s from start, instead of end
+	Magnitude float64  `json:"magnitude,omitempty"`
+	Rate      float64  `json:"rate,omitempty"` // spikes per second
+	Axis      int      `json:"axis,omitempty"` // 1-based channel; 0 all
+}
+
+// SensorFaultCmd is received on drone.<id>.faults. Clear names sensors whose
+// faults are removed first ("all" for every sensor); Inject adds faults.
+type SensorFaultCmd struct {
+	Clear  []string         `json:"clear,omitempty"`
+	Inject []SensorFaultMsg `json:"inject,omitempty"`
+}
+
+// ActiveFaultMsg reports a sensor fault in force inside TelemetryMsg.
+type ActiveFaultMsg struct {
+	Sensor    string  `json:"sensor"`
+	Type      string  `json:"type"`
+	Start     float64 `json:"start"`
+	End       float64 `json:"end,omitempty"`
+	Magnitude float64 `json:"magnitude,omitempty"`
+	Rate      float64 `json:"rate,omitempty"`
+	Axis      int     `json:"axis,omitempty"`
+}
+
+// applySensorFaultCmd clears and injects d's sensor faults. Nothing changes
+// if any entry is invalid. Callers must hold the simulator write lock.
+func applySensorFaultCmd(d *sim.Drone, cmd SensorFaultCmd) error {
+	for _, name := range cmd.Clear {
+		if strings.EqualFold(name, "all") {
+			continue
+		}
+		if _, err := d.FaultSchedule(name); err != nil {
+			return err
+		}
+	}
+	faults := make([]sim.SensorFault, 0, len(cmd.Inject))
+	for i, m := range cmd.Inject {
+		if _, err := d.FaultSchedule(m.Sensor); err != nil {
+			return fmt.Errorf("fault %d: %w", i, err)
+		}
+		kind, ok := sim.ParseSensorFaultKind(m.Type)
+		if !ok {
+			return fmt.Errorf("fault %d: unknown type %q", i, m.Type)
+		}
+		f := sim.SensorFault{Kind: kind, Start: d.Clock(), End: m.End, Magnitude: m.Magnitude, Rate: m.Rate, Axis: m.Axis}
+		if m.Start != nil {
+			f.Start = *m.Start
+		}
+		if m.Duration > 0 {
+			f.End = f.Start + m.Duration
+		}
+		if f.End > 0 && f.End <= f.Start {
+			return fmt.Errorf("fault %d: ends at %.1f s before it starts", i, f.End)
+		}
+		faults = append(faults, f)
+	}
+
+	for _, name := range cmd.Clear {
+		if strings.EqualFold(name, "all") {
+			name = ""
+		}
+		d.ClearFaults(name)
+	}
+	for i, f := range faults {
+		d.InjectFault(cmd.Inject[i].Sensor, f)
+	}
+	return nil
+}
+
+// sensorFaultsMsgFor lists d's sensor faults in force, or nil without any.
+func sensorFaultsMsgFor(d *sim.Drone) []ActiveFaultMsg {
+	var out []ActiveFaultMsg
+	for _, f := range d.ActiveFaults() {
+		out = append(out, ActiveFaultMsg{
+			Sensor:    f.Sensor,
+			Type:      f.Kind.String(),
+			Start:     f.Start,
+			End:       f.End,
+			Magnitude: f.Magnitude,
+			Rate:      f.Rate,
+			Axis:      f.Axis,
+		})
+	}
+	return out
+}
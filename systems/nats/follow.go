@@ -0,0 +1,125 @@
+package nats
+
+import (
+	"fmt"
+	"strings"
+
+	sim "drone-simulator/internal/sim"
+)
+
+// TargetCmd is received on target.<name>. A position report creates or moves
+// an externally driven target; a path makes a scripted one.
+type TargetCmd struct {
+	Position *Vec3Msg  `json:"position,omitempty"`
+	Velocity Vec3Msg   `json:"velocity"`
+	Path     []Vec3Msg `json:"path,omitempty"`
+	Speed    float64   `json:"speed,omitempty"` // m/s along path
+	Loop     bool      `json:"loop,omitempty"`
+	Remove   bool      `json:"remove,omitempty"`
+}
+
+// FollowCmd is received on drone.<id>.follow. {"stop": true} hovers in place.
+type FollowCmd struct {
+	Target      string  `json:"target"`
+	Offset      Vec3Msg `json:"offset"`                // x right, z ahead of the target
+	WorldOffset bool    `json:"worldOffset,omitempty"` // offset in world axes
+	Altitude    float64 `json:"altitude,omitempty"`    // m above the target
+	Heading     string  `json:"heading,omitempty"`     // face (default), course, fixed
+	Yaw         float64 `json:"yaw,omitempty"`         // radians, for heading "fixed"
+	Lead        float64 `json:"lead,omitempty"`        // s of acceleration lead (<0 = none)
+	MaxSpeed    float64 `json:"maxSpeed,omitempty"`    // m/s
+	Stop        bool    `json:"stop,omitempty"`
+}
+
+// FollowMsg reports target tracking in telemetry.
+type FollowMsg struct {
+	Target        string  `json:"target"`
+	Error         Vec3Msg `json:"error"` // station minus drone position
+	ErrorDistance float64 `json:"errorDistance"`
+	Range         float64 `json:"range"`
+	Lost          bool    `json:"lost,omitempty"`
+}
+
+var followHeadings = map[string]sim.FollowHeading{
+	"":       sim.FollowFaceTarget,
+	"face":   sim.FollowFaceTarget,
+	"course": sim.FollowTargetCourse,
+	"fixed":  sim.FollowFixedHeading,
+}
+
+// applyTargetCmd updates the world's target called name. Callers must hold
+// the simulator write lock.
+func applyTargetCmd(w *sim.World, name string, cmd TargetCmd) error {
+	if cmd.Remove {
+		w.RemoveTarget(name)
+		return nil
+	}
+	if len(cmd.Path) > 0 {
+		path := make([]sim.Vec3, 0, len(cmd.Path))
+		for _, p := range cmd.Path {
+			path = append(path, sim.Vec3{X: p.X, Y: p.Y, Z: p.Z})
+		}
+		w.AddTarget(sim.NewScriptedTarget(name, path, cmd.Speed, cmd.Loop))
+		return nil
+	}
+	if cmd.Position == nil {
+		return fmt.Errorf("target %s: need a position or a path", name)
+	}
+	t := w.Target(name)
+	if t == nil {
+		t = &sim.Target{Name: name}
+		w.AddTarget(t)
+	}
+	t.SetState(sim.Vec3{X: cmd.Position.X, Y: cmd.Position.Y, Z: cmd.Position.Z},
+		sim.Vec3{X: cmd.Velocity.X, Y: cmd.Velocity.Y, Z: cmd.Velocity.Z})
+	return nil
+}
+
+// applyFollowCmd starts, changes or stops following. Callers must hold the
+// simulator write lock.
+func applyFollowCmd(d *sim.Drone, w *sim.World, cmd FollowCmd) error {
+	if cmd.Stop {
+		if d.FlightMode == sim.FlightModeFollow {
+			d.SetFlightMode(sim.FlightModeHover)
+		}
+		return nil
+	}
+	heading, ok := followHeadings[strings.ToLower(cmd.Heading)]
+	if !ok {
+		return fmt.Errorf("follow: unknown heading %q", cmd.Heading)
+	}
+	t := w.Target(cmd.Target)
+	if t == nil {
+		return fmt.Errorf("follow: no target %q", cmd.Target)
+	}
+	cfg := sim.FollowConfig{
+		Target:      t,
+		Offset:      sim.Vec3{X: cmd.Offset.X, Z: cmd.Offset.Z},
+		WorldOffset: cmd.WorldOffset,
+		Altitude:    cmd.Altitude,
+		Heading:     heading,
+		Yaw:         cmd.Yaw,
+		Lead:        cmd.Lead,
+		MaxSpeed:    cmd.MaxSpeed,
+	}
+	if _, following := d.FollowConfig(); following {
+		d.SetFollow(cfg)
+		return nil
+	}
+	return d.StartFollow(cfg)
+}
+
+// followMsgFor returns tracking state for telemetry, or nil when not following.
+func followMsgFor(d *sim.Drone) *FollowMsg {
+	st, ok := d.FollowStatus()
+	if !ok {
+		return nil
+	}
+	return &FollowMsg{
+		Target:        st.Target,
+		Error:         Vec3Msg{X: st.Error.X, Y: st.Error.Y, Z: st.Error.Z},
+		ErrorDistance: st.ErrorDistance,
+		Range:         st.Range,
+		Lost:          st.Lost,
+	}
+}
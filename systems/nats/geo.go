@@ -0,0 +1,57 @@
+package nats
+
+import (
+	"fmt"
+
+	sim "drone-simulator/internal/sim"
+)
+
+// GeoTargetMsg lets a command give its target in WGS84 instead of the
+// simulator frame. Lat and lon (degrees) replace x/z and must come together;
+// altMSL (meters above sea level) replaces y.
+type GeoTargetMsg struct {
+	Lat    *float64 `json:"lat,omitempty"`
+	Lon    *float64 `json:"lon,omitempty"`
+	AltMSL *float64 `json:"altMSL,omitempty"`
+}
+
+// resolve returns local with any geodetic coordinates of g mapped into the
+// frame anchored at origin.
+func (g GeoTargetMsg) resolve(origin sim.GeoPoint, local sim.Vec3) (sim.Vec3, error) {
+	if (g.Lat == nil) != (g.Lon == nil) {
+		return local, fmt.Errorf("lat and lon must be given together")
+	}
+	if g.Lat == nil && g.AltMSL == nil {
+		return local, nil
+	}
+	p := origin.ToGeo(local)
+	if g.Lat != nil {
+		p.Lat, p.Lon = *g.Lat, *g.Lon
+	}
+	if g.AltMSL != nil {
+		p.Alt = *g.AltMSL
+	}
+	if err := p.Validate(); err != nil {
+		return local, err
+	}
+	return origin.ToLocal(p), nil
+}
+
+// OriginMsg is the WGS84 anchor of the simulator frame. It is received on
+// world.origin to move the anchor and sent back to requests on it.
+type OriginMsg struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+	Alt float64 `json:"alt"` // meters above sea level
+}
+
+func originMsgFor(o sim.GeoPoint) OriginMsg {
+	return OriginMsg{Lat: o.Lat, Lon: o.Lon, Alt: o.Alt}
+}
+
+// worldOrigin returns the simulator's WGS84 anchor under the read lock.
+func worldOrigin(s *sim.Simulator) sim.GeoPoint {
+	s.RLock()
+	defer s.RUnlock()
+	return s.World().GeoOrigin()
+}
@@ -0,0 +1,106 @@
+package nats
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	sim "drone-simulator/internal/sim"
+)
+
+// GeofenceMsg describes one fence in a GeofenceCmd. Coordinates are in the
+// simulator frame (meters, Y up).
+type GeofenceMsg struct {
+	Name      string       `json:"name"`
+	Shape     string       `json:"shape"` // cylinder, polygon
+	Inclusion bool         `json:"inclusion"`
+	X         float64      `json:"x,omitempty"` // cylinder centre
+	Z         float64      `json:"z,omitempty"`
+	Radius    float64      `json:"radius,omitempty"`
+	Polygon   [][2]float64 `json:"polygon,omitempty"` // [[x, z], ...]
+	Floor     float64      `json:"floor,omitempty"`
+	Ceiling   float64      `json:"ceiling,omitempty"` // 0 = unbounded
+	Action    string       `json:"action,omitempty"`  // warn, hold, rth, land, terminate
+}
+
+// GeofenceCmd is received on drone.<id>.geofence and replaces the drone's fences.
+type GeofenceCmd struct {
+	Fences []GeofenceMsg `json:"fences"`
+}
+
+// EventMsg is published to events.<id>.<kind>
+type EventMsg struct {
+	ID        int     `json:"id"`
+	Timestamp int64   `json:"timestamp"`
+	Kind      string  `json:"kind"`
+	Source    string  `json:"source,omitempty"`
+	Action    string  `json:"action,omitempty"`
+	Position  Vec3Msg `json:"position"`
+	Detail    string  `json:"detail,omitempty"`
+}
+
+var fenceActions = map[string]sim.AutoAction{
+	"":          sim.ActionNone,
+	"warn":      sim.ActionNone,
+	"hold":      sim.ActionHold,
+	"rth":       sim.ActionReturnHome,
+	"land":      sim.ActionLand,
+	"terminate": sim.ActionTerminate,
+}
+
+// geofencesFromMsg converts a GeofenceCmd into simulator fences.
+func geofencesFromMsg(cmd GeofenceCmd) ([]*sim.Geofence, error) {
+	fences := make([]*sim.Geofence, 0, len(cmd.Fences))
+	for i, fm := range cmd.Fences {
+		action, ok := fenceActions[strings.ToLower(fm.Action)]
+		if !ok {
+			return nil, fmt.Errorf("fence %d: unknown action %q", i, fm.Action)
+		}
+		f := &sim.Geofence{
+			Name:      fm.Name,
+			Inclusion: fm.Inclusion,
+			Floor:     fm.Floor,
+			Ceiling:   fm.Ceiling,
+			Action:    action,
+		}
+		if f.Name == "" {
+			f.Name = fmt.Sprintf("fence%d", i)
+		}
+		switch strings.ToLower(fm.Shape) {
+		case "cylinder":
+			if fm.Radius <= 0 {
+				return nil, fmt.Errorf("fence %d: radius must be positive", i)
+			}
+			f.Shape = sim.FenceCylinder
+			f.Center = sim.Vec3{X: fm.X, Z: fm.Z}
+			f.Radius = fm.Radius
+		case "polygon":
+			if len(fm.Polygon) < 3 {
+				return nil, fmt.Errorf("fence %d: polygon needs at least 3 vertices", i)
+			}
+			f.Shape = sim.FencePolygon
+			for _, v := range fm.Polygon {
+				f.Polygon = append(f.Polygon, sim.Vec3{X: v[0], Z: v[1]})
+			}
+		default:
+			return nil, fmt.Errorf("fence %d: unknown shape %q", i, fm.Shape)
+		}
+		if f.Ceiling > 0 && f.Ceiling <= f.Floor {
+			return nil, fmt.Errorf("fence %d: ceiling must be above floor", i)
+		}
+		fences = append(fences, f)
+	}
+	return fences, nil
+}
+
+func newEventMsg(id int, e sim.Event) EventMsg {
+	return EventMsg{
+		ID:        id,
+		Timestamp: time.Now().UnixMilli(),
+		Kind:      e.Kind,
+		Source:    e.Source,
+		Action:    e.Action,
+		Position:  Vec3Msg{X: e.Position.X, Y: e.Position.Y, Z: e.Position.Z},
+		Detail:    e.Detail,
+	}
+}
@@ -0,0 +1,139 @@
+package nats
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	sim "drone-simulator/internal/sim"
+)
+
+// GimbalCmd is received on drone.<id>.gimbal. Angles are in degrees like
+// rotation (pitch x, positive up; yaw y, positive left; roll z); only the
+// fields given change. A roi or target switches to ROI mode unless a mode is
+// given.
+type GimbalCmd struct {
+	Mode         string   `json:"mode,omitempty"` // follow, lock, roi, body
+	Pitch        *float64 `json:"pitch,omitempty"`
+	Yaw          *float64 `json:"yaw,omitempty"`
+	Roll         *float64 `json:"roll,omitempty"`
+	ROI          *Vec3Msg `json:"roi,omitempty"`    // world point to aim at
+	Target       string   `json:"target,omitempty"` // world target to track
+	GeoTargetMsg          // roi in WGS84 instead
+
+	Offset   *Vec3Msg `json:"offset,omitempty"`   // m, body axes
+	MinAngle *Vec3Msg `json:"minAngle,omitempty"` // joint limits
+	MaxAngle *Vec3Msg `json:"maxAngle,omitempty"`
+	MaxRate  *Vec3Msg `json:"maxRate,omitempty"` // deg/s joint slew rates
+	FOV      *float64 `json:"fov,omitempty"`     // vertical field of view
+}
+
+// GimbalMsg reports the gimbal inside TelemetryMsg, angles in degrees.
+type GimbalMsg struct {
+	Mode     string   `json:"mode"`
+	Joint    Vec3Msg  `json:"joint"`    // from the body
+	Attitude Vec3Msg  `json:"attitude"` // camera in the world
+	ROI      *Vec3Msg `json:"roi,omitempty"`
+	Target   string   `json:"target,omitempty"`
+}
+
+var gimbalModes = map[string]sim.GimbalMode{
+	"follow": sim.GimbalFollow,
+	"lock":   sim.GimbalLock,
+	"roi":    sim.GimbalROI,
+	"body":   sim.GimbalBody,
+}
+
+func degVec(v Vec3Msg) sim.Vec3 {
+	return sim.Vec3{X: sim.DegToRad(v.X), Y: sim.DegToRad(v.Y), Z: sim.DegToRad(v.Z)}
+}
+
+func degMsg(v sim.Vec3) Vec3Msg {
+	return Vec3Msg{X: sim.RadToDeg(v.X), Y: sim.RadToDeg(v.Y), Z: sim.RadToDeg(v.Z)}
+}
+
+// applyGimbalCmd points or configures d's gimbal. Nothing changes on an
+// error. Callers must hold the simulator write lock.
+func applyGimbalCmd(d *sim.Drone, w *sim.World, cmd GimbalCmd) error {
+	g := d.Gimbal
+	if cmd.Mode != "" {
+		m, ok := gimbalModes[strings.ToLower(cmd.Mode)]
+		if !ok {
+			return fmt.Errorf("gimbal: unknown mode %q", cmd.Mode)
+		}
+		g.Mode = m
+	}
+	for _, a := range []struct {
+		v   *float64
+		dst *float64
+	}{{cmd.Pitch, &g.Command.X}, {cmd.Yaw, &g.Command.Y}, {cmd.Roll, &g.Command.Z}} {
+		if a.v != nil {
+			*a.dst = sim.DegToRad(*a.v)
+		}
+	}
+
+	geo := cmd.Lat != nil || cmd.AltMSL != nil
+	switch {
+	case cmd.Target != "":
+		t := w.Target(cmd.Target)
+		if t == nil {
+			return fmt.Errorf("gimbal: no target %q", cmd.Target)
+		}
+		g.ROITarget = t
+	case cmd.ROI != nil || geo:
+		var local sim.Vec3
+		if cmd.ROI != nil {
+			local = sim.Vec3{X: cmd.ROI.X, Y: cmd.ROI.Y, Z: cmd.ROI.Z}
+		}
+		roi, err := cmd.resolve(w.GeoOrigin(), local)
+		if err != nil {
+			return fmt.Errorf("gimbal: %w", err)
+		}
+		g.ROI, g.ROITarget = roi, nil
+	}
+	if cmd.Mode == "" && (cmd.Target != "" || cmd.ROI != nil || geo) {
+		g.Mode = sim.GimbalROI
+	}
+
+	if cmd.Offset != nil {
+		g.Offset = sim.Vec3{X: cmd.Offset.X, Y: cmd.Offset.Y, Z: cmd.Offset.Z}
+	}
+	if cmd.MinAngle != nil {
+		g.MinAngle = degVec(*cmd.MinAngle)
+	}
+	if cmd.MaxAngle != nil {
+		g.MaxAngle = degVec(*cmd.MaxAngle)
+	}
+	if g.MinAngle.X > g.MaxAngle.X || g.MinAngle.Y > g.MaxAngle.Y || g.MinAngle.Z > g.MaxAngle.Z {
+		return fmt.Errorf("gimbal: minAngle above maxAngle")
+	}
+	if g.MinAngle.X < -math.Pi/2 || g.MaxAngle.X > math.Pi/2 {
+		return fmt.Errorf("gimbal: pitch limits beyond ±90°")
+	}
+	if cmd.MaxRate != nil {
+		g.MaxRate = degVec(*cmd.MaxRate)
+	}
+	if cmd.FOV != nil {
+		if *cmd.FOV <= 0 || *cmd.FOV >= 180 {
+			return fmt.Errorf("gimbal: fov %v out of range", *cmd.FOV)
+		}
+		g.FOV = sim.DegToRad(*cmd.FOV)
+	}
+	d.Gimbal = g
+	return nil
+}
+
+// gimbalMsgFor returns the gimbal state for telemetry.
+func gimbalMsgFor(d *sim.Drone) *GimbalMsg {
+	g := &d.Gimbal
+	_, att := g.Pose(d)
+	m := &GimbalMsg{Mode: g.Mode.String(), Joint: degMsg(g.Joint()), Attitude: degMsg(att)}
+	if g.Mode == sim.GimbalROI {
+		if g.ROITarget != nil {
+			m.Target = g.ROITarget.Name
+		} else {
+			m.ROI = &Vec3Msg{X: g.ROI.X, Y: g.ROI.Y, Z: g.ROI.Z}
+		}
+	}
+	return m
+}
@@ -0,0 +1,47 @@
+package nats
+
+import (
+	"time"
+
+	sim "drone-simulator/internal/sim"
+)
+
+// InputMsg reports the remote stick link in telemetry.
+type InputMsg struct {
+	Messages     int     `json:"messages"`
+	RateHz       float64 `json:"rateHz"`
+	LatencyMs    float64 `json:"latencyMs"`
+	MaxLatencyMs float64 `json:"maxLatencyMs"`
+	AgeMs        float64 `json:"ageMs"` // since the last sample
+	Stale        bool    `json:"stale,omitempty"`
+}
+
+// applyInputCmd holds a stick sample on the drone, which flies it in its
+// current mode. Callers must hold the simulator write lock.
+func applyInputCmd(d *sim.Drone, cmd InputCmd, now time.Time) {
+	if cmd.Release {
+		d.ReleaseSticks()
+		return
+	}
+	latency := -1.0
+	if cmd.Timestamp > 0 {
+		latency = max(float64(now.UnixMilli()-cmd.Timestamp), 0) / 1000
+	}
+	d.SetSticks(sim.Sticks{Throttle: cmd.Throttle, Roll: cmd.Roll, Pitch: cmd.Pitch, Yaw: cmd.Yaw}, latency)
+}
+
+// inputMsgFor returns stick link statistics, or nil when no sticks are engaged.
+func inputMsgFor(d *sim.Drone) *InputMsg {
+	st := d.StickStats()
+	if !st.Active {
+		return nil
+	}
+	return &InputMsg{
+		Messages:     st.Messages,
+		RateHz:       st.RateHz,
+		LatencyMs:    st.Latency * 1000,
+		MaxLatencyMs: st.MaxLatency * 1000,
+		AgeMs:        st.Age * 1000,
+		Stale:        st.Stale,
+	}
+}
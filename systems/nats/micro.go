@@ -7,7 +7,6 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
-	"time"
 
 	sim "drone-simulator/internal/sim"
 
@@ -25,9 +24,27 @@ type MicroService struct {
 
 // Response types for HTTP API
 type DroneResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message,omitempty"`
-	Error   string `json:"error,omitempty"`
+	Success bool            `json:"success"`
+	Message string          `json:"message,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Reasons []PreArmMessage `json:"reasons,omitempty"`
+}
+
+// PreArmMessage is one failing pre-arm check.
+type PreArmMessage struct {
+	Check  string `json:"check"`
+	Reason string `json:"reason"`
+}
+
+func preArmMessages(failures []sim.PreArmFailure) []PreArmMessage {
+	if len(failures) == 0 {
+		return nil
+	}
+	out := make([]PreArmMessage, len(failures))
+	for i, f := range failures {
+		out[i] = PreArmMessage{Check: f.Check.String(), Reason: f.Reason}
+	}
+	return out
 }
 
 type DroneListResponse struct {
@@ -200,19 +217,7 @@ func (ms *MicroService) handleList(req micro.Request) {
 	}
 
 	for i, d := range drones {
-		resp.Drones[i] = TelemetryMsg{
-			ID:         i,
-			Timestamp:  time.Now().UnixMilli(),
-			Position:   Vec3Msg{X: d.Position.X, Y: d.Position.Y, Z: d.Position.Z},
-			Velocity:   Vec3Msg{X: d.Velocity.X, Y: d.Velocity.Y, Z: d.Velocity.Z},
-			Rotation:   Vec3Msg{X: d.Rotation.X, Y: d.Rotation.Y, Z: d.Rotation.Z},
-			Battery:    d.BatteryPercent,
-			FlightMode: flightModeString(d.FlightMode),
-			Throttle:   d.ThrottlePercent,
-			Armed:      d.IsArmed,
-			OnGround:   d.OnGround,
-			Destroyed:  d.Destroyed,
-		}
+		resp.Drones[i] = newTelemetryMsg(i, d)
 	}
 	ms.simulator.RUnlock()
 
@@ -235,19 +240,7 @@ func (ms *MicroService) handleStatus(req micro.Request) {
 	ms.simulator.RLock()
 	resp := DroneStatusResponse{
 		Success: true,
-		Drone: TelemetryMsg{
-			ID:         id,
-			Timestamp:  time.Now().UnixMilli(),
-			Position:   Vec3Msg{X: drone.Position.X, Y: drone.Position.Y, Z: drone.Position.Z},
-			Velocity:   Vec3Msg{X: drone.Velocity.X, Y: drone.Velocity.Y, Z: drone.Velocity.Z},
-			Rotation:   Vec3Msg{X: drone.Rotation.X, Y: drone.Rotation.Y, Z: drone.Rotation.Z},
-			Battery:    drone.BatteryPercent,
-			FlightMode: flightModeString(drone.FlightMode),
-			Throttle:   drone.ThrottlePercent,
-			Armed:      drone.IsArmed,
-			OnGround:   drone.OnGround,
-			Destroyed:  drone.Destroyed,
-		},
+		Drone:   newTelemetryMsg(id, drone),
 	}
 	ms.simulator.RUnlock()
 
@@ -268,9 +261,19 @@ func (ms *MicroService) handleArm(req micro.Request) {
 	}
 
 	ms.simulator.Lock()
-	drone.Arm()
+	res := drone.Arm()
 	ms.simulator.Unlock()
 
+	if !res.Armed {
+		log.Printf("HTTP: drone %d: %v", id, res.Err())
+		ms.respondJSON(req, http.StatusConflict, DroneResponse{
+			Success: false,
+			Error:   fmt.Sprintf("drone %d failed pre-arm checks", id),
+			Reasons: preArmMessages(res.Failures),
+		})
+		return
+	}
+
 	log.Printf("HTTP: drone %d armed", id)
 	ms.respondSuccess(req, fmt.Sprintf("drone %d armed", id))
 }
@@ -371,6 +374,23 @@ func (ms *MicroService) handleGoto(req micro.Request) {
 		ms.respondError(req, http.StatusBadRequest, "invalid JSON payload")
 		return
 	}
+	target, err := cmd.resolve(worldOrigin(ms.simulator), sim.Vec3{X: cmd.X, Y: cmd.Y, Z: cmd.Z})
+	if err != nil {
+		ms.respondError(req, http.StatusBadRequest, err.Error())
+		return
+	}
+	cmd.X, cmd.Y, cmd.Z = target.X, target.Y, target.Z
+
+	if cmd.Plan {
+		path, err := gotoPlanned(ms.simulator, drone, cmd)
+		if err != nil {
+			ms.respondError(req, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		log.Printf("HTTP: drone %d planned goto (%.1f, %.1f, %.1f)", id, cmd.X, cmd.Y, cmd.Z)
+		ms.respondSuccess(req, fmt.Sprintf("drone %d flying planned path (%d legs)", id, len(path)-1))
+		return
+	}
 
 	ms.simulator.Lock()
 	drone.SetFlightMode(sim.FlightModeAltitudeHold)
@@ -0,0 +1,161 @@
+package nats
+
+import (
+	"fmt"
+	"strings"
+
+	sim "drone-simulator/internal/sim"
+)
+
+// MissionItemMsg is one step of a MissionUploadCmd. Coordinates are in the
+// simulator frame (meters, Y up) unless given in WGS84; yaw is in radians.
+// Trajectory via points are always in the simulator frame.
+type MissionItemMsg struct {
+	Type         string    `json:"type"` // takeoff, waypoint, loiter_time, loiter_turns, change_speed, release_payload, rth, land, trajectory
+	X            float64   `json:"x"`
+	Y            float64   `json:"y"`
+	Z            float64   `json:"z"`
+	AcceptRadius float64   `json:"acceptRadius,omitempty"`
+	Speed        float64   `json:"speed,omitempty"`
+	Yaw          float64   `json:"yaw,omitempty"`
+	HoldYaw      bool      `json:"holdYaw,omitempty"`
+	Time         float64   `json:"time,omitempty"`   // loiter_time seconds
+	Turns        float64   `json:"turns,omitempty"`  // loiter_turns count
+	Radius       float64   `json:"radius,omitempty"` // loiter_turns radius
+	InPlace      bool      `json:"inPlace,omitempty"`
+	Path         []Vec3Msg `json:"path,omitempty"`  // trajectory via points before x/y/z
+	Order        string    `json:"order,omitempty"` // trajectory: snap (default) or jerk
+	Accel        float64   `json:"accel,omitempty"` // trajectory acceleration limit (m/s^2)
+
+	GeoTargetMsg // optional WGS84 position in place of x/y/z
+}
+
+// MissionUploadCmd is received on drone.<id>.mission.upload
+type MissionUploadCmd struct {
+	CruiseSpeed float64          `json:"cruiseSpeed,omitempty"`
+	Items       []MissionItemMsg `json:"items"`
+}
+
+// MissionMsg reports mission progress inside TelemetryMsg.
+type MissionMsg struct {
+	State             string  `json:"state"`
+	Current           int     `json:"current"`
+	Total             int     `json:"total"`
+	DistanceRemaining float64 `json:"distanceRemaining"`
+	ETA               float64 `json:"eta"`
+}
+
+var missionItemTypes = map[string]sim.MissionItemType{
+	"waypoint":        sim.MissionItemWaypoint,
+	"takeoff":         sim.MissionItemTakeoff,
+	"loiter_time":     sim.MissionItemLoiterTime,
+	"loiter_turns":    sim.MissionItemLoiterTurns,
+	"change_speed":    sim.MissionItemChangeSpeed,
+	"release_payload": sim.MissionItemReleasePayload,
+	"rth":             sim.MissionItemReturnHome,
+	"land":            sim.MissionItemLand,
+	"trajectory":      sim.MissionItemTrajectory,
+}
+
+var trajectoryOrders = map[string]sim.TrajectoryOrder{
+	"":     sim.TrajectoryMinSnap,
+	"snap": sim.TrajectoryMinSnap,
+	"jerk": sim.TrajectoryMinJerk,
+}
+
+// missionFromMsg converts an uploaded mission into a simulator mission,
+// mapping geodetic positions into the frame anchored at origin.
+func missionFromMsg(cmd MissionUploadCmd, origin sim.GeoPoint) (*sim.Mission, error) {
+	if len(cmd.Items) == 0 {
+		return nil, fmt.Errorf("mission has no items")
+	}
+	items := make([]sim.MissionItem, 0, len(cmd.Items))
+	for i, it := range cmd.Items {
+		t, ok := missionItemTypes[strings.ToLower(it.Type)]
+		if !ok {
+			return nil, fmt.Errorf("item %d: unknown type %q", i, it.Type)
+		}
+		order, ok := trajectoryOrders[strings.ToLower(it.Order)]
+		if !ok {
+			return nil, fmt.Errorf("item %d: unknown trajectory order %q", i, it.Order)
+		}
+		pos, err := it.resolve(origin, sim.Vec3{X: it.X, Y: it.Y, Z: it.Z})
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+		var path []sim.Vec3
+		for _, p := range it.Path {
+			path = append(path, sim.Vec3{X: p.X, Y: p.Y, Z: p.Z})
+		}
+		items = append(items, sim.MissionItem{
+			Type:         t,
+			Position:     pos,
+			AcceptRadius: it.AcceptRadius,
+			Speed:        it.Speed,
+			Yaw:          it.Yaw,
+			HoldYaw:      it.HoldYaw,
+			LoiterTime:   it.Time,
+			LoiterTurns:  it.Turns,
+			LoiterRadius: it.Radius,
+			InPlace:      it.InPlace,
+			Path:         path,
+			Order:        order,
+			MaxAccel:     it.Accel,
+		})
+	}
+	m := sim.NewMission(items)
+	if cmd.CruiseSpeed > 0 {
+		m.CruiseSpeed = cmd.CruiseSpeed
+	}
+	return m, nil
+}
+
+// applyMissionAction performs a mission control action on a drone. Callers
+// must hold the simulator write lock.
+func applyMissionAction(d *sim.Drone, action string, m *sim.Mission) bool {
+	switch action {
+	case "upload":
+		d.SetMission(m)
+		return true
+	case "start":
+		return d.StartMission()
+	case "pause":
+		return d.PauseMission()
+	case "resume":
+		return d.ResumeMission()
+	case "clear":
+		d.ClearMission()
+		return true
+	}
+	return false
+}
+
+// missionMsgFor returns mission progress for telemetry, or nil without a mission.
+func missionMsgFor(d *sim.Drone) *MissionMsg {
+	if d.Mission == nil {
+		return nil
+	}
+	p := d.MissionProgress()
+	return &MissionMsg{
+		State:             missionStateString(p.State),
+		Current:           p.Current,
+		Total:             p.Total,
+		DistanceRemaining: p.DistanceRemaining,
+		ETA:               p.ETA,
+	}
+}
+
+func missionStateString(state sim.MissionState) string {
+	switch state {
+	case sim.MissionIdle:
+		return "Idle"
+	case sim.MissionRunning:
+		return "Running"
+	case sim.MissionPaused:
+		return "Paused"
+	case sim.MissionComplete:
+		return "Complete"
+	default:
+		return "Unknown"
+	}
+}
@@ -0,0 +1,77 @@
+package nats
+
+import (
+	sim "drone-simulator/internal/sim"
+)
+
+// OrbitCmd is received on drone.<id>.orbit. It starts an orbit around x/z
+// (y is the height of the point of interest); zero radius and altitude are
+// taken from the drone's position. With "update": true only the non-zero
+// fields of the running orbit change. {"stop": true} hovers in place.
+type OrbitCmd struct {
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	Z        float64 `json:"z"`
+	Radius   float64 `json:"radius,omitempty"`   // m
+	Altitude float64 `json:"altitude,omitempty"` // m
+	Speed    float64 `json:"speed,omitempty"`    // m/s, positive = clockwise from above
+	Update   bool    `json:"update,omitempty"`
+	Stop     bool    `json:"stop,omitempty"`
+}
+
+// OrbitMsg reports the orbit being flown in telemetry.
+type OrbitMsg struct {
+	Center         Vec3Msg `json:"center"`
+	Radius         float64 `json:"radius"`
+	Altitude       float64 `json:"altitude"`
+	Speed          float64 `json:"speed"`
+	EffectiveSpeed float64 `json:"effectiveSpeed"` // after the centripetal limit
+	RadiusError    float64 `json:"radiusError"`
+}
+
+// applyOrbitCmd starts, updates or stops an orbit. Callers must hold the
+// simulator write lock.
+func applyOrbitCmd(d *sim.Drone, cmd OrbitCmd) error {
+	if cmd.Stop {
+		if d.FlightMode == sim.FlightModeOrbit {
+			d.SetFlightMode(sim.FlightModeHover)
+		}
+		return nil
+	}
+	if st, ok := d.OrbitStatus(); ok && cmd.Update {
+		cfg := st.OrbitConfig
+		if cmd.Radius > 0 {
+			cfg.Radius = cmd.Radius
+		}
+		if cmd.Altitude > 0 {
+			cfg.Altitude = cmd.Altitude
+		}
+		if cmd.Speed != 0 {
+			cfg.Speed = cmd.Speed
+		}
+		d.SetOrbit(cfg)
+		return nil
+	}
+	return d.StartOrbit(sim.OrbitConfig{
+		Center:   sim.Vec3{X: cmd.X, Y: cmd.Y, Z: cmd.Z},
+		Radius:   cmd.Radius,
+		Altitude: cmd.Altitude,
+		Speed:    cmd.Speed,
+	})
+}
+
+// orbitMsgFor returns the orbit for telemetry, or nil when not orbiting.
+func orbitMsgFor(d *sim.Drone) *OrbitMsg {
+	st, ok := d.OrbitStatus()
+	if !ok {
+		return nil
+	}
+	return &OrbitMsg{
+		Center:         Vec3Msg{X: st.Center.X, Y: st.Center.Y, Z: st.Center.Z},
+		Radius:         st.Radius,
+		Altitude:       st.Altitude,
+		Speed:          st.Speed,
+		EffectiveSpeed: st.EffectiveSpeed,
+		RadiusError:    st.RadiusError,
+	}
+}
@@ -0,0 +1,77 @@
+package nats
+
+import (
+	"fmt"
+	"strings"
+
+	sim "drone-simulator/internal/sim"
+)
+
+// ObstacleMsg is an axis-aligned box in the simulator frame (meters, Y up).
+type ObstacleMsg struct {
+	Name string  `json:"name"`
+	Min  Vec3Msg `json:"min"`
+	Max  Vec3Msg `json:"max"`
+}
+
+// WorldCmd is received on world.obstacles. Obstacles are added to the world
+// (or replace it with Replace); Remove drops obstacles by name first.
+type WorldCmd struct {
+	Obstacles []ObstacleMsg `json:"obstacles,omitempty"`
+	Replace   bool          `json:"replace,omitempty"`
+	Remove    []string      `json:"remove,omitempty"`
+}
+
+var planAlgorithms = map[string]sim.PlannerAlgorithm{
+	"":        sim.PlanAStar,
+	"astar":   sim.PlanAStar,
+	"rrtstar": sim.PlanRRTStar,
+}
+
+// obstaclesFromMsg converts obstacle messages, normalising each box so Min
+// is the lower corner.
+func obstaclesFromMsg(msgs []ObstacleMsg) []sim.Obstacle {
+	out := make([]sim.Obstacle, 0, len(msgs))
+	for _, m := range msgs {
+		a, b := m.Min, m.Max
+		out = append(out, sim.Obstacle{
+			Name: m.Name,
+			Min:  sim.Vec3{X: min(a.X, b.X), Y: min(a.Y, b.Y), Z: min(a.Z, b.Z)},
+			Max:  sim.Vec3{X: max(a.X, b.X), Y: max(a.Y, b.Y), Z: max(a.Z, b.Z)},
+		})
+	}
+	return out
+}
+
+// applyWorldCmd updates w. Callers must hold the simulator write lock.
+func applyWorldCmd(w *sim.World, cmd WorldCmd) {
+	for _, name := range cmd.Remove {
+		w.RemoveObstacle(name)
+	}
+	obs := obstaclesFromMsg(cmd.Obstacles)
+	if cmd.Replace {
+		w.SetObstacles(obs)
+		return
+	}
+	for _, o := range obs {
+		w.AddObstacle(o)
+	}
+}
+
+// gotoPlanned plans and starts a planned goto for cmd. The simulator lock is
+// held only to snapshot the world and to start the route, not for the search.
+func gotoPlanned(s *sim.Simulator, drone *sim.Drone, cmd GotoCmd) ([]sim.Vec3, error) {
+	alg, ok := planAlgorithms[strings.ToLower(cmd.Algorithm)]
+	if !ok {
+		return nil, fmt.Errorf("unknown algorithm %q", cmd.Algorithm)
+	}
+	s.RLock()
+	plan := drone.PlanRoute(sim.Vec3{X: cmd.X, Y: cmd.Y, Z: cmd.Z}, cmd.Speed, sim.PlanOptions{Algorithm: alg})
+	s.RUnlock()
+	if err := plan.Search(); err != nil {
+		return nil, err
+	}
+	s.Lock()
+	defer s.Unlock()
+	return plan.Path, drone.FlyRoute(plan)
+}
@@ -0,0 +1,163 @@
+package nats
+
+import (
+	"fmt"
+	"math"
+
+	sim "drone-simulator/internal/sim"
+)
+
+// OscillationMsg is a sinusoidal deck motion.
+type OscillationMsg struct {
+	Amplitude float64 `json:"amplitude"` // m for heave, degrees for pitch and roll
+	Period    float64 `json:"period"`    // s
+	Phase     float64 `json:"phase"`     // rad
+}
+
+// PlatformCmd is received on platform.<name>. A path makes a scripted deck;
+// a position report creates or moves an externally driven one. Deck motion
+// fields apply to new and existing platforms alike.
+type PlatformCmd struct {
+	Position *Vec3Msg        `json:"position,omitempty"`
+	Velocity Vec3Msg         `json:"velocity"`
+	Path     []Vec3Msg       `json:"path,omitempty"`
+	Speed    float64         `json:"speed,omitempty"` // m/s along path
+	Loop     bool            `json:"loop,omitempty"`
+	Length   float64         `json:"length,omitempty"` // m along the heading
+	Width    float64         `json:"width,omitempty"`  // m across
+	Deck     float64         `json:"deck,omitempty"`   // m of deck above the track point
+	Heave    *OscillationMsg `json:"heave,omitempty"`
+	Pitch    *OscillationMsg `json:"pitch,omitempty"`
+	Roll     *OscillationMsg `json:"roll,omitempty"`
+	Marker   *Vec3Msg        `json:"marker,omitempty"` // x right, z ahead of the deck centre
+	Remove   bool            `json:"remove,omitempty"`
+}
+
+// PrecisionLandCmd is received on drone.<id>.precland. {"stop": true} hovers
+// in place.
+type PrecisionLandCmd struct {
+	Platform    string  `json:"platform"`
+	Altitude    float64 `json:"altitude,omitempty"`    // m above the deck for the approach
+	DescentRate float64 `json:"descentRate,omitempty"` // m/s relative to the deck
+	MaxOffset   float64 `json:"maxOffset,omitempty"`   // m off the marker above which the descent pauses
+	Stop        bool    `json:"stop,omitempty"`
+}
+
+// PrecisionLandMsg reports a precision landing in telemetry.
+type PrecisionLandMsg struct {
+	Platform       string  `json:"platform"`
+	Phase          string  `json:"phase"`
+	Locked         bool    `json:"locked"`
+	Relative       Vec3Msg `json:"relative"` // marker minus drone position
+	Height         float64 `json:"height"`   // m above the deck
+	TouchdownError float64 `json:"touchdownError,omitempty"`
+	TouchdownSpeed float64 `json:"touchdownSpeed,omitempty"`
+}
+
+// Default deck size for platforms created without one
+const (
+	defaultPlatformLength = 6.0
+	defaultPlatformWidth  = 4.0
+)
+
+// applyPlatformCmd updates the world's platform called name. Callers must
+// hold the simulator write lock.
+func applyPlatformCmd(w *sim.World, name string, cmd PlatformCmd) error {
+	if cmd.Remove {
+		w.RemovePlatform(name)
+		return nil
+	}
+	p := w.Platform(name)
+	if len(cmd.Path) > 0 || p == nil {
+		var track *sim.Target
+		switch {
+		case len(cmd.Path) > 0:
+			path := make([]sim.Vec3, 0, len(cmd.Path))
+			for _, q := range cmd.Path {
+				path = append(path, sim.Vec3{X: q.X, Y: q.Y, Z: q.Z})
+			}
+			track = sim.NewScriptedTarget(name, path, cmd.Speed, cmd.Loop)
+		case cmd.Position != nil:
+			track = &sim.Target{Name: name}
+		default:
+			return fmt.Errorf("platform %s: need a position or a path", name)
+		}
+		if p != nil {
+			// Swap only the track: drones landing on the deck keep it
+			p.Track = track
+		} else {
+			p = sim.NewPlatform(name, track, defaultPlatformLength, defaultPlatformWidth, 0)
+			w.AddPlatform(p)
+		}
+	}
+	if cmd.Position != nil && p.Track != nil {
+		p.Track.SetState(sim.Vec3{X: cmd.Position.X, Y: cmd.Position.Y, Z: cmd.Position.Z},
+			sim.Vec3{X: cmd.Velocity.X, Y: cmd.Velocity.Y, Z: cmd.Velocity.Z})
+	}
+	if cmd.Length > 0 {
+		p.Length = cmd.Length
+	}
+	if cmd.Width > 0 {
+		p.Width = cmd.Width
+	}
+	if cmd.Deck != 0 {
+		p.Deck = cmd.Deck
+	}
+	if cmd.Heave != nil {
+		p.Heave = sim.Oscillation{Amplitude: cmd.Heave.Amplitude, Period: cmd.Heave.Period, Phase: cmd.Heave.Phase}
+	}
+	if cmd.Pitch != nil {
+		p.Pitch = degOscillation(*cmd.Pitch)
+	}
+	if cmd.Roll != nil {
+		p.Roll = degOscillation(*cmd.Roll)
+	}
+	if cmd.Marker != nil {
+		p.Marker = sim.Vec3{X: cmd.Marker.X, Z: cmd.Marker.Z}
+	}
+	p.Update(0)
+	return nil
+}
+
+func degOscillation(o OscillationMsg) sim.Oscillation {
+	return sim.Oscillation{Amplitude: o.Amplitude * math.Pi / 180, Period: o.Period, Phase: o.Phase}
+}
+
+// applyPrecisionLandCmd starts or stops a precision landing. Callers must
+// hold the simulator write lock.
+func applyPrecisionLandCmd(d *sim.Drone, w *sim.World, cmd PrecisionLandCmd) error {
+	if cmd.Stop {
+		if d.FlightMode == sim.FlightModePrecisionLand {
+			d.SetFlightMode(sim.FlightModeHover)
+		}
+		return nil
+	}
+	p := w.Platform(cmd.Platform)
+	if p == nil {
+		return fmt.Errorf("precland: no platform %q", cmd.Platform)
+	}
+	return d.StartPrecisionLand(sim.PrecisionLandConfig{
+		Platform:    p,
+		Altitude:    cmd.Altitude,
+		DescentRate: cmd.DescentRate,
+		MaxOffset:   cmd.MaxOffset,
+	})
+}
+
+// precisionLandMsgFor returns landing state for telemetry, or nil when not
+// precision landing.
+func precisionLandMsgFor(d *sim.Drone) *PrecisionLandMsg {
+	st, ok := d.PrecisionLandStatus()
+	if !ok {
+		return nil
+	}
+	return &PrecisionLandMsg{
+		Platform:       st.Platform,
+		Phase:          st.Phase.String(),
+		Locked:         st.Locked,
+		Relative:       Vec3Msg{X: st.Relative.X, Y: st.Relative.Y, Z: st.Relative.Z},
+		Height:         st.Height,
+		TouchdownError: st.TouchdownError,
+		TouchdownSpeed: st.TouchdownSpeed,
+	}
+}
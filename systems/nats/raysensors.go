@@ -0,0 +1,203 @@
+package nats
+
+import (
+	"fmt"
+	"math"
+
+	sim "drone-simulator/internal/sim"
+)
+
+// RaySensorCmd is received on drone.<id>.sensor.<kind> (rangefinder, flow or
+// lidar). It mounts the sensor with its defaults on first use; omitted fields
+// keep their values, and fields of other kinds are ignored.
+// {"remove": true} unmounts it.
+type RaySensorCmd struct {
+	Rate     float64  `json:"rate,omitempty"`     // Hz (scans per second for lidar)
+	Offset   *Vec3Msg `json:"offset,omitempty"`   // m from the drone's centre: x left, y up, z nose
+	Rotation *Vec3Msg `json:"rotation,omitempty"` // degrees pitch (x), yaw (y), roll (z) from the body
+	MinRange *float64 `json:"minRange,omitempty"` // m
+	MaxRange float64  `json:"maxRange,omitempty"` // m
+	Noise    *float64 `json:"noise,omitempty"`    // m, or rad/s for flow
+	Remove   bool     `json:"remove,omitempty"`
+
+	// Optical flow
+	Texture *float64 `json:"texture,omitempty"` // 0..1
+
+	// Lidar
+	Preset        string   `json:"preset,omitempty"`        // 2d or 3d defaults when mounting
+	HorizontalFOV float64  `json:"horizontalFov,omitempty"` // degrees
+	Samples       int      `json:"samples,omitempty"`       // beams per channel
+	Channels      int      `json:"channels,omitempty"`
+	VerticalFOV   *float64 `json:"verticalFov,omitempty"` // degrees
+}
+
+// applyRaySensorCmd mounts, configures or removes d's sensor of kind.
+// Callers must hold the simulator write lock.
+func applyRaySensorCmd(d *sim.Drone, kind string, cmd RaySensorCmd) error {
+	mount := func(m *sim.SensorMount) {
+		if cmd.Offset != nil {
+			m.Offset = sim.Vec3{X: cmd.Offset.X, Y: cmd.Offset.Y, Z: cmd.Offset.Z}
+		}
+		if cmd.Rotation != nil {
+			m.Rotation = sim.Vec3{X: cmd.Rotation.X, Y: cmd.Rotation.Y, Z: cmd.Rotation.Z}.Mul(math.Pi / 180)
+		}
+	}
+	positive := func(dst *float64, v float64) {
+		if v > 0 {
+			*dst = v
+		}
+	}
+	optional := func(dst *float64, v *float64) {
+		if v != nil {
+			*dst = math.Max(*v, 0)
+		}
+	}
+
+	switch kind {
+	case "rangefinder":
+		if cmd.Remove {
+			d.Rangefinder = nil
+			return nil
+		}
+		if d.Rangefinder == nil {
+			r := sim.DefaultRangefinder()
+			d.Rangefinder = &r
+		}
+		r := d.Rangefinder
+		mount(&r.Mount)
+		positive(&r.Rate, cmd.Rate)
+		positive(&r.MaxRange, cmd.MaxRange)
+		optional(&r.MinRange, cmd.MinRange)
+		optional(&r.Noise, cmd.Noise)
+	case "flow":
+		if cmd.Remove {
+			d.Flow = nil
+			return nil
+		}
+		if d.Flow == nil {
+			f := sim.DefaultOpticalFlow()
+			d.Flow = &f
+		}
+		f := d.Flow
+		mount(&f.Mount)
+		positive(&f.Rate, cmd.Rate)
+		positive(&f.MaxRange, cmd.MaxRange)
+		optional(&f.Noise, cmd.Noise)
+		if cmd.Texture != nil {
+			f.Texture = math.Min(math.Max(*cmd.Texture, 0), 1)
+		}
+	case "lidar":
+		if cmd.Remove {
+			d.Lidar = nil
+			return nil
+		}
+		if d.Lidar == nil || cmd.Preset != "" {
+			var l sim.Lidar
+			switch cmd.Preset {
+			case "", "2d":
+				l = sim.DefaultLidar()
+			case "3d":
+				l = sim.DefaultLidar3D()
+			default:
+				return fmt.Errorf("lidar: unknown preset %q", cmd.Preset)
+			}
+			if d.Lidar != nil {
+				l.Mount = d.Lidar.Mount
+			}
+			d.Lidar = &l
+		}
+		l := d.Lidar
+		mount(&l.Mount)
+		positive(&l.Rate, cmd.Rate)
+		positive(&l.MaxRange, cmd.MaxRange)
+		optional(&l.MinRange, cmd.MinRange)
+		optional(&l.Noise, cmd.Noise)
+		positive(&l.HorizontalFOV, math.Min(cmd.HorizontalFOV, 360)*math.Pi/180)
+		if cmd.Samples > 0 {
+			l.Samples = cmd.Samples
+		}
+		if cmd.Channels > 0 {
+			l.Channels = cmd.Channels
+		}
+		if cmd.VerticalFOV != nil {
+			l.VerticalFOV = math.Max(*cmd.VerticalFOV, 0) * math.Pi / 180
+		}
+	default:
+		return fmt.Errorf("sensor: unknown kind %q", kind)
+	}
+	return nil
+}
+
+// RangeMsg reports the latest rangefinder reading in telemetry.
+type RangeMsg struct {
+	Distance float64 `json:"distance"` // m along the beam
+	Valid    bool    `json:"valid"`
+	Target   string  `json:"target"` // None, Ground, Obstacle, Platform or Drone
+}
+
+// FlowMsg reports the latest optical-flow reading in telemetry.
+type FlowMsg struct {
+	X        float64 `json:"x"`        // rad/s image motion along the sensor's x
+	Y        float64 `json:"y"`        // rad/s image motion along the sensor's y
+	BodyRate Vec3Msg `json:"bodyRate"` // rad/s, sensor frame
+	Distance float64 `json:"distance"` // m to the surface
+	Quality  float64 `json:"quality"`  // 0..1
+	Valid    bool    `json:"valid"`
+}
+
+// LidarMsg is published to lidar.<droneID> with each new scan.
+type LidarMsg struct {
+	ID       int          `json:"id"`
+	Time     float64      `json:"time"`     // s of simulation
+	Origin   Vec3Msg      `json:"origin"`   // sensor world position
+	Rotation Vec3Msg      `json:"rotation"` // sensor world attitude, rad like the drone's
+	Channels int          `json:"channels"`
+	Samples  int          `json:"samples"`
+	Ranges   []float64    `json:"ranges"` // channels rows of samples; 0 without a return
+	Points   [][3]float64 `json:"points"` // returns in the sensor frame
+}
+
+// rangeMsgFor returns the rangefinder reading for telemetry, or nil without
+// one.
+func rangeMsgFor(d *sim.Drone) *RangeMsg {
+	if d.Rangefinder == nil || d.Rangefinder.Reading().Count == 0 {
+		return nil
+	}
+	r := d.Rangefinder.Reading()
+	return &RangeMsg{Distance: r.Distance, Valid: r.Valid, Target: r.Target.String()}
+}
+
+// flowMsgFor returns the optical-flow reading for telemetry, or nil without
+// one.
+func flowMsgFor(d *sim.Drone) *FlowMsg {
+	if d.Flow == nil || d.Flow.Reading().Count == 0 {
+		return nil
+	}
+	f := d.Flow.Reading()
+	return &FlowMsg{
+		X:        f.FlowX,
+		Y:        f.FlowY,
+		BodyRate: Vec3Msg{X: f.BodyRate.X, Y: f.BodyRate.Y, Z: f.BodyRate.Z},
+		Distance: f.Distance,
+		Quality:  f.Quality,
+		Valid:    f.Valid,
+	}
+}
+
+// lidarMsgFor returns d's latest scan for publishing.
+func lidarMsgFor(id int, s sim.LidarScan) LidarMsg {
+	msg := LidarMsg{
+		ID:       id,
+		Time:     s.Time,
+		Origin:   Vec3Msg{X: s.Origin.X, Y: s.Origin.Y, Z: s.Origin.Z},
+		Rotation: Vec3Msg{X: s.Rotation.X, Y: s.Rotation.Y, Z: s.Rotation.Z},
+		Channels: s.Channels,
+		Samples:  s.Samples,
+		Ranges:   s.Ranges,
+		Points:   make([][3]float64, len(s.Points)),
+	}
+	for i, p := range s.Points {
+		msg.Points[i] = [3]float64{p.X, p.Y, p.Z}
+	}
+	return msg
+}
@@ -0,0 +1,160 @@
+package nats
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	sim "drone-simulator/internal/sim"
+)
+
+// IMUMsg reports the latest IMU sample in telemetry (body frame: x left,
+// y up, z along the nose).
+type IMUMsg struct {
+	Accel     Vec3Msg `json:"accel"` // m/s² specific force
+	Gyro      Vec3Msg `json:"gyro"`  // rad/s
+	Vibration float64 `json:"vibration"`
+	Saturated bool    `json:"saturated,omitempty"`
+}
+
+// imuMsgFor returns the IMU sample for telemetry, or nil before the first.
+func imuMsgFor(d *sim.Drone) *IMUMsg {
+	s := d.IMU.Sample()
+	if s.Count == 0 {
+		return nil
+	}
+	return &IMUMsg{
+		Accel:     Vec3Msg{X: s.Accel.X, Y: s.Accel.Y, Z: s.Accel.Z},
+		Gyro:      Vec3Msg{X: s.Gyro.X, Y: s.Gyro.Y, Z: s.Gyro.Z},
+		Vibration: s.Vibration,
+		Saturated: s.Saturated,
+	}
+}
+
+// GNSSMsg reports the latest receiver fix in telemetry.
+type GNSSMsg struct {
+	Fix        string  `json:"fix"` // None, 2D, 3D
+	Satellites int     `json:"satellites"`
+	HDOP       float64 `json:"hdop"`
+	VDOP       float64 `json:"vdop"`
+	Lat        float64 `json:"lat"`
+	Lon        float64 `json:"lon"`
+	Alt        float64 `json:"alt"` // m above mean sea level
+	Velocity   Vec3Msg `json:"velocity"`
+}
+
+// GNSSOutageMsg schedules a receiver outage; a radius confines it to a zone.
+type GNSSOutageMsg struct {
+	Start  float64  `json:"start"` // s of simulation
+	End    float64  `json:"end"`   // 0 = until cleared
+	Center *Vec3Msg `json:"center,omitempty"`
+	Radius float64  `json:"radius,omitempty"` // m
+}
+
+// GNSSCmd is received on drone.<id>.gnss. Omitted fields keep their values;
+// outages, when present, replace the schedule ([] clears it).
+type GNSSCmd struct {
+	Nav             string          `json:"nav,omitempty"` // truth, gnss or ekf: what the flight software flies on
+	Rate            float64         `json:"rate,omitempty"`
+	Latency         *float64        `json:"latency,omitempty"`
+	HorizontalError *float64        `json:"horizontalError,omitempty"`
+	VerticalError   *float64        `json:"verticalError,omitempty"`
+	Outages         []GNSSOutageMsg `json:"outages"`
+}
+
+var navSources = map[string]sim.NavSource{
+	"truth": sim.NavTruth,
+	"gnss":  sim.NavGNSS,
+	"ekf":   sim.NavEKF,
+}
+
+// applyGNSSCmd configures d's receiver. Callers must hold the simulator
+// write lock.
+func applyGNSSCmd(d *sim.Drone, cmd GNSSCmd) error {
+	src := d.NavSource
+	if cmd.Nav != "" {
+		s, ok := navSources[strings.ToLower(cmd.Nav)]
+		if !ok {
+			return fmt.Errorf("gnss: unknown nav source %q", cmd.Nav)
+		}
+		src = s
+	}
+	g := &d.GNSS
+	if cmd.Rate > 0 {
+		g.Rate = cmd.Rate
+	}
+	if cmd.Latency != nil {
+		g.Latency = math.Max(*cmd.Latency, 0)
+	}
+	if cmd.HorizontalError != nil {
+		g.HorizontalError = math.Max(*cmd.HorizontalError, 0)
+	}
+	if cmd.VerticalError != nil {
+		g.VerticalError = math.Max(*cmd.VerticalError, 0)
+	}
+	if cmd.Outages != nil {
+		g.Outages = g.Outages[:0]
+		for _, o := range cmd.Outages {
+			out := sim.GNSSOutage{Start: o.Start, End: o.End, Radius: o.Radius}
+			if o.Center != nil {
+				out.Center = sim.Vec3{X: o.Center.X, Z: o.Center.Z}
+			}
+			g.Outages = append(g.Outages, out)
+		}
+	}
+	d.NavSource = src
+	return nil
+}
+
+// gnssMsgFor returns the receiver fix for telemetry, or nil before the first.
+func gnssMsgFor(d *sim.Drone) *GNSSMsg {
+	r := d.GNSS.Reading()
+	if r.Count == 0 {
+		return nil
+	}
+	return &GNSSMsg{
+		Fix:        r.Fix.String(),
+		Satellites: r.Satellites,
+		HDOP:       r.HDOP,
+		VDOP:       r.VDOP,
+		Lat:        r.Geo.Lat,
+		Lon:        r.Geo.Lon,
+		Alt:        r.Geo.Alt,
+		Velocity:   Vec3Msg{X: r.Velocity.X, Y: r.Velocity.Y, Z: r.Velocity.Z},
+	}
+}
+
+// BaroMsg reports the latest barometer sample in telemetry.
+type BaroMsg struct {
+	Pressure float64 `json:"pressure"` // Pa
+	Altitude float64 `json:"altitude"` // m MSL from the standard atmosphere
+}
+
+// MagMsg reports the latest magnetometer sample in telemetry.
+type MagMsg struct {
+	Field   Vec3Msg `json:"field"`   // µT, body frame
+	Heading float64 `json:"heading"` // degrees from magnetic north, tilt-compensated
+}
+
+// baroMsgFor returns the barometer sample for telemetry, or nil before the
+// first.
+func baroMsgFor(d *sim.Drone) *BaroMsg {
+	s := d.Baro.Sample()
+	if s.Count == 0 {
+		return nil
+	}
+	return &BaroMsg{Pressure: s.Pressure, Altitude: s.Altitude}
+}
+
+// magMsgFor returns the magnetometer sample for telemetry, or nil before the
+// first.
+func magMsgFor(d *sim.Drone) *MagMsg {
+	s := d.Mag.Sample()
+	if s.Count == 0 {
+		return nil
+	}
+	return &MagMsg{
+		Field:   Vec3Msg{X: s.Field.X, Y: s.Field.Y, Z: s.Field.Z},
+		Heading: s.Heading(d.Rotation.X, d.Rotation.Z),
+	}
+}
@@ -9,6 +9,18 @@ const (
 	SubjectTelemetryPattern = "telemetry.>"     // For nats2sse subscription
 	SubjectTelemetryFmt     = "telemetry.%d"    // telemetry.<droneID>
 
+	// Lidar subjects - published by simulator with each new scan
+	SubjectLidarPattern = "lidar.>"
+	SubjectLidarFmt     = "lidar.%d" // lidar.<droneID>
+
+	// Camera subjects - PNG frames published by simulator with each new image
+	SubjectCameraPattern = "camera.>"
+	SubjectCameraFmt     = "camera.%d.%s" // camera.<droneID>.<rgb|depth>
+
+	// Event subjects - published by simulator when onboard systems raise events
+	SubjectEventsPattern = "events.>"
+	SubjectEventFmt      = "events.%d.%s" // events.<droneID>.<kind>, e.g. events.0.geofence.breach
+
 	// Micro service subjects - request/reply via narun-gw
 	SubjectDroneList    = "drone.list"
 	SubjectDroneStatus  = "drone.status"
@@ -20,6 +32,12 @@ const (
 	SubjectDroneMode    = "drone.mode"
 	SubjectDroneStop    = "drone.stop"
 
+	// World subjects - scenery, targets and platforms shared by all drones
+	SubjectWorldObstacles  = "world.obstacles"
+	SubjectWorldOrigin     = "world.origin" // WGS84 anchor of the local frame
+	SubjectTargetPattern   = "target.*"   // target.<name>: position reports or scripted paths
+	SubjectPlatformPattern = "platform.*" // platform.<name>: moving landing decks
+
 	// Legacy command subjects (direct pub/sub) - deprecated, use micro service
 	SubjectCommandFmt = "drone.%d.%s" // drone.<droneID>.<command>
 )
@@ -29,6 +47,21 @@ func TelemetrySubject(droneID int) string {
 	return fmt.Sprintf(SubjectTelemetryFmt, droneID)
 }
 
+// LidarSubject returns the lidar scan subject for a specific drone.
+func LidarSubject(droneID int) string {
+	return fmt.Sprintf(SubjectLidarFmt, droneID)
+}
+
+// CameraSubject returns the subject for a drone's rgb or depth frames.
+func CameraSubject(droneID int, kind string) string {
+	return fmt.Sprintf(SubjectCameraFmt, droneID, kind)
+}
+
+// EventSubject returns the subject for a drone event of the given kind.
+func EventSubject(droneID int, kind string) string {
+	return fmt.Sprintf(SubjectEventFmt, droneID, kind)
+}
+
 // CommandSubject returns a command subject for a specific drone (legacy).
 func CommandSubject(droneID int, command string) string {
 	return fmt.Sprintf(SubjectCommandFmt, droneID, command)
@@ -0,0 +1,124 @@
+package sim_test
+
+import (
+	sim "drone-simulator/internal/sim"
+	"math"
+	"testing"
+)
+
+// hovering returns an armed drone holding 10 m in AltitudeHold.
+func hovering(t *testing.T) *sim.Drone {
+	t.Helper()
+	d := sim.NewDrone()
+	d.Arm()
+	d.SetThrottle(d.HoverThrottlePercent())
+	d.SetFlightMode(sim.FlightModeAltitudeHold)
+	d.AltitudeHold = 10
+	step(d, 15)
+	if math.Abs(d.Position.Y-10) > 0.5 {
+		t.Fatalf("hover failed: y=%.2f", d.Position.Y)
+	}
+	return d
+}
+
+func runAutotune(t *testing.T, d *sim.Drone, cfg sim.AutotuneConfig) (sim.AutotuneStatus, []sim.Event) {
+	t.Helper()
+	if err := d.StartAutotune(cfg); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	var events []sim.Event
+	for i := 0; i < int(60/fsDt) && d.AutotuneStatus().State == sim.AutotuneRunning; i++ {
+		d.Update(fsDt)
+		events = append(events, d.TakeEvents()...)
+	}
+	return d.AutotuneStatus(), events
+}
+
+func TestAutotuneAltitudeAppliesGains(t *testing.T) {
+	d := hovering(t)
+	st, events := runAutotune(t, d, sim.AutotuneConfig{Axis: sim.TuneAltitude, Apply: true})
+	if st.State != sim.AutotuneDone || st.Result == nil {
+		t.Fatalf("status %+v", st)
+	}
+	r := st.Result
+	if r.Ku <= 0 || r.Tu < 0.5 || r.Tu > 10 || r.Amplitude > 1 {
+		t.Fatalf("implausible identification: %+v", r)
+	}
+	want := sim.GainsFromUltimate(r.Ku, r.Tu, sim.TuneClassic)
+	if !r.Applied || d.AltitudePID.Kp != want.Kp || d.AltitudePID.Ki != want.Ki || d.AltitudePID.Kd != want.Kd {
+		t.Fatalf("gains not applied: pid=%+v want=%+v", d.AltitudePID, want)
+	}
+	if !hasEvent(events, "autotune.done") {
+		t.Fatalf("no autotune.done event")
+	}
+
+	// The tuned loop must still hold and track altitude
+	d.AltitudeHold = 12
+	step(d, 25)
+	if math.Abs(d.Position.Y-12) > 0.15 || math.Abs(d.Velocity.Y) > 0.15 {
+		t.Fatalf("tuned loop did not settle: y=%.2f vy=%.2f", d.Position.Y, d.Velocity.Y)
+	}
+}
+
+func TestAutotunePitchReportOnly(t *testing.T) {
+	d := hovering(t)
+	before := d.PitchPID
+	st, _ := runAutotune(t, d, sim.AutotuneConfig{Axis: sim.TunePitch, Rule: sim.TuneNoOvershoot})
+	if st.State != sim.AutotuneDone || st.Result == nil {
+		t.Fatalf("status %+v", st)
+	}
+	if st.Result.Applied || d.PitchPID != before {
+		t.Fatalf("gains applied without Apply")
+	}
+	if err := d.StartAutotune(sim.AutotuneConfig{Axis: sim.TunePitch, Apply: true}); err == nil {
+		t.Fatalf("apply accepted for an attitude axis")
+	}
+	g := st.Result.Gains
+	if g.Kp <= 0 || g.Ki <= 0 || g.Kd <= 0 {
+		t.Fatalf("gains %+v", g)
+	}
+	if math.Abs(d.Rotation.X) > 0.1 {
+		t.Fatalf("relay left the drone tilted: %.2f", d.Rotation.X)
+	}
+}
+
+func TestAutotuneRefusedAndInterrupted(t *testing.T) {
+	d := sim.NewDrone()
+	if err := d.StartAutotune(sim.AutotuneConfig{}); err == nil {
+		t.Fatalf("autotune started on the ground")
+	}
+
+	d = hovering(t)
+	if err := d.StartAutotune(sim.AutotuneConfig{Axis: sim.TuneRoll}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.StartAutotune(sim.AutotuneConfig{Axis: sim.TuneYaw}); err == nil {
+		t.Fatalf("second autotune started while running")
+	}
+	d.CancelAutotune()
+	if st := d.AutotuneStatus(); st.State != sim.AutotuneFailed || st.Err != "cancelled" {
+		t.Fatalf("cancel: %+v", st)
+	}
+
+	if err := d.StartAutotune(sim.AutotuneConfig{Axis: sim.TuneAltitude}); err != nil {
+		t.Fatal(err)
+	}
+	d.BatteryPercent = d.CriticalBattery - 1
+	events := step(d, 3)
+	if st := d.AutotuneStatus(); st.State != sim.AutotuneFailed {
+		t.Fatalf("failsafe did not stop autotune: %+v", st)
+	}
+	if !hasEvent(events, "autotune.failed") {
+		t.Fatalf("no autotune.failed event")
+	}
+}
+
+func TestGainsFromUltimate(t *testing.T) {
+	g := sim.GainsFromUltimate(10, 2, sim.TuneClassic)
+	if math.Abs(g.Kp-6) > 1e-9 || math.Abs(g.Ki-6) > 1e-9 || math.Abs(g.Kd-1.5) > 1e-9 {
+		t.Fatalf("classic: %+v", g)
+	}
+	if n := sim.GainsFromUltimate(10, 2, sim.TuneNoOvershoot); n.Kp >= g.Kp {
+		t.Fatalf("no-overshoot Kp %.2f not below classic %.2f", n.Kp, g.Kp)
+	}
+}
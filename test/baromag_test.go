@@ -0,0 +1,88 @@
+package sim_test
+
+import (
+	"math"
+	"testing"
+
+	sim "drone-simulator/internal/sim"
+)
+
+// baroError returns the mean barometric altitude error over seconds.
+func baroError(d *sim.Drone, seconds float64) float64 {
+	origin := sim.DefaultOrigin.Alt
+	sum, n := 0.0, 0
+	for i := 0; i < int(seconds/fsDt); i++ {
+		d.Update(fsDt)
+		sum += d.Baro.Sample().Altitude - (origin + d.Position.Y)
+		n++
+	}
+	return sum / float64(n)
+}
+
+func TestBarometerAltitudeAndPropWash(t *testing.T) {
+	d := airborne(t)
+	if e := baroError(d, 5); math.Abs(e) > 0.3 {
+		t.Fatalf("baro altitude off by %.2f m at 10 m", e)
+	}
+	if s := d.Baro.Sample(); s.Pressure > 101325 || s.Pressure < 90000 || s.Count < 700 {
+		t.Fatalf("sample %+v", s)
+	}
+
+	// Hovering a metre up, the prop wash pushes the reading low
+	low := sim.NewDrone()
+	low.Arm()
+	low.SetMission(sim.NewMission([]sim.MissionItem{{Type: sim.MissionItemTakeoff, Position: sim.Vec3{Y: 1}}}))
+	low.StartMission()
+	step(low, 5)
+	if e := baroError(low, 3); e > -0.4 {
+		t.Fatalf("no ground effect at %.2f m: error %.2f m", low.Position.Y, e)
+	}
+}
+
+func TestEarthFieldModel(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		at    sim.GeoPoint
+		south bool
+	}{
+		{"canberra", sim.DefaultOrigin, true},
+		{"london", sim.GeoPoint{Lat: 51.5, Lon: -0.1}, false},
+	} {
+		// The horizontal field points north, along -Z
+		f := sim.EarthField(c.at)
+		if f.Length() < 40 || f.Length() > 65 || f.Z >= 0 {
+			t.Fatalf("%s: field %+v µT (%.1f)", c.name, f, f.Length())
+		}
+		// The field dips down in the north and points up in the south
+		if (f.Y > 0) != c.south || math.Abs(math.Atan2(math.Abs(f.Y), math.Hypot(f.X, f.Z))) < 50*math.Pi/180 {
+			t.Fatalf("%s: inclination of %+v", c.name, f)
+		}
+	}
+}
+
+func TestMagnetometerHeadingAndInterference(t *testing.T) {
+	d := sim.NewDrone()
+	d.Mag.Noise, d.Mag.HardIron, d.Mag.SoftIron, d.Mag.Current = 0, sim.Vec3{}, [3]sim.Vec3{}, sim.Vec3{}
+	earth := sim.EarthField(sim.DefaultOrigin)
+	decl := sim.RadToDeg(math.Atan2(earth.X, -earth.Z))
+	for _, h := range []float64{0, 45, 170, 270} {
+		d.Rotation = sim.Vec3{X: 0.2, Y: sim.HeadingToYaw(h), Z: -0.15}
+		d.AngularVel = sim.Vec3{}
+		step(d, 0.1)
+		got := d.Mag.Sample().Heading(d.Rotation.X, d.Rotation.Z)
+		if diff := math.Remainder(got-(h-decl), 360); math.Abs(diff) > 0.5 {
+			t.Fatalf("heading %.1f° reads %.1f° magnetic (declination %.1f°)", h, got, decl)
+		}
+	}
+
+	// Motor current bends the field
+	quiet := airborne(t)
+	noisy := airborne(t)
+	quiet.Mag.Current = sim.Vec3{}
+	step(quiet, 1)
+	step(noisy, 1)
+	shift := noisy.Mag.Sample().Field.Sub(quiet.Mag.Sample().Field)
+	if shift.Y < 2 {
+		t.Fatalf("motor current moved the field by %+v µT", shift)
+	}
+}
@@ -0,0 +1,136 @@
+package sim_test
+
+import (
+	"math"
+	"testing"
+
+	sim "drone-simulator/internal/sim"
+)
+
+// altitudeController holds an altitude with collective thrust alone and
+// levels the airframe with body torque.
+type altitudeController struct {
+	target float64
+	calls  int
+	last   sim.ControllerState
+}
+
+func (c *altitudeController) Update(s sim.ControllerState, dt float64) sim.Actuators {
+	c.calls++
+	c.last = s
+	thr := s.Hover + 8*(c.target-s.Position.Y) - 6*s.Velocity.Y
+	level := sim.Vec3{
+		X: (-20*s.Rotation.X - 5*s.AngularVel.X) * s.Inertia.X,
+		Z: (-20*s.Rotation.Z - 5*s.AngularVel.Z) * s.Inertia.Z,
+	}
+	return sim.Actuators{Throttle: thr, Torque: level}
+}
+
+func TestCustomControllerFliesDrone(t *testing.T) {
+	d := sim.NewDrone()
+	c := &altitudeController{target: 5}
+	d.Controller = c
+	d.Arm()
+	d.SetPilotInput(sim.PilotInput{ThrottleRate: 10})
+	step(d, 15)
+	if math.Abs(d.Position.Y-5) > 0.3 {
+		t.Fatalf("custom controller holds y=%.2f, want 5", d.Position.Y)
+	}
+	if c.calls != int(15/fsDt) || math.Abs(c.last.Time-15) > 2*fsDt {
+		t.Fatalf("controller called %d times, last at t=%.3f", c.calls, c.last.Time)
+	}
+	if !c.last.Armed || c.last.Pilot.ThrottleRate != 10 || c.last.MaxThrust <= 0 {
+		t.Fatalf("state not passed through: %+v", c.last)
+	}
+
+	// Disarmed drones ignore their controller
+	d.Disarm()
+	step(d, 3)
+	if !d.OnGround && d.Position.Y > 0.5 {
+		t.Fatalf("disarmed drone still flying at y=%.2f", d.Position.Y)
+	}
+}
+
+// motorController drives the motors directly.
+type motorController struct{ cmd []float64 }
+
+func (c motorController) Update(sim.ControllerState, float64) sim.Actuators {
+	return sim.Actuators{Motors: c.cmd}
+}
+
+func TestControllerCommandsMotorsDirectly(t *testing.T) {
+	d := sim.NewDrone()
+	d.Controller = motorController{cmd: []float64{0.9, 0.9, 0.9, 0.9}}
+	d.Arm()
+	step(d, 1)
+	if d.Position.Y < 1 {
+		t.Fatalf("per-motor thrust did not lift the drone: y=%.2f", d.Position.Y)
+	}
+
+	// Unequal thrust between the +Z and -Z arms tilts about body X only
+	d = sim.NewDrone()
+	d.Controller = motorController{cmd: []float64{0.9, 0.8, 0.9, 0.8}}
+	d.Arm()
+	step(d, 0.5)
+	if math.Abs(d.Rotation.X) < 0.05 || math.Abs(d.Rotation.Z) > 0.01 {
+		t.Fatalf("differential thrust gave pitch %.3f roll %.3f", d.Rotation.X, d.Rotation.Z)
+	}
+}
+
+// wrappedAutopilot records the built-in autopilot's commands.
+type wrappedAutopilot struct {
+	d   *sim.Drone
+	out sim.Actuators
+}
+
+func (w *wrappedAutopilot) Update(s sim.ControllerState, dt float64) sim.Actuators {
+	w.out = w.d.Autopilot().Update(s, dt)
+	return w.out
+}
+
+func TestAutopilotIsAController(t *testing.T) {
+	d := airborne(t)
+	y := d.Position.Y
+	w := &wrappedAutopilot{d: d}
+	d.Controller = w
+	d.SetFlightMode(sim.FlightModeHover)
+	d.Velocity.X = 2
+	step(d, 5)
+	if math.Abs(d.Position.Y-y) > 0.3 {
+		t.Fatalf("wrapped autopilot lost altitude: %.2f -> %.2f", y, d.Position.Y)
+	}
+	if w.out.Throttle <= 0 || w.out.Lift == 0 {
+		t.Fatalf("autopilot commands not reported: %+v", w.out)
+	}
+}
+
+func TestSwarmLeavesCustomControllerAlone(t *testing.T) {
+	d0, d1 := sim.NewDrone(), sim.NewDrone()
+	s := sim.NewSwarm([]*sim.Drone{d0, d1})
+	c := &altitudeController{target: 3}
+	d1.Controller = c
+	d0.Arm()
+	for i := 0; i < int(5/fsDt); i++ {
+		s.Update(fsDt)
+		d0.Update(fsDt)
+		d1.Update(fsDt)
+	}
+	if d1.Controller != sim.Controller(c) || c.calls == 0 {
+		t.Fatal("swarm replaced the follower's controller")
+	}
+	if math.Abs(d1.Position.Y-3) > 0.3 {
+		t.Fatalf("custom follower at y=%.2f, want 3", d1.Position.Y)
+	}
+
+	// Followers without one get the formation controller; the leader never does
+	d1.Controller = nil
+	s.Update(fsDt)
+	if d1.Controller == nil || d0.Controller != nil {
+		t.Fatalf("formation controller not installed on the follower only")
+	}
+	s.SetLeader(1)
+	s.Update(fsDt)
+	if d1.Controller != nil || d0.Controller == nil {
+		t.Fatal("formation controller did not move with the leader")
+	}
+}
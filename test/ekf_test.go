@@ -0,0 +1,127 @@
+package sim_test
+
+import (
+	"math"
+	"testing"
+
+	sim "drone-simulator/internal/sim"
+)
+
+const deg = math.Pi / 180
+
+// ekfSquare is a takeoff and a 20 m square at 10 m.
+func ekfSquare() *sim.Mission {
+	return sim.NewMission([]sim.MissionItem{
+		{Type: sim.MissionItemTakeoff, Position: sim.Vec3{Y: 10}},
+		{Type: sim.MissionItemWaypoint, Position: sim.Vec3{X: 20, Y: 10}},
+		{Type: sim.MissionItemWaypoint, Position: sim.Vec3{X: 20, Y: 10, Z: 20}},
+		{Type: sim.MissionItemWaypoint, Position: sim.Vec3{Y: 10, Z: 20}},
+		{Type: sim.MissionItemWaypoint, Position: sim.Vec3{Y: 10}},
+	})
+}
+
+func TestEKFTracksTruth(t *testing.T) {
+	d := sim.NewDrone()
+	d.World = sim.NewWorld()
+	d.WindVelocity = sim.Vec3{X: 5, Z: -2}
+	step(d, 1)
+	if !d.EKF.Estimate().Valid {
+		t.Fatal("filter not aligned after a second of sensor data")
+	}
+	if res := d.Arm(); !res.Armed {
+		t.Fatalf("arm: %v", res.Reasons())
+	}
+	d.SetMission(ekfSquare())
+	d.StartMission()
+
+	worst := sim.EKFError{}
+	for i := 0; i < int(40/fsDt); i++ {
+		d.Update(fsDt)
+		if i < int(5/fsDt) {
+			continue // converging
+		}
+		e := d.EKFError()
+		worst.Position.X = math.Max(worst.Position.X, horizontalLen(e.Position))
+		worst.Position.Y = math.Max(worst.Position.Y, math.Abs(e.Position.Y))
+		worst.Velocity.X = math.Max(worst.Velocity.X, e.Velocity.Length())
+		worst.Attitude.X = math.Max(worst.Attitude.X, math.Max(math.Abs(e.Attitude.X), math.Abs(e.Attitude.Z)))
+		worst.Attitude.Y = math.Max(worst.Attitude.Y, math.Abs(e.Attitude.Y))
+	}
+	// Yaw is as good as the uncalibrated compass
+	if worst.Position.X > 2 || worst.Position.Y > 1.5 || worst.Velocity.X > 0.6 ||
+		worst.Attitude.X > 2.5*deg || worst.Attitude.Y > 10*deg {
+		t.Fatalf("worst error while flying in wind: %+v", worst)
+	}
+
+	est := d.EKF.Estimate()
+	if est.GNSS.Fused < 150 || est.Baro.Fused < 1500 || est.Mag.Fused < 1500 {
+		t.Fatalf("fused %+v / %+v / %+v", est.GNSS, est.Baro, est.Mag)
+	}
+}
+
+func TestNavEKFFliesOnTheFilter(t *testing.T) {
+	d := sim.NewDrone()
+	d.World = sim.NewWorld()
+	d.NavSource = sim.NavEKF
+	d.WindVelocity = sim.Vec3{X: 4}
+	d.Update(fsDt)
+	if !d.PositionLost || d.Arm().Armed {
+		t.Fatal("armed before the filter aligned")
+	}
+	step(d, 1)
+	if d.PositionLost {
+		t.Fatal("no position after a second of sensor data")
+	}
+	if res := d.Arm(); !res.Armed {
+		t.Fatalf("arm: %v", res.Reasons())
+	}
+	d.SetMission(ekfSquare())
+	d.StartMission()
+	step(d, 60)
+
+	// Back over the start at 10 m, steady, off only by the estimate's error
+	if d.Mission.State != sim.MissionComplete || horizontalLen(d.Position) > 3 || math.Abs(d.Position.Y-10) > 2 {
+		t.Fatalf("mission %v at %+v", d.Mission.State, d.Position)
+	}
+	if horizontalLen(d.Velocity) > 0.5 || math.Abs(d.AngularVel.Y) > 0.1 {
+		t.Fatalf("unsteady: velocity %+v, rates %+v", d.Velocity, d.AngularVel)
+	}
+	// Navigation is the filter's solution from the start of the last step
+	pos, _, ok := d.Navigation()
+	if !ok || pos.Sub(d.EKF.Estimate().Position).Length() > 0.05 {
+		t.Fatalf("navigation %+v is not the filter's", pos)
+	}
+}
+
+func TestEKFGatesOutliers(t *testing.T) {
+	d := sim.NewDrone()
+	d.World = sim.NewWorld()
+	step(d, 10)
+	before := d.EKF.Estimate()
+
+	// A steel roof: the field swings 90° and the heading innovation is
+	// refused rather than dragging the yaw round
+	earth := sim.EarthField(d.World.GeoOrigin())
+	field := sim.Vec3{X: earth.Z, Y: earth.Y, Z: -earth.X}
+	d.Mag.Field = &field
+	step(d, 5)
+	est := d.EKF.Estimate()
+	if est.Mag.Rejected < 240 || est.Mag.Fused != before.Mag.Fused || est.Mag.Ratio < 5 {
+		t.Fatalf("magnetometer %+v after %+v", est.Mag, before.Mag)
+	}
+	if e := d.EKFError(); math.Abs(e.Attitude.Y) > 8*deg {
+		t.Fatalf("yaw dragged off by %.1f°", e.Attitude.Y/deg)
+	}
+
+	// Without GNSS the IMU carries the position a while, uncertainty growing
+	d.Mag.Field = nil
+	d.GNSS.Outages = []sim.GNSSOutage{{}}
+	step(d, 10)
+	est = d.EKF.Estimate()
+	if e := d.EKFError(); horizontalLen(e.Position) > 5 {
+		t.Fatalf("dead reckoning drifted %+v", e.Position)
+	}
+	if est.PositionSigma.X <= before.PositionSigma.X {
+		t.Fatalf("σ %+v did not grow from %+v", est.PositionSigma, before.PositionSigma)
+	}
+}
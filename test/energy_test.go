@@ -0,0 +1,93 @@
+package sim_test
+
+import (
+	"math"
+	"testing"
+
+	sim "drone-simulator/internal/sim"
+)
+
+func TestEnergyEnduranceAndRange(t *testing.T) {
+	d := airborne(t)
+	step(d, 6) // settle the smoothed draw
+	e := d.EnergyEstimate()
+	if e.Power < 40 || e.Power > 100 {
+		t.Fatalf("hover power %.1f W", e.Power)
+	}
+	want := e.Usable * 3600 / e.Power
+	if math.Abs(e.Endurance-want) > 1 || e.Endurance < 600 {
+		t.Fatalf("endurance %.0f s, want %.0f s", e.Endurance, want)
+	}
+
+	// Flying on at the same draw empties the battery to the reserve on time
+	before := d.BatteryPercent
+	step(d, 30)
+	used := (before - d.BatteryPercent) / (before - d.CriticalBattery)
+	if math.Abs(used-30/e.Endurance) > 0.1*30/e.Endurance {
+		t.Fatalf("used %.4f of the usable charge in 30 s, estimate said %.4f", used, 30/e.Endurance)
+	}
+
+	d.Velocity = sim.Vec3{X: 3, Z: 4}
+	if e := d.EnergyEstimate(); math.Abs(e.Range-5*e.Endurance) > 1e-6 {
+		t.Fatalf("range %.0f m at 5 m/s for %.0f s", e.Range, e.Endurance)
+	}
+}
+
+func TestEnergyReturnCostsDistanceAndWind(t *testing.T) {
+	d := airborne(t)
+	near := d.EnergyEstimate()
+	if near.HomeDistance > 1 || !near.Reachable || near.ReturnTime <= 0 {
+		t.Fatalf("at home: %+v", near)
+	}
+
+	d.Position.X = 200 // home is to the west
+	far := d.EnergyEstimate()
+	if far.ReturnSpeed != 5 || far.ReturnTime < near.ReturnTime+40 || far.ReturnEnergy <= near.ReturnEnergy {
+		t.Fatalf("200 m out: %+v", far)
+	}
+	d.WindVelocity = sim.Vec3{X: 15} // headwind on the way home
+	head := d.EnergyEstimate()
+	d.WindVelocity = sim.Vec3{X: -15}
+	tail := d.EnergyEstimate()
+	if head.ReturnEnergy <= tail.ReturnEnergy {
+		t.Fatalf("headwind return %.3f Wh not dearer than tailwind %.3f Wh", head.ReturnEnergy, tail.ReturnEnergy)
+	}
+	d.WindVelocity = sim.Vec3{X: 80}
+	if e := d.EnergyEstimate(); e.Reachable || !e.MustReturn {
+		t.Fatalf("80 m/s headwind: %+v", e)
+	}
+}
+
+func TestFailsafeSmartRTH(t *testing.T) {
+	d := airborne(t)
+	d.Failsafe.Rules[sim.FailsafeLowBattery].Action = sim.ActionNone
+	d.Position.X = 400
+	d.BatteryPercent = 25
+	step(d, 3)
+	if e := d.EnergyEstimate(); e.MustReturn || d.Failsafe.Triggered(sim.FailsafeSmartRTH) {
+		t.Fatalf("enough energy to return but tripped: %+v", e)
+	}
+
+	d.BatteryPercent = 19
+	if !d.EnergyEstimate().MustReturn {
+		t.Fatalf("19%% at 400 m should force a return: %+v", d.EnergyEstimate())
+	}
+	events := step(d, 3)
+	if !hasEvent(events, "failsafe.trigger") || d.Failsafe.Active() != sim.FailsafeSmartRTH || d.ActiveAction() != sim.ActionReturnHome {
+		t.Fatalf("active %v action %v", d.Failsafe.Active(), d.ActiveAction())
+	}
+}
+
+func TestFailsafeSmartRTHWhenHomeUnreachable(t *testing.T) {
+	d := airborne(t)
+	d.Failsafe.Rules[sim.FailsafeSmartRTH].Action = sim.ActionLand
+	d.Position.X = 400
+	d.WindVelocity = sim.Vec3{X: 80} // headwind no airframe can fly against
+	if e := d.EnergyEstimate(); e.Reachable || !e.MustReturn {
+		t.Fatalf("home out of reach: %+v", e)
+	}
+	events := step(d, 3)
+	if !hasEvent(events, "failsafe.trigger") || d.Failsafe.Active() != sim.FailsafeSmartRTH || d.ActiveAction() != sim.ActionLand {
+		t.Fatalf("active %v action %v", d.Failsafe.Active(), d.ActiveAction())
+	}
+}
@@ -0,0 +1,138 @@
+package sim_test
+
+import (
+	sim "drone-simulator/internal/sim"
+	"testing"
+)
+
+const fsDt = 1.0 / 120
+
+func step(d *sim.Drone, seconds float64) []sim.Event {
+	var events []sim.Event
+	for i := 0; i < int(seconds/fsDt); i++ {
+		d.Update(fsDt)
+		events = append(events, d.TakeEvents()...)
+	}
+	return events
+}
+
+// airborne returns an armed drone hovering at 10 m via a one-item mission.
+func airborne(t *testing.T) *sim.Drone {
+	t.Helper()
+	d := sim.NewDrone()
+	d.Arm()
+	d.SetMission(sim.NewMission([]sim.MissionItem{{Type: sim.MissionItemTakeoff, Position: sim.Vec3{Y: 10}}}))
+	d.StartMission()
+	step(d, 10)
+	if d.Position.Y < 9 {
+		t.Fatalf("takeoff failed: y=%.2f", d.Position.Y)
+	}
+	return d
+}
+
+// TestFailsafeCriticalBatteryLands used to disarm mid-air; now it must land.
+func TestFailsafeCriticalBatteryLands(t *testing.T) {
+	d := airborne(t)
+	d.BatteryPercent = d.CriticalBattery - 1
+	step(d, 0.5)
+	if !d.IsArmed {
+		t.Fatalf("critical battery disarmed the drone mid-air")
+	}
+	step(d, 2)
+	if d.Failsafe.Active() != sim.FailsafeCriticalBattery || d.ActiveAction() != sim.ActionLand {
+		t.Fatalf("active=%v action=%v", d.Failsafe.Active(), d.ActiveAction())
+	}
+	step(d, 40)
+	if d.IsArmed || d.Position.Y > 0.1 {
+		t.Fatalf("expected landed and disarmed, y=%.2f armed=%v", d.Position.Y, d.IsArmed)
+	}
+	for i, e := range d.Engines {
+		if e.Efficiency < 0.99 {
+			t.Fatalf("engine %d damaged: %.2f", i, e.Efficiency)
+		}
+	}
+}
+
+func TestFailsafePriority(t *testing.T) {
+	d := airborne(t)
+	d.BatteryPercent = d.LowBatteryWarning - 1
+	events := step(d, 3)
+	if d.Failsafe.Active() != sim.FailsafeLowBattery || d.ActiveAction() != sim.ActionReturnHome {
+		t.Fatalf("low battery: active=%v action=%v", d.Failsafe.Active(), d.ActiveAction())
+	}
+	if !hasEvent(events, "failsafe.trigger") || !hasEvent(events, "failsafe.action") {
+		t.Fatalf("missing transition events: %+v", events)
+	}
+
+	// Position loss outranks low battery and forces a landing in place
+	d.PositionLost = true
+	step(d, 1.5)
+	if d.Failsafe.Active() != sim.FailsafePositionLoss || d.ActiveAction() != sim.ActionLand {
+		t.Fatalf("position loss: active=%v action=%v", d.Failsafe.Active(), d.ActiveAction())
+	}
+
+	// Once the estimate is back the latched low-battery RTH resumes
+	d.PositionLost = false
+	events = step(d, 4)
+	if d.Failsafe.Active() != sim.FailsafeLowBattery || d.ActiveAction() != sim.ActionReturnHome {
+		t.Fatalf("after recovery: active=%v action=%v", d.Failsafe.Active(), d.ActiveAction())
+	}
+	if !hasEvent(events, "failsafe.clear") {
+		t.Fatalf("position loss clear not reported: %+v", events)
+	}
+}
+
+func TestFailsafeLinkLossHysteresis(t *testing.T) {
+	d := airborne(t)
+	d.Heartbeat()
+
+	// A gap shorter than the timeout is ignored
+	step(d, d.Failsafe.LinkTimeout-1)
+	d.Heartbeat()
+	step(d, 1)
+	if d.Failsafe.Triggered(sim.FailsafeLinkLoss) {
+		t.Fatalf("link loss triggered before the timeout")
+	}
+
+	step(d, d.Failsafe.LinkTimeout)
+	if d.Failsafe.Active() != sim.FailsafeLinkLoss || d.ActiveAction() != sim.ActionReturnHome {
+		t.Fatalf("link loss: active=%v action=%v", d.Failsafe.Active(), d.ActiveAction())
+	}
+
+	// The link must stay healthy for ClearDelay before control is returned
+	clearDelay := d.Failsafe.Rules[sim.FailsafeLinkLoss].ClearDelay
+	for elapsed := 0.0; elapsed < clearDelay-0.5; elapsed += 0.5 {
+		d.Heartbeat()
+		step(d, 0.5)
+	}
+	if d.Failsafe.Active() != sim.FailsafeLinkLoss {
+		t.Fatalf("link loss cleared before the clear delay")
+	}
+	for elapsed := 0.0; elapsed < 1.0; elapsed += 0.25 {
+		d.Heartbeat()
+		step(d, 0.25)
+	}
+	if d.Failsafe.Active() != sim.FailsafeNone || d.ActiveAction() != sim.ActionNone {
+		t.Fatalf("link regained: active=%v action=%v", d.Failsafe.Active(), d.ActiveAction())
+	}
+}
+
+func TestFailsafeBatteryHysteresis(t *testing.T) {
+	d := airborne(t)
+	d.Failsafe.Rules[sim.FailsafeLowBattery] = sim.FailsafeRule{Action: sim.ActionHold}
+	d.BatteryPercent = d.LowBatteryWarning
+	step(d, 0.1)
+	if d.ActiveAction() != sim.ActionHold {
+		t.Fatalf("low battery hold not started: %v", d.ActiveAction())
+	}
+	d.BatteryPercent = d.LowBatteryWarning + 1
+	step(d, 0.1)
+	if !d.Failsafe.Triggered(sim.FailsafeLowBattery) {
+		t.Fatalf("cleared inside the hysteresis band")
+	}
+	d.BatteryPercent = d.LowBatteryWarning + d.Failsafe.BatteryHysteresis + 1
+	step(d, 0.1)
+	if d.Failsafe.Triggered(sim.FailsafeLowBattery) || d.ActiveAction() != sim.ActionNone {
+		t.Fatalf("did not clear above the hysteresis band")
+	}
+}
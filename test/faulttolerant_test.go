@@ -0,0 +1,88 @@
+package sim_test
+
+import (
+	sim "drone-simulator/internal/sim"
+	"math"
+	"testing"
+)
+
+// hoverThenFail hovers at 10 m, holds position for a moment and fails engine i.
+func hoverThenFail(t *testing.T, i int, tolerant bool) (*sim.Drone, []sim.Event) {
+	t.Helper()
+	d := airborne(t)
+	d.FaultTolerantControl = tolerant
+	if f := d.MotorFault(); f.Motor != -1 {
+		t.Fatalf("false motor fault in healthy hover: %+v", f)
+	}
+	d.FailEngine(i)
+	return d, step(d, 1)
+}
+
+func TestMotorFailureDetected(t *testing.T) {
+	for i := 0; i < 4; i++ {
+		d, events := hoverThenFail(t, i, true)
+		f := d.MotorFault()
+		if f.Motor != i || !f.Active {
+			t.Fatalf("engine %d: fault=%+v", i, f)
+		}
+		if !hasEvent(events, "fault.motor") {
+			t.Fatalf("engine %d: no fault.motor event in %+v", i, events)
+		}
+	}
+}
+
+func TestMotorFailureSpinsAndLands(t *testing.T) {
+	d, _ := hoverThenFail(t, 0, true)
+	maxSpin, maxTilt, touchdown := 0.0, 0.0, 0.0
+	for i := 0; i < int(40/fsDt) && d.IsArmed; i++ {
+		vy := d.Velocity.Y
+		d.Update(fsDt)
+		maxSpin = math.Max(maxSpin, math.Abs(d.AngularVel.Y))
+		maxTilt = math.Max(maxTilt, math.Max(math.Abs(d.Rotation.X), math.Abs(d.Rotation.Z)))
+		if d.OnGround && touchdown == 0 {
+			touchdown = -vy
+		}
+	}
+	if d.IsArmed || d.Destroyed || d.Position.Y > 0.1 {
+		t.Fatalf("expected landed intact: y=%.2f armed=%v destroyed=%v", d.Position.Y, d.IsArmed, d.Destroyed)
+	}
+	if maxSpin < 1 {
+		t.Fatalf("expected yaw spin, max |r|=%.2f rad/s", maxSpin)
+	}
+	if maxTilt > 0.5 {
+		t.Fatalf("tilt not held: %.2f rad", maxTilt)
+	}
+	if touchdown > 1.2 {
+		t.Fatalf("touchdown speed %.2f m/s", touchdown)
+	}
+	for i, e := range d.Engines {
+		if i != 0 && e.Efficiency < 0.99 {
+			t.Fatalf("engine %d damaged: %.2f", i, e.Efficiency)
+		}
+	}
+}
+
+// With recovery disabled the failure is still detected and reported, but the
+// normal controller keeps flying.
+func TestMotorFailureReportedWithoutRecovery(t *testing.T) {
+	d, events := hoverThenFail(t, 2, false)
+	if f := d.MotorFault(); f.Motor != 2 || f.Active {
+		t.Fatalf("expected detection only, fault=%+v", f)
+	}
+	if !hasEvent(events, "fault.motor") {
+		t.Fatalf("no fault.motor event")
+	}
+	if d.FlightMode != sim.FlightModeMission || !d.IsArmed {
+		t.Fatalf("recovery took over: mode=%v armed=%v", d.FlightMode, d.IsArmed)
+	}
+}
+
+func TestMotorFaultResetOnArm(t *testing.T) {
+	d, _ := hoverThenFail(t, 1, true)
+	step(d, 40)
+	d.RepairEngine(1)
+	d.Arm()
+	if f := d.MotorFault(); f.Motor != -1 || f.Active {
+		t.Fatalf("fault not cleared on arm: %+v", f)
+	}
+}
@@ -0,0 +1,138 @@
+package sim_test
+
+import (
+	"math"
+	"testing"
+
+	sim "drone-simulator/internal/sim"
+)
+
+// stepWith advances target and drone together, returning the events and the
+// mean follow error after settle seconds.
+func stepWith(d *sim.Drone, tgt *sim.Target, seconds, settle float64) ([]sim.Event, float64) {
+	var events []sim.Event
+	sum, n := 0.0, 0
+	for i := 0; i < int(seconds/fsDt); i++ {
+		tgt.Update(fsDt)
+		d.Update(fsDt)
+		events = append(events, d.TakeEvents()...)
+		if st, ok := d.FollowStatus(); ok && float64(i)*fsDt >= settle {
+			sum += st.ErrorDistance
+			n++
+		}
+	}
+	if n == 0 {
+		return events, 0
+	}
+	return events, sum / float64(n)
+}
+
+func TestScriptedTargetDrivesPath(t *testing.T) {
+	square := []sim.Vec3{{}, {X: 20}, {X: 20, Z: 20}, {Z: 20}}
+	tgt := sim.NewScriptedTarget("car", square, 5, true)
+	for i := 0; i < int(5/fsDt); i++ {
+		tgt.Update(fsDt)
+	}
+	// 25 m along the loop: 5 m up the second side
+	if p := tgt.Position; math.Abs(p.X-20) > 0.1 || math.Abs(p.Z-5) > 0.1 {
+		t.Fatalf("target at %+v after 5 s", p)
+	}
+	// Driving +Z: nose along +Z is heading 0
+	if math.Abs(tgt.Heading) > 1e-6 || math.Abs(tgt.Velocity.Z-5) > 1e-6 {
+		t.Fatalf("heading %.2f velocity %+v", tgt.Heading, tgt.Velocity)
+	}
+	for i := 0; i < int(12/fsDt); i++ {
+		tgt.Update(fsDt)
+	}
+	// 85 m = one 80 m lap plus 5 m: back on the first side
+	if p := tgt.Position; math.Abs(p.X-5) > 0.1 || math.Abs(p.Z) > 0.1 {
+		t.Fatalf("looping target at %+v after 17 s", p)
+	}
+}
+
+func TestFollowKeepsStationBehindTarget(t *testing.T) {
+	d := airborne(t)
+	tgt := sim.NewScriptedTarget("car", []sim.Vec3{{X: 5}, {X: 205}}, 4, false)
+	if err := d.StartFollow(sim.FollowConfig{Target: tgt, Offset: sim.Vec3{X: 3, Z: -8}, Altitude: 6}); err != nil {
+		t.Fatal(err)
+	}
+	_, mean := stepWith(d, tgt, 25, 12)
+	if mean > 0.5 {
+		t.Fatalf("mean station error %.2f m", mean)
+	}
+	// Heading east (+X): behind is -X, right is south (+Z)
+	rel := d.Position.Sub(tgt.Position)
+	if math.Abs(rel.X+8) > 1 || math.Abs(rel.Z-3) > 1 || math.Abs(rel.Y-6) > 0.5 {
+		t.Fatalf("drone at %+v from the target, want (-8, 6, 3)", rel)
+	}
+	st, _ := d.FollowStatus()
+	if math.Abs(st.Range-math.Sqrt(64+9+36)) > 1 {
+		t.Fatalf("range %.2f", st.Range)
+	}
+}
+
+func TestFollowLeadAndSpeedLimit(t *testing.T) {
+	// A target circling: leading its centripetal acceleration must track
+	// better than ignoring it
+	var circle []sim.Vec3
+	for i := 0; i < 64; i++ {
+		a := 2 * math.Pi * float64(i) / 64
+		circle = append(circle, sim.Vec3{X: 15 * math.Sin(a), Z: 15 - 15*math.Cos(a)})
+	}
+	mean := map[float64]float64{}
+	for _, lead := range []float64{-1, 0} {
+		d := airborne(t)
+		tgt := sim.NewScriptedTarget("car", circle, 5, true)
+		d.StartFollow(sim.FollowConfig{Target: tgt, WorldOffset: true, Altitude: 5, Lead: lead})
+		_, mean[lead] = stepWith(d, tgt, 30, 10)
+	}
+	if mean[0] > 0.75*mean[-1] {
+		t.Fatalf("lead did not help: %.2f m vs %.2f m without", mean[0], mean[-1])
+	}
+
+	d := airborne(t)
+	tgt := sim.NewScriptedTarget("fast", []sim.Vec3{{}, {X: 500}}, 10, false)
+	d.StartFollow(sim.FollowConfig{Target: tgt, MaxSpeed: 4})
+	peak := 0.0
+	for i := 0; i < int(10/fsDt); i++ {
+		tgt.Update(fsDt)
+		d.Update(fsDt)
+		peak = math.Max(peak, math.Hypot(d.Velocity.X, d.Velocity.Z))
+	}
+	if peak > 4.5 {
+		t.Fatalf("speed limit 4 m/s exceeded: %.2f", peak)
+	}
+	if st, _ := d.FollowStatus(); st.ErrorDistance < 20 {
+		t.Fatalf("speed-limited drone kept up with a 10 m/s target (error %.1f m)", st.ErrorDistance)
+	}
+}
+
+func TestFollowExternalTargetLostAndRegained(t *testing.T) {
+	d := airborne(t)
+	tgt := &sim.Target{Name: "runner"}
+	tgt.SetState(sim.Vec3{X: 5}, sim.Vec3{X: 1})
+	if err := d.StartFollow(sim.FollowConfig{Target: tgt, Altitude: 8}); err != nil {
+		t.Fatal(err)
+	}
+	events, _ := stepWith(d, tgt, 5, 0)
+	if !hasEvent(events, "follow.lost") {
+		t.Fatal("no follow.lost after reports stopped")
+	}
+	st, _ := d.FollowStatus()
+	if !st.Lost {
+		t.Fatal("status not lost")
+	}
+	held := d.Position
+	stepWith(d, tgt, 3, 0)
+	if moved := d.Position.Sub(held).Length(); moved > 1 {
+		t.Fatalf("drone drifted %.2f m while the target was lost", moved)
+	}
+	tgt.SetState(sim.Vec3{X: 10}, sim.Vec3{})
+	events, _ = stepWith(d, tgt, 0.5, 0)
+	if !hasEvent(events, "follow.regained") {
+		t.Fatal("no follow.regained after a new report")
+	}
+	if err := d.StartFollow(sim.FollowConfig{}); err == nil {
+		t.Fatal("follow without a target accepted")
+	}
+}
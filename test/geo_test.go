@@ -0,0 +1,117 @@
+package sim_test
+
+import (
+	"math"
+	"testing"
+
+	sim "drone-simulator/internal/sim"
+)
+
+func TestLocalFrameConversions(t *testing.T) {
+	v := sim.Vec3{X: 3, Y: 5, Z: -7} // 3 m east, 5 m up, 7 m north
+	if enu := sim.LocalToENU(v); enu != (sim.Vec3{X: 3, Y: 7, Z: 5}) {
+		t.Fatalf("ENU %+v", enu)
+	}
+	if ned := sim.LocalToNED(v); ned != (sim.Vec3{X: 7, Y: 3, Z: -5}) {
+		t.Fatalf("NED %+v", ned)
+	}
+	// East × up is south: the frame is right-handed
+	east, up := sim.ENUToLocal(sim.Vec3{X: 1}), sim.ENUToLocal(sim.Vec3{Z: 1})
+	if south := sim.ENUToLocal(sim.Vec3{Y: -1}); east.Cross(up) != south {
+		t.Fatalf("east × up = %+v, south is %+v", east.Cross(up), south)
+	}
+	if sim.ENUToLocal(sim.LocalToENU(v)) != v || sim.NEDToLocal(sim.LocalToNED(v)) != v {
+		t.Fatal("conversions do not round-trip")
+	}
+
+	o := sim.GeoPoint{Lat: 47.397742, Lon: 8.545594, Alt: 488}
+	p := o.FromNED(sim.Vec3{X: 1000, Y: -500, Z: -120})
+	if p.Lat <= o.Lat || p.Lon >= o.Lon || math.Abs(p.Alt-608) > 1e-9 {
+		t.Fatalf("1 km north, 500 m west, 120 m up is %+v", p)
+	}
+	// A thousandth of a degree of latitude is about 111 m
+	if n := o.ToNED(sim.GeoPoint{Lat: o.Lat + 0.001, Lon: o.Lon, Alt: o.Alt}); math.Abs(n.X-111.2) > 0.5 {
+		t.Fatalf("0.001° north is %.1f m", n.X)
+	}
+}
+
+func TestGeoRoundTripThroughLatLon(t *testing.T) {
+	o := sim.GeoPoint{Lat: 47.397742, Lon: 8.545594, Alt: 488}
+	// 0.001° north and east: about 111 m and 75 m
+	p := sim.GeoPoint{Lat: o.Lat + 0.001, Lon: o.Lon + 0.001, Alt: 500}
+	v := o.ToLocal(p)
+	if math.Abs(v.Z+111.2) > 0.5 || math.Abs(v.X-75.3) > 0.5 || math.Abs(v.Y-12) > 1e-9 {
+		t.Fatalf("north-east point at %+v, want about (75.3, 12, -111.2)", v)
+	}
+	if enu := sim.LocalToENU(v); enu.X <= 0 || enu.Y <= 0 {
+		t.Fatalf("ENU %+v is not north-east", enu)
+	}
+	if ned := o.ToNED(p); math.Abs(ned.X+v.Z) > 1e-9 || math.Abs(ned.Y-v.X) > 1e-9 || math.Abs(ned.Z+12) > 1e-9 {
+		t.Fatalf("NED %+v for local %+v", ned, v)
+	}
+	back := o.ToGeo(v)
+	if math.Abs(back.Lat-p.Lat) > 1e-9 || math.Abs(back.Lon-p.Lon) > 1e-9 || math.Abs(back.Alt-p.Alt) > 1e-9 {
+		t.Fatalf("round trip %+v, want %+v", back, p)
+	}
+	if q := o.FromNED(o.ToNED(p)); math.Abs(q.Lat-p.Lat) > 1e-9 || math.Abs(q.Lon-p.Lon) > 1e-9 {
+		t.Fatalf("NED round trip %+v, want %+v", q, p)
+	}
+}
+
+func TestHeadingFollowsNose(t *testing.T) {
+	// The nose at yaw y points along (-sin y, 0, cos y); its compass heading
+	// must name the direction it points in
+	for _, c := range []struct {
+		name    string
+		heading float64
+		nose    sim.Vec3 // local
+	}{
+		{"north", 0, sim.Vec3{Z: -1}},
+		{"east", 90, sim.Vec3{X: 1}},
+		{"south", 180, sim.Vec3{Z: 1}},
+		{"west", 270, sim.Vec3{X: -1}},
+	} {
+		yaw := sim.HeadingToYaw(c.heading)
+		nose := sim.Vec3{X: -math.Sin(yaw), Z: math.Cos(yaw)}
+		if nose.Sub(c.nose).Length() > 1e-9 {
+			t.Errorf("%s: heading %.0f points the nose at %+v", c.name, c.heading, nose)
+		}
+		if h := sim.YawToHeading(yaw); math.Abs(math.Remainder(h-c.heading, 360)) > 1e-9 {
+			t.Errorf("%s: yaw %.3f reads heading %.1f", c.name, yaw, h)
+		}
+	}
+}
+
+func TestParseGeoPoint(t *testing.T) {
+	p, err := sim.ParseGeoPoint("47.397742, 8.545594,488")
+	if err != nil || p != (sim.GeoPoint{Lat: 47.397742, Lon: 8.545594, Alt: 488}) {
+		t.Fatalf("%+v, %v", p, err)
+	}
+	if p, err := sim.ParseGeoPoint("-35.36,149.16"); err != nil || p.Alt != 0 {
+		t.Fatalf("without altitude: %+v, %v", p, err)
+	}
+	for _, s := range []string{"", "47.3", "91,8", "47,181", "47,x", "1,2,3,4"} {
+		if _, err := sim.ParseGeoPoint(s); err == nil {
+			t.Errorf("%q accepted", s)
+		}
+	}
+}
+
+func TestDroneGeodeticState(t *testing.T) {
+	d := sim.NewDrone()
+	d.World = sim.NewWorld()
+	d.World.Origin = sim.GeoPoint{Lat: 51.5, Lon: -0.12, Alt: 20}
+	d.Position = sim.Vec3{X: -50, Y: 30, Z: -200}
+	d.Rotation.Y = sim.HeadingToYaw(90)
+
+	g := d.Geo()
+	if g.Lat <= 51.5 || g.Lon >= -0.12 || math.Abs(g.Alt-50) > 1e-9 {
+		t.Fatalf("geo %+v", g)
+	}
+	if back := d.World.Origin.ToLocal(g); back.Sub(d.Position).Length() > 1e-6 {
+		t.Fatalf("round trip %+v", back)
+	}
+	if h := d.Heading(); math.Abs(h-90) > 1e-9 {
+		t.Fatalf("heading %.3f", h)
+	}
+}
@@ -0,0 +1,117 @@
+package sim_test
+
+import (
+	sim "drone-simulator/internal/sim"
+	"testing"
+)
+
+func hasEvent(events []sim.Event, kind string) bool {
+	for _, e := range events {
+		if e.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func TestGeofenceContains(t *testing.T) {
+	cyl := &sim.Geofence{Shape: sim.FenceCylinder, Center: sim.Vec3{X: 10, Z: 0}, Radius: 5, Floor: 2, Ceiling: 20}
+	if !cyl.Contains(sim.Vec3{X: 12, Y: 10, Z: 3}) {
+		t.Fatalf("point inside cylinder reported outside")
+	}
+	if cyl.Contains(sim.Vec3{X: 12, Y: 1, Z: 3}) || cyl.Contains(sim.Vec3{X: 12, Y: 21, Z: 3}) {
+		t.Fatalf("floor/ceiling not applied")
+	}
+	if cyl.Contains(sim.Vec3{X: 16, Y: 10}) {
+		t.Fatalf("point outside radius reported inside")
+	}
+
+	// Concave L-shaped polygon, unbounded ceiling
+	poly := &sim.Geofence{Shape: sim.FencePolygon, Inclusion: true, Polygon: []sim.Vec3{
+		{X: 0, Z: 0}, {X: 20, Z: 0}, {X: 20, Z: 10}, {X: 10, Z: 10}, {X: 10, Z: 20}, {X: 0, Z: 20},
+	}}
+	if !poly.Contains(sim.Vec3{X: 5, Y: 300, Z: 15}) || poly.Violated(sim.Vec3{X: 15, Y: 5, Z: 5}) {
+		t.Fatalf("points inside polygon rejected")
+	}
+	if !poly.Violated(sim.Vec3{X: 15, Y: 5, Z: 15}) {
+		t.Fatalf("point in the notch should violate an inclusion fence")
+	}
+}
+
+// TestGeofencePredictiveHold flies at an exclusion zone with a Hold action and
+// expects the drone to stop before the boundary without ever breaching it.
+func TestGeofencePredictiveHold(t *testing.T) {
+	d := sim.NewDrone()
+	d.Geofences = []*sim.Geofence{{Name: "tower", Shape: sim.FenceCylinder, Center: sim.Vec3{X: 40}, Radius: 10, Action: sim.ActionHold}}
+	d.Arm()
+	d.SetMission(sim.NewMission([]sim.MissionItem{
+		{Type: sim.MissionItemTakeoff, Position: sim.Vec3{Y: 8}},
+		{Type: sim.MissionItemWaypoint, Position: sim.Vec3{X: 60, Y: 8}, Speed: 8},
+	}))
+	d.StartMission()
+
+	var events []sim.Event
+	dt := 1.0 / 120
+	for i := 0; i < int(30/dt); i++ {
+		d.Update(dt)
+		events = append(events, d.TakeEvents()...)
+		if d.Position.X > 30 {
+			t.Fatalf("drone entered the exclusion zone: x=%.2f", d.Position.X)
+		}
+	}
+	if !hasEvent(events, "geofence.predicted") || hasEvent(events, "geofence.breach") {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+	if d.ActiveAction() != sim.ActionHold {
+		t.Fatalf("action = %v, want Hold", d.ActiveAction())
+	}
+	if d.Velocity.Length() > 0.3 {
+		t.Fatalf("drone still moving at %.2f m/s", d.Velocity.Length())
+	}
+}
+
+// TestGeofenceBreachReturnsHome leaves an inclusion cylinder whose action is
+// RTH and expects the drone to come back and land at its launch point.
+func TestGeofenceBreachReturnsHome(t *testing.T) {
+	d := sim.NewDrone()
+	d.ReturnAltitude = 8
+	d.Geofences = []*sim.Geofence{{Name: "field", Shape: sim.FenceCylinder, Inclusion: true, Radius: 15, Ceiling: 30, Action: sim.ActionReturnHome}}
+	d.Arm()
+	d.SetMission(sim.NewMission([]sim.MissionItem{
+		{Type: sim.MissionItemTakeoff, Position: sim.Vec3{Y: 6}},
+		{Type: sim.MissionItemWaypoint, Position: sim.Vec3{Z: 40, Y: 6}},
+	}))
+	d.StartMission()
+
+	var events []sim.Event
+	dt := 1.0 / 120
+	for i := 0; i < int(60/dt) && d.IsArmed; i++ {
+		d.Update(dt)
+		events = append(events, d.TakeEvents()...)
+	}
+	if !hasEvent(events, "geofence.breach") || !hasEvent(events, "geofence.clear") {
+		t.Fatalf("expected breach then clear, got %+v", events)
+	}
+	if d.IsArmed || d.Position.Sub(d.Home).Length() > 1.5 {
+		t.Fatalf("expected landing at home, armed=%v pos=%+v", d.IsArmed, d.Position)
+	}
+}
+
+func TestGeofenceCeilingTerminates(t *testing.T) {
+	d := sim.NewDrone()
+	d.Geofences = []*sim.Geofence{{Name: "ceiling", Shape: sim.FencePolygon, Inclusion: true, Ceiling: 5,
+		Polygon: []sim.Vec3{{X: -50, Z: -50}, {X: 50, Z: -50}, {X: 50, Z: 50}, {X: -50, Z: 50}}, Action: sim.ActionTerminate}}
+	d.Arm()
+	d.SetMission(sim.NewMission([]sim.MissionItem{{Type: sim.MissionItemTakeoff, Position: sim.Vec3{Y: 10}}}))
+	d.StartMission()
+	dt := 1.0 / 120
+	for i := 0; i < int(15/dt) && d.IsArmed; i++ {
+		d.Update(dt)
+	}
+	if d.IsArmed || d.ActiveAction() != sim.ActionTerminate {
+		t.Fatalf("expected termination above the ceiling, armed=%v y=%.2f", d.IsArmed, d.Position.Y)
+	}
+	if d.Position.Y < 5 || d.Position.Y > 6.5 {
+		t.Fatalf("terminated at unexpected height %.2f", d.Position.Y)
+	}
+}
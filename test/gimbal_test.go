@@ -0,0 +1,116 @@
+package sim_test
+
+import (
+	"math"
+	"testing"
+
+	sim "drone-simulator/internal/sim"
+)
+
+// cameraAxis returns the world direction a camera of attitude rot looks in.
+func cameraAxis(rot sim.Vec3) sim.Vec3 {
+	return sim.Vec3{X: -math.Sin(rot.Y) * math.Cos(rot.X), Y: math.Sin(rot.X), Z: math.Cos(rot.Y) * math.Cos(rot.X)}
+}
+
+func TestGimbalStabilisesInFlight(t *testing.T) {
+	d := sim.NewDrone()
+	d.World = sim.NewWorld()
+	d.Gimbal.Command = sim.Vec3{X: -30 * deg}
+	if res := d.Arm(); !res.Armed {
+		t.Fatalf("arm: %v", res.Reasons())
+	}
+	d.SetMission(ekfSquare())
+	d.StartMission()
+	step(d, 3)
+
+	var tilt, pitchErr, rollErr float64
+	for i := 0; i < int(20/fsDt); i++ {
+		d.Update(fsDt)
+		_, cam := d.Gimbal.Pose(d)
+		tilt = math.Max(tilt, math.Max(math.Abs(d.Rotation.X), math.Abs(d.Rotation.Z)))
+		pitchErr = math.Max(pitchErr, math.Abs(cam.X+30*deg))
+		rollErr = math.Max(rollErr, math.Abs(cam.Z))
+		if yaw := math.Remainder(cam.Y-d.Rotation.Y, 2*math.Pi); math.Abs(yaw) > 2*deg {
+			t.Fatalf("camera yaw %.1f° off the nose", yaw/deg)
+		}
+	}
+	if tilt < 5*deg || pitchErr > 1.5*deg || rollErr > 1.5*deg {
+		t.Fatalf("body tilted %.1f°; camera pitch off by %.2f°, roll by %.2f°", tilt/deg, pitchErr/deg, rollErr/deg)
+	}
+}
+
+func TestGimbalSlewAndLimits(t *testing.T) {
+	d := sim.NewDrone()
+	d.World = sim.NewWorld()
+	d.Gimbal.Mode = sim.GimbalBody
+	d.Gimbal.Command = sim.Vec3{X: -90 * deg, Z: 60 * deg}
+	step(d, 0.25)
+	// 120°/s in pitch and 90°/s in roll
+	if j := d.Gimbal.Joint(); math.Abs(j.X+30*deg) > 1*deg || math.Abs(j.Z-22.5*deg) > 1*deg {
+		t.Fatalf("joints after 0.25 s: %.1f° / %.1f°", j.X/deg, j.Z/deg)
+	}
+	step(d, 1)
+	if j := d.Gimbal.Joint(); math.Abs(j.X+90*deg) > 1e-9 || math.Abs(j.Z-40*deg) > 1e-9 {
+		t.Fatalf("joints %.1f° / %.1f°, want -90° and the 40° roll stop", j.X/deg, j.Z/deg)
+	}
+	d.Gimbal.Command = sim.Vec3{X: 60 * deg}
+	step(d, 2)
+	if j := d.Gimbal.Joint(); math.Abs(j.X-30*deg) > 1e-9 {
+		t.Fatalf("pitch %.1f° past the 30° stop", j.X/deg)
+	}
+
+	// Lock holds a world heading however the airframe turns; Follow turns with it
+	d.Rotation.Y = 1
+	d.Gimbal.Mode, d.Gimbal.Command = sim.GimbalLock, sim.Vec3{Y: 0.2}
+	step(d, 2)
+	if _, cam := d.Gimbal.Pose(d); math.Abs(cam.Y-0.2) > 0.01 || math.Abs(d.Gimbal.Joint().Y+0.8) > 0.01 {
+		t.Fatalf("locked camera yaw %.3f, joint %.3f", cam.Y, d.Gimbal.Joint().Y)
+	}
+	d.Gimbal.Mode = sim.GimbalFollow
+	step(d, 2)
+	if _, cam := d.Gimbal.Pose(d); math.Abs(cam.Y-1.2) > 0.01 {
+		t.Fatalf("following camera yaw %.3f, want the nose plus 0.2", cam.Y)
+	}
+}
+
+func TestGimbalROI(t *testing.T) {
+	d := sim.NewDrone()
+	d.World = sim.NewWorld()
+	if res := d.Arm(); !res.Armed {
+		t.Fatalf("arm: %v", res.Reasons())
+	}
+	d.SetMission(sim.NewMission([]sim.MissionItem{
+		{Type: sim.MissionItemTakeoff, Position: sim.Vec3{Y: 15}},
+		{Type: sim.MissionItemWaypoint, Position: sim.Vec3{X: 20, Y: 15, Z: 20}},
+	}))
+	d.StartMission()
+
+	roi := sim.Vec3{X: 10, Z: 30}
+	d.Gimbal.PointAt(roi)
+	worst := 0.0
+	for i := 0; i < int(25/fsDt); i++ {
+		d.Update(fsDt)
+		if i < int(3/fsDt) {
+			continue // slewing round
+		}
+		pos, cam := d.Gimbal.Pose(d)
+		want := roi.Sub(pos).Normalize()
+		worst = math.Max(worst, math.Acos(math.Min(cameraAxis(cam).Dot(want), 1)))
+	}
+	if worst > 2*deg {
+		t.Fatalf("camera strayed %.2f° off the ROI", worst/deg)
+	}
+
+	// A moving target is tracked
+	tgt := sim.NewScriptedTarget("car", []sim.Vec3{{X: -20, Z: 40}, {X: 40, Z: 40}}, 5, true)
+	d.World.AddTarget(tgt)
+	d.Gimbal.ROITarget = tgt
+	for i := 0; i < int(6/fsDt); i++ {
+		d.World.Update(fsDt)
+		d.Update(fsDt)
+	}
+	pos, cam := d.Gimbal.Pose(d)
+	if off := math.Acos(math.Min(cameraAxis(cam).Dot(tgt.Position.Sub(pos).Normalize()), 1)); off > 2*deg {
+		t.Fatalf("camera %.2f° off the moving target", off/deg)
+	}
+}
@@ -0,0 +1,121 @@
+package sim_test
+
+import (
+	"math"
+	"testing"
+
+	sim "drone-simulator/internal/sim"
+)
+
+func TestGNSSOpenSkyFix(t *testing.T) {
+	d := sim.NewDrone()
+	d.World = sim.NewWorld()
+	step(d, 10)
+	r := d.GNSS.Reading()
+	if r.Fix != sim.GNSSFix3D || r.Satellites != 14 || r.HDOP != 0.8 || r.VDOP != 1.2 {
+		t.Fatalf("open sky: %+v", r)
+	}
+	// About 5 Hz, each fix a latency old
+	if r.Count < 48 || r.Count > 50 {
+		t.Fatalf("%d fixes in 10 s at 5 Hz", r.Count)
+	}
+	if lag := 10 - r.Time; lag < 0.1-1e-9 || lag > 0.3+1e-9 {
+		t.Fatalf("fix taken at %.3f s, %.3f s before now", r.Time, lag)
+	}
+	e := r.Position.Sub(d.Position)
+	if math.Hypot(e.X, e.Z) > 5 || math.Abs(e.Y) > 8 || e.Length() == 0 {
+		t.Fatalf("fix error %+v", e)
+	}
+	g := d.World.GeoOrigin().ToGeo(r.Position)
+	if math.Abs(r.Geo.Lat-g.Lat) > 1e-9 || math.Abs(r.Geo.Lon-g.Lon) > 1e-9 || math.Abs(r.Geo.Alt-g.Alt) > 1e-6 {
+		t.Fatalf("geodetic %+v, want %+v", r.Geo, g)
+	}
+
+	// Gauss-Markov error wanders slowly: one second changes it little
+	before := d.GNSS.Error()
+	step(d, 1)
+	if d.GNSS.Error().Sub(before).Length() > 0.5 {
+		t.Fatalf("error jumped from %+v to %+v in 1 s", before, d.GNSS.Error())
+	}
+}
+
+func TestGNSSUrbanCanyon(t *testing.T) {
+	d := sim.NewDrone()
+	d.World = sim.NewWorld()
+	// A street running north between two 40 m blocks
+	d.World.AddObstacle(sim.Obstacle{Name: "west", Min: sim.Vec3{X: -30, Z: -100}, Max: sim.Vec3{X: -5, Y: 40, Z: 100}})
+	d.World.AddObstacle(sim.Obstacle{Name: "east", Min: sim.Vec3{X: 5, Z: -100}, Max: sim.Vec3{X: 30, Y: 40, Z: 100}})
+	step(d, 2)
+	r := d.GNSS.Reading()
+	if r.Fix == sim.GNSSNoFix || r.Satellites >= 10 || r.HDOP <= 0.8 {
+		t.Fatalf("in the canyon: %+v", r)
+	}
+
+	// Inside the block the sky is gone
+	d.Position = sim.Vec3{X: -20, Y: 5}
+	step(d, 1)
+	if r := d.GNSS.Reading(); r.Fix != sim.GNSSNoFix || r.Satellites > 2 {
+		t.Fatalf("under 35 m of building: %+v", r)
+	}
+}
+
+func TestGNSSScheduledOutage(t *testing.T) {
+	d := sim.NewDrone()
+	d.GNSS.Outages = []sim.GNSSOutage{{Start: 2, End: 4}}
+	step(d, 3)
+	if r := d.GNSS.Reading(); r.Fix != sim.GNSSNoFix {
+		t.Fatalf("fix during the outage: %+v", r)
+	}
+	step(d, 2)
+	if r := d.GNSS.Reading(); r.Fix != sim.GNSSFix3D {
+		t.Fatalf("no fix after the outage: %+v", r)
+	}
+
+	// Zones only deny the receiver inside them
+	d.GNSS.Outages = []sim.GNSSOutage{{Center: sim.Vec3{X: 100}, Radius: 20}}
+	step(d, 1)
+	if r := d.GNSS.Reading(); r.Fix != sim.GNSSFix3D {
+		t.Fatalf("denied outside the zone: %+v", r)
+	}
+}
+
+func TestNavGNSSFliesOnFixes(t *testing.T) {
+	d := sim.NewDrone()
+	d.NavSource = sim.NavGNSS
+	if !d.PositionLost {
+		d.Update(fsDt)
+	}
+	if !d.PositionLost || d.Arm().Armed {
+		t.Fatal("armed without a GNSS fix")
+	}
+	step(d, 1)
+	if d.PositionLost {
+		t.Fatal("no position after a second of fixes")
+	}
+	if res := d.Arm(); !res.Armed {
+		t.Fatalf("arm: %v", res.Reasons())
+	}
+	d.SetMission(sim.NewMission([]sim.MissionItem{{Type: sim.MissionItemTakeoff, Position: sim.Vec3{Y: 10}}}))
+	d.StartMission()
+	step(d, 20)
+
+	// The drone holds where the receiver says it should be, so the truth is
+	// off by the receiver's error
+	pos, _, ok := d.Navigation()
+	if !ok || horizontalLen(pos) > 1.5 || math.Abs(pos.Y-d.AltitudeHold) > 1 {
+		t.Fatalf("navigation solution %+v", pos)
+	}
+	off := d.Position.Sub(pos)
+	if math.Hypot(off.X+d.GNSS.Error().X, off.Z+d.GNSS.Error().Z) > 0.5 || math.Hypot(off.X, off.Z) < 0.05 {
+		t.Fatalf("truth %+v vs navigation %+v, receiver error %+v", d.Position, pos, d.GNSS.Error())
+	}
+
+	// Losing the receiver loses the position and the failsafe lands
+	d.GNSS.Outages = []sim.GNSSOutage{{}}
+	events := step(d, 3)
+	if !d.PositionLost || !hasEvent(events, "failsafe.trigger") || d.Failsafe.Active() != sim.FailsafePositionLoss {
+		t.Fatalf("lost %v, failsafe %v", d.PositionLost, d.Failsafe.Active())
+	}
+}
+
+func horizontalLen(v sim.Vec3) float64 { return math.Hypot(v.X, v.Z) }
@@ -0,0 +1,95 @@
+package sim_test
+
+import (
+	"math"
+	"testing"
+
+	sim "drone-simulator/internal/sim"
+)
+
+// imuStats steps d for seconds and returns the mean and standard deviation
+// of the accelerometer and gyro samples seen at the end of each step.
+func imuStats(d *sim.Drone, seconds float64) (accelMean, accelStd, gyroMean sim.Vec3) {
+	var sum, sq, gsum sim.Vec3
+	n := int(seconds / fsDt)
+	for i := 0; i < n; i++ {
+		d.Update(fsDt)
+		s := d.IMU.Sample()
+		sum = sum.Add(s.Accel)
+		sq = sq.Add(sim.Vec3{X: s.Accel.X * s.Accel.X, Y: s.Accel.Y * s.Accel.Y, Z: s.Accel.Z * s.Accel.Z})
+		gsum = gsum.Add(s.Gyro)
+	}
+	accelMean = sum.Mul(1 / float64(n))
+	v := sq.Mul(1 / float64(n)).Sub(sim.Vec3{X: accelMean.X * accelMean.X, Y: accelMean.Y * accelMean.Y, Z: accelMean.Z * accelMean.Z})
+	return accelMean, sim.Vec3{X: math.Sqrt(v.X), Y: math.Sqrt(v.Y), Z: math.Sqrt(v.Z)}, gsum.Mul(1 / float64(n))
+}
+
+func TestIMUAtRestReadsGravityWithNoiseAndBias(t *testing.T) {
+	d := sim.NewDrone()
+	step(d, 1) // settle onto the ground from the spawn height
+	mean, std, gyro := imuStats(d, 5)
+	if math.Abs(mean.Y-9.81) > 0.2 || math.Abs(mean.X) > 0.2 || math.Abs(mean.Z) > 0.2 {
+		t.Fatalf("mean specific force at rest %+v", mean)
+	}
+	for _, s := range []float64{std.X, std.Y, std.Z} {
+		if math.Abs(s-d.IMU.AccelNoise) > 0.2*d.IMU.AccelNoise {
+			t.Fatalf("accel noise %+v, want %.3f", std, d.IMU.AccelNoise)
+		}
+	}
+	_, gyroBias := d.IMU.Biases()
+	if gyro.Sub(gyroBias).Length() > 0.002 || gyroBias.Length() == 0 {
+		t.Fatalf("gyro mean %+v, bias %+v", gyro, gyroBias)
+	}
+	if s := d.IMU.Sample(); math.Abs(float64(s.Count)-400*s.Time) > 1e-6 || s.Time < 5.99 {
+		t.Fatalf("%d samples by %.4f s at 400 Hz", s.Count, s.Time)
+	}
+}
+
+func TestIMUIsReproduciblePerSeed(t *testing.T) {
+	a, b, c := sim.NewDrone(), sim.NewDrone(), sim.NewDrone()
+	c.Seed = 2
+	step(a, 1)
+	step(b, 1)
+	step(c, 1)
+	if a.IMU.Sample() != b.IMU.Sample() {
+		t.Fatalf("same seed, different samples: %+v vs %+v", a.IMU.Sample(), b.IMU.Sample())
+	}
+	if a.IMU.Sample().Accel == c.IMU.Sample().Accel {
+		t.Fatal("different seeds gave the same noise")
+	}
+}
+
+func TestIMUScaleMisalignmentAndSaturation(t *testing.T) {
+	d := sim.NewDrone()
+	d.IMU = sim.IMU{Rate: 100, AccelScale: sim.Vec3{Y: 0.02}, AccelAlign: sim.Vec3{Z: 0.01}}
+	step(d, 0.5)
+	s := d.IMU.Sample()
+	// Misalignment about Z leaks vertical gravity into X: (a × g).X = -0.01·9.81
+	if math.Abs(s.Accel.Y-9.81*1.02) > 1e-6 || math.Abs(s.Accel.X+0.0981) > 1e-6 || s.Saturated {
+		t.Fatalf("accel %+v", s.Accel)
+	}
+	d.IMU.AccelRange = 5
+	step(d, 0.1)
+	if s := d.IMU.Sample(); s.Accel.Y != 5 || !s.Saturated {
+		t.Fatalf("not clipped at 5 m/s²: %+v", s)
+	}
+}
+
+func TestIMUVibrationFollowsRotorsAndImbalance(t *testing.T) {
+	d := airborne(t)
+	_, smooth, _ := imuStats(d, 2)
+	if v := d.IMU.Sample().Vibration; v <= 0 || v > 1 {
+		t.Fatalf("balanced rotors vibrate %.2f m/s²", v)
+	}
+	d.Engines[2].Imbalance = 0.5
+	_, shaky, _ := imuStats(d, 2)
+	if shaky.X < 3*smooth.X || shaky.Z < 3*smooth.Z {
+		t.Fatalf("imbalance did not shake the frame: std %+v before, %+v after", smooth, shaky)
+	}
+
+	d.Disarm()
+	step(d, 2)
+	if v := d.IMU.Sample().Vibration; v > 0.01 {
+		t.Fatalf("stopped rotors still vibrate %.3f m/s²", v)
+	}
+}
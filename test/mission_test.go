@@ -0,0 +1,113 @@
+package sim_test
+
+import (
+	sim "drone-simulator/internal/sim"
+	"math"
+	"testing"
+)
+
+func runMission(d *sim.Drone, seconds float64) {
+	dt := 1.0 / 120
+	for i := 0; i < int(seconds/dt); i++ {
+		d.Update(dt)
+		if d.Mission != nil && d.Mission.State == sim.MissionComplete {
+			return
+		}
+	}
+}
+
+// TestMissionWaypointsAndReturnHome flies takeoff -> waypoints -> RTH and
+// expects a gentle landing at the launch point.
+func TestMissionWaypointsAndReturnHome(t *testing.T) {
+	d := sim.NewDrone()
+	d.Arm()
+	d.SetMission(sim.NewMission([]sim.MissionItem{
+		{Type: sim.MissionItemTakeoff, Position: sim.Vec3{Y: 8}},
+		{Type: sim.MissionItemWaypoint, Position: sim.Vec3{X: 20, Y: 8, Z: 0}},
+		{Type: sim.MissionItemWaypoint, Position: sim.Vec3{X: 20, Y: 10, Z: 15}, Speed: 6},
+		{Type: sim.MissionItemReturnHome},
+	}))
+	if !d.StartMission() {
+		t.Fatalf("mission did not start")
+	}
+	runMission(d, 120)
+
+	if d.Mission.State != sim.MissionComplete {
+		t.Fatalf("mission not complete: item %d state %v pos=%+v", d.Mission.Current, d.Mission.State, d.Position)
+	}
+	if dist := math.Hypot(d.Position.X-d.Home.X, d.Position.Z-d.Home.Z); dist > 1.5 {
+		t.Fatalf("landed %.2fm from home", dist)
+	}
+	if d.IsArmed || d.Position.Y > 0.1 {
+		t.Fatalf("expected disarmed on ground, armed=%v pos=%+v", d.IsArmed, d.Position)
+	}
+	for i, e := range d.Engines {
+		if e.Efficiency < 0.99 {
+			t.Fatalf("engine %d damaged on landing: eff=%.2f", i, e.Efficiency)
+		}
+	}
+}
+
+func TestMissionPauseHoldsPosition(t *testing.T) {
+	d := sim.NewDrone()
+	d.Arm()
+	d.SetMission(sim.NewMission([]sim.MissionItem{
+		{Type: sim.MissionItemTakeoff, Position: sim.Vec3{Y: 6}},
+		{Type: sim.MissionItemWaypoint, Position: sim.Vec3{X: 60, Y: 6, Z: 0}},
+	}))
+	d.StartMission()
+	runMission(d, 8)
+	if !d.PauseMission() {
+		t.Fatalf("pause rejected")
+	}
+	// Let the drone brake, then check it stays put
+	runMission(d, 4)
+	held := d.Position
+	runMission(d, 5)
+	if drift := d.Position.Sub(held).Length(); drift > 0.5 {
+		t.Fatalf("paused drone drifted %.2fm", drift)
+	}
+	if !d.ResumeMission() {
+		t.Fatalf("resume rejected")
+	}
+	runMission(d, 30)
+	if d.Mission.State != sim.MissionComplete {
+		t.Fatalf("mission did not complete after resume: item %d", d.Mission.Current)
+	}
+}
+
+func TestMissionProgressAndPayload(t *testing.T) {
+	d := sim.NewDrone()
+	d.MaxTakeoffMass = d.Mass + 0.05
+	if !d.AttachPayload(0.05) {
+		t.Fatalf("payload within MTOM rejected")
+	}
+	d.Arm()
+	d.SetMission(sim.NewMission([]sim.MissionItem{
+		{Type: sim.MissionItemTakeoff, Position: sim.Vec3{Y: 5}},
+		{Type: sim.MissionItemWaypoint, Position: sim.Vec3{X: 10, Y: 5, Z: 0}},
+		{Type: sim.MissionItemReleasePayload},
+		{Type: sim.MissionItemLoiterTime, Position: sim.Vec3{X: 10, Y: 5, Z: 0}, LoiterTime: 2},
+	}))
+	d.StartMission()
+
+	p := d.MissionProgress()
+	if p.Total != 4 || p.Current != 0 {
+		t.Fatalf("unexpected progress %+v", p)
+	}
+	// 5m climb + 10m leg at the default speed plus the loiter
+	if p.DistanceRemaining < 14.5 || p.DistanceRemaining > 15.5 {
+		t.Fatalf("distance remaining = %.2f, want ~15", p.DistanceRemaining)
+	}
+	if p.ETA < 4.5 {
+		t.Fatalf("ETA too small: %.2f", p.ETA)
+	}
+
+	runMission(d, 40)
+	if d.Mission.State != sim.MissionComplete {
+		t.Fatalf("mission not complete: item %d", d.Mission.Current)
+	}
+	if d.PayloadMass != 0 {
+		t.Fatalf("payload not released")
+	}
+}
@@ -0,0 +1,153 @@
+package sim_test
+
+import (
+	sim "drone-simulator/internal/sim"
+	"math"
+	"testing"
+)
+
+const samplePlan = `{
+    "fileType": "Plan",
+    "geoFence": {"circles": [], "polygons": [], "version": 2},
+    "groundStation": "QGroundControl",
+    "mission": {
+        "cruiseSpeed": 15,
+        "firmwareType": 12,
+        "hoverSpeed": 6,
+        "items": [
+            {"autoContinue": true, "command": 22, "doJumpId": 1, "frame": 3,
+             "params": [0, 0, 0, null, 47.3977419, 8.5455938, 12], "type": "SimpleItem"},
+            {"autoContinue": true, "command": 16, "doJumpId": 2, "frame": 3,
+             "params": [0, 2, 0, 90, 47.3980, 8.5455938, 12], "type": "SimpleItem"},
+            {"autoContinue": true, "command": 178, "doJumpId": 3, "frame": 2,
+             "params": [1, 8, -1, 0, 0, 0, 0], "type": "SimpleItem"},
+            {"autoContinue": true, "command": 19, "doJumpId": 4, "frame": 3,
+             "params": [5, 0, 0, 1, 47.3980, 8.5460, 15], "type": "SimpleItem"},
+            {"autoContinue": true, "command": 20, "doJumpId": 5, "frame": 2,
+             "params": [0, 0, 0, 0, 0, 0, 0], "type": "SimpleItem"}
+        ],
+        "plannedHomePosition": [47.3977419, 8.5455938, 488],
+        "vehicleType": 2,
+        "version": 2
+    },
+    "rallyPoints": {"points": [], "version": 2},
+    "version": 1
+}`
+
+func TestParsePlan(t *testing.T) {
+	m, origin, err := sim.ParsePlan([]byte(samplePlan), nil)
+	if err != nil {
+		t.Fatalf("ParsePlan: %v", err)
+	}
+	if origin.Lat != 47.3977419 || origin.Alt != 488 {
+		t.Fatalf("origin should default to home, got %+v", origin)
+	}
+	if len(m.Items) != 5 || m.CruiseSpeed != 6 {
+		t.Fatalf("got %d items, cruise %.1f", len(m.Items), m.CruiseSpeed)
+	}
+	want := []sim.MissionItemType{sim.MissionItemTakeoff, sim.MissionItemWaypoint, sim.MissionItemChangeSpeed, sim.MissionItemLoiterTime, sim.MissionItemReturnHome}
+	for i, typ := range want {
+		if m.Items[i].Type != typ {
+			t.Fatalf("item %d type = %v, want %v", i, m.Items[i].Type, typ)
+		}
+	}
+	if y := m.Items[0].Position.Y; y != 12 {
+		t.Fatalf("takeoff altitude = %.2f", y)
+	}
+	// 0.0002581 deg of latitude north is ~28.7 m along -Z
+	wp := m.Items[1]
+	if math.Abs(wp.Position.Z+28.7) > 0.2 || math.Abs(wp.Position.X) > 0.01 {
+		t.Fatalf("waypoint local position = %+v", wp.Position)
+	}
+	if !wp.HoldYaw || math.Abs(sim.YawToHeading(wp.Yaw)-90) > 1e-9 || wp.AcceptRadius != 2 {
+		t.Fatalf("waypoint yaw/accept = %v %.3f %.1f", wp.HoldYaw, wp.Yaw, wp.AcceptRadius)
+	}
+	// East of home is +X
+	if lt := m.Items[3]; lt.Position.X < 30 || lt.LoiterTime != 5 || lt.Position.Y != 15 {
+		t.Fatalf("loiter item = %+v", lt)
+	}
+	if m.Items[2].Speed != 8 {
+		t.Fatalf("change speed = %.1f", m.Items[2].Speed)
+	}
+}
+
+func TestParsePlanRejectsUnsupportedCommand(t *testing.T) {
+	bad := `{"fileType":"Plan","mission":{"plannedHomePosition":[0,0,0],"items":[
+		{"type":"SimpleItem","command":183,"frame":2,"params":[0,0,0,0,0,0,0]}]}}`
+	if _, _, err := sim.ParsePlan([]byte(bad), nil); err == nil {
+		t.Fatalf("expected error for DO_SET_SERVO")
+	}
+}
+
+func TestParseWPL(t *testing.T) {
+	wpl := "QGC WPL 110\n" +
+		"0\t1\t0\t16\t0\t0\t0\t0\t-35.3632621\t149.1652374\t584.0\t1\n" +
+		"1\t0\t3\t22\t0\t0\t0\tnan\t0\t0\t20\t1\n" +
+		"2\t0\t3\t16\t3\t0\t0\t0\t-35.3641\t149.1652374\t20\t1\n" +
+		"3\t0\t3\t21\t0\t0\t0\t0\t0\t0\t0\t1\n"
+	origin := sim.GeoPoint{Lat: -35.3632621, Lon: 149.1652374, Alt: 580}
+	m, used, err := sim.ParseWPL([]byte(wpl), &origin)
+	if err != nil {
+		t.Fatalf("ParseWPL: %v", err)
+	}
+	if used != origin || len(m.Items) != 3 {
+		t.Fatalf("origin %+v, %d items", used, len(m.Items))
+	}
+	// Home is 4 m above the given origin, so relative altitudes shift up
+	if to := m.Items[0]; math.Abs(to.Position.Y-24) > 1e-6 || to.HoldYaw {
+		t.Fatalf("takeoff item = %+v", to)
+	}
+	// A waypoint hold time becomes a timed loiter; south is +Z and a yaw of
+	// 0 asks for north
+	hold := m.Items[1]
+	if hold.Type != sim.MissionItemLoiterTime || hold.LoiterTime != 3 || hold.Position.Z < 90 || !hold.HoldYaw || sim.YawToHeading(hold.Yaw) != 0 {
+		t.Fatalf("hold item = %+v", hold)
+	}
+	if land := m.Items[2]; land.Type != sim.MissionItemLand || !land.InPlace {
+		t.Fatalf("land item = %+v", land)
+	}
+}
+
+func TestExportPlanRoundTrip(t *testing.T) {
+	origin := sim.GeoPoint{Lat: 52.0, Lon: 4.3, Alt: 10}
+	m := sim.NewMission([]sim.MissionItem{
+		{Type: sim.MissionItemTakeoff, Position: sim.Vec3{Y: 10}},
+		{Type: sim.MissionItemWaypoint, Position: sim.Vec3{X: 40, Y: 10, Z: -25}, AcceptRadius: 1.5, Yaw: 0.5, HoldYaw: true},
+		{Type: sim.MissionItemLoiterTurns, Position: sim.Vec3{X: 40, Y: 12, Z: 0}, LoiterTurns: 2, LoiterRadius: 6},
+		{Type: sim.MissionItemReleasePayload},
+		{Type: sim.MissionItemLand, Position: sim.Vec3{X: 5, Z: 5}},
+	})
+	m.CruiseSpeed = 7
+	data, err := sim.ExportPlan(m, origin, sim.Vec3{Y: 0.05})
+	if err != nil {
+		t.Fatalf("ExportPlan: %v", err)
+	}
+	back, _, err := sim.ParsePlan(data, &origin)
+	if err != nil {
+		t.Fatalf("re-import: %v\n%s", err, data)
+	}
+	if len(back.Items) != len(m.Items) || back.CruiseSpeed != 7 {
+		t.Fatalf("round trip changed the mission: %d items, cruise %.1f", len(back.Items), back.CruiseSpeed)
+	}
+	for i := range m.Items {
+		a, b := m.Items[i], back.Items[i]
+		if a.Type != b.Type {
+			t.Fatalf("item %d type %v -> %v", i, a.Type, b.Type)
+		}
+		if a.Type == sim.MissionItemReleasePayload {
+			continue
+		}
+		if d := a.Position.Sub(b.Position); a.Type != sim.MissionItemTakeoff && math.Hypot(d.X, d.Z) > 0.01 {
+			t.Fatalf("item %d moved by %+v", i, d)
+		}
+		if math.Abs(a.Position.Y-b.Position.Y) > 1e-6 {
+			t.Fatalf("item %d altitude %.2f -> %.2f", i, a.Position.Y, b.Position.Y)
+		}
+	}
+	if wp := back.Items[1]; !wp.HoldYaw || math.Abs(wp.Yaw-0.5) > 1e-9 || wp.AcceptRadius != 1.5 {
+		t.Fatalf("waypoint yaw/accept lost: %+v", wp)
+	}
+	if lt := back.Items[2]; lt.LoiterTurns != 2 || lt.LoiterRadius != 6 {
+		t.Fatalf("loiter turns lost: %+v", lt)
+	}
+}
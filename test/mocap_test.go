@@ -0,0 +1,213 @@
+package sim_test
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	sim "drone-simulator/internal/sim"
+	"drone-simulator/systems/mocap"
+)
+
+// mocapPoses is a fixed source: drone 0 level at (1, 2, 3) turned 90°
+// clockwise (nose west), drone 1 at the origin.
+func mocapPoses() []mocap.Pose {
+	return []mocap.Pose{
+		{Position: sim.Vec3{X: 1, Y: 2, Z: 3}, Rotation: sim.Vec3{Y: 90 * deg}},
+		{},
+	}
+}
+
+func startMocap(t *testing.T, cfg mocap.Config) *mocap.Server {
+	t.Helper()
+	s, err := mocap.New(cfg, mocapPoses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Start()
+	t.Cleanup(s.Stop)
+	return s
+}
+
+func receive(t *testing.T, c *mocap.Client, n int) []mocap.Frame {
+	t.Helper()
+	var out []mocap.Frame
+	for len(out) < n {
+		f, _, err := c.Next(2 * time.Second)
+		if err != nil {
+			t.Fatalf("after %d frames: %v", len(out), err)
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+func quatNear(q [4]float64, want [4]float64) bool {
+	for i := range q {
+		if math.Abs(q[i]-want[i]) > 1e-3 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMocapNatNetUnicast(t *testing.T) {
+	s := startMocap(t, mocap.Config{Format: mocap.FormatNatNet, Command: "127.0.0.1:0", Rate: 200})
+	c, err := mocap.Listen(mocap.FormatNatNet, "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Connect(s.CommandAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+
+	frames := receive(t, c, 20)
+	if c.Server == "" {
+		t.Fatalf("no server info in reply to the connect")
+	}
+	for i := 1; i < len(frames); i++ {
+		if frames[i].Number <= frames[i-1].Number || frames[i].Time <= frames[i-1].Time {
+			t.Fatalf("frames out of order: %+v then %+v", frames[i-1], frames[i])
+		}
+	}
+	f := frames[len(frames)-1]
+	if len(f.Bodies) != 2 || f.Bodies[0].ID != 1 || f.Bodies[1].Name != "drone1" {
+		t.Fatalf("bodies %+v", f.Bodies)
+	}
+	// Y up is the simulator's frame; a clockwise turn is -90° about +Y
+	b := f.Bodies[0]
+	h := math.Sqrt(0.5)
+	if b.Position.Sub(sim.Vec3{X: 1, Y: 2, Z: 3}).Length() > 1e-5 || !b.Tracked || !quatNear(b.Orientation, [4]float64{0, -h, 0, h}) {
+		t.Fatalf("drone0 %+v", b)
+	}
+	if !quatNear(f.Bodies[1].Orientation, [4]float64{0, 0, 0, 1}) {
+		t.Fatalf("level drone at yaw 0 at %v, want identity", f.Bodies[1].Orientation)
+	}
+}
+
+func TestMocapVRPNZUp(t *testing.T) {
+	s := startMocap(t, mocap.Config{Format: mocap.FormatVRPN, Up: mocap.ZUp, Command: "127.0.0.1:0", Rate: 200})
+	c, err := mocap.DialVRPN("127.0.0.1:0", s.CommandAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	f := receive(t, c, 5)[4]
+	if len(f.Bodies) != 2 || f.Bodies[0].Name != "drone0" {
+		t.Fatalf("bodies %+v", f.Bodies)
+	}
+	if math.Abs(f.Time-float64(time.Now().UnixNano())/1e9) > 5 {
+		t.Fatalf("timestamp %.3f not wall-clock", f.Time)
+	}
+	// Z up is east-north-up, so 3 m south is -3 on Y; clockwise from
+	// above is -90° about +Z
+	b := f.Bodies[0]
+	h := math.Sqrt(0.5)
+	if b.Position.Sub(sim.Vec3{X: 1, Y: -3, Z: 2}).Length() > 1e-9 || !quatNear(b.Orientation, [4]float64{0, 0, -h, h}) {
+		t.Fatalf("drone0 %+v", b)
+	}
+}
+
+func TestMocapVRPNHandshake(t *testing.T) {
+	s := startMocap(t, mocap.Config{Format: mocap.FormatVRPN, Command: "127.0.0.1:0", Rate: 200})
+	port := s.CommandAddr().(*net.TCPAddr).Port
+
+	// A client lobs "host port" at the UDP port; the server connects back
+	// and both sides send their cookie
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	lob, err := net.Dial("udp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lob.Close()
+	fmt.Fprintf(lob, "127.0.0.1 %d\x00", ln.Addr().(*net.TCPAddr).Port)
+	ln.(*net.TCPListener).SetDeadline(time.Now().Add(2 * time.Second))
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("no connection back after the lob: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	cookie := make([]byte, 24)
+	if _, err := io.ReadFull(conn, cookie); err != nil || !strings.HasPrefix(string(cookie), "vrpn: ver. 07.35") {
+		t.Fatalf("cookie %q, %v", cookie, err)
+	}
+	// Without a UDP description the reports come over TCP
+	copy(cookie, "vrpn: ver. 07.35  0")
+	conn.Write(cookie)
+	d := mocap.NewVRPNDecoder()
+	for reports := 0; reports == 0; {
+		msg := make([]byte, 24)
+		if _, err := io.ReadFull(conn, msg); err != nil {
+			t.Fatalf("no reports over TCP: %v", err)
+		}
+		n := int(binary.BigEndian.Uint32(msg))
+		msg = append(msg, make([]byte, (n+7)/8*8-24)...)
+		if _, err := io.ReadFull(conn, msg[24:]); err != nil {
+			t.Fatalf("short message: %v", err)
+		}
+		f, err := d.Decode(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reports += len(f.Bodies)
+	}
+
+	// A client with another major version is turned away
+	bad, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bad.Close()
+	bad.SetDeadline(time.Now().Add(2 * time.Second))
+	bad.Write(append([]byte("vrpn: ver. 06.00  0"), 0, 0, 0, 0, 0))
+	io.ReadFull(bad, cookie)
+	if _, err := bad.Read(cookie); err == nil {
+		t.Fatal("connection with a version 6 cookie kept open")
+	}
+}
+
+func TestMocapDropoutsAndOcclusion(t *testing.T) {
+	c, err := mocap.Listen(mocap.FormatNatNet, "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	startMocap(t, mocap.Config{
+		Format: mocap.FormatNatNet, Dest: c.LocalAddr().String(), Rate: 250,
+		Jitter: time.Millisecond, Dropout: 0.3, Occlusion: 0.5, Seed: 7,
+	})
+
+	frames := receive(t, c, 150)
+	span := frames[len(frames)-1].Number - frames[0].Number + 1
+	lost := 1 - float64(len(frames))/float64(span)
+	untracked := 0
+	for _, f := range frames {
+		for _, b := range f.Bodies {
+			if !b.Tracked {
+				untracked++
+			}
+		}
+	}
+	if lost < 0.15 || lost > 0.45 {
+		t.Fatalf("%.0f%% of frames lost, want about 30%%", lost*100)
+	}
+	if share := float64(untracked) / float64(2*len(frames)); share < 0.35 || share > 0.65 {
+		t.Fatalf("%.0f%% of body reports untracked, want about 50%%", share*100)
+	}
+
+	if _, err := mocap.New(mocap.Config{Rate: 100, Jitter: 20 * time.Millisecond}, mocapPoses); err == nil {
+		t.Fatalf("jitter beyond the frame period accepted")
+	}
+}
@@ -0,0 +1,105 @@
+package sim_test
+
+import (
+	"math"
+	"testing"
+
+	sim "drone-simulator/internal/sim"
+)
+
+// orbitStats flies for seconds and returns the worst radius and altitude
+// error seen after settle seconds, the worst nose-to-centre angle and the
+// total angle swept around the centre.
+func orbitStats(d *sim.Drone, center sim.Vec3, radius, altitude, settle, seconds float64) (rErr, yErr, yawErr, swept float64) {
+	last := math.Atan2(d.Position.Z-center.Z, d.Position.X-center.X)
+	for i := 0; i < int(seconds/fsDt); i++ {
+		d.Update(fsDt)
+		a := math.Atan2(d.Position.Z-center.Z, d.Position.X-center.X)
+		swept += math.Remainder(a-last, 2*math.Pi)
+		last = a
+		if float64(i)*fsDt < settle {
+			continue
+		}
+		r := math.Hypot(d.Position.X-center.X, d.Position.Z-center.Z)
+		rErr = math.Max(rErr, math.Abs(r-radius))
+		yErr = math.Max(yErr, math.Abs(d.Position.Y-altitude))
+		// Nose is body +Z: (-sin(yaw), cos(yaw)) in X/Z
+		want := math.Atan2(-(center.X - d.Position.X), center.Z-d.Position.Z)
+		yawErr = math.Max(yawErr, math.Abs(math.Remainder(d.Rotation.Y-want, 2*math.Pi)))
+	}
+	return rErr, yErr, yawErr, swept
+}
+
+func TestOrbitCirclesPointFacingCentre(t *testing.T) {
+	d := airborne(t)
+	center := sim.Vec3{X: 12, Y: 0, Z: 0}
+	if err := d.StartOrbit(sim.OrbitConfig{Center: center, Radius: 8, Altitude: 12, Speed: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if d.FlightMode != sim.FlightModeOrbit {
+		t.Fatalf("mode = %v after StartOrbit", d.FlightMode)
+	}
+	rErr, yErr, yawErr, swept := orbitStats(d, center, 8, 12, 12, 40)
+	if rErr > 0.5 || yErr > 0.5 {
+		t.Fatalf("orbit error: radius %.2f m, altitude %.2f m", rErr, yErr)
+	}
+	if yawErr > 5*math.Pi/180 {
+		t.Fatalf("nose strayed %.1f° from the centre", yawErr*180/math.Pi)
+	}
+	// 3 m/s on an 8 m circle is 0.375 rad/s clockwise; allow the
+	// transit in and speed-up
+	if swept < 10 {
+		t.Fatalf("swept only %.1f rad in 40 s", swept)
+	}
+}
+
+func TestOrbitStableInWind(t *testing.T) {
+	d := airborne(t)
+	d.WindVelocity = sim.Vec3{X: 5, Z: -2}
+	center := sim.Vec3{X: 0, Y: 0, Z: 10}
+	if err := d.StartOrbit(sim.OrbitConfig{Center: center, Radius: 10, Speed: -4}); err != nil {
+		t.Fatal(err)
+	}
+	rErr, _, _, swept := orbitStats(d, center, 10, 10, 20, 60)
+	if rErr > 1.0 {
+		t.Fatalf("wind pushed the orbit %.2f m off radius", rErr)
+	}
+	if swept > -10 {
+		t.Fatalf("counter-clockwise orbit swept %.1f rad", swept)
+	}
+}
+
+func TestOrbitLiveAdjustAndSpeedLimit(t *testing.T) {
+	d := airborne(t)
+	if err := d.StartOrbit(sim.OrbitConfig{Center: sim.Vec3{X: 10}, Radius: 2, Speed: 10}); err != nil {
+		t.Fatal(err)
+	}
+	st, ok := d.OrbitStatus()
+	if !ok {
+		t.Fatal("no orbit status")
+	}
+	// v²/r must stay within 0.7 of the 3.5 m/s² lateral budget
+	if want := math.Sqrt(0.7 * 3.5 * 2); math.Abs(st.EffectiveSpeed-want) > 1e-9 {
+		t.Fatalf("effective speed %.2f, want %.2f", st.EffectiveSpeed, want)
+	}
+
+	alt := st.Altitude
+	d.SetOrbitSticks(0, 1, 1)
+	step(d, 2)
+	d.SetOrbitSticks(0, 0, 0)
+	st, _ = d.OrbitStatus()
+	if math.Abs(st.Radius-8) > 0.1 || math.Abs(st.Altitude-alt-3) > 0.1 {
+		t.Fatalf("after 2 s of stick: radius %.2f, altitude %.2f", st.Radius, st.Altitude)
+	}
+	cfg := st.OrbitConfig
+	cfg.Speed = -2
+	d.SetOrbit(cfg)
+	if st, _ = d.OrbitStatus(); st.EffectiveSpeed != -2 {
+		t.Fatalf("speed update ignored: %.2f", st.EffectiveSpeed)
+	}
+
+	d.Disarm()
+	if err := d.StartOrbit(sim.OrbitConfig{Center: sim.Vec3{}}); err == nil {
+		t.Fatal("orbit started on a disarmed drone")
+	}
+}
@@ -0,0 +1,196 @@
+package sim_test
+
+import (
+	"errors"
+	"testing"
+
+	sim "drone-simulator/internal/sim"
+)
+
+// wallWorld has a 30 m wide, 15 m tall wall across the X axis at x=20.
+func wallWorld() *sim.World {
+	w := sim.NewWorld()
+	w.AddObstacle(sim.Obstacle{Name: "wall", Min: sim.Vec3{X: 19, Y: 0, Z: -15}, Max: sim.Vec3{X: 21, Y: 15, Z: 15}})
+	return w
+}
+
+// minClearance samples every leg of path and returns the smallest clearance.
+func minClearance(w *sim.World, path []sim.Vec3) float64 {
+	best := w.Clearance(path[0])
+	for i := 1; i < len(path); i++ {
+		d := path[i].Sub(path[i-1])
+		n := int(d.Length()/0.05) + 1
+		for s := 0; s <= n; s++ {
+			if c := w.Clearance(path[i-1].Add(d.Mul(float64(s) / float64(n)))); c < best {
+				best = c
+			}
+		}
+	}
+	return best
+}
+
+func TestPlanPathAroundWall(t *testing.T) {
+	w := wallWorld()
+	start, goal := sim.Vec3{X: 0, Y: 10, Z: 0}, sim.Vec3{X: 40, Y: 10, Z: 0}
+	for _, alg := range []sim.PlannerAlgorithm{sim.PlanAStar, sim.PlanRRTStar} {
+		path, err := sim.PlanPath(w, start, goal, sim.PlanOptions{Algorithm: alg, Seed: 7})
+		if err != nil {
+			t.Fatalf("%v: %v", alg, err)
+		}
+		if path[0] != start || path[len(path)-1] != goal {
+			t.Fatalf("%v: path runs %v..%v", alg, path[0], path[len(path)-1])
+		}
+		if c := minClearance(w, path); c < 0.6 {
+			t.Fatalf("%v: path passes %.2f m from the wall", alg, c)
+		}
+		// Shortcutting leaves only a handful of corner points
+		if len(path) > 6 {
+			t.Fatalf("%v: %d points after shortcutting", alg, len(path))
+		}
+		length := 0.0
+		for i := 1; i < len(path); i++ {
+			length += path[i].Sub(path[i-1]).Length()
+		}
+		// Over the top (5 m up, 5 m down) is ~43 m; allow RRT* some slack
+		if length > 55 {
+			t.Fatalf("%v: path is %.1f m long", alg, length)
+		}
+	}
+}
+
+func TestPlanPathOpenSpaceIsStraight(t *testing.T) {
+	path, err := sim.PlanPath(sim.NewWorld(), sim.Vec3{Y: 5}, sim.Vec3{X: 30, Y: 8, Z: -20}, sim.PlanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(path) != 2 {
+		t.Fatalf("open space path has %d points", len(path))
+	}
+}
+
+func TestPlanPathRRTStarDeterministic(t *testing.T) {
+	w := wallWorld()
+	opts := sim.PlanOptions{Algorithm: sim.PlanRRTStar, Seed: 3}
+	a, errA := sim.PlanPath(w, sim.Vec3{Y: 10}, sim.Vec3{X: 40, Y: 10}, opts)
+	b, errB := sim.PlanPath(w, sim.Vec3{Y: 10}, sim.Vec3{X: 40, Y: 10}, opts)
+	if errA != nil || errB != nil {
+		t.Fatalf("plan failed: %v / %v", errA, errB)
+	}
+	if len(a) != len(b) {
+		t.Fatalf("same seed gave %d and %d points", len(a), len(b))
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("same seed diverged at point %d: %v vs %v", i, a[i], b[i])
+		}
+	}
+}
+
+func TestPlanPathTerrainAndBlockedGoal(t *testing.T) {
+	// A 20 m ridge along Z between start and goal
+	h := &sim.HeightMap{Origin: sim.Vec3{X: -10, Z: -40}, Spacing: 5, Cols: 13, Rows: 17}
+	h.Heights = make([]float64, h.Cols*h.Rows)
+	for r := 0; r < h.Rows; r++ {
+		h.Heights[r*h.Cols+6] = 20 // x = 20
+	}
+	w := sim.NewWorld()
+	w.SetTerrain(h)
+	path, err := sim.PlanPath(w, sim.Vec3{Y: 5}, sim.Vec3{X: 40, Y: 5}, sim.PlanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := minClearance(w, path); c < 0.6 {
+		t.Fatalf("path passes %.2f m above the ridge", c)
+	}
+
+	w.AddObstacle(sim.Obstacle{Name: "block", Min: sim.Vec3{X: 38, Y: 0, Z: -2}, Max: sim.Vec3{X: 42, Y: 8, Z: 2}})
+	if _, err := sim.PlanPath(w, sim.Vec3{Y: 5}, sim.Vec3{X: 40, Y: 5}, sim.PlanOptions{}); !errors.Is(err, sim.ErrGoalBlocked) {
+		t.Fatalf("goal inside an obstacle: err=%v", err)
+	}
+}
+
+// TestGotoPlannedReplansAroundNewObstacle drops a wall across the route
+// mid-flight; the drone must re-plan and still reach the goal without
+// touching it.
+func TestGotoPlannedReplansAroundNewObstacle(t *testing.T) {
+	d := airborne(t)
+	d.World = sim.NewWorld()
+	goal := sim.Vec3{X: 40, Y: 10, Z: 0}
+	path, err := d.GotoPlanned(goal, 4, sim.PlanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(path) != 2 {
+		t.Fatalf("empty world plan has %d points", len(path))
+	}
+	events := step(d, 2)
+
+	d.World.AddObstacle(sim.Obstacle{Name: "wall", Min: sim.Vec3{X: 19, Y: 0, Z: -15}, Max: sim.Vec3{X: 21, Y: 15, Z: 15}})
+	closest := d.World.Clearance(d.Position)
+	for i := 0; i < 40*120 && d.MissionProgress().State != sim.MissionComplete; i++ {
+		d.Update(fsDt)
+		events = append(events, d.TakeEvents()...)
+		if c := d.World.Clearance(d.Position); c < closest {
+			closest = c
+		}
+	}
+	if !hasEvent(events, "plan.replan") {
+		t.Fatalf("no plan.replan event after the wall appeared")
+	}
+	if closest < 0.15 {
+		t.Fatalf("drone came within %.2f m of the wall", closest)
+	}
+	if dist := d.Position.Sub(goal).Length(); dist > 1.5 {
+		t.Fatalf("drone stopped %.1f m from the goal", dist)
+	}
+}
+
+// TestPlanRouteInflatesByDroneSize plans around the wall outside any lock:
+// a larger airframe must keep a wider berth.
+func TestPlanRouteInflatesByDroneSize(t *testing.T) {
+	d := airborne(t)
+	d.World = wallWorld()
+	d.Dimensions = sim.Vec3{X: 2.4, Y: 2.4, Z: 0.5}
+	plan := d.PlanRoute(sim.Vec3{X: 40, Y: 10}, 4, sim.PlanOptions{})
+	if err := plan.Search(); err != nil {
+		t.Fatal(err)
+	}
+	if c := minClearance(d.World, plan.Path[1:]); c < 1.9 {
+		t.Fatalf("path keeps %.2f m from the wall, want the 2.2 m half diagonal plus margin", c)
+	}
+	if err := d.FlyRoute(plan); err != nil || d.PlannedPath() == nil {
+		t.Fatalf("route not flown: %v", err)
+	}
+}
+
+// TestGotoPlannedReplanKeepsOperatorPause changes the world twice: an
+// obstacle off the route must not stop the drone, and a pause the operator
+// gives while a blocked route is re-planned must outlast the re-plan.
+func TestGotoPlannedReplanKeepsOperatorPause(t *testing.T) {
+	d := airborne(t)
+	d.World = sim.NewWorld()
+	if _, err := d.GotoPlanned(sim.Vec3{X: 40, Y: 10}, 4, sim.PlanOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	step(d, 1)
+
+	d.World.AddObstacle(sim.Obstacle{Name: "aside", Min: sim.Vec3{X: 10, Y: 0, Z: 20}, Max: sim.Vec3{X: 12, Y: 15, Z: 22}})
+	d.Update(fsDt)
+	if st := d.MissionProgress().State; st != sim.MissionRunning {
+		t.Fatalf("obstacle off the route left the mission %v", st)
+	}
+
+	d.World.AddObstacle(sim.Obstacle{Name: "wall", Min: sim.Vec3{X: 19, Y: 0, Z: -15}, Max: sim.Vec3{X: 21, Y: 15, Z: 15}})
+	d.Update(fsDt)
+	if st := d.MissionProgress().State; st != sim.MissionPaused {
+		t.Fatalf("blocked route not held while re-planning: %v", st)
+	}
+	d.PauseMission()
+	events := step(d, 2)
+	if !hasEvent(events, "plan.replan") {
+		t.Fatalf("no plan.replan event after the wall appeared")
+	}
+	if st := d.MissionProgress().State; st != sim.MissionPaused {
+		t.Fatalf("re-plan overrode the operator's pause: %v", st)
+	}
+}
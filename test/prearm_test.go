@@ -0,0 +1,82 @@
+package sim_test
+
+import (
+	"testing"
+
+	sim "drone-simulator/internal/sim"
+)
+
+func failedChecks(r sim.ArmResult) map[sim.PreArmCheck]bool {
+	m := map[sim.PreArmCheck]bool{}
+	for _, f := range r.Failures {
+		m[f.Check] = true
+	}
+	return m
+}
+
+func TestArmReportsEveryFailingCheck(t *testing.T) {
+	d := sim.NewDrone()
+	d.BatteryPercent = d.CriticalBattery
+	d.Rotation.Z = 0.6
+	d.Engines[2].Functional = false
+	d.PositionLost = true
+	d.Calibration.Compass = false
+	d.Geofences = []*sim.Geofence{{Name: "nofly", Shape: sim.FenceCylinder, Center: sim.Vec3{}, Radius: 10, Ceiling: 50}}
+
+	r := d.Arm()
+	if r.Armed || d.IsArmed {
+		t.Fatal("armed with failing pre-arm checks")
+	}
+	got := failedChecks(r)
+	for _, c := range []sim.PreArmCheck{sim.CheckBattery, sim.CheckVoltage, sim.CheckTilt, sim.CheckEngines, sim.CheckPosition, sim.CheckGeofence, sim.CheckCalibration} {
+		if !got[c] {
+			t.Errorf("check %v not reported in %+v", c, r.Failures)
+		}
+	}
+	if got[sim.CheckLanded] || r.Err() == nil {
+		t.Fatalf("unexpected result %+v", r)
+	}
+
+	// One event per distinct refusal
+	events := d.TakeEvents()
+	d.Arm()
+	events = append(events, d.TakeEvents()...)
+	n := 0
+	for _, e := range events {
+		if e.Kind == "arm.denied" {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Fatalf("%d arm.denied events, want 1", n)
+	}
+}
+
+func TestArmSucceedsOnceChecksPass(t *testing.T) {
+	d := sim.NewDrone()
+	d.Calibration.Gyro = false
+	if r := d.Arm(); r.Armed || len(r.Failures) != 1 || r.Failures[0].Check != sim.CheckCalibration {
+		t.Fatalf("uncalibrated gyro: %+v", r)
+	}
+	d.Calibration.Gyro = true
+	if r := d.Arm(); !r.Armed || len(r.Failures) != 0 || r.Err() != nil || !d.IsArmed {
+		t.Fatalf("healthy drone did not arm: %+v", r)
+	}
+}
+
+func TestBatteryVoltageFallsWithChargeAndLoad(t *testing.T) {
+	d := sim.NewDrone()
+	full := d.BatteryVoltage()
+	if full < 12.5 || full > 12.61 {
+		t.Fatalf("full 3S pack at %.2f V", full)
+	}
+	d.BatteryPercent = 12
+	low := d.BatteryVoltage()
+	if low >= full || low/3 >= d.PreArm.MinCellVoltage {
+		t.Fatalf("12%% pack at %.2f V, want below %.2f V/cell", low, d.PreArm.MinCellVoltage)
+	}
+	d.PowerDraw = 300
+	if sag := d.BatteryVoltage(); sag >= low {
+		t.Fatalf("no sag under load: %.2f V at rest, %.2f V loaded", low, sag)
+	}
+}
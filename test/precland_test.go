@@ -0,0 +1,153 @@
+package sim_test
+
+import (
+	"math"
+	"testing"
+
+	sim "drone-simulator/internal/sim"
+)
+
+// stepWorld advances the world and then the drone, like the simulator.
+func stepWorld(d *sim.Drone, w *sim.World, seconds float64) []sim.Event {
+	var events []sim.Event
+	for i := 0; i < int(seconds/fsDt); i++ {
+		w.Update(fsDt)
+		d.Update(fsDt)
+		events = append(events, d.TakeEvents()...)
+	}
+	return events
+}
+
+// ship returns a 6×4 m deck 3 m up, steaming south at speed and rolling.
+func ship(speed float64) *sim.Platform {
+	track := sim.NewScriptedTarget("hull", []sim.Vec3{{X: 15}, {X: 15, Z: 2000}}, speed, false)
+	p := sim.NewPlatform("ship", track, 6, 4, 3)
+	p.Heave = sim.Oscillation{Amplitude: 0.3, Period: 7}
+	p.Pitch = sim.Oscillation{Amplitude: 3 * math.Pi / 180, Period: 6}
+	p.Roll = sim.Oscillation{Amplitude: 5 * math.Pi / 180, Period: 8, Phase: 1}
+	return p
+}
+
+func TestPlatformDeckMotion(t *testing.T) {
+	p := ship(2)
+	w := sim.NewWorld()
+	w.AddPlatform(p)
+	var maxHeave, maxRate float64
+	for i := 0; i < int(10/fsDt); i++ {
+		w.Update(fsDt)
+		maxHeave = math.Max(maxHeave, math.Abs(p.Position.Y-3))
+		maxRate = math.Max(maxRate, math.Abs(p.Velocity.Y))
+	}
+	if math.Abs(maxHeave-0.3) > 0.01 || math.Abs(maxRate-0.3*2*math.Pi/7) > 0.01 {
+		t.Fatalf("heave %.3f m at up to %.3f m/s", maxHeave, maxRate)
+	}
+	if math.Abs(p.Position.Z-20) > 0.1 || math.Abs(p.Velocity.Z-2) > 1e-6 {
+		t.Fatalf("deck at %+v moving %+v after 10 s at 2 m/s", p.Position, p.Velocity)
+	}
+	// The deck is tilted: the bow and the right edge sit at different heights
+	h0, _, _ := p.Surface(p.Position.X, p.Position.Z)
+	hb, _, _ := p.Surface(p.Position.X, p.Position.Z+2.5)
+	hr, _, _ := p.Surface(p.Position.X+1.5, p.Position.Z) // heading south: deck X is world +X
+	if math.Abs(hb-h0-2.5*math.Tan(p.Rotation.X)) > 1e-6 || math.Abs(hr-h0+1.5*math.Tan(p.Rotation.Z)) > 1e-6 {
+		t.Fatalf("deck plane: centre %.3f bow %.3f right %.3f, rotation %+v", h0, hb, hr, p.Rotation)
+	}
+	if _, _, ok := p.Surface(p.Position.X+3, p.Position.Z); ok {
+		t.Fatal("point off the deck reported as on it")
+	}
+}
+
+func TestDroneRestsOnMovingDeck(t *testing.T) {
+	p := ship(2)
+	w := sim.NewWorld()
+	w.AddPlatform(p)
+	d := sim.NewDrone()
+	d.World = w
+	d.Position = p.Position.Add(sim.Vec3{Y: 0.5})
+	d.Velocity = p.Velocity
+	stepWorld(d, w, 10)
+	h, _, ok := p.Surface(d.Position.X, d.Position.Z)
+	if !ok || d.Position.Y-h > 0.1 || d.Destroyed {
+		t.Fatalf("drone at %+v fell off the deck at %+v", d.Position, p.Position)
+	}
+	if math.Abs(d.Velocity.Z-2) > 0.1 || !d.OnGround {
+		t.Fatalf("drone not carried: v %+v on ground %v", d.Velocity, d.OnGround)
+	}
+}
+
+func TestPrecisionLandOnMovingShip(t *testing.T) {
+	p := ship(2)
+	w := sim.NewWorld()
+	w.AddPlatform(p)
+	d := airborne(t)
+	d.World = w
+	if err := d.StartPrecisionLand(sim.PrecisionLandConfig{Platform: p}); err != nil {
+		t.Fatal(err)
+	}
+	var events []sim.Event
+	for i := 0; i < 90 && d.IsArmed; i++ {
+		events = append(events, stepWorld(d, w, 1)...)
+	}
+	st, ok := d.PrecisionLandStatus()
+	if !ok || st.Phase != sim.PrecLandLanded || d.IsArmed {
+		t.Fatalf("did not land: %+v", st)
+	}
+	if !hasEvent(events, "precland.acquired") || !hasEvent(events, "precland.landed") {
+		t.Fatalf("events %+v", events)
+	}
+	if st.TouchdownError > 0.4 || st.TouchdownSpeed > 1.0 || d.Destroyed {
+		t.Fatalf("touchdown %.2f m off the marker at %.2f m/s", st.TouchdownError, st.TouchdownSpeed)
+	}
+	for i, e := range d.Engines {
+		if e.Efficiency < 0.99 {
+			t.Fatalf("engine %d damaged on touchdown: %.2f", i, e.Efficiency)
+		}
+	}
+
+	// Landed drones ride the deck
+	stepWorld(d, w, 5)
+	if h, _, ok := p.Surface(d.Position.X, d.Position.Z); !ok || d.Position.Y-h > 0.1 {
+		t.Fatalf("slid off the deck: drone %+v deck %+v", d.Position, p.Position)
+	}
+}
+
+func TestPrecisionLandGoesAroundWhenMarkerLost(t *testing.T) {
+	p := ship(0)
+	w := sim.NewWorld()
+	w.AddPlatform(p)
+	d := airborne(t)
+	d.World = w
+	if err := d.StartPrecisionLand(sim.PrecisionLandConfig{Platform: p}); err != nil {
+		t.Fatal(err)
+	}
+	stepWorld(d, w, 14)
+	if st, _ := d.PrecisionLandStatus(); st.Phase != sim.PrecLandDescend || !st.Locked {
+		t.Fatalf("not descending on the marker: %+v", st)
+	}
+	// The camera goes blind: climb back to the approach height
+	d.Fiducial.Range = 0
+	events := stepWorld(d, w, 8)
+	st, _ := d.PrecisionLandStatus()
+	if !hasEvent(events, "precland.lost") || st.Phase != sim.PrecLandApproach || st.Locked {
+		t.Fatalf("no go-around: %+v", st)
+	}
+	if d.Position.Y-p.Position.Y < 6 || !d.IsArmed {
+		t.Fatalf("go-around height %.1f m above the deck", d.Position.Y-p.Position.Y)
+	}
+}
+
+func TestPrecisionLandAbortsWhenPlatformRemoved(t *testing.T) {
+	p := ship(0)
+	w := sim.NewWorld()
+	w.AddPlatform(p)
+	d := airborne(t)
+	d.World = w
+	if err := d.StartPrecisionLand(sim.PrecisionLandConfig{Platform: p}); err != nil {
+		t.Fatal(err)
+	}
+	stepWorld(d, w, 2)
+	w.RemovePlatform("ship")
+	events := stepWorld(d, w, 1)
+	if _, ok := d.PrecisionLandStatus(); ok || d.FlightMode != sim.FlightModeHover || !hasEvent(events, "precland.aborted") {
+		t.Fatalf("still landing on a removed platform: mode %v events %+v", d.FlightMode, events)
+	}
+}
@@ -0,0 +1,115 @@
+package sim_test
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"math"
+	"testing"
+
+	sim "drone-simulator/internal/sim"
+)
+
+func TestRenderDepthAndLayout(t *testing.T) {
+	w := sim.NewWorld()
+	down := sim.CameraView{Position: sim.Vec3{Y: 10}, Rotation: sim.Vec3{X: -90 * deg}, FOV: 60 * deg, Width: 160, Height: 120}
+	img := w.Render(down, nil)
+	// Depth is along the optical axis, so flat ground is 10 m everywhere
+	for _, p := range [][2]int{{80, 60}, {0, 0}, {159, 119}, {20, 100}} {
+		if z := img.DepthAt(p[0], p[1]); math.Abs(z-10) > 0.01 {
+			t.Fatalf("ground depth at %v = %.3f, want 10", p, z)
+		}
+	}
+
+	// A box under the camera is nearer than the ground round it
+	w.AddObstacle(sim.Obstacle{Name: "crate", Min: sim.Vec3{X: -1, Z: -1}, Max: sim.Vec3{X: 1, Y: 4, Z: 1}})
+	img = w.Render(down, nil)
+	if z := img.DepthAt(80, 60); math.Abs(z-6) > 0.01 {
+		t.Fatalf("depth to the crate top %.3f, want 6", z)
+	}
+	if z := img.DepthAt(5, 5); math.Abs(z-10) > 0.01 {
+		t.Fatalf("ground beside the crate at %.3f", z)
+	}
+
+	// Looking south, east is on the left and the sky has no depth; a
+	// drone in front hides what lies behind it
+	w = sim.NewWorld()
+	other := sim.NewDrone()
+	other.Position = sim.Vec3{X: 0, Y: 2, Z: 5}
+	other.Dimensions = sim.Vec3{X: 1, Y: 1, Z: 1}
+	w.Drones = append(w.Drones, other)
+	w.AddObstacle(sim.Obstacle{Name: "east", Min: sim.Vec3{X: 8, Z: 20}, Max: sim.Vec3{X: 12, Y: 10, Z: 24}})
+	ahead := sim.CameraView{Position: sim.Vec3{Y: 2}, FOV: 60 * deg, Width: 160, Height: 120}
+	img = w.Render(ahead, nil)
+	if z := img.DepthAt(80, 2); z != 0 {
+		t.Fatalf("sky at depth %.2f", z)
+	}
+	if z := img.DepthAt(80, 60); math.Abs(z-4.5) > 0.01 {
+		t.Fatalf("drone face at %.3f, want 4.5", z)
+	}
+	if c := img.RGB.RGBAAt(80, 60); c.R < 2*c.G {
+		t.Fatalf("drone pixel %v not red", c)
+	}
+	right, left := 0, 0
+	for y := 0; y < 120; y++ {
+		for x := 0; x < 160; x++ {
+			if z := img.DepthAt(x, y); z > 19.9 && z < 20.1 {
+				if x >= 80 {
+					right++
+				} else {
+					left++
+				}
+			}
+		}
+	}
+	if left == 0 || right != 0 {
+		t.Fatalf("east obstacle face: %d pixels right, %d left", right, left)
+	}
+
+	// The viewer's own airframe is skipped
+	if img := w.Render(sim.CameraView{Position: other.Position, FOV: 60 * deg, Width: 16, Height: 12}, other); img.DepthAt(8, 6) != 0 && img.DepthAt(8, 6) < 0.6 {
+		t.Fatalf("camera sees its own airframe at %.2f m", img.DepthAt(8, 6))
+	}
+}
+
+func TestImageCameraOnGimbal(t *testing.T) {
+	d := sim.NewDrone()
+	d.World = sim.NewWorld()
+	d.Position = sim.Vec3{Y: 20}
+	d.Gimbal.Mode = sim.GimbalLock
+	d.Gimbal.Command = sim.Vec3{X: -90 * deg}
+	cam := sim.DefaultImageCamera()
+	cam.Width, cam.Height, cam.Rate = 64, 48, 10
+	d.ImageCamera = &cam
+	for i := 0; i < int(2/fsDt); i++ {
+		d.Position.Y, d.Velocity = 20, sim.Vec3{}
+		d.Update(fsDt)
+	}
+	img := cam.Image()
+	if img == nil || img.Count < 19 || img.Count > 21 {
+		t.Fatalf("frames after 2 s at 10 Hz: %+v", img)
+	}
+	pos, rot := d.Gimbal.Pose(d)
+	if img.View.Position.Sub(pos).Length() > 0.5 || math.Abs(img.View.Rotation.X-rot.X) > 1*deg {
+		t.Fatalf("frame from %v %v, gimbal at %v %v", img.View.Position, img.View.Rotation, pos, rot)
+	}
+	if z := img.DepthAt(32, 24); math.Abs(z-pos.Y) > 0.05 {
+		t.Fatalf("looking down from %.2f m sees ground at %.2f m", pos.Y, z)
+	}
+
+	var buf bytes.Buffer
+	if err := img.WriteDepthPNG(&buf); err != nil {
+		t.Fatal(err)
+	}
+	dec, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, ok := dec.(*image.Gray16)
+	if !ok {
+		t.Fatalf("depth PNG decodes as %T", dec)
+	}
+	if mm := float64(g.Gray16At(32, 24).Y); math.Abs(mm-img.DepthAt(32, 24)*1000) > 1 {
+		t.Fatalf("depth PNG %v mm, image %.4f m", mm, img.DepthAt(32, 24))
+	}
+}
@@ -0,0 +1,149 @@
+package sim_test
+
+import (
+	"math"
+	"testing"
+
+	sim "drone-simulator/internal/sim"
+)
+
+func TestRaycastSurfaces(t *testing.T) {
+	w := sim.NewWorld()
+	down := sim.Vec3{Y: -1}
+	if h := w.Raycast(sim.Vec3{Y: 5}, down, 10, nil); h.Kind != sim.RayGround || math.Abs(h.Distance-5) > 1e-9 {
+		t.Fatalf("flat ground: %+v", h)
+	}
+	if h := w.Raycast(sim.Vec3{Y: 5}, down, 4, nil); h.Kind != sim.RayMiss {
+		t.Fatalf("ground beyond range: %+v", h)
+	}
+
+	// A 10 m slope rising east: 1 m per metre
+	w.SetTerrain(&sim.HeightMap{Spacing: 10, Cols: 3, Rows: 2, Heights: []float64{0, 10, 20, 0, 10, 20}})
+	if h := w.Raycast(sim.Vec3{X: 5, Y: 20, Z: 5}, down, 30, nil); h.Kind != sim.RayGround || math.Abs(h.Distance-15) > 0.01 {
+		t.Fatalf("terrain: %+v", h)
+	}
+	w.SetTerrain(nil)
+
+	w.AddObstacle(sim.Obstacle{Name: "wall", Min: sim.Vec3{X: -5, Z: 10}, Max: sim.Vec3{X: 5, Y: 10, Z: 11}})
+	if h := w.Raycast(sim.Vec3{Y: 2}, sim.Vec3{Z: 1}, 30, nil); h.Kind != sim.RayObstacle || h.Name != "wall" || math.Abs(h.Distance-10) > 1e-9 {
+		t.Fatalf("obstacle: %+v", h)
+	}
+
+	deck := sim.NewPlatform("deck", sim.NewScriptedTarget("deck", []sim.Vec3{{X: 20}, {X: 40}}, 2, false), 6, 4, 3)
+	w.AddPlatform(deck)
+	deck.Update(1)
+	if h := w.Raycast(sim.Vec3{X: deck.Position.X, Y: 10}, down, 20, nil); h.Kind != sim.RayPlatform || math.Abs(h.Distance-7) > 1e-6 || h.Velocity.X < 1.9 {
+		t.Fatalf("platform: %+v", h)
+	}
+
+	// Other drones are solid; the one casting is not
+	other := sim.NewDrone()
+	other.Position = sim.Vec3{X: -20, Y: 4}
+	w.Drones = []*sim.Drone{other}
+	if h := w.Raycast(sim.Vec3{X: -20, Y: 10}, down, 20, nil); h.Kind != sim.RayDrone || math.Abs(h.Distance-(6-other.Dimensions.Z/2)) > 1e-6 {
+		t.Fatalf("drone: %+v", h)
+	}
+	if h := w.Raycast(sim.Vec3{X: -20, Y: 10}, down, 20, other); h.Kind != sim.RayGround {
+		t.Fatalf("skipped drone: %+v", h)
+	}
+}
+
+func TestRangefinderAndFlowInFlight(t *testing.T) {
+	d := sim.NewDrone()
+	d.World = sim.NewWorld()
+	d.World.AddObstacle(sim.Obstacle{Name: "roof", Min: sim.Vec3{X: -5, Z: 40}, Max: sim.Vec3{X: 5, Y: 4, Z: 60}})
+	rf, flow := sim.DefaultRangefinder(), sim.DefaultOpticalFlow()
+	d.Rangefinder, d.Flow = &rf, &flow
+	step(d, 1)
+	if r := d.Rangefinder.Reading(); r.Count < 49 || r.Valid {
+		t.Fatalf("on the ground, inside the minimum range: %+v", r)
+	}
+	if res := d.Arm(); !res.Armed {
+		t.Fatalf("arm: %v", res.Reasons())
+	}
+	d.SetMission(sim.NewMission([]sim.MissionItem{
+		{Type: sim.MissionItemTakeoff, Position: sim.Vec3{Y: 8}},
+		{Type: sim.MissionItemWaypoint, Position: sim.Vec3{Y: 8, Z: 100}, Speed: 4},
+	}))
+	d.StartMission()
+	step(d, 12)
+
+	// Climbed out, cruising south at 4 m/s: the surface slides back under
+	// the image at v/h
+	r, f := d.Rangefinder.Reading(), d.Flow.Reading()
+	if !r.Valid || r.Target != sim.RayGround || math.Abs(r.Distance-d.Position.Y) > 0.3 {
+		t.Fatalf("range %+v at %.2f m", r, d.Position.Y)
+	}
+	if d.Position.Z < 10 || d.Position.Z > 35 || d.Velocity.Z < 3.5 {
+		t.Fatalf("not cruising clear of the roof: %+v, %+v", d.Position, d.Velocity)
+	}
+	want := -d.Velocity.Z / f.Distance
+	if !f.Valid || math.Abs(f.FlowY-f.BodyRate.X-want) > 0.15 || math.Abs(f.FlowX+f.BodyRate.Y) > 0.15 || f.Quality < 0.3 {
+		t.Fatalf("flow %+v, want y %.2f", f, want)
+	}
+
+	// Over the roof the beam stops 4 m short
+	for i := 0; i < int(10/fsDt) && d.Position.Z < 50; i++ {
+		d.Update(fsDt)
+	}
+	step(d, 0.1)
+	if r := d.Rangefinder.Reading(); r.Target != sim.RayObstacle || math.Abs(r.Distance-(d.Position.Y-4)) > 0.3 {
+		t.Fatalf("over the roof at %+v: %+v", d.Position, r)
+	}
+
+	// A bare floor gives nothing to track
+	d.Flow.Texture = 0.1
+	step(d, 0.2)
+	if f := d.Flow.Reading(); f.Valid || f.Quality >= 0.2 {
+		t.Fatalf("untextured floor: %+v", f)
+	}
+}
+
+func TestLidarScans(t *testing.T) {
+	d := sim.NewDrone()
+	d.World = sim.NewWorld()
+	d.World.AddObstacle(sim.Obstacle{Name: "north", Min: sim.Vec3{X: -20, Z: 10}, Max: sim.Vec3{X: 20, Y: 10, Z: 12}})
+	other := sim.NewDrone()
+	other.Position = sim.Vec3{X: -5, Y: d.Position.Y + 0.1}
+	d.World.Drones = []*sim.Drone{d, other}
+
+	// A level 2D scan: the wall dead ahead, the other drone to the west, and
+	// nothing elsewhere
+	lidar := sim.DefaultLidar()
+	lidar.Mount.Offset = sim.Vec3{Y: 0.1}
+	d.Lidar = &lidar
+	step(d, 0.51)
+	s := d.Lidar.Scan()
+	if s.Count != 5 || len(s.Ranges) != 360 {
+		t.Fatalf("%d scans of %d ranges", s.Count, len(s.Ranges))
+	}
+	if r := s.Range(0, 180); math.Abs(r-10) > 0.1 {
+		t.Fatalf("ahead %.2f m, want the wall at 10", r)
+	}
+	if r := s.Range(0, 90); math.Abs(r-5) > 0.2 {
+		t.Fatalf("west %.2f m, want the other drone at 5", r)
+	}
+	if r := s.Range(0, 0); r != 0 {
+		t.Fatalf("behind %.2f m, want no return", r)
+	}
+	for _, p := range s.Points {
+		if math.Abs(p.Y) > 1e-9 {
+			t.Fatalf("2D scan point off the plane: %+v", p)
+		}
+	}
+
+	// A 3D scanner on a mast, pitched down 20°: its lowest channel, 35°
+	// below the horizon, meets the ground ahead
+	l3 := sim.DefaultLidar3D()
+	l3.Mount = sim.SensorMount{Offset: sim.Vec3{Y: 2}, Rotation: sim.Vec3{X: -20 * math.Pi / 180}}
+	d.Lidar = &l3
+	step(d, 0.2)
+	s = d.Lidar.Scan()
+	if s.Channels != 16 || len(s.Ranges) != 16*720 {
+		t.Fatalf("3D scan %d×%d", s.Channels, s.Samples)
+	}
+	want := s.Origin.Y / math.Sin(35*math.Pi/180)
+	if r := s.Range(0, 360); math.Abs(r-want) > 0.1 {
+		t.Fatalf("lowest channel ahead %.2f m, want the ground at %.2f", r, want)
+	}
+}
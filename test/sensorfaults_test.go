@@ -0,0 +1,179 @@
+package sim_test
+
+import (
+	"math"
+	"testing"
+
+	sim "drone-simulator/internal/sim"
+)
+
+// faultTwins returns two identical drones on the ground; faults drawn on one
+// leave its sensors' own noise untouched, so the other is the clean output.
+func faultTwins() (*sim.Drone, *sim.Drone) {
+	a, b := sim.NewDrone(), sim.NewDrone()
+	a.World, b.World = sim.NewWorld(), sim.NewWorld()
+	return a, b
+}
+
+func stepTwins(a, b *sim.Drone, seconds float64, each func()) {
+	for i := 0; i < int(seconds/fsDt+0.5); i++ {
+		a.Update(fsDt)
+		b.Update(fsDt)
+		if each != nil {
+			each()
+		}
+	}
+}
+
+func TestSensorFaultsCorruptOutput(t *testing.T) {
+	d, clean := faultTwins()
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	must(d.InjectFault("gnss", sim.SensorFault{Kind: sim.FaultBias, Start: 2, End: 4, Magnitude: 5, Axis: 1}))
+	must(d.InjectFault("baro", sim.SensorFault{Kind: sim.FaultDrift, Start: 2, Magnitude: 10}))
+	must(d.InjectFault("mag", sim.SensorFault{Kind: sim.FaultStuck, Start: 2, End: 4}))
+	must(d.InjectFault("imu", sim.SensorFault{Kind: sim.FaultDropout, Start: 2, End: 2.5}))
+	stepTwins(d, clean, 3, nil)
+
+	g, cg := d.GNSS.Reading(), clean.GNSS.Reading()
+	if off := g.Position.Sub(cg.Position); math.Abs(off.X-5) > 1e-9 || math.Abs(off.Z) > 1e-9 || g.Velocity != cg.Velocity {
+		t.Fatalf("GNSS bias: position off by %+v", off)
+	}
+	b, cb := d.Baro.Sample(), clean.Baro.Sample()
+	if want := 10 * (b.Time - 2); math.Abs(b.Pressure-cb.Pressure-want) > 1e-6 || b.Altitude >= cb.Altitude {
+		t.Fatalf("baro drift %.2f Pa, want %.2f", b.Pressure-cb.Pressure, want)
+	}
+	if n := d.IMU.Sample().Count; math.Abs(float64(clean.IMU.Sample().Count-n-200)) > 2 {
+		t.Fatalf("IMU output %d samples through a 0.5 s dropout, clean %d", n, clean.IMU.Sample().Count)
+	}
+	if got := len(d.ActiveFaults()); got != 3 {
+		t.Fatalf("%d faults active at 3 s: %+v", got, d.ActiveFaults())
+	}
+
+	stuck := d.Mag.Sample()
+	stepTwins(d, clean, 0.5, nil)
+	if m := d.Mag.Sample(); m.Field != stuck.Field || m.Count <= stuck.Count || m.Time <= stuck.Time {
+		t.Fatalf("stuck magnetometer moved: %+v after %+v", m, stuck)
+	}
+	stepTwins(d, clean, 1, nil)
+	if d.Mag.Sample().Field != clean.Mag.Sample().Field || d.GNSS.Reading().Position != clean.GNSS.Reading().Position {
+		t.Fatal("sensors not restored when their faults ended")
+	}
+	if d.ClearFaults(""); len(d.ActiveFaults()) != 0 {
+		t.Fatalf("faults left after clearing: %+v", d.ActiveFaults())
+	}
+}
+
+func TestSensorFaultSpikesNoiseAndDelay(t *testing.T) {
+	d, clean := faultTwins()
+	d.InjectFault("baro", sim.SensorFault{Kind: sim.FaultSpikes, Magnitude: 500, Rate: 2})
+	d.InjectFault("mag", sim.SensorFault{Kind: sim.FaultNoise, Magnitude: 2, Axis: 2})
+	d.InjectFault("gnss", sim.SensorFault{Kind: sim.FaultDelay, Start: 5, Magnitude: 1})
+
+	spikes, last := 0, 0
+	var sum, sumSq float64
+	n := 0
+	fixes := map[int64]sim.Vec3{} // clean fixes by the ms they were taken
+	stepTwins(d, clean, 20, func() {
+		if b := d.Baro.Sample(); b.Count != last {
+			last = b.Count
+			if math.Abs(b.Pressure-clean.Baro.Sample().Pressure) > 250 {
+				spikes++
+			}
+			e := d.Mag.Sample().Field.Y - clean.Mag.Sample().Field.Y
+			sum, sumSq, n = sum+e, sumSq+e*e, n+1
+		}
+		r := clean.GNSS.Reading()
+		fixes[int64(math.Round(r.Time*1000))] = r.Position
+	})
+	// 2 per second for 20 s
+	if spikes < 25 || spikes > 55 {
+		t.Fatalf("%d baro spikes in 20 s", spikes)
+	}
+	mean := sum / float64(n)
+	if sd := math.Sqrt(sumSq/float64(n) - mean*mean); math.Abs(sd-2) > 0.3 || d.Mag.Sample().Field.X != clean.Mag.Sample().Field.X {
+		t.Fatalf("magnetometer noise σ %.2f on Y, X %v vs %v", sd, d.Mag.Sample().Field.X, clean.Mag.Sample().Field.X)
+	}
+
+	// The delayed fix is a second old but stamped as the newest
+	g, cg := d.GNSS.Reading(), clean.GNSS.Reading()
+	if math.Abs(g.Time-cg.Time) > 0.21 {
+		t.Fatalf("delayed fix stamped %.2f s, clean %.2f s", g.Time, cg.Time)
+	}
+	old, ok := fixes[int64(math.Round((g.Time-1)*1000))]
+	if !ok || old != g.Position {
+		t.Fatalf("delayed fix %+v is not the one taken a second before %.2f s (%+v)", g.Position, g.Time, old)
+	}
+}
+
+func TestScenarioSchedulesFaults(t *testing.T) {
+	sc, err := sim.ParseScenario([]byte(`{"faults": [
+		{"drone": 1, "sensor": "gnss", "type": "dropout", "start": 1, "duration": 2},
+		{"drone": 0, "sensor": "BARO", "type": "bias", "start": 0.5, "magnitude": -40}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := faultTwins()
+	if err := sc.Apply([]*sim.Drone{a, b}); err != nil {
+		t.Fatal(err)
+	}
+	step(a, 1.5)
+	step(b, 1.5)
+	fa, fb := a.ActiveFaults(), b.ActiveFaults()
+	if len(fa) != 1 || fa[0].Sensor != "baro" || fa[0].Kind != sim.FaultBias || len(fb) != 1 || fb[0].End != 3 {
+		t.Fatalf("active faults %+v / %+v", fa, fb)
+	}
+	count := b.GNSS.Reading().Count
+	step(b, 1)
+	if b.GNSS.Reading().Count != count {
+		t.Fatal("GNSS output during a dropout")
+	}
+	step(b, 1)
+	if b.GNSS.Reading().Count == count || len(b.ActiveFaults()) != 0 {
+		t.Fatal("GNSS did not come back after the dropout")
+	}
+
+	for _, doc := range []string{
+		`{"faults": [{"sensor": "gnss", "type": "melt"}]}`,
+		`{"faults": [{"sensor": "gnss", "type": "bias", "start": 5, "end": 2}]}`,
+	} {
+		if _, err := sim.ParseScenario([]byte(doc)); err == nil {
+			t.Errorf("accepted %s", doc)
+		}
+	}
+	for _, doc := range []string{
+		`{"faults": [{"drone": 2, "sensor": "gnss", "type": "bias"}]}`,
+		`{"faults": [{"sensor": "rangefinder", "type": "bias"}]}`,
+		`{"faults": [{"sensor": "sonar", "type": "bias"}]}`,
+	} {
+		sc, err := sim.ParseScenario([]byte(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sc.Apply([]*sim.Drone{a, b}); err == nil {
+			t.Errorf("applied %s", doc)
+		}
+	}
+}
+
+func TestEKFRejectsGNSSJump(t *testing.T) {
+	d := sim.NewDrone()
+	d.World = sim.NewWorld()
+	step(d, 10)
+	before := d.EKF.Estimate().GNSS
+
+	d.InjectFault("gnss", sim.SensorFault{Kind: sim.FaultBias, Start: d.Clock(), Magnitude: 40, Axis: 1})
+	step(d, 3)
+	est := d.EKF.Estimate()
+	if est.GNSS.Rejected-before.Rejected < 10 {
+		t.Fatalf("GNSS %+v after a 40 m jump, %+v before", est.GNSS, before)
+	}
+	if e := d.EKFError(); horizontalLen(e.Position) > 3 {
+		t.Fatalf("estimate dragged %+v by the jump", e.Position)
+	}
+}
@@ -0,0 +1,130 @@
+package sim_test
+
+import (
+	"math"
+	"testing"
+
+	sim "drone-simulator/internal/sim"
+)
+
+// flySticks sends s at hz with the given latency for seconds of sim time.
+func flySticks(d *sim.Drone, s sim.Sticks, hz, latency, seconds float64) []sim.Event {
+	var events []sim.Event
+	every := int(math.Round(1 / (hz * fsDt)))
+	for i := 0; i < int(seconds/fsDt); i++ {
+		if i%every == 0 {
+			d.SetSticks(s, latency)
+		}
+		d.Update(fsDt)
+		events = append(events, d.TakeEvents()...)
+	}
+	return events
+}
+
+// stickDrone returns an airborne drone in mode with centred sticks engaged.
+func stickDrone(t *testing.T, mode sim.FlightMode) *sim.Drone {
+	t.Helper()
+	d := airborne(t)
+	d.SetFlightMode(mode)
+	flySticks(d, sim.Sticks{Throttle: 0.5}, 50, 0, 1)
+	return d
+}
+
+func TestSticksAngleModeRollAndYaw(t *testing.T) {
+	d := stickDrone(t, sim.FlightModeAltitudeHold)
+	y, yaw := d.Position.Y, d.Rotation.Y
+	flySticks(d, sim.Sticks{Throttle: 0.5, Roll: 0.5, Yaw: 0.5}, 50, 0, 1)
+	// Roll right tilts toward body -X, the drone's right; clockwise yaw
+	// increases it
+	if d.Rotation.Z > -0.1 {
+		t.Fatalf("roll stick gave roll %.3f", d.Rotation.Z)
+	}
+	if turned := angleBetween(d.Rotation.Y, yaw); turned < 0.5 {
+		t.Fatalf("yaw stick turned %.2f rad, want clockwise", turned)
+	}
+	if math.Abs(d.Position.Y-y) > 0.5 {
+		t.Fatalf("mid throttle changed altitude %.2f -> %.2f", y, d.Position.Y)
+	}
+
+	// Throttle above mid-stick climbs in AltitudeHold
+	flySticks(d, sim.Sticks{Throttle: 1}, 50, 0, 2)
+	if d.Position.Y < y+3 {
+		t.Fatalf("full throttle climbed to %.2f from %.2f", d.Position.Y, y)
+	}
+}
+
+func angleBetween(a, b float64) float64 {
+	return math.Remainder(a-b, 2*math.Pi)
+}
+
+func TestSticksHoverFliesVelocityAndHolds(t *testing.T) {
+	d := stickDrone(t, sim.FlightModeHover)
+	start, yaw := d.Position, d.Rotation.Y
+	flySticks(d, sim.Sticks{Throttle: 0.5, Pitch: 1}, 50, 0, 4)
+	// Forward is (-sin(yaw), cos(yaw))
+	fwd := -math.Sin(yaw)*d.Velocity.X + math.Cos(yaw)*d.Velocity.Z
+	if fwd < 5 {
+		t.Fatalf("full forward stick gave %.2f m/s", fwd)
+	}
+	flySticks(d, sim.Sticks{Throttle: 0.5}, 50, 0, 6)
+	stopped := d.Position
+	flySticks(d, sim.Sticks{Throttle: 0.5}, 50, 0, 3)
+	if drift := d.Position.Sub(stopped).Length(); drift > 0.3 || math.Hypot(d.Velocity.X, d.Velocity.Z) > 0.2 {
+		t.Fatalf("centred sticks did not hold: drift %.2f m, v %+v", drift, d.Velocity)
+	}
+	if d.Position.Sub(start).Length() < 15 || math.Abs(d.Position.Y-start.Y) > 0.5 {
+		t.Fatalf("hover flight went from %+v to %+v", start, d.Position)
+	}
+
+	// Right is (-cos(yaw), -sin(yaw))
+	flySticks(d, sim.Sticks{Throttle: 0.5, Roll: 1}, 50, 0, 3)
+	if right := -math.Cos(yaw)*d.Velocity.X - math.Sin(yaw)*d.Velocity.Z; right < 4 {
+		t.Fatalf("full right stick gave %.2f m/s to the right", right)
+	}
+}
+
+func TestSticksDeadmanTripsInputLoss(t *testing.T) {
+	d := stickDrone(t, sim.FlightModeAltitudeHold)
+	flySticks(d, sim.Sticks{Throttle: 0.5, Roll: 0.6}, 50, 0, 0.5)
+
+	// Samples stop: the deadman centres the sticks and the failsafe holds
+	events := step(d, d.Failsafe.InputTimeout+0.2)
+	if !hasEvent(events, "failsafe.trigger") || d.Failsafe.Active() != sim.FailsafeInputLoss || d.ActiveAction() != sim.ActionHold {
+		t.Fatalf("input loss: active %v action %v", d.Failsafe.Active(), d.ActiveAction())
+	}
+	if s, _ := d.Sticks(); s.Roll != 0 || !d.StickStats().Stale {
+		t.Fatalf("stale sticks not centred: %+v", s)
+	}
+	step(d, 6)
+	if v := math.Hypot(d.Velocity.X, d.Velocity.Z); v > 0.3 {
+		t.Fatalf("still moving at %.2f m/s under the input-loss hold", v)
+	}
+
+	// Samples resume: the failsafe clears and the sticks fly again
+	events = flySticks(d, sim.Sticks{Throttle: 0.5}, 50, 0, 1)
+	if !hasEvent(events, "failsafe.clear") || d.ActiveAction() != sim.ActionNone {
+		t.Fatalf("input loss did not clear: action %v", d.ActiveAction())
+	}
+
+	// Released sticks are not watched
+	d.ReleaseSticks()
+	step(d, 2)
+	if d.Failsafe.Triggered(sim.FailsafeInputLoss) {
+		t.Fatal("released sticks tripped the deadman")
+	}
+}
+
+func TestStickStats(t *testing.T) {
+	d := stickDrone(t, sim.FlightModeHover)
+	flySticks(d, sim.Sticks{Throttle: 0.5}, 40, 0.03, 3)
+	st := d.StickStats()
+	if math.Abs(st.RateHz-40) > 2 {
+		t.Fatalf("rate %.1f Hz, want 40", st.RateHz)
+	}
+	if math.Abs(st.Latency-0.03) > 0.005 || st.MaxLatency < 0.03 {
+		t.Fatalf("latency %.3f max %.3f", st.Latency, st.MaxLatency)
+	}
+	if st.Messages < 150 || st.Age > 0.03 || st.Stale {
+		t.Fatalf("stats %+v", st)
+	}
+}
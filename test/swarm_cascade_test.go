@@ -15,7 +15,11 @@ func TestSwarmFollowerLowOscillationDuringTranslation(t *testing.T) {
     drones := make([]*sim.Drone, 0, n)
     for i := 0; i < n; i++ {
         d := sim.NewDrone()
-        // Start a little above ground to avoid takeoff transients
+        // Arm on the ground (pre-arm checks refuse airborne drones), then
+        // start a little above it to avoid takeoff transients
+        if err := d.Arm().Err(); err != nil {
+            t.Fatalf("drone %d: %v", i, err)
+        }
         d.Position.Y = 1.5
         drones = append(drones, d)
     }
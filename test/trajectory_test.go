@@ -0,0 +1,112 @@
+package sim_test
+
+import (
+	sim "drone-simulator/internal/sim"
+	"math"
+	"testing"
+)
+
+var trajPoints = []sim.Vec3{{Y: 10}, {X: 20, Y: 10, Z: 10}, {X: 20, Y: 14, Z: 30}, {X: 0, Y: 10, Z: 40}}
+
+func TestTrajectoryPassesWaypointsAtRest(t *testing.T) {
+	for _, order := range []sim.TrajectoryOrder{sim.TrajectoryMinSnap, sim.TrajectoryMinJerk} {
+		tr, err := sim.NewTrajectory(trajPoints, order, sim.TrajectoryLimits{MaxSpeed: 6, MaxAccel: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		at := 0.0
+		for i, p := range trajPoints {
+			if got := tr.Sample(at).Position; got.Sub(p).Length() > 1e-6 {
+				t.Fatalf("%v: waypoint %d at t=%.2f: got %+v want %+v", order, i, at, got, p)
+			}
+			if i < len(tr.Times) {
+				at += tr.Times[i]
+			}
+		}
+		for _, s := range []float64{0, tr.Duration()} {
+			ref := tr.Sample(s)
+			if ref.Velocity.Length() > 1e-6 || ref.Acceleration.Length() > 1e-6 {
+				t.Fatalf("%v: not at rest at t=%.2f: %+v", order, s, ref)
+			}
+		}
+	}
+}
+
+func TestTrajectoryRespectsLimitsAndIsSmooth(t *testing.T) {
+	lim := sim.TrajectoryLimits{MaxSpeed: 5, MaxAccel: 1.5}
+	tr, err := sim.NewTrajectory(trajPoints, sim.TrajectoryMinSnap, lim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const h = 1e-3
+	vPeak, aPeak := 0.0, 0.0
+	prev := tr.Sample(0)
+	for s := h; s <= tr.Duration(); s += h {
+		ref := tr.Sample(s)
+		vPeak = math.Max(vPeak, ref.Velocity.Length())
+		aPeak = math.Max(aPeak, ref.Acceleration.Length())
+		// Feed-forward terms are the derivatives of the reference
+		if dv := ref.Position.Sub(prev.Position).Mul(1 / h).Sub(ref.Velocity).Length(); dv > 0.05 {
+			t.Fatalf("velocity inconsistent at t=%.3f: %.3f", s, dv)
+		}
+		if da := ref.Velocity.Sub(prev.Velocity).Mul(1 / h).Sub(ref.Acceleration).Length(); da > 0.05 {
+			t.Fatalf("acceleration discontinuous at t=%.3f: %.3f", s, da)
+		}
+		prev = ref
+	}
+	if vPeak > lim.MaxSpeed*1.02 || aPeak > lim.MaxAccel*1.02 {
+		t.Fatalf("limits exceeded: v=%.2f a=%.2f", vPeak, aPeak)
+	}
+	if vPeak < lim.MaxSpeed*0.9 && aPeak < lim.MaxAccel*0.9 {
+		t.Fatalf("time allocation too conservative: v=%.2f a=%.2f", vPeak, aPeak)
+	}
+}
+
+func TestTrajectoryRejectsDegenerate(t *testing.T) {
+	if _, err := sim.NewTrajectory([]sim.Vec3{{Y: 1}, {Y: 1}}, sim.TrajectoryMinSnap, sim.TrajectoryLimits{}); err == nil {
+		t.Fatalf("expected error for coincident waypoints")
+	}
+}
+
+func TestMissionTrajectoryTracking(t *testing.T) {
+	d := sim.NewDrone()
+	d.Arm()
+	path := trajPoints[1 : len(trajPoints)-1]
+	end := trajPoints[len(trajPoints)-1]
+	d.SetMission(sim.NewMission([]sim.MissionItem{
+		{Type: sim.MissionItemTakeoff, Position: sim.Vec3{Y: 10}},
+		{Type: sim.MissionItemTrajectory, Path: path, Position: end, Speed: 4},
+	}))
+	d.StartMission()
+	for i := 0; i < int(20/fsDt) && d.Mission.Current == 0; i++ {
+		d.Update(fsDt)
+	}
+	if d.Mission.Current != 1 {
+		t.Fatalf("takeoff did not complete")
+	}
+	// The trajectory item starts from wherever the drone is
+	tr, err := sim.NewTrajectory(append(append([]sim.Vec3{d.Position}, path...), end), sim.TrajectoryMinSnap, sim.TrajectoryLimits{MaxSpeed: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	maxErr := 0.0
+	for s := fsDt; s < tr.Duration(); s += fsDt {
+		d.Update(fsDt)
+		if s < 2*fsDt {
+			if eta := d.MissionProgress().ETA; math.Abs(eta-tr.Duration()) > 0.1 {
+				t.Fatalf("ETA %.1f, trajectory %.1f s", eta, tr.Duration())
+			}
+		}
+		// Skip the hand-over from the takeoff climb
+		if s > 4 {
+			maxErr = math.Max(maxErr, tr.Sample(s).Position.Sub(d.Position).Length())
+		}
+	}
+	step(d, 3)
+	if d.Mission.State != sim.MissionComplete {
+		t.Fatalf("mission not complete: %+v", d.MissionProgress())
+	}
+	if maxErr > 0.3 {
+		t.Fatalf("tracking error %.2f m", maxErr)
+	}
+}